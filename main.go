@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/simonkoeck/g2/pkg/audit"
+	"github.com/simonkoeck/g2/pkg/events"
 	"github.com/simonkoeck/g2/pkg/exitcode"
 	"github.com/simonkoeck/g2/pkg/git"
 	"github.com/simonkoeck/g2/pkg/logging"
 	"github.com/simonkoeck/g2/pkg/output"
+	"github.com/simonkoeck/g2/pkg/rpc"
 	"github.com/simonkoeck/g2/pkg/semantic"
 	"github.com/simonkoeck/g2/pkg/tui"
 	"github.com/simonkoeck/g2/pkg/ui"
@@ -50,9 +57,17 @@ var gitExec git.Executor = git.NewDefaultExecutor()
 func main() {
 	args := os.Args[1:]
 
+	// Split off git's global options (-C, -c, --git-dir, ...) so we dispatch
+	// on the real subcommand. Anything we can't parse is left for git to report.
+	globalOpts, rest, err := parseGitGlobalOptions(args)
+	if err != nil {
+		passthrough("git", args...)
+		return
+	}
+
 	// Handle global flags first
-	if len(args) > 0 {
-		switch args[0] {
+	if len(rest) > 0 {
+		switch rest[0] {
 		case "--help", "-h", "help":
 			printHelp()
 			return
@@ -62,26 +77,38 @@ func main() {
 		}
 	}
 
+	// Commands we don't handle go straight to git with the original arguments
+	if !isG2Command(rest) {
+		passthrough("git", args...)
+		return
+	}
+
+	if err := applyGitGlobalOptions(globalOpts); err != nil {
+		// Let git produce its usual error for a bad -C directory
+		passthrough("git", args...)
+		return
+	}
+
 	// If no args, check if we're in the middle of an operation
-	if len(args) == 0 {
+	if len(rest) == 0 {
 		if op := detectInProgressOperation(); op != nil {
 			os.Exit(handleInProgressOperation(*op))
 		}
-		passthrough("git", args...)
+		passthrough("git", globalOpts.Args...)
 		return
 	}
 
 	// Route to appropriate handler
-	switch args[0] {
+	switch rest[0] {
 	case "merge":
-		os.Exit(smartMerge(args))
+		os.Exit(smartMerge(rest))
 	case "rebase":
-		os.Exit(smartRebase(args))
+		os.Exit(smartRebase(rest))
 	case "cherry-pick":
-		os.Exit(smartCherryPick(args))
+		os.Exit(smartCherryPick(rest))
 	case "merge-driver":
 		// Git merge driver mode - called by Git for individual files
-		os.Exit(mergeDriver(args[1:]))
+		os.Exit(mergeDriver(rest[1:]))
 	case "continue":
 		// g2 continue - continue any in-progress operation
 		os.Exit(continueOperation())
@@ -91,16 +118,151 @@ func main() {
 	case "status":
 		// g2 status - show current operation status
 		os.Exit(showStatus())
-	default:
-		passthrough("git", args...)
+	case "cache":
+		// g2 cache clear - manage the parse cache
+		os.Exit(cacheCommand(rest[1:]))
+	case "log":
+		// g2 log --g2 - show the resolutions of past runs; git log otherwise
+		opts, ok := parseLogArgs(rest[1:])
+		if !ok {
+			passthrough("git", args...)
+			return
+		}
+		os.Exit(logCommand(opts))
+	case "rpc", "lsp":
+		// g2 rpc - serve conflicts to editors over JSON-RPC on stdio
+		os.Exit(rpcCommand(rest[1:]))
+	}
+}
+
+// isG2Command reports whether g2 handles the subcommand itself rather than
+// passing it through to git. An empty command checks for in-progress operations.
+func isG2Command(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	switch args[0] {
+	case "merge", "rebase", "cherry-pick", "merge-driver", "continue", "abort", "status", "cache", "log", "rpc", "lsp":
+		return true
+	}
+	return false
+}
+
+// gitGlobalOptions holds git options given before the subcommand,
+// e.g. `g2 -C ../svc -c merge.conflictStyle=diff3 merge feature`.
+type gitGlobalOptions struct {
+	Dir  string   // Working directory from -C (empty = unchanged)
+	Args []string // Options forwarded to every git invocation
+}
+
+// Global options applied to the current run (set by applyGitGlobalOptions)
+var gitGlobalOpts gitGlobalOptions
+
+// parseGitGlobalOptions splits git's global options from the front of args.
+// It returns the parsed options and the remaining args, starting at the subcommand.
+// -C directories are combined the way git does (each relative to the previous one).
+func parseGitGlobalOptions(args []string) (gitGlobalOptions, []string, error) {
+	var opts gitGlobalOptions
+
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+
+		// Options that take a value, either as "--opt=value" or "--opt value"
+		if name, value, ok := splitGitValueOption(arg); ok {
+			if value == nil {
+				if i+1 >= len(args) {
+					return opts, nil, fmt.Errorf("option '%s' requires a value", name)
+				}
+				i++
+				value = &args[i]
+			}
+			switch name {
+			case "-C":
+				if *value != "" {
+					if filepath.IsAbs(*value) || opts.Dir == "" {
+						opts.Dir = *value
+					} else {
+						opts.Dir = filepath.Join(opts.Dir, *value)
+					}
+				}
+			case "-c":
+				opts.Args = append(opts.Args, "-c", *value)
+			default:
+				opts.Args = append(opts.Args, name+"="+*value)
+			}
+			continue
+		}
+
+		switch arg {
+		case "-p", "--paginate", "-P", "--no-pager",
+			"--bare", "--no-replace-objects", "--no-lazy-fetch",
+			"--no-optional-locks", "--no-advice",
+			"--literal-pathspecs", "--glob-pathspecs",
+			"--noglob-pathspecs", "--icase-pathspecs":
+			opts.Args = append(opts.Args, arg)
+			continue
+		}
+
+		// First non-option (or an option we don't know) is the subcommand
+		break
+	}
+
+	return opts, args[i:], nil
+}
+
+// splitGitValueOption recognizes git global options that take a value.
+// It returns the option name and its inline value (nil if the value is the next arg).
+func splitGitValueOption(arg string) (string, *string, bool) {
+	switch arg {
+	case "-C", "-c", "--git-dir", "--work-tree", "--namespace", "--config-env":
+		return arg, nil, true
+	}
+	for _, name := range []string{"--git-dir", "--work-tree", "--namespace", "--config-env", "--exec-path"} {
+		if strings.HasPrefix(arg, name+"=") {
+			value := strings.TrimPrefix(arg, name+"=")
+			return name, &value, true
+		}
+	}
+	return "", nil, false
+}
+
+// applyGitGlobalOptions changes into the -C directory and installs a git
+// executor that forwards the remaining global options to every git call.
+func applyGitGlobalOptions(opts gitGlobalOptions) error {
+	if opts.Dir != "" {
+		dir, err := filepath.Abs(opts.Dir)
+		if err != nil {
+			return err
+		}
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+		opts.Dir = dir
 	}
+
+	gitGlobalOpts = opts
+	gitExec = newGitExecutor(git.DefaultTimeout)
+	semantic.SetGitExecutor(gitExec)
+	return nil
+}
+
+// newGitExecutor creates a git executor with the current global options applied
+func newGitExecutor(timeout time.Duration) *git.DefaultExecutor {
+	executor := git.NewExecutorWithTimeout(timeout)
+	executor.Dir = gitGlobalOpts.Dir
+	executor.GlobalArgs = gitGlobalOpts.Args
+	return executor
 }
 
 func printHelp() {
 	help := `G2 - Smart Git with Semantic Conflict Resolution
 
 USAGE:
-    g2 <command> [options] [args]
+    g2 [git-options] <command> [options] [args]
+
+    Git global options before the command (-C <path>, -c <name>=<value>,
+    --git-dir, --work-tree, --no-pager, ...) are applied to every git call.
 
 COMMANDS:
     merge <branch>       Merge a branch with semantic conflict resolution
@@ -110,6 +272,11 @@ COMMANDS:
     continue             Continue an in-progress operation after resolving conflicts
     abort                Abort an in-progress operation
     status               Show current operation status
+    cache clear          Remove cached parse results from .git/g2/cache
+    log --g2             Show how past g2 runs resolved conflicts (--json, -n N,
+                         --file=PATH); without --g2 this is git log
+    rpc, lsp             Serve the conflicts in progress to editors over JSON-RPC
+                         on stdio (language server diagnostics and code actions)
     help                 Show this help message
     version              Show version information
 
@@ -118,10 +285,28 @@ COMMANDS:
 OPTIONS:
     --dry-run            Show what would be done without making changes
     --json               Output results as JSON
+    --format=FORMAT      Output results as json, sarif or junit
+    --report=FILE        Write a merge report (FILE.md or FILE.html)
+    --explain            Explain how each conflict was classified
+    --events=ndjson      Stream progress events as JSON lines (stdout or --events-fd)
+    --events-fd=N        Write events to file descriptor N instead of stdout
+    --commands=ndjson    Read resolution commands as JSON lines on stdin
+    --interactive=MODE   Resolve conflicts in the full-screen tui (default) or
+                         with a line-based prompt
+    --resolve FILE:NAME=RESOLUTION
+                         Resolve a definition's conflict without the TUI (local,
+                         remote, both, base, skip or custom:@BODY_FILE;
+                         repeatable)
+    --resolutions FILE   Resolve conflicts as listed in a YAML file
+    --prefer=SIDE        Keep the local or remote side of conflicts that still
+                         need resolution after all safe auto-merges
+    --prefer-kind=KIND:SIDE
+                         Like --prefer for definitions of one kind (repeatable)
     --verbose, -v        Show detailed progress
     --no-backup          Don't create .orig backup files
     --log-level=LEVEL    Set log level (debug, info, warn, error)
     --timeout=DURATION   Set git command timeout (e.g., 30s, 1m)
+    --jobs=N             Analyze and synthesize N files in parallel (default: CPU count)
 
 EXAMPLES:
     g2 merge feature-branch
@@ -129,6 +314,13 @@ EXAMPLES:
     g2 cherry-pick abc123
     g2 merge --dry-run feature-branch
     g2 merge --json feature-branch | jq .
+    g2 merge --dry-run --format=sarif main > g2.sarif
+    g2 merge --dry-run --explain feature-branch
+    g2 merge --events=ndjson --commands=ndjson feature-branch
+    g2 merge --resolve 'utils.py:validate_email=remote' feature-branch
+    g2 merge --prefer=local --prefer-kind=key:remote feature-branch
+    g2 merge --interactive=prompt feature-branch
+    g2 -C ../service merge feature-branch
 
 GIT MERGE DRIVER SETUP:
     # Add to ~/.gitconfig:
@@ -232,6 +424,8 @@ func continueOperation() int {
 
 	ctx := context.Background()
 	config := parseGlobalConfig([]string{})
+	enableParseCache(ctx)
+	enableRepoConfig(ctx, false)
 
 	// First, try to resolve any remaining conflicts
 	conflictingFiles, _ := semantic.GetConflictingFiles()
@@ -245,15 +439,23 @@ func continueOperation() int {
 		}
 	}
 
-	// Now continue the operation
+	// Now continue the operation, recording g2's resolutions as trailers
+	trailers := operationTrailers(ctx, op.Type)
 	var args []string
 	switch op.Type {
 	case OpMerge:
 		// For merge, just commit if all conflicts are resolved
 		args = []string{"commit", "--no-edit"}
+		for _, trailer := range trailers {
+			args = append(args, "--trailer", trailer)
+		}
 	case OpRebase:
+		// The merge backend commits with rebase-merge/message; the apply
+		// backend has no message to add to
+		addMessageTrailers(ctx, filepath.Join(op.GitDir, "rebase-merge", "message"), trailers)
 		args = []string{"rebase", "--continue"}
 	case OpCherryPick:
+		addMessageTrailers(ctx, filepath.Join(op.GitDir, "MERGE_MSG"), trailers)
 		args = []string{"cherry-pick", "--continue"}
 	}
 
@@ -353,7 +555,8 @@ func passthrough(cmd string, args ...string) {
 // parseGlobalConfig parses g2-specific flags from args
 func parseGlobalConfig(args []string) semantic.MergeConfig {
 	config := semantic.DefaultMergeConfig()
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch {
 		case arg == "--dry-run":
 			config.DryRun = true
@@ -363,28 +566,95 @@ func parseGlobalConfig(args []string) semantic.MergeConfig {
 			config.CreateBackup = false
 		case arg == "--json":
 			config.JSONOutput = true
+			if config.Format == "" {
+				config.Format = output.FormatJSON
+			}
+		case strings.HasPrefix(arg, "--format="):
+			config.JSONOutput = true
+			config.Format = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "--report="):
+			config.Report = strings.TrimPrefix(arg, "--report=")
+		case arg == "--explain":
+			config.Explain = true
+		case strings.HasPrefix(arg, "--events="):
+			config.Events = strings.TrimPrefix(arg, "--events=")
+		case strings.HasPrefix(arg, "--events-fd="):
+			// Anything but a descriptor number above 0 is marked invalid (-1)
+			// so that events never fall back to stdout
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--events-fd="))
+			if err != nil || n <= 0 {
+				n = -1
+			}
+			config.EventsFD = n
+		case strings.HasPrefix(arg, "--commands="):
+			config.Commands = strings.TrimPrefix(arg, "--commands=")
+		case strings.HasPrefix(arg, "--interactive="):
+			config.Interactive = strings.TrimPrefix(arg, "--interactive=")
+		case arg == "--resolve" && i+1 < len(args):
+			i++
+			config.Resolve = append(config.Resolve, args[i])
+		case strings.HasPrefix(arg, "--resolve="):
+			config.Resolve = append(config.Resolve, strings.TrimPrefix(arg, "--resolve="))
+		case arg == "--resolutions" && i+1 < len(args):
+			i++
+			config.Resolutions = args[i]
+		case strings.HasPrefix(arg, "--resolutions="):
+			config.Resolutions = strings.TrimPrefix(arg, "--resolutions=")
+		case strings.HasPrefix(arg, "--prefer="):
+			config.Prefer = strings.TrimPrefix(arg, "--prefer=")
+		case strings.HasPrefix(arg, "--prefer-kind="):
+			config.PreferKinds = append(config.PreferKinds, strings.TrimPrefix(arg, "--prefer-kind="))
 		case strings.HasPrefix(arg, "--log-level="):
 			config.LogLevel = strings.TrimPrefix(arg, "--log-level=")
 		case strings.HasPrefix(arg, "--timeout="):
 			if d, err := time.ParseDuration(strings.TrimPrefix(arg, "--timeout=")); err == nil {
 				config.GitTimeout = d
 			}
+		case strings.HasPrefix(arg, "--jobs="):
+			if n, err := strconv.Atoi(strings.TrimPrefix(arg, "--jobs=")); err == nil && n > 0 {
+				config.Jobs = n
+			}
 		}
 	}
+	// Events on stdout replace all other output
+	if eventsOnStdout(config) {
+		config.JSONOutput = true
+	}
 	return config
 }
 
+// eventsOnStdout reports whether the event stream is written to stdout
+func eventsOnStdout(config semantic.MergeConfig) bool {
+	return config.Events != "" && config.EventsFD == 0
+}
+
 // filterG2Flags removes g2-specific flags from args, returning git args
 func filterG2Flags(args []string) []string {
 	var gitArgs []string
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch {
+		case arg == "--resolve", arg == "--resolutions":
+			// Skip the flag and its value
+			i++
 		case arg == "--dry-run",
 			arg == "--verbose", arg == "-v",
 			arg == "--no-backup",
 			arg == "--json",
+			strings.HasPrefix(arg, "--format="),
+			strings.HasPrefix(arg, "--report="),
+			arg == "--explain",
+			strings.HasPrefix(arg, "--events="),
+			strings.HasPrefix(arg, "--events-fd="),
+			strings.HasPrefix(arg, "--commands="),
+			strings.HasPrefix(arg, "--interactive="),
+			strings.HasPrefix(arg, "--resolve="),
+			strings.HasPrefix(arg, "--resolutions="),
+			strings.HasPrefix(arg, "--prefer="),
+			strings.HasPrefix(arg, "--prefer-kind="),
 			strings.HasPrefix(arg, "--log-level="),
-			strings.HasPrefix(arg, "--timeout="):
+			strings.HasPrefix(arg, "--timeout="),
+			strings.HasPrefix(arg, "--jobs="):
 			// Skip g2-specific flags
 		default:
 			gitArgs = append(gitArgs, arg)
@@ -393,215 +663,690 @@ func filterG2Flags(args []string) []string {
 	return gitArgs
 }
 
-// initConfig initializes logging and executor based on config
-func initConfig(config semantic.MergeConfig) {
-	logging.Init(logging.Config{
-		Level:      logging.ParseLevel(config.LogLevel),
-		JSONFormat: config.JSONOutput,
-	})
-
-	if config.GitTimeout > 0 && config.GitTimeout != git.DefaultTimeout {
-		gitExec = git.NewExecutorWithTimeout(config.GitTimeout)
-		semantic.SetGitExecutor(gitExec)
+// runOperation runs the git operation. With machine-readable output git's
+// own output goes to stderr, so stdout carries only the result.
+func runOperation(ctx context.Context, config semantic.MergeConfig, gitArgs []string) error {
+	emitter.Emit(events.Event{Event: events.OperationStarted, Operation: gitArgs[0], Args: gitArgs[1:]})
+	if !config.JSONOutput {
+		return gitExec.RunWithStdio(ctx, gitArgs...)
 	}
+	out, err := gitExec.Output(ctx, gitArgs...)
+	os.Stderr.Write(out)
+	return err
 }
 
-// smartMerge runs git merge with semantic conflict analysis
-func smartMerge(args []string) int {
-	ctx := context.Background()
-	config := parseGlobalConfig(args)
-	gitArgs := filterG2Flags(args)
-	initConfig(config)
-
-	var jsonResult *output.MergeResult
-	if config.JSONOutput {
-		jsonResult = output.NewMergeResult()
-		jsonResult.DryRun = config.DryRun
+// checkOutputOptions validates --format, --report, --events, --events-fd, --commands,
+// --interactive, --resolve, --resolutions and --prefer before anything runs, and opens the
+// event stream
+func checkOutputOptions(config semantic.MergeConfig) error {
+	if config.Format != "" && !output.IsFormat(config.Format) {
+		return fmt.Errorf("unknown output format %q (use json, sarif or junit)", config.Format)
 	}
-
-	if !isGitRepo(ctx) {
-		if config.JSONOutput {
-			jsonResult.SetError(fmt.Errorf("not a git repository"))
-			output.WriteJSONStdout(jsonResult)
-		} else {
-			ui.Error("Not a git repository")
+	if config.Report != "" {
+		if _, err := output.ReportFormat(config.Report); err != nil {
+			return err
 		}
-		return exitcode.NotGitRepo
 	}
-
-	if !config.JSONOutput {
-		ui.Header("G2 Smart Merge")
-		if config.DryRun {
-			ui.Info("Dry-run mode: no files will be modified")
-			fmt.Println()
+	if config.Events != "" && config.Events != events.FormatNDJSON {
+		return fmt.Errorf("unknown event format %q (use ndjson)", config.Events)
+	}
+	if config.EventsFD < 0 {
+		return fmt.Errorf("invalid --events-fd (use a file descriptor number above 0)")
+	}
+	if config.Commands != "" {
+		if config.Commands != events.FormatNDJSON {
+			return fmt.Errorf("unknown command format %q (use ndjson)", config.Commands)
+		}
+		if config.Events == "" {
+			return fmt.Errorf("--commands requires --events")
 		}
-		ui.Step("Running git merge...")
 	}
-	logging.Debug("running git merge", "args", gitArgs)
-
-	err := gitExec.RunWithStdio(ctx, gitArgs...)
-	return handleOperationResult(ctx, config, jsonResult, err, OpMerge)
+	switch config.Interactive {
+	case "", tui.ModeTUI, tui.ModePrompt:
+	default:
+		return fmt.Errorf("unknown interactive mode %q (use tui or prompt)", config.Interactive)
+	}
+	if _, err := loadScriptedResolutions(config); err != nil {
+		return err
+	}
+	if _, err := semantic.ParsePreference(config.Prefer, config.PreferKinds); err != nil {
+		return err
+	}
+	return openEvents(config)
 }
 
-// smartRebase runs git rebase with semantic conflict analysis
-func smartRebase(args []string) int {
-	ctx := context.Background()
-	config := parseGlobalConfig(args)
-	gitArgs := filterG2Flags(args)
-	initConfig(config)
+// emitter streams progress events (--events); nil when disabled
+var emitter *events.Emitter
 
-	var jsonResult *output.MergeResult
-	if config.JSONOutput {
-		jsonResult = output.NewMergeResult()
-		jsonResult.DryRun = config.DryRun
+// openEvents sets up the event stream on stdout or --events-fd
+func openEvents(config semantic.MergeConfig) error {
+	emitter = nil
+	if config.Events == "" {
+		return nil
 	}
-
-	if !isGitRepo(ctx) {
-		if config.JSONOutput {
-			jsonResult.SetError(fmt.Errorf("not a git repository"))
-			output.WriteJSONStdout(jsonResult)
-		} else {
-			ui.Error("Not a git repository")
+	w := io.Writer(os.Stdout)
+	if config.EventsFD != 0 {
+		f := os.NewFile(uintptr(config.EventsFD), "events")
+		if f == nil {
+			return fmt.Errorf("invalid events file descriptor %d", config.EventsFD)
 		}
-		return exitcode.NotGitRepo
-	}
-
-	// Check for --continue, --abort, --skip
-	for _, arg := range gitArgs {
-		switch arg {
-		case "--continue":
-			return continueOperation()
-		case "--abort":
-			return abortOperation()
-		case "--skip":
-			// Pass through to git
-			if !config.JSONOutput {
-				ui.Step("Skipping current commit...")
-			}
-			if err := gitExec.RunWithStdio(ctx, gitArgs...); err != nil {
-				return exitcode.GitError
-			}
-			return exitcode.Success
+		if _, err := f.Stat(); err != nil {
+			return fmt.Errorf("events file descriptor %d: %w", config.EventsFD, err)
 		}
+		w = f
 	}
+	emitter = events.NewEmitter(w)
+	return nil
+}
 
-	if !config.JSONOutput {
-		ui.Header("G2 Smart Rebase")
-		if config.DryRun {
-			ui.Info("Dry-run mode: no files will be modified")
-			fmt.Println()
-		}
-		ui.Step("Running git rebase...")
+// newResult returns the result to collect for --json, --format or
+// --report, or nil if none is requested
+func newResult(config semantic.MergeConfig) *output.MergeResult {
+	if !config.JSONOutput && config.Report == "" {
+		return nil
 	}
-	logging.Debug("running git rebase", "args", gitArgs)
-
-	err := gitExec.RunWithStdio(ctx, gitArgs...)
-	return handleOperationResult(ctx, config, jsonResult, err, OpRebase)
+	result := output.NewMergeResult()
+	result.DryRun = config.DryRun
+	if p, err := semantic.ParsePreference(config.Prefer, config.PreferKinds); err == nil && p.IsSet() {
+		result.Prefer = preference(p)
+	}
+	return result
 }
 
-// smartCherryPick runs git cherry-pick with semantic conflict analysis
-func smartCherryPick(args []string) int {
-	ctx := context.Background()
-	config := parseGlobalConfig(args)
-	gitArgs := filterG2Flags(args)
-	initConfig(config)
-
-	var jsonResult *output.MergeResult
-	if config.JSONOutput {
-		jsonResult = output.NewMergeResult()
-		jsonResult.DryRun = config.DryRun
+// preference converts --prefer and --prefer-kind for output
+func preference(p semantic.Preference) *output.Preference {
+	out := &output.Preference{}
+	if p.Default != semantic.UserResolutionNone {
+		out.Default = p.Default.String()
 	}
-
-	if !isGitRepo(ctx) {
-		if config.JSONOutput {
-			jsonResult.SetError(fmt.Errorf("not a git repository"))
-			output.WriteJSONStdout(jsonResult)
-		} else {
-			ui.Error("Not a git repository")
+	for kind, side := range p.Kinds {
+		if out.Kinds == nil {
+			out.Kinds = make(map[string]string)
 		}
-		return exitcode.NotGitRepo
+		out.Kinds[kind] = side.String()
 	}
+	return out
+}
 
-	// Check for --continue, --abort
-	for _, arg := range gitArgs {
-		switch arg {
-		case "--continue":
-			return continueOperation()
-		case "--abort":
-			return abortOperation()
+// writeResult prints the result in the configured machine-readable format
+// and writes the merge report, if requested
+func writeResult(config semantic.MergeConfig, result *output.MergeResult) {
+	// With events on stdout the result is part of the finished event
+	if config.JSONOutput && !eventsOnStdout(config) {
+		if err := output.WriteStdout(result, config.Format); err != nil {
+			logging.Error("failed to write result", "format", config.Format, "error", err)
 		}
 	}
-
-	if !config.JSONOutput {
-		ui.Header("G2 Smart Cherry-Pick")
-		if config.DryRun {
-			ui.Info("Dry-run mode: no files will be modified")
-			fmt.Println()
+	if config.Report == "" {
+		return
+	}
+	if err := output.WriteReport(config.Report, result); err != nil {
+		logging.Error("failed to write report", "path", config.Report, "error", err)
+		if !config.JSONOutput {
+			ui.Warning(fmt.Sprintf("Failed to write report: %v", err))
 		}
-		ui.Step("Running git cherry-pick...")
+	} else if !config.JSONOutput {
+		ui.Info(fmt.Sprintf("Merge report written to %s", config.Report))
 	}
-	logging.Debug("running git cherry-pick", "args", gitArgs)
-
-	err := gitExec.RunWithStdio(ctx, gitArgs...)
-	return handleOperationResult(ctx, config, jsonResult, err, OpCherryPick)
 }
 
-// mergeDriver implements a Git merge driver
-// Called by Git with: g2 merge-driver %O %A %B %L %P
-// Where: %O=base, %A=local (ours), %B=remote (theirs), %L=conflict marker size, %P=path
-// Git expects the merged result written back to %A, exit 0=clean, 1=conflicts
-func mergeDriver(args []string) int {
-	if len(args) < 4 {
-		fmt.Fprintf(os.Stderr, "Usage: g2 merge-driver <base> <local> <remote> <marker-size> [path]\n")
-		return exitcode.GitError
-	}
+// initConfig initializes logging and executor based on config
+func initConfig(config semantic.MergeConfig) {
+	logging.Init(logging.Config{
+		Level:      logging.ParseLevel(config.LogLevel),
+		JSONFormat: config.JSONOutput,
+	})
 
-	basePath := args[0]
-	localPath := args[1]
-	remotePath := args[2]
-	// markerSize := args[3] // We don't use this currently
-	var filePath string
-	if len(args) >= 5 {
-		filePath = args[4]
-	} else {
-		filePath = localPath
+	if config.GitTimeout > 0 && config.GitTimeout != git.DefaultTimeout {
+		gitExec = newGitExecutor(config.GitTimeout)
+		semantic.SetGitExecutor(gitExec)
 	}
 
-	// Read all three versions
-	baseContent, err := os.ReadFile(basePath)
-	if err != nil {
-		// Base might not exist for new files
-		baseContent = nil
-	}
+	enableParseCache(context.Background())
+	enableRepoConfig(context.Background(), config.JSONOutput)
+}
 
-	localContent, err := os.ReadFile(localPath)
+// enableRepoConfig loads .g2.yaml and g2.* git config for the current
+// repository. Invalid entries are reported and skipped.
+func enableRepoConfig(ctx context.Context, quiet bool) {
+	repoRoot, err := getRepoRoot(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read local file %s: %v\n", localPath, err)
-		return exitcode.GitError
+		logging.Debug("repository config disabled", "error", err)
+		return
 	}
 
-	remoteContent, err := os.ReadFile(remotePath)
+	repoConfig, err := semantic.LoadRepoConfig(ctx, repoRoot)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read remote file %s: %v\n", remotePath, err)
-		return exitcode.GitError
+		if quiet {
+			logging.Warn("invalid g2 configuration", "error", err)
+		} else {
+			ui.Warning(fmt.Sprintf("Ignoring invalid g2 configuration: %v", err))
+		}
+	}
+	if repoConfig.MaxFileSize > 0 {
+		semantic.MaxFileSize = repoConfig.MaxFileSize
 	}
+	semantic.SetRepoConfig(repoConfig)
+}
 
-	// Check if this is a semantic file type
-	if !semantic.IsSemanticFile(filePath) {
-		// Fall back to Git's default merge for non-semantic files
-		return 1 // Tell Git to use its default merge
+// enableParseCache turns on the on-disk parse cache under .git/g2/cache.
+// The cache is shared by all worktrees of a repository.
+func enableParseCache(ctx context.Context) {
+	dir, err := parseCacheDir(ctx)
+	if err != nil {
+		logging.Debug("parse cache disabled", "error", err)
+		return
 	}
 
-	// Analyze the conflict
-	analysis := semantic.AnalyzeConflictFromContents(filePath, baseContent, localContent, remoteContent)
+	cache := semantic.NewParseCache(dir)
+	cache.PruneIfDue()
+	semantic.SetParseCache(cache)
+}
 
-	// Detect moves within this file
-	analysis.Conflicts = semantic.DetectMoves(analysis.Conflicts)
+// parseCacheDir returns the parse cache directory of the current repository
+func parseCacheDir(ctx context.Context) (string, error) {
+	gitDir, err := gitCommonDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return semantic.ParseCacheDir(gitDir), nil
+}
 
-	// Synthesize the result
-	mergedContent, allAutoMerged, err := semantic.SynthesizeToBytes(analysis)
+// gitCommonDir returns the git directory shared by all worktrees of the
+// current repository
+func gitCommonDir(ctx context.Context) (string, error) {
+	output, err := gitExec.Output(ctx, "rev-parse", "--git-common-dir")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Synthesis error for %s: %v\n", filePath, err)
+		return "", err
+	}
+	gitDir := strings.TrimSpace(string(output))
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("git directory %q not found", gitDir)
+	}
+	return gitDir, nil
+}
+
+// cacheCommand handles `g2 cache <subcommand>`
+func cacheCommand(args []string) int {
+	if len(args) != 1 || args[0] != "clear" {
+		fmt.Fprintf(os.Stderr, "Usage: g2 cache clear\n")
+		return exitcode.GitError
+	}
+
+	ctx := context.Background()
+	if !isGitRepo(ctx) {
+		ui.Error("Not a git repository")
+		return exitcode.NotGitRepo
+	}
+
+	dir, err := parseCacheDir(ctx)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to locate parse cache: %v", err))
+		return exitcode.GitError
+	}
+	if err := semantic.NewParseCache(dir).Clear(); err != nil {
+		ui.Error(err.Error())
+		return exitcode.GitError
+	}
+
+	ui.Success("Parse cache cleared")
+	return exitcode.Success
+}
+
+// operationHeadRefs names the ref of the commit each operation applies
+var operationHeadRefs = map[OperationType]string{
+	OpMerge:      "MERGE_HEAD",
+	OpRebase:     "REBASE_HEAD",
+	OpCherryPick: "CHERRY_PICK_HEAD",
+}
+
+// operationHeads returns HEAD and the commit being merged, rebased or
+// picked ("" if unknown)
+func operationHeads(ctx context.Context, opType OperationType) (head, mergeHead string) {
+	if out, err := gitExec.Output(ctx, "rev-parse", "-q", "--verify", "HEAD"); err == nil {
+		head = strings.TrimSpace(string(out))
+	}
+	if out, err := gitExec.Output(ctx, "rev-parse", "-q", "--verify", operationHeadRefs[opType]); err == nil {
+		mergeHead = strings.TrimSpace(string(out))
+	}
+	return head, mergeHead
+}
+
+// recordAudit appends the resolutions of a run to the audit log under .git/g2
+func recordAudit(ctx context.Context, config semantic.MergeConfig, opType OperationType, conflictingFiles []string, resultsByFile map[string]*semantic.SynthesisResult) {
+	if config.DryRun {
+		return
+	}
+	gitDir, err := gitCommonDir(ctx)
+	if err != nil {
+		logging.Debug("audit log disabled", "error", err)
+		return
+	}
+
+	run := audit.Run{Time: time.Now().UTC(), Operation: opType.String()}
+	run.Head, run.MergeHead = operationHeads(ctx, opType)
+	for _, file := range conflictingFiles {
+		result, ok := resultsByFile[file]
+		if !ok {
+			run.Entries = append(run.Entries, audit.Entry{File: file, Type: "Text Conflict", Resolution: "none"})
+			continue
+		}
+		for i := range result.Conflicts {
+			run.Entries = append(run.Entries, auditEntry(file, &result.Conflicts[i]))
+		}
+	}
+
+	if err := audit.Append(audit.Path(gitDir), run); err != nil {
+		logging.Warn("failed to write audit log", "error", err)
+	}
+}
+
+// auditEntry converts a synthesized conflict for the audit log
+func auditEntry(file string, c *semantic.SynthesisConflict) audit.Entry {
+	entry := audit.Entry{
+		File:       file,
+		Type:       c.UIConflict.ConflictType,
+		Resolution: c.Resolution(),
+		Source:     c.ResolutionSource(),
+		Rule:       c.UIConflict.Rule,
+	}
+	entry.Name, entry.Kind = conflictDefinition(c)
+	if entry.Resolution == "auto" {
+		entry.Class = string(semantic.ClassifyConflict(c))
+		if c.Move != nil && c.Move.SourceName != c.Move.DestName {
+			entry.Class = "rename"
+		}
+	}
+	return entry
+}
+
+// operationTrailers returns the commit message trailers for the resolutions
+// recorded for the merge, rebase or cherry-pick in progress
+func operationTrailers(ctx context.Context, opType OperationType) []string {
+	gitDir, err := gitCommonDir(ctx)
+	if err != nil {
+		return nil
+	}
+	runs, err := audit.Read(audit.Path(gitDir))
+	if err != nil {
+		logging.Warn("failed to read audit log", "error", err)
+		return nil
+	}
+	head, mergeHead := operationHeads(ctx, opType)
+	if mergeHead == "" {
+		return nil
+	}
+	return audit.Trailers(runs, head, mergeHead)
+}
+
+// addMessageTrailers adds trailers to the message file that a continued
+// rebase or cherry-pick commits with. A missing file is left alone.
+func addMessageTrailers(ctx context.Context, file string, trailers []string) {
+	if len(trailers) == 0 || !fileExists(file) {
+		return
+	}
+	args := []string{"interpret-trailers", "--in-place", "--if-exists", "addIfDifferent"}
+	for _, trailer := range trailers {
+		args = append(args, "--trailer", trailer)
+	}
+	if err := gitExec.Run(ctx, append(args, file)...); err != nil {
+		logging.Warn("failed to add trailers", "file", file, "error", err)
+	}
+}
+
+// logOptions are the options of `g2 log`
+type logOptions struct {
+	JSON  bool     // Print runs as JSON
+	Limit int      // Maximum number of runs (0 = all)
+	Paths []string // Only entries for these files or directories
+}
+
+// parseLogArgs parses `g2 log --g2 [--json] [-n N] [--file=PATH]...`. It
+// reports false without --g2 or if any other argument is not one of these
+// options; the command is then git's log.
+func parseLogArgs(args []string) (logOptions, bool) {
+	var opts logOptions
+	if !slices.Contains(args, "--g2") {
+		return opts, false
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--g2":
+		case arg == "--json":
+			opts.JSON = true
+		case arg == "-n" && i+1 < len(args):
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				return opts, false
+			}
+			opts.Limit = n
+		case strings.HasPrefix(arg, "--file="):
+			opts.Paths = append(opts.Paths, strings.TrimPrefix(arg, "--file="))
+		default:
+			return opts, false
+		}
+	}
+	return opts, true
+}
+
+// logCommand handles `g2 log`: the runs in the audit log, newest first
+func logCommand(opts logOptions) int {
+	ctx := context.Background()
+	if !isGitRepo(ctx) {
+		ui.Error("Not a git repository")
+		return exitcode.NotGitRepo
+	}
+	gitDir, err := gitCommonDir(ctx)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to locate audit log: %v", err))
+		return exitcode.GitError
+	}
+	runs, err := audit.Read(audit.Path(gitDir))
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to read audit log: %v", err))
+		return exitcode.GitError
+	}
+
+	selected := selectRuns(runs, opts.Paths, opts.Limit)
+	if opts.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(selected); err != nil {
+			return exitcode.GitError
+		}
+		return exitcode.Success
+	}
+	if len(selected) == 0 {
+		ui.Info("No g2 runs recorded")
+		return exitcode.Success
+	}
+	printRuns(os.Stdout, selected)
+	return exitcode.Success
+}
+
+// selectRuns returns the runs newest first, keeping only entries for paths
+// (files or directories, all if empty), at most limit runs (0 = all)
+func selectRuns(runs []audit.Run, paths []string, limit int) []audit.Run {
+	selected := []audit.Run{}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if limit > 0 && len(selected) == limit {
+			break
+		}
+		run := runs[i]
+		if len(paths) > 0 {
+			var entries []audit.Entry
+			for _, e := range run.Entries {
+				if matchesPath(e.File, paths) {
+					entries = append(entries, e)
+				}
+			}
+			if len(entries) == 0 {
+				continue
+			}
+			run.Entries = entries
+		}
+		selected = append(selected, run)
+	}
+	return selected
+}
+
+// matchesPath reports whether file is one of paths or inside one of them
+func matchesPath(file string, paths []string) bool {
+	for _, path := range paths {
+		path = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(path)), "/")
+		if path == "." || file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// printRuns prints audit runs as text
+func printRuns(w io.Writer, runs []audit.Run) {
+	for i, run := range runs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		resolved := 0
+		for _, e := range run.Entries {
+			if e.Resolved() {
+				resolved++
+			}
+		}
+		fmt.Fprintf(w, "%s  %s", run.Time.Local().Format("2006-01-02 15:04:05"), run.Operation)
+		if run.MergeHead != "" {
+			fmt.Fprintf(w, " of %s", shortOID(run.MergeHead))
+		}
+		if run.Head != "" {
+			fmt.Fprintf(w, " onto %s", shortOID(run.Head))
+		}
+		fmt.Fprintf(w, "  (%d of %d resolved)\n", resolved, len(run.Entries))
+		for _, e := range run.Entries {
+			decision := e.Resolution
+			switch {
+			case !e.Resolved():
+				decision = "unresolved"
+			case e.Class != "":
+				decision += " (" + e.Class + ")"
+			case e.Rule != "":
+				decision += " (rule " + e.Rule + ")"
+			case e.Source != "":
+				decision += " (" + e.Source + ")"
+			}
+			fmt.Fprintf(w, "    %-22s %s  %s\n", decision, e.Definition(), e.Type)
+		}
+	}
+}
+
+// shortOID abbreviates an object id
+func shortOID(oid string) string {
+	if len(oid) > 7 {
+		return oid[:7]
+	}
+	return oid
+}
+
+// smartMerge runs git merge with semantic conflict analysis
+func smartMerge(args []string) int {
+	ctx := context.Background()
+	config := parseGlobalConfig(args)
+	gitArgs := filterG2Flags(args)
+	if err := checkOutputOptions(config); err != nil {
+		ui.Error(fmt.Sprintf("Invalid option: %v", err))
+		return exitcode.GitError
+	}
+	initConfig(config)
+
+	jsonResult := newResult(config)
+
+	if !isGitRepo(ctx) {
+		if config.JSONOutput {
+			jsonResult.SetError(fmt.Errorf("not a git repository"))
+			writeResult(config, jsonResult)
+		} else {
+			ui.Error("Not a git repository")
+		}
+		return exitcode.NotGitRepo
+	}
+
+	if !config.JSONOutput {
+		ui.Header("G2 Smart Merge")
+		if config.DryRun {
+			ui.Info("Dry-run mode: no files will be modified")
+			fmt.Println()
+		}
+		ui.Step("Running git merge...")
+	}
+	logging.Debug("running git merge", "args", gitArgs)
+
+	err := runOperation(ctx, config, gitArgs)
+	return handleOperationResult(ctx, config, jsonResult, err, OpMerge)
+}
+
+// smartRebase runs git rebase with semantic conflict analysis
+func smartRebase(args []string) int {
+	ctx := context.Background()
+	config := parseGlobalConfig(args)
+	gitArgs := filterG2Flags(args)
+	if err := checkOutputOptions(config); err != nil {
+		ui.Error(fmt.Sprintf("Invalid option: %v", err))
+		return exitcode.GitError
+	}
+	initConfig(config)
+
+	jsonResult := newResult(config)
+
+	if !isGitRepo(ctx) {
+		if config.JSONOutput {
+			jsonResult.SetError(fmt.Errorf("not a git repository"))
+			writeResult(config, jsonResult)
+		} else {
+			ui.Error("Not a git repository")
+		}
+		return exitcode.NotGitRepo
+	}
+
+	// Check for --continue, --abort, --skip
+	for _, arg := range gitArgs {
+		switch arg {
+		case "--continue":
+			return continueOperation()
+		case "--abort":
+			return abortOperation()
+		case "--skip":
+			// Pass through to git
+			if !config.JSONOutput {
+				ui.Step("Skipping current commit...")
+			}
+			if err := gitExec.RunWithStdio(ctx, gitArgs...); err != nil {
+				return exitcode.GitError
+			}
+			return exitcode.Success
+		}
+	}
+
+	if !config.JSONOutput {
+		ui.Header("G2 Smart Rebase")
+		if config.DryRun {
+			ui.Info("Dry-run mode: no files will be modified")
+			fmt.Println()
+		}
+		ui.Step("Running git rebase...")
+	}
+	logging.Debug("running git rebase", "args", gitArgs)
+
+	err := runOperation(ctx, config, gitArgs)
+	return handleOperationResult(ctx, config, jsonResult, err, OpRebase)
+}
+
+// smartCherryPick runs git cherry-pick with semantic conflict analysis
+func smartCherryPick(args []string) int {
+	ctx := context.Background()
+	config := parseGlobalConfig(args)
+	gitArgs := filterG2Flags(args)
+	if err := checkOutputOptions(config); err != nil {
+		ui.Error(fmt.Sprintf("Invalid option: %v", err))
+		return exitcode.GitError
+	}
+	initConfig(config)
+
+	jsonResult := newResult(config)
+
+	if !isGitRepo(ctx) {
+		if config.JSONOutput {
+			jsonResult.SetError(fmt.Errorf("not a git repository"))
+			writeResult(config, jsonResult)
+		} else {
+			ui.Error("Not a git repository")
+		}
+		return exitcode.NotGitRepo
+	}
+
+	// Check for --continue, --abort
+	for _, arg := range gitArgs {
+		switch arg {
+		case "--continue":
+			return continueOperation()
+		case "--abort":
+			return abortOperation()
+		}
+	}
+
+	if !config.JSONOutput {
+		ui.Header("G2 Smart Cherry-Pick")
+		if config.DryRun {
+			ui.Info("Dry-run mode: no files will be modified")
+			fmt.Println()
+		}
+		ui.Step("Running git cherry-pick...")
+	}
+	logging.Debug("running git cherry-pick", "args", gitArgs)
+
+	err := runOperation(ctx, config, gitArgs)
+	return handleOperationResult(ctx, config, jsonResult, err, OpCherryPick)
+}
+
+// mergeDriver implements a Git merge driver
+// Called by Git with: g2 merge-driver %O %A %B %L %P
+// Where: %O=base, %A=local (ours), %B=remote (theirs), %L=conflict marker size, %P=path
+// Git expects the merged result written back to %A, exit 0=clean, 1=conflicts
+func mergeDriver(args []string) int {
+	if len(args) < 4 {
+		fmt.Fprintf(os.Stderr, "Usage: g2 merge-driver <base> <local> <remote> <marker-size> [path]\n")
+		return exitcode.GitError
+	}
+
+	basePath := args[0]
+	localPath := args[1]
+	remotePath := args[2]
+	// markerSize := args[3] // We don't use this currently
+	var filePath string
+	if len(args) >= 5 {
+		filePath = args[4]
+	} else {
+		filePath = localPath
+	}
+
+	// Read all three versions
+	baseContent, err := os.ReadFile(basePath)
+	if err != nil {
+		// Base might not exist for new files
+		baseContent = nil
+	}
+
+	localContent, err := os.ReadFile(localPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read local file %s: %v\n", localPath, err)
+		return exitcode.GitError
+	}
+
+	remoteContent, err := os.ReadFile(remotePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read remote file %s: %v\n", remotePath, err)
+		return exitcode.GitError
+	}
+
+	// Check if this is a semantic file type (.g2.yaml may override the language)
+	enableRepoConfig(context.Background(), true)
+	if !semantic.IsSemanticFile(filePath) {
+		// Fall back to Git's default merge for non-semantic files
+		return 1 // Tell Git to use its default merge
+	}
+
+	// Analyze the conflict
+	enableParseCache(context.Background())
+	analysis := semantic.AnalyzeConflictFromContents(filePath, baseContent, localContent, remoteContent)
+
+	// Detect moves within this file
+	analysis.Conflicts = semantic.DetectMovesWithConfig(analysis.Conflicts, semantic.MoveConfigFor(filePath))
+	semantic.ApplyPolicy(analysis)
+
+	// Synthesize the result
+	mergedContent, allAutoMerged, err := semantic.SynthesizeToBytes(analysis)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Synthesis error for %s: %v\n", filePath, err)
 		return exitcode.GitError
 	}
 
@@ -618,12 +1363,17 @@ func mergeDriver(args []string) int {
 }
 
 // handleOperationResult handles the result of a git operation (merge/rebase/cherry-pick)
-func handleOperationResult(ctx context.Context, config semantic.MergeConfig, jsonResult *output.MergeResult, err error, opType OperationType) int {
+func handleOperationResult(ctx context.Context, config semantic.MergeConfig, jsonResult *output.MergeResult, err error, opType OperationType) (code int) {
+	defer func() {
+		emitter.Emit(events.Event{Event: events.Finished, Operation: opType.String(), ExitCode: &code, Result: jsonResult})
+	}()
+
 	if err == nil {
-		if config.JSONOutput {
+		if jsonResult != nil {
 			jsonResult.Success = true
-			output.WriteJSONStdout(jsonResult)
-		} else {
+			writeResult(config, jsonResult)
+		}
+		if !config.JSONOutput {
 			fmt.Println()
 			ui.Success(fmt.Sprintf("%s completed successfully!", strings.Title(opType.String())))
 		}
@@ -635,7 +1385,7 @@ func handleOperationResult(ctx context.Context, config semantic.MergeConfig, jso
 		logging.Error("git operation timed out", "operation", opType.String(), "error", err)
 		if config.JSONOutput {
 			jsonResult.SetError(fmt.Errorf("git %s timed out", opType.String()))
-			output.WriteJSONStdout(jsonResult)
+			writeResult(config, jsonResult)
 		} else {
 			ui.Error(fmt.Sprintf("Git %s timed out", opType.String()))
 		}
@@ -655,7 +1405,7 @@ func handleOperationResult(ctx context.Context, config semantic.MergeConfig, jso
 		logging.Error("operation failed with unexpected error", "operation", opType.String(), "error", err)
 		if config.JSONOutput {
 			jsonResult.SetError(fmt.Errorf("%s failed: %v", opType.String(), err))
-			output.WriteJSONStdout(jsonResult)
+			writeResult(config, jsonResult)
 		} else {
 			ui.Error(fmt.Sprintf("%s failed: %v", strings.Title(opType.String()), err))
 		}
@@ -668,7 +1418,7 @@ func handleOperationResult(ctx context.Context, config semantic.MergeConfig, jso
 		logging.Error("git operation failed", "operation", opType.String(), "exit_code", opExitCode)
 		if config.JSONOutput {
 			jsonResult.SetError(fmt.Errorf("git %s failed with exit code %d", opType.String(), opExitCode))
-			output.WriteJSONStdout(jsonResult)
+			writeResult(config, jsonResult)
 		}
 		return opExitCode
 	}
@@ -700,7 +1450,7 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 		logging.Error("failed to get conflicting files", "error", err)
 		if config.JSONOutput && jsonResult != nil {
 			jsonResult.SetError(fmt.Errorf("failed to get conflicting files: %v", err))
-			output.WriteJSONStdout(jsonResult)
+			writeResult(config, jsonResult)
 		} else if !config.JSONOutput {
 			ui.Error(fmt.Sprintf("Failed to get conflicting files: %v", err))
 		}
@@ -710,77 +1460,35 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 	if len(conflictingFiles) == 0 {
 		if config.JSONOutput && jsonResult != nil {
 			jsonResult.SetError(fmt.Errorf("no conflicting files found"))
-			output.WriteJSONStdout(jsonResult)
-		} else if !config.JSONOutput {
-			ui.Info("No conflicting files found (operation may have failed for another reason)")
-		}
-		return exitcode.GitError
-	}
-
-	// Analyze each file
-	synthesesByFile := make(map[string]*semantic.SynthesisAnalysis)
-	for _, file := range conflictingFiles {
-		if semantic.IsSemanticFile(file) {
-			synthesis := semantic.AnalyzeConflictForSynthesis(file)
-			synthesesByFile[file] = synthesis
-		}
-	}
-
-	// Detect inter-file moves
-	var allAnalyses []*semantic.SynthesisAnalysis
-	for _, a := range synthesesByFile {
-		allAnalyses = append(allAnalyses, a)
-	}
-	interFileMoves := semantic.DetectInterFileMoves(allAnalyses)
-	semantic.ApplyInterFileMoves(allAnalyses, interFileMoves)
-
-	// Handle import updates for inter-file moves
-	if len(interFileMoves) > 0 && !config.DryRun {
-		repoRoot, _ := getRepoRoot(ctx)
-		if repoRoot != "" {
-			importUpdates, err := semantic.FindImportUpdates(interFileMoves, repoRoot)
-			if err != nil {
-				logging.Warn("failed to scan for import updates", "error", err)
-				if config.Verbose && !config.JSONOutput {
-					ui.Warning(fmt.Sprintf("Failed to scan for import updates: %v", err))
-				}
-			}
-			if len(importUpdates) > 0 {
-				if !config.JSONOutput {
-					fmt.Println()
-					ui.Step("Updating imports for moved definitions...")
-				}
-				results := semantic.ApplyImportUpdates(importUpdates, repoRoot)
-
-				for _, result := range results {
-					if result.Error == nil && result.UpdatesCount > 0 {
-						if err := gitExec.Run(ctx, "add", result.File); err != nil {
-							logging.Warn("failed to stage import update", "file", result.File, "error", err)
-						} else if config.Verbose && !config.JSONOutput {
-							ui.Info(fmt.Sprintf("  Updated %d import(s) in %s", result.UpdatesCount, result.File))
-						}
-					}
-				}
-
-				totalUpdates := 0
-				for _, r := range results {
-					totalUpdates += r.UpdatesCount
-				}
-				if totalUpdates > 0 && !config.JSONOutput {
-					ui.Info(fmt.Sprintf("Updated %d import(s) in %d file(s)", totalUpdates, len(results)))
-				}
-			}
+			writeResult(config, jsonResult)
+		} else if !config.JSONOutput {
+			ui.Info("No conflicting files found (operation may have failed for another reason)")
 		}
-	} else if len(interFileMoves) > 0 && config.DryRun {
-		repoRoot, _ := getRepoRoot(ctx)
-		if repoRoot != "" {
-			importUpdates, _ := semantic.FindImportUpdates(interFileMoves, repoRoot)
-			if len(importUpdates) > 0 && !config.JSONOutput {
-				fmt.Println()
-				ui.Step("Import updates needed (dry-run):")
-				fmt.Print(semantic.FormatImportUpdateSummary(importUpdates))
-			}
+		return exitcode.GitError
+	}
+
+	synthesesByFile, interFileMoves := analyzeConflicts(ctx, config, conflictingFiles)
+
+	// Apply --resolve and --resolutions before anything is written
+	scripted, err := applyScriptedResolutions(config, conflictingFiles, synthesesByFile)
+	if err != nil {
+		logging.Error("failed to apply resolutions", "error", err)
+		if config.JSONOutput && jsonResult != nil {
+			jsonResult.SetError(err)
+			writeResult(config, jsonResult)
+		} else if !config.JSONOutput {
+			ui.Error(fmt.Sprintf("Invalid resolutions:\n%v", err))
+			ui.Info(fmt.Sprintf("No files were written. Resolve the conflicts, or run 'g2 abort' to cancel the %s", opType.String()))
 		}
+		return exitcode.GitError
+	}
+	// Unresolved conflicts left after that take the preferred side
+	preferred := applyPreference(config, conflictingFiles, synthesesByFile)
+	conflictRefs := emitAnalysis(conflictingFiles, synthesesByFile)
+
+	// Handle import updates for inter-file moves
+	if len(interFileMoves) > 0 {
+		updateImports(ctx, config, jsonResult, interFileMoves)
 	}
 
 	// Collect all conflicts for display
@@ -808,14 +1516,28 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 			needsResolution++
 		}
 	}
+	unresolved := needsResolution - scripted - preferred
 
 	if !config.JSONOutput {
 		ui.Summary(needsResolution, len(allConflicts))
+		if scripted > 0 {
+			ui.Info(fmt.Sprintf("%d conflict(s) resolved by --resolve/--resolutions", scripted))
+		}
+		if preferred > 0 {
+			ui.Info(fmt.Sprintf("%d conflict(s) resolved by --prefer/--prefer-kind", preferred))
+		}
 		fmt.Println()
 	}
 
-	// If running in a terminal and there are unresolved conflicts, launch TUI BEFORE synthesis
-	if !config.JSONOutput && !config.DryRun && tui.IsTerminal() && needsResolution > 0 {
+	// An editor driving resolutions over the command channel replaces the TUI
+	if config.Commands != "" && !config.DryRun && unresolved > 0 {
+		return resolveWithCommands(ctx, config, conflictingFiles, synthesesByFile, conflictRefs, jsonResult, opType)
+	}
+
+	// If running in a terminal and there are unresolved conflicts, launch TUI BEFORE synthesis.
+	// The prompt only needs line input, so it runs wherever it is asked for.
+	interactive := tui.IsTerminal() || config.Interactive == tui.ModePrompt
+	if !config.JSONOutput && !config.DryRun && interactive && unresolved > 0 {
 		return launchConflictTUI(ctx, config, conflictingFiles, synthesesByFile, jsonResult, opType)
 	}
 
@@ -829,24 +1551,16 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 
 	allAutoMerged := true
 	filesWithMarkers := 0
+	resultsByFile := synthesizeFiles(config, conflictingFiles, synthesesByFile)
+	printExplanations(config, conflictingFiles, resultsByFile)
+	recordAudit(ctx, config, opType, conflictingFiles, resultsByFile)
 
 	for _, file := range conflictingFiles {
 		if semantic.IsSemanticFile(file) {
-			synthesis := synthesesByFile[file]
-			result := semantic.SynthesizeFile(synthesis, config)
+			result := resultsByFile[file]
 
-			if config.JSONOutput && jsonResult != nil {
-				fileResult := output.FileResult{
-					File:          file,
-					ConflictCount: result.ConflictCount,
-					ResolvedCount: result.AutoMergeCount,
-					AllAutoMerged: result.AllAutoMerged,
-					HasMarkers:    !result.AllAutoMerged && result.Success,
-				}
-				if result.Error != nil {
-					fileResult.Error = result.Error.Error()
-				}
-				jsonResult.AddFileResult(fileResult)
+			if jsonResult != nil {
+				jsonResult.AddFileResult(fileResult(file, result, jsonResult.ImportEdits))
 			}
 
 			if result.Error != nil {
@@ -861,11 +1575,12 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 			}
 		} else {
 			allAutoMerged = false
-			if config.JSONOutput && jsonResult != nil {
+			if jsonResult != nil {
 				jsonResult.AddFileResult(output.FileResult{
 					File:          file,
 					ConflictCount: 1,
 					HasMarkers:    true,
+					Conflicts:     []output.ConflictDetail{},
 				})
 			}
 		}
@@ -889,20 +1604,22 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 		if err := gitExec.Run(ctx, abortArgs...); err != nil {
 			logging.Debug("abort failed (may not be in operation state)", "error", err)
 		}
-		if config.JSONOutput && jsonResult != nil {
+		if jsonResult != nil {
 			jsonResult.Finalize()
-			output.WriteJSONStdout(jsonResult)
-		} else if !config.JSONOutput {
+			writeResult(config, jsonResult)
+		}
+		if !config.JSONOutput {
 			ui.Info("Dry run complete - no files were modified")
 		}
 		return exitcode.Success
 	}
 
-	if allAutoMerged && needsResolution == 0 {
-		if config.JSONOutput && jsonResult != nil {
+	if allAutoMerged && unresolved == 0 {
+		if jsonResult != nil {
 			jsonResult.Finalize()
-			output.WriteJSONStdout(jsonResult)
-		} else if !config.JSONOutput {
+			writeResult(config, jsonResult)
+		}
+		if !config.JSONOutput {
 			ui.Success("All conflicts auto-merged and staged!")
 			if opType != OpMerge {
 				ui.Info(fmt.Sprintf("Run 'g2 continue' to finish the %s", opType.String()))
@@ -917,13 +1634,96 @@ func resolveConflictsWithJSON(ctx context.Context, config semantic.MergeConfig,
 		}
 		ui.Info(fmt.Sprintf("Run 'g2 continue' to continue after resolving, or 'g2 abort' to cancel"))
 	}
-	if config.JSONOutput && jsonResult != nil {
+	if jsonResult != nil {
 		jsonResult.Finalize()
-		output.WriteJSONStdout(jsonResult)
+		writeResult(config, jsonResult)
 	}
 	return exitcode.ConflictsRemain
 }
 
+// loadScriptedResolutions parses the --resolve values and reads the
+// --resolutions file
+func loadScriptedResolutions(config semantic.MergeConfig) ([]semantic.ScriptedResolution, error) {
+	var resolutions []semantic.ScriptedResolution
+	if config.Resolutions != "" {
+		loaded, err := semantic.LoadResolutions(config.Resolutions)
+		if err != nil {
+			return nil, err
+		}
+		resolutions = loaded
+	}
+	// Flags come last so they override the file
+	for _, value := range config.Resolve {
+		r, err := semantic.ParseResolveFlag(value)
+		if err != nil {
+			return nil, err
+		}
+		resolutions = append(resolutions, r)
+	}
+	return resolutions, nil
+}
+
+// applyScriptedResolutions applies --resolve and --resolutions to the
+// analyzed conflicts and returns how many conflicts they resolved
+func applyScriptedResolutions(config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis) (int, error) {
+	resolutions, err := loadScriptedResolutions(config)
+	if err != nil || len(resolutions) == 0 {
+		return 0, err
+	}
+	return semantic.ApplyResolutions(analysesOf(conflictingFiles, synthesesByFile), resolutions)
+}
+
+// applyPreference resolves the conflicts that still need resolution with
+// the side given by --prefer and --prefer-kind, and returns how many it
+// resolved
+func applyPreference(config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis) int {
+	p, err := semantic.ParsePreference(config.Prefer, config.PreferKinds)
+	if err != nil || !p.IsSet() {
+		return 0
+	}
+	return semantic.ApplyPreference(analysesOf(conflictingFiles, synthesesByFile), p)
+}
+
+// analysesOf returns the analyses of the semantic files, in file order
+func analysesOf(conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis) []*semantic.SynthesisAnalysis {
+	var analyses []*semantic.SynthesisAnalysis
+	for _, file := range conflictingFiles {
+		if synthesis, ok := synthesesByFile[file]; ok {
+			analyses = append(analyses, synthesis)
+		}
+	}
+	return analyses
+}
+
+// analyzeConflicts analyzes the semantic files among the conflicting files
+// and links the definitions moved between them
+func analyzeConflicts(ctx context.Context, config semantic.MergeConfig, conflictingFiles []string) (map[string]*semantic.SynthesisAnalysis, []semantic.InterFileMove) {
+	// Load all stage blobs in one pass, then analyze each file
+	var semanticFiles []string
+	for _, file := range conflictingFiles {
+		if semantic.IsSemanticFile(file) {
+			semanticFiles = append(semanticFiles, file)
+		}
+	}
+	versionsByFile := semantic.LoadConflictVersions(ctx, semanticFiles)
+	if emitter != nil {
+		config.OnAnalyzed = func(analysis *semantic.SynthesisAnalysis) {
+			emitter.Emit(events.Event{Event: events.FileAnalyzed, File: analysis.File, Conflicts: len(analysis.Conflicts)})
+		}
+	}
+	allAnalyses := semantic.AnalyzeFilesForSynthesis(semanticFiles, versionsByFile, config)
+
+	synthesesByFile := make(map[string]*semantic.SynthesisAnalysis)
+	for i, file := range semanticFiles {
+		synthesesByFile[file] = allAnalyses[i]
+	}
+
+	// Detect inter-file moves once every file has been analyzed
+	interFileMoves := semantic.DetectInterFileMoves(allAnalyses)
+	semantic.ApplyInterFileMoves(allAnalyses, interFileMoves)
+	return synthesesByFile, interFileMoves
+}
+
 // isGitRepo checks if the current directory is inside a git repository
 func isGitRepo(ctx context.Context) bool {
 	return gitExec.Run(ctx, "rev-parse", "--git-dir") == nil
@@ -938,6 +1738,254 @@ func getRepoRoot(ctx context.Context) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// updateImports rewrites imports that refer to definitions moved between
+// files. In dry-run mode the edits are shown as diffs instead of written.
+// Each edit is recorded in the JSON result.
+func updateImports(ctx context.Context, config semantic.MergeConfig, jsonResult *output.MergeResult, moves []semantic.InterFileMove) {
+	repoRoot, _ := getRepoRoot(ctx)
+	if repoRoot == "" {
+		return
+	}
+
+	importUpdates, err := semantic.FindImportUpdatesWithContext(ctx, moves, repoRoot)
+	if err != nil {
+		logging.Warn("failed to scan for import updates", "error", err)
+		if config.Verbose && !config.JSONOutput {
+			ui.Warning(fmt.Sprintf("Failed to scan for import updates: %v", err))
+		}
+	}
+	if len(importUpdates) == 0 {
+		return
+	}
+
+	if !config.JSONOutput {
+		fmt.Println()
+		if config.DryRun {
+			ui.Step("Import updates needed (dry-run):")
+			fmt.Print(semantic.FormatImportUpdateSummary(importUpdates))
+		} else {
+			ui.Step("Updating imports for moved definitions...")
+		}
+	}
+	results := semantic.ApplyImportUpdates(importUpdates, repoRoot, config)
+
+	resultsByFile := make(map[string]semantic.ImportUpdateResult)
+	totalUpdates, updatedFiles := 0, 0
+	var changed []string
+	for _, result := range results {
+		resultsByFile[result.File] = result
+		if result.Error != nil {
+			logging.Warn("failed to update imports", "file", result.File, "error", result.Error)
+			if !config.JSONOutput {
+				ui.Warning(fmt.Sprintf("Failed to update imports in %s: %v", result.File, result.Error))
+			}
+			continue
+		}
+		if result.UpdatesCount == 0 {
+			continue
+		}
+		totalUpdates += result.UpdatesCount
+		updatedFiles++
+		if config.DryRun {
+			continue
+		}
+		changed = append(changed, result.File)
+		if config.Verbose && !config.JSONOutput {
+			ui.Info(fmt.Sprintf("  Updated %d import(s) in %s", result.UpdatesCount, result.File))
+		}
+	}
+	stageTrackedFiles(ctx, repoRoot, changed)
+	if totalUpdates > 0 && !config.DryRun && !config.JSONOutput {
+		ui.Info(fmt.Sprintf("Updated %d import(s) in %d file(s)", totalUpdates, updatedFiles))
+	}
+
+	if jsonResult != nil || emitter != nil {
+		for _, update := range importUpdates {
+			result := resultsByFile[update.File]
+			edit := output.ImportEdit{
+				File:       update.File,
+				Line:       update.LineNumber,
+				Kind:       update.Kind,
+				Definition: update.Definition,
+				From:       update.FromModule,
+				To:         update.ToModule,
+				Old:        update.OldImport,
+				New:        update.NewImport,
+				Applied:    !config.DryRun && result.Error == nil,
+			}
+			if result.Error != nil {
+				edit.Error = result.Error.Error()
+			}
+			emitter.Emit(events.Event{Event: events.ImportUpdated, File: edit.File, Import: &edit})
+			if jsonResult != nil {
+				jsonResult.AddImportEdit(edit)
+			}
+		}
+	}
+}
+
+// stageTrackedFiles stages the files (relative to repoRoot) that git already
+// tracks. Untracked files are left for the user to add.
+func stageTrackedFiles(ctx context.Context, repoRoot string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	output, err := gitExec.Output(ctx, append([]string{"-C", repoRoot, "ls-files", "-z", "--"}, files...)...)
+	if err != nil {
+		logging.Warn("failed to list tracked files", "error", err)
+		return
+	}
+	var tracked []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			tracked = append(tracked, file)
+		}
+	}
+	if len(tracked) == 0 {
+		return
+	}
+	if err := gitExec.Run(ctx, append([]string{"-C", repoRoot, "add", "--"}, tracked...)...); err != nil {
+		logging.Warn("failed to stage import updates", "files", tracked, "error", err)
+	}
+}
+
+// fileResult builds the JSON result of a synthesized file. Import edits are
+// attached to the moves they were made for.
+func fileResult(file string, result *semantic.SynthesisResult, importEdits []output.ImportEdit) output.FileResult {
+	fr := output.FileResult{
+		File:          file,
+		ConflictCount: result.ConflictCount,
+		ResolvedCount: result.AutoMergeCount,
+		AllAutoMerged: result.AllAutoMerged,
+		HasMarkers:    !result.AllAutoMerged && result.Success,
+		Conflicts:     make([]output.ConflictDetail, 0, len(result.Conflicts)),
+	}
+	for _, match := range result.Rules {
+		fr.Rules = append(fr.Rules, output.RuleMatch{
+			Conflict:   match.Conflict,
+			Rule:       match.Rule,
+			Resolution: match.Resolution,
+		})
+	}
+	for i := range result.Conflicts {
+		fr.Conflicts = append(fr.Conflicts, conflictDetail(&result.Conflicts[i], importEdits))
+	}
+	if result.Error != nil {
+		fr.Error = result.Error.Error()
+	}
+	return fr
+}
+
+// conflictDefinition returns the name and kind of a conflict's definition
+// (semantic.ConflictDefinition)
+func conflictDefinition(c *semantic.SynthesisConflict) (name, kind string) {
+	if def := semantic.ConflictDefinition(c); def != nil {
+		return def.Name, def.Kind
+	}
+	return "", ""
+}
+
+// conflictDetail converts a synthesized conflict for JSON output
+func conflictDetail(c *semantic.SynthesisConflict, importEdits []output.ImportEdit) output.ConflictDetail {
+	detail := output.ConflictDetail{
+		Type:       c.UIConflict.ConflictType,
+		Status:     c.UIConflict.Status,
+		Resolution: c.Resolution(),
+		Source:     c.ResolutionSource(),
+		Rule:       c.UIConflict.Rule,
+		Base:       definitionRange(c.Base),
+		Local:      definitionRange(c.Local),
+		Remote:     definitionRange(c.Remote),
+		Bodies:     &output.Bodies{},
+		Explain:    explanation(c.Explain),
+	}
+	if c.Base != nil {
+		detail.Bodies.Base = c.Base.Body
+	}
+	if c.Local != nil {
+		detail.Bodies.Local = c.Local.Body
+	}
+	if c.Remote != nil {
+		detail.Bodies.Remote = c.Remote.Body
+	}
+	detail.Bodies.Merged, detail.Bodies.HasMerged = c.MergedBody()
+	detail.Name, detail.Kind = conflictDefinition(c)
+	if c.Move != nil {
+		detail.Move = &output.Move{
+			FromFile:   c.Move.SourceFile,
+			ToFile:     c.Move.DestFile,
+			FromName:   c.Move.SourceName,
+			ToName:     c.Move.DestName,
+			MatchType:  c.Move.MatchType,
+			Similarity: c.Move.Similarity,
+		}
+		if c.Move.SourceFile != c.Move.DestFile {
+			for _, edit := range importEdits {
+				if edit.Definition == c.Move.SourceName || edit.Definition == c.Move.DestName {
+					detail.Move.ImportEdits = append(detail.Move.ImportEdits, edit)
+				}
+			}
+		}
+	}
+	return detail
+}
+
+// explanation converts a conflict's decision trace for output
+func explanation(e *semantic.Explanation) *output.Explanation {
+	if e == nil {
+		return nil
+	}
+	out := &output.Explanation{
+		Rule: e.Rule,
+		Normalized: output.Normalized{
+			Base:   e.BaseNormalized,
+			Local:  e.LocalNormalized,
+			Remote: e.RemoteNormalized,
+		},
+		Notes: e.Notes,
+	}
+	if e.Move != nil {
+		out.Move = &output.MoveScore{
+			Candidate:    e.Move.Candidate,
+			DeleteTokens: e.Move.DeleteTokens,
+			AddTokens:    e.Move.AddTokens,
+			Similarity:   e.Move.Similarity,
+			Threshold:    e.Move.Threshold,
+			Summary:      e.Move.String(),
+		}
+	}
+	return out
+}
+
+// printExplanations prints how each synthesized conflict was classified
+// (--explain in text mode)
+func printExplanations(config semantic.MergeConfig, conflictingFiles []string, resultsByFile map[string]*semantic.SynthesisResult) {
+	if !config.Explain || config.JSONOutput {
+		return
+	}
+	fmt.Println()
+	ui.Step("How conflicts were classified:")
+	fmt.Println()
+	for _, file := range conflictingFiles {
+		if result, ok := resultsByFile[file]; ok {
+			output.WriteExplanations(os.Stdout, fileResult(file, result, nil))
+		}
+	}
+}
+
+// definitionRange returns the 1-based line and byte range of a definition
+func definitionRange(def *semantic.Definition) *output.Range {
+	if def == nil {
+		return nil
+	}
+	return &output.Range{
+		StartLine: int(def.StartLine) + 1,
+		EndLine:   int(def.EndLine) + 1,
+		StartByte: int(def.StartByte),
+		EndByte:   int(def.EndByte),
+	}
+}
+
 // SmartMergeWithExecutor runs smart merge with a custom executor (for testing)
 func SmartMergeWithExecutor(args []string, exec git.Executor) int {
 	oldExec := gitExec
@@ -997,22 +2045,12 @@ func launchConflictTUI(ctx context.Context, config semantic.MergeConfig, conflic
 		}
 
 		for i, sc := range synthesis.Conflicts {
-			if sc.UIConflict.Status != "Needs Resolution" {
+			if sc.UIConflict.Status != "Needs Resolution" || sc.UserResolution != semantic.UserResolutionNone {
 				continue
 			}
 
 			// Get name and kind from whichever definition exists
-			var name, kind string
-			if sc.Local != nil {
-				name = sc.Local.Name
-				kind = sc.Local.Kind
-			} else if sc.Remote != nil {
-				name = sc.Remote.Name
-				kind = sc.Remote.Kind
-			} else if sc.Base != nil {
-				name = sc.Base.Name
-				kind = sc.Base.Kind
-			}
+			name, kind := conflictDefinition(&sc)
 
 			// Store index for later resolution mapping
 			conflictIndices[conflictKey{file: file, name: name}] = i
@@ -1049,8 +2087,14 @@ func launchConflictTUI(ctx context.Context, config semantic.MergeConfig, conflic
 
 	ui.Info(fmt.Sprintf("\nLaunching interactive resolver for %d conflict(s)...\n", len(tuiConflicts)))
 
-	// Run the TUI
-	result, err := tui.RunResolver(tuiConflicts)
+	// Run the TUI, or the prompt for terminals it does not work in
+	var result *tui.ResolverResult
+	var err error
+	if config.Interactive == tui.ModePrompt {
+		result, err = tui.RunPrompt(tuiConflicts, commandInput, os.Stdout, editConflict)
+	} else {
+		result, err = tui.RunResolver(tuiConflicts)
+	}
 	if err != nil {
 		ui.Error(fmt.Sprintf("TUI error: %v", err))
 		return exitcode.GitError
@@ -1103,7 +2147,11 @@ func launchConflictTUI(ctx context.Context, config semantic.MergeConfig, conflic
 			synthesis.Conflicts[idx].UserResolution = semantic.UserResolutionBase
 		case tui.ResolutionSkip:
 			synthesis.Conflicts[idx].UserResolution = semantic.UserResolutionSkip
+		case tui.ResolutionCustom:
+			synthesis.Conflicts[idx].UserResolution = semantic.UserResolutionCustom
+			synthesis.Conflicts[idx].CustomBody = c.CustomContent
 		}
+		synthesis.Conflicts[idx].ResolvedBy = semantic.ResolvedByTUI
 	}
 
 	if resolved == 0 && manualEdits == 0 {
@@ -1116,6 +2164,185 @@ func launchConflictTUI(ctx context.Context, config semantic.MergeConfig, conflic
 	return synthesizeAllFilesWithManualCount(ctx, config, conflictingFiles, synthesesByFile, jsonResult, opType, manualEdits)
 }
 
+// conflictRef locates a conflict by its id on the event stream
+type conflictRef struct {
+	file  string
+	index int // Index in the file's synthesis.Conflicts
+}
+
+// numberConflicts numbers the conflicts of semantic files from 1, in file
+// order. The returned refs are indexed by id-1.
+func numberConflicts(conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis) []conflictRef {
+	var refs []conflictRef
+	for _, file := range conflictingFiles {
+		if synthesis, ok := synthesesByFile[file]; ok {
+			for i := range synthesis.Conflicts {
+				refs = append(refs, conflictRef{file: file, index: i})
+			}
+		}
+	}
+	return refs
+}
+
+// emitAnalysis emits the classified conflicts and the moves found among
+// them once classification is final, and returns the conflicts by id
+// (numberConflicts). Semantic files were reported as analyzed by the
+// analysis workers (analyzeConflicts). Moves are emitted once, from the file
+// they were moved out of.
+func emitAnalysis(conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis) []conflictRef {
+	refs := numberConflicts(conflictingFiles, synthesesByFile)
+	if emitter == nil {
+		return refs
+	}
+
+	id := 0
+	for _, file := range conflictingFiles {
+		synthesis, ok := synthesesByFile[file]
+		if !ok {
+			emitter.Emit(events.Event{Event: events.FileAnalyzed, File: file, Conflicts: 1})
+			detail := output.ConflictDetail{Type: "Text Conflict", Status: "Needs Resolution", Resolution: "none"}
+			emitter.Emit(events.Event{Event: events.ConflictClassified, File: file, Conflict: &detail})
+			continue
+		}
+
+		for i := range synthesis.Conflicts {
+			id++
+			c := &synthesis.Conflicts[i]
+			detail := conflictDetail(c, nil)
+			emitter.Emit(events.Event{Event: events.ConflictClassified, File: file, ID: id, Conflict: &detail})
+			if c.Move != nil && c.Move.SourceFile == file {
+				emitter.Emit(events.Event{Event: events.MoveDetected, File: file, ID: id, Move: detail.Move})
+			}
+		}
+	}
+	return refs
+}
+
+// commandInput is the command channel (--commands) and the input of
+// --interactive=prompt, replaced in tests
+var commandInput io.Reader = os.Stdin
+
+// editConflict edits a conflict for --interactive=prompt, replaced in tests
+var editConflict tui.EditFunc = tui.EditInEditor
+
+// resolveWithCommands lets an editor resolve the conflicts that need
+// resolution by sending commands on stdin, then synthesizes the files. The
+// commands are mapped to resolutions as the TUI's choices are.
+func resolveWithCommands(ctx context.Context, config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis, refs []conflictRef, jsonResult *output.MergeResult, opType OperationType) int {
+	lookup := func(id int) *semantic.SynthesisConflict {
+		if id < 1 || id > len(refs) {
+			return nil
+		}
+		ref := refs[id-1]
+		return &synthesesByFile[ref.file].Conflicts[ref.index]
+	}
+
+	var pending []int
+	for id := 1; id <= len(refs); id++ {
+		if c := lookup(id); c.UIConflict.Status == "Needs Resolution" && c.UserResolution == semantic.UserResolutionNone {
+			pending = append(pending, id)
+		}
+	}
+	emitter.Emit(events.Event{Event: events.AwaitingUser, Pending: pending})
+
+	reader := events.NewCommandReader(commandInput)
+	for {
+		cmd, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			emitter.Emit(events.Event{Event: events.CommandError, Message: err.Error()})
+			continue
+		}
+
+		switch cmd.Command {
+		case events.CommandResolve:
+			c := lookup(cmd.ID)
+			if c == nil {
+				emitter.Emit(events.Event{Event: events.CommandError, ID: cmd.ID, Message: fmt.Sprintf("no conflict %d", cmd.ID)})
+				continue
+			}
+			if err := applyResolution(c, cmd.Resolution, semantic.ResolvedByCommand); err != nil {
+				emitter.Emit(events.Event{Event: events.CommandError, ID: cmd.ID, Message: err.Error()})
+				continue
+			}
+			detail := conflictDetail(c, nil)
+			emitter.Emit(events.Event{Event: events.ResolutionAccepted, File: refs[cmd.ID-1].file, ID: cmd.ID, Conflict: &detail})
+		case events.CommandDone:
+			return synthesizeWithCommands(ctx, config, conflictingFiles, synthesesByFile, jsonResult, opType)
+		case events.CommandAbort:
+			return exitcode.ConflictsRemain
+		default:
+			emitter.Emit(events.Event{Event: events.CommandError, Message: fmt.Sprintf("unknown command %q (use resolve, done or abort)", cmd.Command)})
+		}
+	}
+	return synthesizeWithCommands(ctx, config, conflictingFiles, synthesesByFile, jsonResult, opType)
+}
+
+// applyResolution sets the resolution named by name (local, remote, both,
+// base, skip, or none to undo a choice) on a conflict that needs resolution
+func applyResolution(c *semantic.SynthesisConflict, name, source string) error {
+	if c.UIConflict.Status != "Needs Resolution" {
+		return fmt.Errorf("%s does not need resolution", c.UIConflict.ConflictType)
+	}
+	resolution, ok := semantic.ParseUserResolution(name)
+	if !ok {
+		return fmt.Errorf("unknown resolution %q (use local, remote, both, base, skip or none)", name)
+	}
+	c.UserResolution = resolution
+	c.ResolvedBy = source
+	if resolution == semantic.UserResolutionNone {
+		c.ResolvedBy = ""
+	}
+	return nil
+}
+
+// synthesizeWithCommands synthesizes the files once the command channel is
+// done, counting skipped conflicts as left for manual editing
+func synthesizeWithCommands(ctx context.Context, config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis, jsonResult *output.MergeResult, opType OperationType) int {
+	manualEdits := 0
+	for _, synthesis := range synthesesByFile {
+		for _, c := range synthesis.Conflicts {
+			if c.UserResolution == semantic.UserResolutionSkip {
+				manualEdits++
+			}
+		}
+	}
+	return synthesizeAllFilesWithManualCount(ctx, config, conflictingFiles, synthesesByFile, jsonResult, opType, manualEdits)
+}
+
+// synthesizeFiles synthesizes all semantic files using the configured number
+// of jobs and returns the results keyed by file
+func synthesizeFiles(config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis) map[string]*semantic.SynthesisResult {
+	var files []string
+	var analyses []*semantic.SynthesisAnalysis
+	for _, file := range conflictingFiles {
+		if synthesis, ok := synthesesByFile[file]; ok {
+			files = append(files, file)
+			analyses = append(analyses, synthesis)
+		}
+	}
+
+	if emitter != nil {
+		config.OnSynthesized = func(result *semantic.SynthesisResult) {
+			if result.Written {
+				emitter.Emit(events.Event{Event: events.FileWritten, File: result.File})
+			}
+			if result.Staged {
+				emitter.Emit(events.Event{Event: events.FileStaged, File: result.File})
+			}
+		}
+	}
+	results := semantic.SynthesizeFiles(analyses, config)
+
+	resultsByFile := make(map[string]*semantic.SynthesisResult, len(files))
+	for i, file := range files {
+		resultsByFile[file] = results[i]
+	}
+	return resultsByFile
+}
+
 // synthesizeAllFiles runs synthesis on all conflicting files
 func synthesizeAllFiles(ctx context.Context, config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis, jsonResult *output.MergeResult, opType OperationType) int {
 	return synthesizeAllFilesWithManualCount(ctx, config, conflictingFiles, synthesesByFile, jsonResult, opType, 0)
@@ -1125,29 +2352,23 @@ func synthesizeAllFiles(ctx context.Context, config semantic.MergeConfig, confli
 func synthesizeAllFilesWithManualCount(ctx context.Context, config semantic.MergeConfig, conflictingFiles []string, synthesesByFile map[string]*semantic.SynthesisAnalysis, jsonResult *output.MergeResult, opType OperationType, manualEdits int) int {
 	allAutoMerged := true
 	filesWithMarkers := 0
+	resultsByFile := synthesizeFiles(config, conflictingFiles, synthesesByFile)
+	printExplanations(config, conflictingFiles, resultsByFile)
+	recordAudit(ctx, config, opType, conflictingFiles, resultsByFile)
 
 	for _, file := range conflictingFiles {
 		if semantic.IsSemanticFile(file) {
-			synthesis := synthesesByFile[file]
-			result := semantic.SynthesizeFile(synthesis, config)
+			result := resultsByFile[file]
 
-			if config.JSONOutput && jsonResult != nil {
-				fileResult := output.FileResult{
-					File:          file,
-					ConflictCount: result.ConflictCount,
-					ResolvedCount: result.AutoMergeCount,
-					AllAutoMerged: result.AllAutoMerged,
-					HasMarkers:    !result.AllAutoMerged && result.Success,
-				}
-				if result.Error != nil {
-					fileResult.Error = result.Error.Error()
-				}
-				jsonResult.AddFileResult(fileResult)
+			if jsonResult != nil {
+				jsonResult.AddFileResult(fileResult(file, result, jsonResult.ImportEdits))
 			}
 
 			if result.Error != nil {
 				logging.Warn("failed to synthesize file", "file", file, "error", result.Error)
-				ui.Warning(fmt.Sprintf("Failed to synthesize %s: %v", file, result.Error))
+				if !config.JSONOutput {
+					ui.Warning(fmt.Sprintf("Failed to synthesize %s: %v", file, result.Error))
+				}
 				allAutoMerged = false
 			} else if !result.AllAutoMerged {
 				allAutoMerged = false
@@ -1155,42 +2376,115 @@ func synthesizeAllFilesWithManualCount(ctx context.Context, config semantic.Merg
 			}
 		} else {
 			allAutoMerged = false
-			if config.JSONOutput && jsonResult != nil {
+			if jsonResult != nil {
 				jsonResult.AddFileResult(output.FileResult{
 					File:          file,
 					ConflictCount: 1,
 					HasMarkers:    true,
+					Conflicts:     []output.ConflictDetail{},
 				})
 			}
 		}
 	}
 
-	fmt.Println()
+	if !config.JSONOutput {
+		fmt.Println()
+	}
 
 	if allAutoMerged {
-		if config.JSONOutput && jsonResult != nil {
+		if jsonResult != nil {
 			jsonResult.Finalize()
-			output.WriteJSONStdout(jsonResult)
+			writeResult(config, jsonResult)
 		}
-		ui.Success("All conflicts resolved and staged!")
-		if opType != OpMerge {
-			ui.Info(fmt.Sprintf("Run 'g2 continue' to finish the %s", opType.String()))
+		if !config.JSONOutput {
+			ui.Success("All conflicts resolved and staged!")
+			if opType != OpMerge {
+				ui.Info(fmt.Sprintf("Run 'g2 continue' to finish the %s", opType.String()))
+			}
 		}
 		return exitcode.Success
 	}
 
 	// If user marked some for manual editing, provide helpful message
-	if manualEdits > 0 {
+	if !config.JSONOutput && manualEdits > 0 {
 		ui.Info(fmt.Sprintf("%d conflict(s) left for manual editing", manualEdits))
 		ui.Info("Edit the files to resolve conflict markers, then run 'g2 continue'")
-	} else if filesWithMarkers > 0 {
+	} else if !config.JSONOutput && filesWithMarkers > 0 {
 		ui.Info(fmt.Sprintf("%d file(s) have conflict markers - resolve manually", filesWithMarkers))
 		ui.Info(fmt.Sprintf("Run 'g2 continue' to continue after resolving, or 'g2 abort' to cancel"))
 	}
 
-	if config.JSONOutput && jsonResult != nil {
+	if jsonResult != nil {
 		jsonResult.Finalize()
-		output.WriteJSONStdout(jsonResult)
+		writeResult(config, jsonResult)
 	}
 	return exitcode.ConflictsRemain
 }
+
+// rpcCommand runs `g2 rpc` (also `g2 lsp`): the rpc.Server on stdio
+func rpcCommand(args []string) int {
+	ctx := context.Background()
+	if !isGitRepo(ctx) {
+		fmt.Fprintln(os.Stderr, "g2 rpc: not a git repository")
+		return exitcode.GitError
+	}
+	config := parseGlobalConfig(args)
+	config.JSONOutput = true // stdout carries the protocol
+	initConfig(config)
+
+	root, err := getRepoRoot(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "g2 rpc: %v\n", err)
+		return exitcode.GitError
+	}
+	conn := rpc.NewConn(os.Stdin, os.Stdout)
+	server := rpc.NewServer(ctx, rpcBackend{config: config}, conn, root, Version)
+	if err := server.Load(); err != nil {
+		logging.Warn("failed to load conflicts", "error", err)
+	}
+	if err := rpc.Serve(conn, server.Handle); err != nil {
+		fmt.Fprintf(os.Stderr, "g2 rpc: %v\n", err)
+		return exitcode.GitError
+	}
+	return exitcode.Success
+}
+
+// rpcBackend drives the rpc server with the conflicts of the operation in
+// progress
+type rpcBackend struct {
+	config semantic.MergeConfig
+}
+
+// Load analyzes the conflicting files of the repository
+func (b rpcBackend) Load(ctx context.Context) ([]string, map[string]*semantic.SynthesisAnalysis, error) {
+	files, err := semantic.GetConflictingFilesWithContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	syntheses, _ := analyzeConflicts(ctx, b.config, files)
+	return files, syntheses, nil
+}
+
+// Detail converts a conflict like JSON output does
+func (b rpcBackend) Detail(c *semantic.SynthesisConflict) output.ConflictDetail {
+	return conflictDetail(c, nil)
+}
+
+// Resolve applies a resolution chosen in the editor
+func (b rpcBackend) Resolve(c *semantic.SynthesisConflict, resolution string) error {
+	return applyResolution(c, resolution, semantic.ResolvedByEditor)
+}
+
+// Write synthesizes and stages files and records them in the audit log
+func (b rpcBackend) Write(ctx context.Context, files []string, syntheses map[string]*semantic.SynthesisAnalysis) map[string]*semantic.SynthesisResult {
+	resultsByFile := synthesizeFiles(b.config, files, syntheses)
+	if op := detectInProgressOperation(); op != nil {
+		recordAudit(ctx, b.config, op.Type, files, resultsByFile)
+	}
+	return resultsByFile
+}
+
+// FileResult converts a file result like JSON output does
+func (b rpcBackend) FileResult(file string, result *semantic.SynthesisResult) output.FileResult {
+	return fileResult(file, result, nil)
+}
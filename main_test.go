@@ -5,11 +5,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/simonkoeck/g2/pkg/audit"
+	"github.com/simonkoeck/g2/pkg/events"
 	"github.com/simonkoeck/g2/pkg/exitcode"
 	"github.com/simonkoeck/g2/pkg/git"
 	"github.com/simonkoeck/g2/pkg/output"
+	"github.com/simonkoeck/g2/pkg/rpc"
+	"github.com/simonkoeck/g2/pkg/semantic"
+	"github.com/simonkoeck/g2/pkg/tui"
 )
 
 // mockExitError is a mock error that mimics exec.ExitError with a specific exit code
@@ -390,6 +402,58 @@ func TestMergeResultJSON(t *testing.T) {
 	}
 }
 
+func TestConflictDetail(t *testing.T) {
+	base := &semantic.Definition{Name: "helper", Kind: "function", StartLine: 3, EndLine: 5, StartByte: 40, EndByte: 90}
+	moved := &semantic.Definition{Name: "helper", Kind: "function", StartLine: 0, EndLine: 2, StartByte: 0, EndByte: 50}
+	edits := []output.ImportEdit{
+		{File: "main.py", Line: 1, Definition: "helper", From: "utils", To: "helpers", Applied: true},
+		{File: "main.py", Line: 2, Definition: "other", From: "utils", To: "helpers", Applied: true},
+	}
+
+	source := semantic.SynthesisConflict{
+		Base: base,
+		Move: &semantic.MoveInfo{SourceFile: "utils.py", DestFile: "helpers.py", SourceName: "helper", DestName: "helper", MatchType: "Exact Match", Similarity: 1},
+	}
+	source.UIConflict.Status = "Can Auto-merge"
+	detail := conflictDetail(&source, edits)
+	if detail.Name != "helper" || detail.Kind != "function" || detail.Resolution != "auto" || detail.Source != "auto" {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+	if *detail.Base != (output.Range{StartLine: 4, EndLine: 6, StartByte: 40, EndByte: 90}) || detail.Local != nil {
+		t.Errorf("unexpected ranges: base %+v, local %+v", detail.Base, detail.Local)
+	}
+	if detail.Move == nil || detail.Move.ToFile != "helpers.py" || len(detail.Move.ImportEdits) != 1 || detail.Move.ImportEdits[0].Line != 1 {
+		t.Errorf("unexpected move: %+v", detail.Move)
+	}
+
+	// A move within a file makes no import edits
+	source.Move.DestFile = "utils.py"
+	if detail := conflictDetail(&source, edits); len(detail.Move.ImportEdits) != 0 {
+		t.Errorf("expected no import edits, got %+v", detail.Move.ImportEdits)
+	}
+
+	tests := []struct {
+		status     string
+		resolution semantic.UserResolution
+		resolvedBy string
+		want       [2]string // resolution, source
+	}{
+		{"Needs Resolution", semantic.UserResolutionNone, "", [2]string{"none", ""}},
+		{"Needs Resolution", semantic.UserResolutionRemote, semantic.ResolvedByTUI, [2]string{"remote", "tui"}},
+		{"Needs Resolution", semantic.UserResolutionSkip, semantic.ResolvedByTUI, [2]string{"skip", "tui"}},
+		{"Resolved by Policy", semantic.UserResolutionLocal, semantic.ResolvedByPolicy, [2]string{"local", "policy"}},
+		{"Resolved by Policy", semantic.UserResolutionNone, semantic.ResolvedByPolicy, [2]string{"regenerate", "policy"}},
+	}
+	for _, tt := range tests {
+		c := semantic.SynthesisConflict{Local: moved, UserResolution: tt.resolution, ResolvedBy: tt.resolvedBy}
+		c.UIConflict.Status = tt.status
+		detail := conflictDetail(&c, nil)
+		if got := [2]string{detail.Resolution, detail.Source}; got != tt.want {
+			t.Errorf("%s/%s: expected %v, got %v", tt.status, tt.resolution, tt.want, got)
+		}
+	}
+}
+
 // ==================== Operation Type Tests ====================
 
 func TestOperationType_String(t *testing.T) {
@@ -487,3 +551,685 @@ func TestPassthroughNotCalledForCherryPick(t *testing.T) {
 		t.Errorf("expected exit code %d, got %d", exitcode.Success, exitCode)
 	}
 }
+
+// ==================== Git Global Option Tests ====================
+
+func TestParseGitGlobalOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantDir  string
+		wantArgs []string
+		wantRest []string
+	}{
+		{
+			name:     "no options",
+			args:     []string{"merge", "feature"},
+			wantRest: []string{"merge", "feature"},
+		},
+		{
+			name:     "-C directory",
+			args:     []string{"-C", "../svc", "merge", "feature"},
+			wantDir:  "../svc",
+			wantRest: []string{"merge", "feature"},
+		},
+		{
+			name:     "repeated -C is cumulative",
+			args:     []string{"-C", "repos", "-C", "svc", "rebase", "main"},
+			wantDir:  filepath.Join("repos", "svc"),
+			wantRest: []string{"rebase", "main"},
+		},
+		{
+			name:     "absolute -C resets",
+			args:     []string{"-C", "repos", "-C", "/abs/svc", "status"},
+			wantDir:  "/abs/svc",
+			wantRest: []string{"status"},
+		},
+		{
+			name:     "config values",
+			args:     []string{"-c", "merge.conflictStyle=diff3", "merge", "x"},
+			wantArgs: []string{"-c", "merge.conflictStyle=diff3"},
+			wantRest: []string{"merge", "x"},
+		},
+		{
+			name:     "git-dir and work-tree in both forms",
+			args:     []string{"--git-dir", "../repo.git", "--work-tree=..", "rebase", "main"},
+			wantArgs: []string{"--git-dir=../repo.git", "--work-tree=.."},
+			wantRest: []string{"rebase", "main"},
+		},
+		{
+			name:     "flag options",
+			args:     []string{"--no-pager", "-P", "--literal-pathspecs", "cherry-pick", "abc"},
+			wantArgs: []string{"--no-pager", "-P", "--literal-pathspecs"},
+			wantRest: []string{"cherry-pick", "abc"},
+		},
+		{
+			name:     "only global options",
+			args:     []string{"-C", "svc"},
+			wantDir:  "svc",
+			wantRest: []string{},
+		},
+		{
+			name:     "unknown option stops parsing",
+			args:     []string{"--version"},
+			wantRest: []string{"--version"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, rest, err := parseGitGlobalOptions(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Dir != tt.wantDir {
+				t.Errorf("Dir = %q, want %q", opts.Dir, tt.wantDir)
+			}
+			if !reflect.DeepEqual(opts.Args, tt.wantArgs) {
+				t.Errorf("Args = %q, want %q", opts.Args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestParseGitGlobalOptions_MissingValue(t *testing.T) {
+	for _, args := range [][]string{{"-C"}, {"-c"}, {"--git-dir"}} {
+		if _, _, err := parseGitGlobalOptions(args); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
+
+func TestIsG2Command(t *testing.T) {
+	if !isG2Command(nil) {
+		t.Error("empty command should be handled by g2")
+	}
+	for _, cmd := range []string{"merge", "rebase", "cherry-pick", "continue", "abort", "status", "merge-driver", "cache", "log", "rpc", "lsp"} {
+		if !isG2Command([]string{cmd}) {
+			t.Errorf("%s should be handled by g2", cmd)
+		}
+	}
+	if isG2Command([]string{"diff"}) {
+		t.Error("diff should be passed through to git")
+	}
+}
+
+func TestApplyGitGlobalOptions(t *testing.T) {
+	oldExec, oldOpts := gitExec, gitGlobalOpts
+	oldWd, _ := os.Getwd()
+	defer func() {
+		gitExec, gitGlobalOpts = oldExec, oldOpts
+		semantic.SetGitExecutor(oldExec)
+		os.Chdir(oldWd)
+	}()
+
+	dir := t.TempDir()
+	opts := gitGlobalOptions{Dir: dir, Args: []string{"-c", "core.quotePath=false"}}
+	if err := applyGitGlobalOptions(opts); err != nil {
+		t.Fatalf("applyGitGlobalOptions failed: %v", err)
+	}
+
+	wd, _ := os.Getwd()
+	if resolved, _ := filepath.EvalSymlinks(dir); wd != dir && wd != resolved {
+		t.Errorf("working directory = %q, want %q", wd, dir)
+	}
+
+	executor, ok := gitExec.(*git.DefaultExecutor)
+	if !ok {
+		t.Fatalf("expected *git.DefaultExecutor, got %T", gitExec)
+	}
+	if executor.Dir != dir {
+		t.Errorf("executor Dir = %q, want %q", executor.Dir, dir)
+	}
+	if !reflect.DeepEqual(executor.GlobalArgs, opts.Args) {
+		t.Errorf("executor GlobalArgs = %q, want %q", executor.GlobalArgs, opts.Args)
+	}
+
+	// Custom timeouts must keep the global options
+	if timed := newGitExecutor(time.Minute); !reflect.DeepEqual(timed.GlobalArgs, opts.Args) {
+		t.Errorf("timeout executor lost GlobalArgs: %q", timed.GlobalArgs)
+	}
+
+	if err := applyGitGlobalOptions(gitGlobalOptions{Dir: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected error for missing -C directory")
+	}
+}
+
+// TestParseGlobalConfig_Jobs tests parsing and filtering of --jobs
+func TestParseGlobalConfig_Jobs(t *testing.T) {
+	config := parseGlobalConfig([]string{"feature", "--jobs=3"})
+	if config.Jobs != 3 {
+		t.Errorf("expected Jobs=3, got %d", config.Jobs)
+	}
+
+	defaultJobs := semantic.DefaultMergeConfig().Jobs
+	for _, arg := range []string{"--jobs=0", "--jobs=-2", "--jobs=many"} {
+		if got := parseGlobalConfig([]string{arg}).Jobs; got != defaultJobs {
+			t.Errorf("%s: expected default %d, got %d", arg, defaultJobs, got)
+		}
+	}
+
+	if got := filterG2Flags([]string{"feature", "--jobs=3"}); !reflect.DeepEqual(got, []string{"feature"}) {
+		t.Errorf("expected --jobs to be filtered, got %v", got)
+	}
+}
+
+func TestParseGlobalConfig_Format(t *testing.T) {
+	tests := map[string]struct {
+		args   []string
+		format string
+	}{
+		"json flag":         {[]string{"--json"}, "json"},
+		"sarif":             {[]string{"--format=sarif"}, "sarif"},
+		"format after json": {[]string{"--json", "--format=junit"}, "junit"},
+		"json after format": {[]string{"--format=junit", "--json"}, "junit"},
+	}
+	for name, tt := range tests {
+		config := parseGlobalConfig(tt.args)
+		if !config.JSONOutput || config.Format != tt.format {
+			t.Errorf("%s: expected machine-readable %s output, got %v/%q", name, tt.format, config.JSONOutput, config.Format)
+		}
+	}
+
+	if got := filterG2Flags([]string{"main", "--format=sarif"}); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("expected --format to be filtered, got %v", got)
+	}
+
+	mock := git.NewMockExecutor()
+	if code := SmartMergeWithExecutor([]string{"merge", "--format=xml", "main"}, mock); code != exitcode.GitError {
+		t.Errorf("expected an error for an unknown format, got exit code %d", code)
+	}
+	if calls := mock.Calls(); len(calls) != 0 {
+		t.Errorf("expected no git calls for an unknown format, got %v", calls)
+	}
+}
+
+// TestParseGlobalConfig_Resolve tests --resolve and --resolutions, with and
+// without "="
+func TestParseGlobalConfig_Resolve(t *testing.T) {
+	args := []string{"--resolve", "utils.py:calc=remote", "feature", "--resolve=utils.py:fmt=local", "--resolutions", "r.yaml", "--no-ff"}
+	config := parseGlobalConfig(args)
+	if !reflect.DeepEqual(config.Resolve, []string{"utils.py:calc=remote", "utils.py:fmt=local"}) || config.Resolutions != "r.yaml" {
+		t.Errorf("unexpected resolutions: %q, %q", config.Resolve, config.Resolutions)
+	}
+	if got := filterG2Flags(args); !reflect.DeepEqual(got, []string{"feature", "--no-ff"}) {
+		t.Errorf("expected resolution flags and values to be filtered, got %v", got)
+	}
+
+	// Invalid values fail before git runs
+	t.Cleanup(func() { emitter = nil })
+	for _, args := range [][]string{{"--resolve=utils.py=remote"}, {"--resolutions=" + filepath.Join(t.TempDir(), "missing.yaml")}} {
+		if err := checkOutputOptions(parseGlobalConfig(args)); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+
+	synthesesByFile := map[string]*semantic.SynthesisAnalysis{
+		"calc.py": semantic.AnalyzeConflictFromContents("calc.py",
+			[]byte("def calc(x):\n    return x\n"),
+			[]byte("def calc(x):\n    return x + 1\n"),
+			[]byte("def calc(x):\n    return x + 2\n")),
+	}
+	config = parseGlobalConfig([]string{"--resolve", "calc.py:calc=remote"})
+	if n, err := applyScriptedResolutions(config, []string{"calc.py", "data.bin"}, synthesesByFile); n != 1 || err != nil {
+		t.Fatalf("expected one resolved conflict, got %d, %v", n, err)
+	}
+	if c := synthesesByFile["calc.py"].Conflicts[0]; c.Resolution() != "remote" || c.ResolutionSource() != semantic.ResolvedByScript {
+		t.Errorf("unexpected resolution %s (%s)", c.Resolution(), c.ResolutionSource())
+	}
+	config = parseGlobalConfig([]string{"--resolve", "calc.py:other=remote"})
+	if _, err := applyScriptedResolutions(config, []string{"calc.py"}, synthesesByFile); err == nil || !strings.Contains(err.Error(), "calc.py:other matches no conflict") {
+		t.Errorf("expected an error for an unmatched resolution, got %v", err)
+	}
+}
+
+// TestParseGlobalConfig_Prefer tests the --prefer and --prefer-kind flags
+func TestParseGlobalConfig_Prefer(t *testing.T) {
+	args := []string{"--json", "--prefer=local", "feature", "--prefer-kind=key:remote", "--prefer-kind=method:remote"}
+	config := parseGlobalConfig(args)
+	if config.Prefer != "local" || !reflect.DeepEqual(config.PreferKinds, []string{"key:remote", "method:remote"}) {
+		t.Errorf("unexpected preference: %q, %q", config.Prefer, config.PreferKinds)
+	}
+	if got := filterG2Flags(args); !reflect.DeepEqual(got, []string{"feature"}) {
+		t.Errorf("expected preference flags to be filtered, got %v", got)
+	}
+	want := &output.Preference{Default: "local", Kinds: map[string]string{"key": "remote", "method": "remote"}}
+	if got := newResult(config).Prefer; !reflect.DeepEqual(got, want) {
+		t.Errorf("the preference should be recorded in the output, got %+v", got)
+	}
+	if got := newResult(parseGlobalConfig([]string{"--json"})).Prefer; got != nil {
+		t.Errorf("expected no preference in the output, got %+v", got)
+	}
+
+	t.Cleanup(func() { emitter = nil })
+	for _, args := range [][]string{{"--prefer=both"}, {"--prefer-kind=key"}} {
+		if err := checkOutputOptions(parseGlobalConfig(args)); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+
+	synthesesByFile := map[string]*semantic.SynthesisAnalysis{
+		"calc.py": semantic.AnalyzeConflictFromContents("calc.py",
+			[]byte("def calc(x):\n    return x\n"),
+			[]byte("def calc(x):\n    return x + 1\n"),
+			[]byte("def calc(x):\n    return x + 2\n")),
+	}
+	if n := applyPreference(parseGlobalConfig([]string{"--prefer=remote"}), []string{"calc.py"}, synthesesByFile); n != 1 {
+		t.Fatalf("expected one conflict resolved by preference, got %d", n)
+	}
+	if c := synthesesByFile["calc.py"].Conflicts[0]; c.Resolution() != "remote" || c.ResolutionSource() != semantic.ResolvedByPrefer {
+		t.Errorf("unexpected resolution %s (%s)", c.Resolution(), c.ResolutionSource())
+	}
+}
+
+// TestAuditLog tests recording resolutions and the g2 log command
+func TestAuditLog(t *testing.T) {
+	move := &semantic.SynthesisConflict{
+		Local: &semantic.Definition{Name: "calc_total", Kind: "function"},
+		Move:  &semantic.MoveInfo{SourceName: "calc", DestName: "calc_total"},
+	}
+	move.UIConflict.ConflictType = "Function 'calc' Renamed+Moved to 'calc_total' (Exact Match)"
+	move.UIConflict.Status = "Can Auto-merge"
+	picked := &semantic.SynthesisConflict{
+		Local:          &semantic.Definition{Name: "total", Kind: "function"},
+		UserResolution: semantic.UserResolutionRemote,
+		ResolvedBy:     semantic.ResolvedByTUI,
+	}
+	picked.UIConflict.ConflictType = "Function 'total' Modified"
+	picked.UIConflict.Status = "Needs Resolution"
+	unresolved := audit.Entry{File: "utils.py", Name: "other", Kind: "function", Type: "Function 'other' Modified", Resolution: "none"}
+
+	runs := []audit.Run{
+		{Operation: "merge", Head: "h1", MergeHead: "m1", Entries: []audit.Entry{auditEntry("utils.py", move), unresolved}},
+		{Operation: "merge", Head: "h1", MergeHead: "m1", Entries: []audit.Entry{auditEntry("utils.py", picked)}},
+		{Operation: "merge", Head: "h0", MergeHead: "m0", Entries: []audit.Entry{{File: "old.py", Type: "Text Conflict", Resolution: "none"}}},
+	}
+	if e := runs[0].Entries[0]; e.Name != "calc_total" || e.Kind != "function" || e.Resolution != "auto" || e.Class != "rename" {
+		t.Errorf("unexpected entry for a move: %+v", e)
+	}
+	if e := runs[1].Entries[0]; e.Resolution != "remote" || e.Source != "tui" || e.Class != "" {
+		t.Errorf("unexpected entry for a picked side: %+v", e)
+	}
+
+	// g2 log --g2 takes only its own options; anything else is git's log
+	if opts, ok := parseLogArgs([]string{"--g2", "--json", "-n", "2", "--file=utils.py"}); !ok || !opts.JSON || opts.Limit != 2 || !reflect.DeepEqual(opts.Paths, []string{"utils.py"}) {
+		t.Errorf("unexpected log options: %+v, %v", opts, ok)
+	}
+	for _, args := range [][]string{{}, {"-n", "5"}, {"--json"}, {"--oneline", "-5"}, {"main..feature"}, {"--g2", "-n", "x"}, {"--g2", "--oneline"}} {
+		if _, ok := parseLogArgs(args); ok {
+			t.Errorf("%q should be passed to git log", args)
+		}
+	}
+
+	// g2 log shows runs newest first, filtered by path
+	if got := selectRuns(runs, nil, 2); len(got) != 2 || got[0].Head != "h0" {
+		t.Errorf("unexpected selection: %+v", got)
+	}
+	if got := selectRuns(runs, []string{"utils.py"}, 0); len(got) != 2 || got[1].Entries[1].Name != "other" {
+		t.Errorf("unexpected selection for utils.py: %+v", got)
+	}
+	var buf bytes.Buffer
+	printRuns(&buf, selectRuns(runs, []string{"./old.py"}, 0))
+	if !strings.Contains(buf.String(), "merge of m0 onto h0  (0 of 1 resolved)") || !strings.Contains(buf.String(), "unresolved") {
+		t.Errorf("unexpected log output:\n%s", buf.String())
+	}
+}
+
+// TestExplain tests --explain parsing and converting a decision trace for output
+func TestExplain(t *testing.T) {
+	if config := parseGlobalConfig([]string{"--explain"}); !config.Explain {
+		t.Error("expected --explain to be parsed")
+	}
+	if got := filterG2Flags([]string{"--explain", "main"}); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("expected --explain to be filtered, got %v", got)
+	}
+
+	c := semantic.SynthesisConflict{
+		Base:  &semantic.Definition{Name: "calc", Kind: "function"},
+		Local: &semantic.Definition{Name: "calc", Kind: "function"},
+		Explain: &semantic.Explanation{
+			Rule:            "changed on both sides; normalized bodies differ",
+			BaseNormalized:  "def calc():\n    return 1",
+			LocalNormalized: "def calc():\n    return 2",
+			Move:            &semantic.MoveScore{Candidate: "calc", DeleteTokens: 12, AddTokens: 14, Similarity: 0.8, Threshold: 0.85},
+			Notes:           []string{"collision: overlaps a method"},
+		},
+	}
+	c.UIConflict.ConflictType = "Function 'calc' Modified"
+	c.UIConflict.Status = "Needs Resolution"
+
+	detail := conflictDetail(&c, nil)
+	if detail.Explain == nil || detail.Explain.Normalized.Remote != "" || detail.Explain.Move.Threshold != 0.85 {
+		t.Fatalf("unexpected explanation: %+v", detail.Explain)
+	}
+	if want := "0.800 < threshold 0.850 (12 and 14 tokens)"; detail.Explain.Move.Summary != want {
+		t.Errorf("move summary = %q, want %q", detail.Explain.Move.Summary, want)
+	}
+	if conflictDetail(&semantic.SynthesisConflict{}, nil).Explain != nil {
+		t.Error("conflicts without a trace should have no explanation")
+	}
+}
+
+// TestEvents tests the event stream options and resolving conflicts over the
+// command channel
+func TestEvents(t *testing.T) {
+	if config := parseGlobalConfig([]string{"--events=ndjson", "--commands=ndjson"}); !config.JSONOutput || config.Events != "ndjson" || config.Commands != "ndjson" {
+		t.Errorf("events on stdout should replace text output, got %+v", config)
+	}
+	if config := parseGlobalConfig([]string{"--events=ndjson", "--events-fd=3"}); config.JSONOutput || config.EventsFD != 3 {
+		t.Errorf("events on a file descriptor should keep text output, got %+v", config)
+	}
+	if config := parseGlobalConfig([]string{"--events=ndjson", "--events-fd=three"}); config.JSONOutput || config.EventsFD != -1 {
+		t.Errorf("an invalid descriptor should be marked and not fall back to stdout, got %+v", config)
+	}
+	if got := filterG2Flags([]string{"--events=ndjson", "--events-fd=3", "--commands=ndjson", "main"}); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("expected event flags to be filtered, got %v", got)
+	}
+	t.Cleanup(func() { emitter = nil })
+	for _, args := range [][]string{
+		{"--events=xml"},
+		{"--commands=ndjson"},
+		{"--events=ndjson", "--commands=json"},
+		{"--events=ndjson", "--events-fd=999"},
+		{"--events=ndjson", "--events-fd=three"},
+		{"--events=ndjson", "--events-fd=0"},
+		{"--events=ndjson", "--events-fd=-3"},
+	} {
+		if err := checkOutputOptions(parseGlobalConfig(args)); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+
+	oldExec, oldInput := gitExec, commandInput
+	oldWd, _ := os.Getwd()
+	defer func() {
+		gitExec, commandInput = oldExec, oldInput
+		semantic.SetGitExecutor(oldExec)
+		os.Chdir(oldWd)
+	}()
+	mock := git.NewMockExecutor()
+	mock.SetResponse("rev-parse", nil, errors.New("not a git repository"))
+	gitExec = mock
+	semantic.SetGitExecutor(mock)
+	os.Chdir(t.TempDir())
+
+	base := []byte("def calc(x):\n    return x\n")
+	local := []byte("def calc(x):\n    return x + 1\n")
+	remote := []byte("def calc(x):\n    return x + 2\n")
+	if err := os.WriteFile("calc.py", local, 0644); err != nil {
+		t.Fatal(err)
+	}
+	synthesesByFile := map[string]*semantic.SynthesisAnalysis{
+		"calc.py": semantic.AnalyzeConflictFromContents("calc.py", base, local, remote),
+	}
+
+	var buf bytes.Buffer
+	emitter = events.NewEmitter(&buf)
+	refs := emitAnalysis([]string{"calc.py", "data.bin"}, synthesesByFile)
+	if len(refs) != 1 {
+		t.Fatalf("expected one conflict id, got %v", refs)
+	}
+	commandInput = strings.NewReader(`not json
+{"command":"resolve","id":9,"resolution":"remote"}
+{"command":"resolve","id":1,"resolution":"sideways"}
+{"command":"resolve","id":1,"resolution":"remote"}
+{"command":"done"}
+`)
+	config := semantic.DefaultMergeConfig()
+	config.JSONOutput = true
+	config.CreateBackup = false
+	code := resolveWithCommands(context.Background(), config, []string{"calc.py"}, synthesesByFile, refs, nil, OpMerge)
+	if code != exitcode.Success {
+		t.Errorf("expected success, got exit code %d", code)
+	}
+	if merged, _ := os.ReadFile("calc.py"); string(merged) != string(remote) {
+		t.Errorf("expected the remote version, got:\n%s", merged)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e events.Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		switch e.Event {
+		case events.ConflictClassified:
+			got = append(got, fmt.Sprintf("%s %s %d", e.Event, e.File, e.ID))
+		case events.AwaitingUser:
+			got = append(got, fmt.Sprintf("%s %v", e.Event, e.Pending))
+		case events.ResolutionAccepted:
+			got = append(got, fmt.Sprintf("%s %d %s/%s", e.Event, e.ID, e.Conflict.Resolution, e.Conflict.Source))
+		default:
+			got = append(got, e.Event+" "+e.File)
+		}
+	}
+	want := []string{
+		"conflict_classified calc.py 1",
+		"file_analyzed data.bin",
+		"conflict_classified data.bin 0",
+		"awaiting_user [1]",
+		"command_error ",
+		"command_error ",
+		"command_error ",
+		"resolution_accepted 1 remote/command",
+		"file_written calc.py",
+		"file_staged calc.py",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+// TestInteractivePrompt tests resolving conflicts with --interactive=prompt
+func TestInteractivePrompt(t *testing.T) {
+	if config := parseGlobalConfig([]string{"--interactive=prompt"}); config.Interactive != tui.ModePrompt {
+		t.Errorf("unexpected interactive mode %q", config.Interactive)
+	}
+	if got := filterG2Flags([]string{"--interactive=prompt", "main"}); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("expected --interactive to be filtered, got %v", got)
+	}
+	t.Cleanup(func() { emitter = nil })
+	if err := checkOutputOptions(parseGlobalConfig([]string{"--interactive=curses"})); err == nil {
+		t.Error("expected an error for an unknown interactive mode")
+	}
+
+	oldExec, oldInput, oldEdit := gitExec, commandInput, editConflict
+	oldWd, _ := os.Getwd()
+	defer func() {
+		gitExec, commandInput, editConflict = oldExec, oldInput, oldEdit
+		semantic.SetGitExecutor(oldExec)
+		os.Chdir(oldWd)
+	}()
+	mock := git.NewMockExecutor()
+	mock.SetResponse("rev-parse", nil, errors.New("not a git repository"))
+	gitExec = mock
+	semantic.SetGitExecutor(mock)
+	os.Chdir(t.TempDir())
+
+	base := []byte("def calc(x):\n    return x\n\n\ndef fmt(x):\n    return str(x)\n")
+	local := []byte("def calc(x):\n    return x + 1\n\n\ndef fmt(x):\n    return repr(x)\n")
+	remote := []byte("def calc(x):\n    return x + 2\n\n\ndef fmt(x):\n    return format(x)\n")
+	if err := os.WriteFile("calc.py", local, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := semantic.DefaultMergeConfig()
+	config.JSONOutput = true
+	config.CreateBackup = false
+	config.Interactive = tui.ModePrompt
+
+	// Closing the input aborts without writing
+	synthesesByFile := map[string]*semantic.SynthesisAnalysis{
+		"calc.py": semantic.AnalyzeConflictFromContents("calc.py", base, local, remote),
+	}
+	commandInput = strings.NewReader("r\n")
+	if code := launchConflictTUI(context.Background(), config, []string{"calc.py"}, synthesesByFile, nil, OpMerge); code != exitcode.ConflictsRemain {
+		t.Errorf("expected conflicts to remain, got exit code %d", code)
+	}
+
+	editConflict = func(c tui.ConflictItem) (string, error) {
+		return "def fmt(x):\n    return f\"{x}\"\n", nil
+	}
+	synthesesByFile = map[string]*semantic.SynthesisAnalysis{
+		"calc.py": semantic.AnalyzeConflictFromContents("calc.py", base, local, remote),
+	}
+	commandInput = strings.NewReader("r\ne\n")
+	if code := launchConflictTUI(context.Background(), config, []string{"calc.py"}, synthesesByFile, nil, OpMerge); code != exitcode.Success {
+		t.Errorf("expected success, got exit code %d", code)
+	}
+	want := "def calc(x):\n    return x + 2\n\n\ndef fmt(x):\n    return f\"{x}\"\n"
+	if merged, _ := os.ReadFile("calc.py"); string(merged) != want {
+		t.Errorf("unexpected merge:\n%s\nwant:\n%s", merged, want)
+	}
+	for _, c := range synthesesByFile["calc.py"].Conflicts {
+		if c.ResolutionSource() != semantic.ResolvedByTUI {
+			t.Errorf("%s: expected the TUI source, got %s", c.Local.Name, c.ResolutionSource())
+		}
+	}
+}
+
+// TestRPC tests that g2 rpc wires the server to the merge machinery:
+// resolutions chosen in the editor are recorded as such and written
+func TestRPC(t *testing.T) {
+	oldExec := gitExec
+	oldWd, _ := os.Getwd()
+	defer func() {
+		gitExec = oldExec
+		semantic.SetGitExecutor(oldExec)
+		os.Chdir(oldWd)
+	}()
+	mock := git.NewMockExecutor()
+	mock.SetResponse("rev-parse", nil, errors.New("not a git repository"))
+	gitExec = mock
+	semantic.SetGitExecutor(mock)
+	os.Chdir(t.TempDir())
+	root, _ := os.Getwd()
+
+	base := []byte("def calc(x):\n    return x\n")
+	local := []byte("def calc(x):\n    return x + 1\n")
+	remote := []byte("def calc(x):\n    return x + 2\n")
+	if err := os.WriteFile("calc.py", local, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := semantic.DefaultMergeConfig()
+	config.JSONOutput = true
+	config.CreateBackup = false
+	backend := fixedRPCBackend{
+		rpcBackend: rpcBackend{config: config},
+		files:      []string{"calc.py"},
+		syntheses: map[string]*semantic.SynthesisAnalysis{
+			"calc.py": semantic.AnalyzeConflictFromContents("calc.py", base, local, remote),
+		},
+	}
+
+	var in bytes.Buffer
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"workspace/executeCommand","params":{"command":"g2.resolve","arguments":[1,"remote"]}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"g2/write","params":{}}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	var out bytes.Buffer
+	conn := rpc.NewConn(&in, &out)
+	server := rpc.NewServer(context.Background(), backend, conn, root, Version)
+	if err := server.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rpc.Serve(conn, server.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(map[string]*rpc.Message)
+	reader := rpc.NewConn(&out, nil)
+	for {
+		msg, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses[string(msg.ID)] = msg
+	}
+	var conflict rpc.Conflict
+	if msg := responses["2"]; msg == nil || msg.Error != nil || json.Unmarshal(msg.Result, &conflict) != nil {
+		t.Fatalf("resolve failed: %+v", msg)
+	}
+	if conflict.Resolution != "remote" || conflict.Source != "editor" || conflict.Name != "calc" {
+		t.Errorf("unexpected resolution: %+v", conflict)
+	}
+	var written []output.FileResult
+	if msg := responses["3"]; msg == nil || msg.Error != nil || json.Unmarshal(msg.Result, &written) != nil {
+		t.Fatalf("write failed: %+v", msg)
+	}
+	if len(written) != 1 || !written[0].AllAutoMerged || written[0].HasMarkers || written[0].ResolvedCount != 1 {
+		t.Errorf("unexpected write result: %+v", written)
+	}
+	if merged, _ := os.ReadFile("calc.py"); string(merged) != string(remote) {
+		t.Errorf("expected the remote version on disk, got:\n%s", merged)
+	}
+}
+
+// fixedRPCBackend serves fixed conflicts to the rpc server
+type fixedRPCBackend struct {
+	rpcBackend
+	files     []string
+	syntheses map[string]*semantic.SynthesisAnalysis
+}
+
+func (b fixedRPCBackend) Load(ctx context.Context) ([]string, map[string]*semantic.SynthesisAnalysis, error) {
+	return b.files, b.syntheses, nil
+}
+
+func TestStageTrackedFiles(t *testing.T) {
+	oldExec := gitExec
+	defer func() { gitExec = oldExec }()
+	mock := git.NewMockExecutor()
+	mock.SetResponse("-C", []byte("app/main.py\x00"), nil)
+	var added []string
+	mock.OnRun = func(ctx context.Context, args []string) error {
+		added = args
+		return nil
+	}
+	gitExec = mock
+
+	stageTrackedFiles(context.Background(), "/repo", []string{"app/main.py", "app/scratch.py"})
+
+	if want := []string{"-C", "/repo", "add", "--", "app/main.py"}; strings.Join(added, " ") != strings.Join(want, " ") {
+		t.Errorf("git add args = %v, want %v", added, want)
+	}
+}
+
+// TestAddMessageTrailers tests that trailers are added to the message file
+// of a rebase or cherry-pick, and that a missing file is left alone
+func TestAddMessageTrailers(t *testing.T) {
+	oldExec := gitExec
+	defer func() { gitExec = oldExec }()
+	mock := git.NewMockExecutor()
+	var calls [][]string
+	mock.OnRun = func(ctx context.Context, args []string) error {
+		calls = append(calls, args)
+		return nil
+	}
+	gitExec = mock
+
+	file := filepath.Join(t.TempDir(), "MERGE_MSG")
+	addMessageTrailers(context.Background(), file, []string{"G2-Resolved: utils.py:total (remote, tui)"})
+	if len(calls) != 0 {
+		t.Fatalf("a missing message file should be left alone, got %v", calls)
+	}
+
+	if err := os.WriteFile(file, []byte("feat: change\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	addMessageTrailers(context.Background(), file, nil)
+	addMessageTrailers(context.Background(), file, []string{"G2-Resolved: utils.py:total (remote, tui)"})
+	want := []string{"interpret-trailers", "--in-place", "--if-exists", "addIfDifferent", "--trailer", "G2-Resolved: utils.py:total (remote, tui)", file}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0], want) {
+		t.Errorf("git calls = %v, want %v", calls, want)
+	}
+}
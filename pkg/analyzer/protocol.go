@@ -0,0 +1,74 @@
+// Package analyzer defines the protocol between G2 and external language
+// analyzers.
+//
+// An analyzer is an executable configured for a set of paths. For each file
+// version G2 needs to parse, it runs the analyzer once, writes a Request as
+// JSON to its stdin and reads a Response as JSON from its stdout. The
+// analyzer exits with status 0 even when the file cannot be parsed, and
+// reports that in Response.Error instead. Anything written to stderr is
+// shown when the analyzer fails.
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ProtocolVersion is the version of the protocol sent in every request.
+// Analyzers should reject versions they do not know.
+const ProtocolVersion = 1
+
+// Request is sent to the analyzer on stdin.
+type Request struct {
+	Protocol int    `json:"protocol"`
+	Content  string `json:"content"` // File content, UTF-8
+}
+
+// Response is read from the analyzer's stdout.
+type Response struct {
+	Definitions []Definition `json:"definitions"`
+	Error       string       `json:"error,omitempty"` // Set if the file cannot be parsed
+}
+
+// Definition is a top-level definition in the file. Byte offsets index into
+// Request.Content; G2 takes the body and line numbers from that range.
+type Definition struct {
+	Name       string `json:"name"`                 // Unique within the file, e.g. "Class.method"
+	Kind       string `json:"kind"`                 // e.g. "function", "class", "section"
+	Signature  string `json:"signature,omitempty"`  // Declaration line, used for display
+	StartByte  int    `json:"start_byte"`           // First byte of the definition
+	EndByte    int    `json:"end_byte"`             // Byte after the definition
+	Normalized string `json:"normalized,omitempty"` // Body with comments and formatting removed; equal forms are treated as the same code
+}
+
+// Run implements an analyzer in Go: it reads a request from stdin, calls
+// analyze and writes the response to stdout. Errors returned by analyze are
+// reported in Response.Error. Run exits the process on protocol errors.
+func Run(analyze func(content []byte) ([]Definition, error)) {
+	if err := Serve(os.Stdin, os.Stdout, analyze); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Serve handles a single request read from r and writes the response to w
+func Serve(r io.Reader, w io.Writer, analyze func(content []byte) ([]Definition, error)) error {
+	var req Request
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Protocol != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d (want %d)", req.Protocol, ProtocolVersion)
+	}
+
+	resp := Response{Definitions: []Definition{}}
+	defs, err := analyze([]byte(req.Content))
+	if err != nil {
+		resp.Error = err.Error()
+	} else if defs != nil {
+		resp.Definitions = defs
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
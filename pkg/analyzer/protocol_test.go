@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProtocolRoundTrip tests that requests and responses survive encoding
+// and decoding, with the field names analyzers rely on
+func TestProtocolRoundTrip(t *testing.T) {
+	req := Request{Protocol: ProtocolVersion, Content: "[server]\nport = 80\n"}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"protocol":1,"content":"[server]\nport = 80\n"}`; string(data) != want {
+		t.Errorf("request = %s, want %s", data, want)
+	}
+	var gotReq Request
+	if err := json.Unmarshal(data, &gotReq); err != nil || gotReq != req {
+		t.Errorf("request round trip = %+v (%v), want %+v", gotReq, err, req)
+	}
+
+	resp := Response{Definitions: []Definition{
+		{Name: "server", Kind: "section", Signature: "[server]", StartByte: 0, EndByte: 19, Normalized: "[server]\nport=80"},
+		{Name: "client", Kind: "section", StartByte: 19, EndByte: 28},
+	}}
+	data, err = json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"definitions"`, `"name"`, `"kind"`, `"signature"`, `"start_byte"`, `"end_byte"`, `"normalized"`} {
+		if !bytes.Contains(data, []byte(key)) {
+			t.Errorf("response should contain %s: %s", key, data)
+		}
+	}
+	if bytes.Contains(data, []byte(`"error"`)) {
+		t.Errorf("an empty error should be omitted: %s", data)
+	}
+	var gotResp Response
+	if err := json.Unmarshal(data, &gotResp); err != nil || !reflect.DeepEqual(gotResp, resp) {
+		t.Errorf("response round trip = %+v (%v), want %+v", gotResp, err, resp)
+	}
+}
+
+// TestServe tests handling one request
+func TestServe(t *testing.T) {
+	request := `{"protocol": 1, "content": "a = 1\n"}`
+	serve := func(input string, analyze func([]byte) ([]Definition, error)) (Response, error) {
+		var out bytes.Buffer
+		if err := Serve(strings.NewReader(input), &out, analyze); err != nil {
+			return Response{}, err
+		}
+		var resp Response
+		if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", out.String(), err)
+		}
+		return resp, nil
+	}
+
+	t.Run("definitions", func(t *testing.T) {
+		var content string
+		resp, err := serve(request, func(b []byte) ([]Definition, error) {
+			content = string(b)
+			return []Definition{{Name: "a", Kind: "key", EndByte: 5}}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != "a = 1\n" {
+			t.Errorf("analyze got %q", content)
+		}
+		if len(resp.Definitions) != 1 || resp.Definitions[0].Name != "a" || resp.Error != "" {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("no definitions", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Serve(strings.NewReader(request), &out, func([]byte) ([]Definition, error) { return nil, nil }); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(out.String()); got != `{"definitions":[]}` {
+			t.Errorf("response = %s, want an empty list", got)
+		}
+	})
+
+	t.Run("analyze error", func(t *testing.T) {
+		resp, err := serve(request, func([]byte) ([]Definition, error) { return nil, errors.New("line 1: bad key") })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "line 1: bad key" || len(resp.Definitions) != 0 {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("protocol errors", func(t *testing.T) {
+		for _, input := range []string{`not json`, `{"protocol": 2, "content": ""}`, `{"content": ""}`} {
+			if _, err := serve(input, func([]byte) ([]Definition, error) { return nil, nil }); err == nil {
+				t.Errorf("%s: expected an error", input)
+			}
+		}
+	})
+}
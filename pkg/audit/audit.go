@@ -0,0 +1,159 @@
+// Package audit records how g2 resolved the conflicts of each run, so that
+// automatic resolutions can be traced after the fact.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Commit message trailers for resolved conflicts
+const (
+	TrailerAutoMerged = "G2-Auto-Merged"
+	TrailerResolved   = "G2-Resolved"
+)
+
+// maxLineSize bounds one run in the log
+const maxLineSize = 16 * 1024 * 1024
+
+// Run is one g2 run that synthesized conflicting files.
+type Run struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`            // merge, rebase or cherry-pick
+	Head      string    `json:"head,omitempty"`       // HEAD when the run resolved the conflicts
+	MergeHead string    `json:"merge_head,omitempty"` // Commit being merged, rebased or picked
+	Entries   []Entry   `json:"entries"`
+}
+
+// Entry is one conflict of a run and how it was resolved.
+type Entry struct {
+	File       string `json:"file"`
+	Name       string `json:"name,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Type       string `json:"type"`
+	Class      string `json:"class,omitempty"` // Conflict class of auto-merges, e.g. "rename"
+	Resolution string `json:"resolution"`      // As in the JSON output: auto, local, remote, ..., none
+	Source     string `json:"source,omitempty"`
+	Rule       string `json:"rule,omitempty"`
+}
+
+// Path returns the audit log of a repository's git directory.
+func Path(gitDir string) string {
+	return filepath.Join(gitDir, "g2", "audit.jsonl")
+}
+
+// Append adds a run to the log at path, one JSON object per line.
+func Append(path string, run Run) error {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns the runs in the log at path, oldest first. A missing log has
+// no runs; lines that cannot be decoded are skipped.
+func Read(path string) ([]Run, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+// Resolved reports whether g2 merged the conflict rather than leaving
+// conflict markers.
+func (e Entry) Resolved() bool {
+	return e.Resolution != "none" && e.Resolution != "skip"
+}
+
+// Definition names the conflict's definition within its file, e.g.
+// "utils.py:calc_total", or just the file for file-level conflicts.
+func (e Entry) Definition() string {
+	if e.Name == "" {
+		return e.File
+	}
+	return e.File + ":" + e.Name
+}
+
+// Trailer returns the commit message trailer of a resolved entry, e.g.
+// "G2-Auto-Merged: utils.py:calc_total (rename)".
+func (e Entry) Trailer() (string, bool) {
+	if !e.Resolved() {
+		return "", false
+	}
+	if e.Resolution == "auto" {
+		detail := e.Class
+		if detail == "" {
+			detail = "auto"
+		}
+		return fmt.Sprintf("%s: %s (%s)", TrailerAutoMerged, e.Definition(), detail), true
+	}
+	detail := e.Resolution
+	switch {
+	case e.Rule != "":
+		detail += ", rule " + e.Rule
+	case e.Source != "":
+		detail += ", " + e.Source
+	}
+	return fmt.Sprintf("%s: %s (%s)", TrailerResolved, e.Definition(), detail), true
+}
+
+// Trailers returns the commit message trailers for the runs that resolved
+// the operation at head merging mergeHead. When a conflict was resolved by
+// several runs, the last one wins.
+func Trailers(runs []Run, head, mergeHead string) []string {
+	type key struct{ file, kind, name string }
+	latest := make(map[key]Entry)
+	var order []key
+	for _, run := range runs {
+		if run.Head != head || run.MergeHead != mergeHead {
+			continue
+		}
+		for _, e := range run.Entries {
+			k := key{e.File, e.Kind, e.Name}
+			if _, seen := latest[k]; !seen {
+				order = append(order, k)
+			}
+			latest[k] = e
+		}
+	}
+
+	var trailers []string
+	for _, k := range order {
+		if trailer, ok := latest[k].Trailer(); ok {
+			trailers = append(trailers, trailer)
+		}
+	}
+	return trailers
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestLog tests appending runs to the log and reading them back
+func TestLog(t *testing.T) {
+	path := Path(t.TempDir())
+	if runs, err := Read(path); err != nil || runs != nil {
+		t.Fatalf("a missing log should have no runs, got %v, %v", runs, err)
+	}
+
+	runs := []Run{
+		{Operation: "merge", Head: "h1", MergeHead: "m1", Entries: []Entry{{File: "utils.py", Name: "calc_total", Kind: "function", Type: "Function 'calc' Renamed+Moved to 'calc_total' (Exact Match)", Class: "rename", Resolution: "auto"}}},
+		{Operation: "rebase", Head: "h2", MergeHead: "m2", Entries: []Entry{{File: "old.py", Type: "Text Conflict", Resolution: "none"}}},
+	}
+	for _, run := range runs {
+		if err := Append(path, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Lines that cannot be decoded are skipped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+
+	read, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, runs) {
+		t.Errorf("read %+v, want %+v", read, runs)
+	}
+}
+
+// TestTrailers tests deriving commit message trailers from recorded runs
+func TestTrailers(t *testing.T) {
+	runs := []Run{
+		{Head: "h1", MergeHead: "m1", Entries: []Entry{
+			{File: "utils.py", Name: "calc_total", Kind: "function", Class: "rename", Resolution: "auto"},
+			{File: "utils.py", Name: "total", Kind: "function", Resolution: "none"},
+			{File: "utils.py", Name: "other", Kind: "function", Resolution: "none"},
+			{File: "config.json", Resolution: "auto"},
+		}},
+		{Head: "h1", MergeHead: "m1", Entries: []Entry{
+			{File: "utils.py", Name: "total", Kind: "function", Resolution: "remote", Source: "tui"},
+			{File: "go.sum", Resolution: "regenerate", Rule: "lockfiles"},
+		}},
+		{Head: "h0", MergeHead: "m0", Entries: []Entry{{File: "old.py", Resolution: "local", Source: "flag"}}},
+	}
+
+	want := []string{
+		"G2-Auto-Merged: utils.py:calc_total (rename)",
+		"G2-Resolved: utils.py:total (remote, tui)",
+		"G2-Auto-Merged: config.json (auto)",
+		"G2-Resolved: go.sum (regenerate, rule lockfiles)",
+	}
+	if got := Trailers(runs, "h1", "m1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("trailers = %q, want %q", got, want)
+	}
+	if got := Trailers(runs, "h1", "other"); len(got) != 0 {
+		t.Errorf("runs of another merge should be ignored, got %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+// Package events streams g2's progress as newline-delimited JSON for editor
+// integrations, and reads resolution commands sent back on stdin.
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/output"
+)
+
+// FormatNDJSON is the only event stream format
+const FormatNDJSON = "ndjson"
+
+// Event types, in the order they occur
+const (
+	OperationStarted   = "operation_started"   // The git operation is about to run
+	FileAnalyzed       = "file_analyzed"       // A conflicting file was parsed and compared
+	ConflictClassified = "conflict_classified" // A conflict's final classification
+	MoveDetected       = "move_detected"       // A definition moved within or between files
+	ImportUpdated      = "import_updated"      // An import of a moved definition was rewritten
+	AwaitingUser       = "awaiting_user"       // g2 waits for resolution commands
+	ResolutionAccepted = "resolution_accepted" // A resolve command was applied
+	CommandError       = "command_error"       // A command was rejected
+	FileWritten        = "file_written"        // A merged file was written
+	FileStaged         = "file_staged"         // A merged file was staged
+	Finished           = "finished"            // The run is over
+)
+
+// Event is one line of the event stream. Only the fields of its type are set.
+type Event struct {
+	Event     string                 `json:"event"`
+	Time      time.Time              `json:"time"`
+	Operation string                 `json:"operation,omitempty"`
+	Args      []string               `json:"args,omitempty"`
+	File      string                 `json:"file,omitempty"`
+	Conflicts int                    `json:"conflicts,omitempty"` // Conflicts found in the file
+	ID        int                    `json:"id,omitempty"`        // Conflict id for resolve commands
+	Conflict  *output.ConflictDetail `json:"conflict,omitempty"`
+	Move      *output.Move           `json:"move,omitempty"`
+	Import    *output.ImportEdit     `json:"import,omitempty"`
+	Pending   []int                  `json:"pending,omitempty"` // Conflict ids awaiting resolution
+	Message   string                 `json:"message,omitempty"`
+	ExitCode  *int                   `json:"exit_code,omitempty"`
+	Result    *output.MergeResult    `json:"result,omitempty"`
+}
+
+// Emitter writes events as newline-delimited JSON. A nil Emitter discards
+// events, so callers need not check whether streaming is enabled.
+type Emitter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewEmitter returns an emitter writing to w.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{w: w, now: time.Now}
+}
+
+// Emit writes one event, stamped with the current time.
+func (e *Emitter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	event.Time = e.now().UTC()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	e.w.Write(append(line, '\n'))
+}
+
+// Command types of the command channel
+const (
+	CommandResolve = "resolve" // Set the resolution of a conflict by id
+	CommandDone    = "done"    // Stop reading commands and synthesize
+	CommandAbort   = "abort"   // Stop without writing any file
+)
+
+// Command is one line of the command channel, e.g.
+// {"command":"resolve","id":3,"resolution":"remote"}.
+type Command struct {
+	Command    string `json:"command"`
+	ID         int    `json:"id,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// CommandReader reads newline-delimited JSON commands.
+type CommandReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewCommandReader returns a reader of the commands in r.
+func NewCommandReader(r io.Reader) *CommandReader {
+	return &CommandReader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the next command, skipping blank lines. It returns io.EOF
+// when the channel is closed; a malformed line returns an error and the
+// following lines can still be read.
+func (r *CommandReader) Next() (Command, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var cmd Command
+		if err := json.Unmarshal([]byte(line), &cmd); err != nil {
+			return Command{}, fmt.Errorf("invalid command %q: %w", line, err)
+		}
+		return cmd, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Command{}, err
+	}
+	return Command{}, io.EOF
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/output"
+)
+
+// TestEmitter tests writing events as newline-delimited JSON
+func TestEmitter(t *testing.T) {
+	var nilEmitter *Emitter
+	nilEmitter.Emit(Event{Event: Finished})
+
+	var buf bytes.Buffer
+	emitter := NewEmitter(&buf)
+	emitter.now = func() time.Time {
+		return time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	}
+	emitter.Emit(Event{Event: FileAnalyzed, File: "calc.py", Conflicts: 2})
+	code := 0
+	emitter.Emit(Event{Event: Finished, ExitCode: &code, Result: &output.MergeResult{}})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{
+		`{"event":"file_analyzed","time":"2024-05-01T12:30:00Z","file":"calc.py","conflicts":2}`,
+		`{"event":"finished","time":"2024-05-01T12:30:00Z","exit_code":0,"result":`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %q", len(want), buf.String())
+	}
+	if lines[0] != want[0] {
+		t.Errorf("event = %s, want %s", lines[0], want[0])
+	}
+	if !strings.HasPrefix(lines[1], want[1]) {
+		t.Errorf("event = %s, want a zero exit code and a result", lines[1])
+	}
+}
+
+// TestCommandReader tests reading commands, skipping blank lines and
+// recovering from malformed ones
+func TestCommandReader(t *testing.T) {
+	r := NewCommandReader(strings.NewReader(`{"command":"resolve","id":3,"resolution":"remote"}
+
+not json
+  {"command":"done"}
+`))
+	cmd, err := r.Next()
+	if err != nil || cmd != (Command{Command: CommandResolve, ID: 3, Resolution: "remote"}) {
+		t.Errorf("unexpected command: %+v, %v", cmd, err)
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "not json") {
+		t.Errorf("expected an error naming the line, got %v", err)
+	}
+	if cmd, err := r.Next(); err != nil || cmd.Command != CommandDone {
+		t.Errorf("expected the done command after an error, got %+v, %v", cmd, err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
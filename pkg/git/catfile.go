@@ -0,0 +1,144 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BlobReader reads object contents by id from a long-lived session
+// (`git cat-file --batch` for the default executor).
+type BlobReader interface {
+	// ReadBlob returns the contents of the object with the given id.
+	// Objects larger than maxSize bytes (0 = no limit) are skipped with
+	// ErrObjectTooLarge without being loaded.
+	ReadBlob(oid string, maxSize int64) ([]byte, error)
+
+	// Close ends the session and releases its resources.
+	Close() error
+}
+
+// ErrObjectMissing is returned by ReadBlob when the object does not exist.
+var ErrObjectMissing = errors.New("object missing")
+
+// ErrSessionClosed is returned by ReadBlob once the session is closed,
+// including when a read error has closed it.
+var ErrSessionClosed = errors.New("cat-file session closed")
+
+// ErrObjectTooLarge is returned by ReadBlob when the object exceeds maxSize.
+var ErrObjectTooLarge = errors.New("file too large")
+
+// CatFileBatch starts a `git cat-file --batch` process for reading many blobs
+// without spawning a git process per object. The session lives until Close
+// is called or ctx is cancelled; the executor timeout does not apply.
+func (e *DefaultExecutor) CatFileBatch(ctx context.Context) (BlobReader, error) {
+	cmd := e.command(ctx, []string{"cat-file", "--batch"})
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cat-file stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cat-file stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+
+	return &catFileSession{
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		wait:   cmd.Wait,
+		kill:   cmd.Process.Kill,
+	}, nil
+}
+
+// catFileSession implements BlobReader over a running `git cat-file --batch`.
+type catFileSession struct {
+	mu     sync.Mutex
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	wait   func() error
+	kill   func() error
+	closed bool
+}
+
+// ReadBlob requests one object and reads its contents from the batch output.
+// Requests are serialized, so a session is safe for concurrent use. After a
+// failed write, read or malformed header the output can no longer be framed,
+// so the session is closed and later reads fail.
+func (s *catFileSession) ReadBlob(oid string, maxSize int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	if strings.ContainsAny(oid, " \n") {
+		return nil, fmt.Errorf("invalid object id %q", oid)
+	}
+
+	if _, err := io.WriteString(s.stdin, oid+"\n"); err != nil {
+		return nil, s.fail(fmt.Errorf("cat-file write: %w", err))
+	}
+
+	// Header: "<oid> <type> <size>" or "<oid> missing"
+	header, err := s.stdout.ReadString('\n')
+	if err != nil {
+		return nil, s.fail(fmt.Errorf("cat-file read header: %w", err))
+	}
+	fields := strings.Fields(header)
+	if len(fields) == 2 && fields[1] == "missing" {
+		return nil, fmt.Errorf("%s: %w", oid, ErrObjectMissing)
+	}
+	if len(fields) != 3 {
+		return nil, s.fail(fmt.Errorf("unexpected cat-file header %q", strings.TrimSpace(header)))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || size < 0 {
+		return nil, s.fail(fmt.Errorf("invalid cat-file size %q", fields[2]))
+	}
+
+	// Contents are followed by a single newline
+	if maxSize > 0 && size > maxSize {
+		if _, err := io.CopyN(io.Discard, s.stdout, size+1); err != nil {
+			return nil, s.fail(fmt.Errorf("cat-file skip contents: %w", err))
+		}
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrObjectTooLarge, size, maxSize)
+	}
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(s.stdout, content); err != nil {
+		return nil, s.fail(fmt.Errorf("cat-file read contents: %w", err))
+	}
+	return content[:size], nil
+}
+
+// fail closes a session whose output is out of sync and returns err. The
+// process is killed, since it may be blocked writing output nobody reads.
+// The caller holds s.mu.
+func (s *catFileSession) fail(err error) error {
+	s.closed = true
+	s.stdin.Close()
+	s.kill()
+	s.wait()
+	return fmt.Errorf("%w: %w", ErrSessionClosed, err)
+}
+
+// Close closes stdin, which makes git exit, and waits for the process.
+func (s *catFileSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.stdin.Close()
+	return s.wait()
+}
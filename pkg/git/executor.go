@@ -26,11 +26,22 @@ type Executor interface {
 	// RunWithStdio executes a git command with stdin/stdout/stderr
 	// connected to the current process (for interactive commands).
 	RunWithStdio(ctx context.Context, args ...string) error
+
+	// CatFileBatch starts a long-lived session for reading blobs by object id.
+	// The caller must Close the returned reader.
+	CatFileBatch(ctx context.Context) (BlobReader, error)
 }
 
 // DefaultExecutor implements Executor using exec.CommandContext.
 type DefaultExecutor struct {
 	Timeout time.Duration
+
+	// Dir is the working directory for git commands (empty = current directory).
+	Dir string
+
+	// GlobalArgs are inserted before the subcommand of every invocation,
+	// e.g. ["-c", "merge.conflictStyle=diff3", "--git-dir=../repo.git"].
+	GlobalArgs []string
 }
 
 // NewDefaultExecutor creates a new DefaultExecutor with the default timeout.
@@ -48,7 +59,7 @@ func (e *DefaultExecutor) Run(ctx context.Context, args ...string) error {
 	ctx, cancel := e.contextWithTimeout(ctx)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd := e.command(ctx, args)
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	return cmd.Run()
@@ -59,7 +70,7 @@ func (e *DefaultExecutor) Output(ctx context.Context, args ...string) ([]byte, e
 	ctx, cancel := e.contextWithTimeout(ctx)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd := e.command(ctx, args)
 	return cmd.Output()
 }
 
@@ -68,13 +79,24 @@ func (e *DefaultExecutor) RunWithStdio(ctx context.Context, args ...string) erro
 	ctx, cancel := e.contextWithTimeout(ctx)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd := e.command(ctx, args)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	return cmd.Run()
 }
 
+// command builds a git command with the executor's global options and directory applied.
+func (e *DefaultExecutor) command(ctx context.Context, args []string) *exec.Cmd {
+	fullArgs := make([]string, 0, len(e.GlobalArgs)+len(args))
+	fullArgs = append(fullArgs, e.GlobalArgs...)
+	fullArgs = append(fullArgs, args...)
+
+	cmd := exec.CommandContext(ctx, "git", fullArgs...)
+	cmd.Dir = e.Dir
+	return cmd
+}
+
 // contextWithTimeout returns a context with the executor's timeout applied.
 // If the provided context already has a deadline, it is used if shorter.
 func (e *DefaultExecutor) contextWithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
@@ -3,6 +3,7 @@ package git
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 )
 
@@ -25,20 +26,30 @@ type MockExecutor struct {
 	calls     []MockCall
 	responses map[string]MockResponse // keyed by first arg (e.g., "merge", "add")
 	defaults  MockResponse            // default response if no match
+	blobs     map[string][]byte       // object id -> contents for CatFileBatch
 
 	// Hooks for custom behavior
 	OnRun         func(ctx context.Context, args []string) error
 	OnOutput      func(ctx context.Context, args []string) ([]byte, error)
 	OnRunWithStdio func(ctx context.Context, args []string) error
+	OnReadBlob     func(oid string) ([]byte, error)
 }
 
 // NewMockExecutor creates a new MockExecutor with no default responses.
 func NewMockExecutor() *MockExecutor {
 	return &MockExecutor{
 		responses: make(map[string]MockResponse),
+		blobs:     make(map[string][]byte),
 	}
 }
 
+// SetBlob configures the contents returned by CatFileBatch for an object id.
+func (m *MockExecutor) SetBlob(oid string, content []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[oid] = content
+}
+
 // SetResponse configures the response for commands starting with the given arg.
 func (m *MockExecutor) SetResponse(firstArg string, output []byte, err error) {
 	m.mu.Lock()
@@ -110,6 +121,41 @@ func (m *MockExecutor) RunWithStdio(ctx context.Context, args ...string) error {
 	return resp.Error
 }
 
+// CatFileBatch records the call and returns a reader over the configured blobs.
+func (m *MockExecutor) CatFileBatch(ctx context.Context) (BlobReader, error) {
+	m.recordCall("CatFileBatch", nil)
+	return &mockBlobReader{mock: m}, nil
+}
+
+// mockBlobReader serves blobs configured with SetBlob or OnReadBlob.
+type mockBlobReader struct {
+	mock *MockExecutor
+}
+
+// ReadBlob records the call and returns the configured blob contents.
+func (r *mockBlobReader) ReadBlob(oid string, maxSize int64) ([]byte, error) {
+	r.mock.recordCall("ReadBlob", []string{oid})
+
+	if r.mock.OnReadBlob != nil {
+		return r.mock.OnReadBlob(oid)
+	}
+
+	r.mock.mu.Lock()
+	defer r.mock.mu.Unlock()
+	if content, ok := r.mock.blobs[oid]; ok {
+		if maxSize > 0 && int64(len(content)) > maxSize {
+			return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrObjectTooLarge, len(content), maxSize)
+		}
+		return content, nil
+	}
+	return nil, fmt.Errorf("%s: %w", oid, ErrObjectMissing)
+}
+
+// Close is a no-op for the mock reader.
+func (r *mockBlobReader) Close() error {
+	return nil
+}
+
 func (m *MockExecutor) recordCall(method string, args []string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
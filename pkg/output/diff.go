@@ -0,0 +1,87 @@
+package output
+
+import "strings"
+
+// maxDiffCells bounds the LCS table of a diff; larger inputs are shown as a
+// full replacement
+const maxDiffCells = 4_000_000
+
+// DiffLine is one line of a line diff.
+type DiffLine struct {
+	Op   byte // ' ' (unchanged), '-' (removed) or '+' (added)
+	Text string
+}
+
+// Diff returns the line diff from a to b, based on their longest common
+// subsequence of lines.
+func Diff(a, b string) []DiffLine {
+	from, to := diffLines(a), diffLines(b)
+	if len(from)*len(to) > maxDiffCells {
+		var lines []DiffLine
+		for _, line := range from {
+			lines = append(lines, DiffLine{Op: '-', Text: line})
+		}
+		for _, line := range to {
+			lines = append(lines, DiffLine{Op: '+', Text: line})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the LCS of from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			lines = append(lines, DiffLine{Op: ' ', Text: from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Op: '-', Text: from[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: '+', Text: to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		lines = append(lines, DiffLine{Op: '-', Text: from[i]})
+	}
+	for ; j < len(to); j++ {
+		lines = append(lines, DiffLine{Op: '+', Text: to[j]})
+	}
+	return lines
+}
+
+// FormatDiff renders a diff with "-", "+" and " " line prefixes.
+func FormatDiff(lines []DiffLine) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteByte(line.Op)
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// diffLines splits text into lines, ignoring a trailing newline
+func diffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,13 @@
+package output
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	got := FormatDiff(Diff("a\nb\nc\n", "a\nc\nd"))
+	if want := " a\n-b\n c\n+d\n"; got != want {
+		t.Errorf("expected diff %q, got %q", want, got)
+	}
+	if got := FormatDiff(Diff("", "x")); got != "+x\n" {
+		t.Errorf("expected an addition, got %q", got)
+	}
+}
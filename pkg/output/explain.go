@@ -0,0 +1,44 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteExplanations writes the decision trace of each conflict of a file
+// as readable text. Conflicts without an explanation are skipped.
+func WriteExplanations(w io.Writer, file FileResult) error {
+	var b strings.Builder
+	for _, c := range file.Conflicts {
+		e := c.Explain
+		if e == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s -> %s\n", file.File, c.Type, c.decision())
+		explainLine(&b, "rule", e.Rule)
+		explainLine(&b, "base", e.Normalized.Base)
+		explainLine(&b, "local", e.Normalized.Local)
+		explainLine(&b, "remote", e.Normalized.Remote)
+		if e.Move != nil {
+			explainLine(&b, "move", fmt.Sprintf("'%s' %s", e.Move.Candidate, e.Move.Summary))
+		}
+		for _, note := range e.Notes {
+			explainLine(&b, "note", note)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// explainLine writes one labeled field, indenting continuation lines under
+// the first. Empty values are skipped.
+func explainLine(b *strings.Builder, label, value string) {
+	if value == "" {
+		return
+	}
+	indent := strings.Repeat(" ", 11)
+	value = strings.ReplaceAll(strings.TrimRight(value, "\n"), "\n", "\n"+indent)
+	fmt.Fprintf(b, "  %-8s %s\n", label+":", value)
+}
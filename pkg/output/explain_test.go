@@ -0,0 +1,37 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestWriteExplanations tests the text rendering of decision traces
+func TestWriteExplanations(t *testing.T) {
+	file := FileResult{File: "calc.py", Conflicts: []ConflictDetail{
+		{Type: "Function 'calc' Modified", Resolution: "none", Explain: &Explanation{
+			Rule:       "changed on both sides; normalized bodies differ",
+			Normalized: Normalized{Base: "def calc():\n    return 1", Local: "def calc():\n    return 2"},
+			Move:       &MoveScore{Candidate: "calc", Summary: "0.800 < threshold 0.850 (12 and 14 tokens)"},
+			Notes:      []string{"collision: overlaps a method"},
+		}},
+		{Type: "Function 'fmt' Modified", Resolution: "none"},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteExplanations(&buf, file); err != nil {
+		t.Fatal(err)
+	}
+	want := `calc.py: Function 'calc' Modified -> conflict markers
+  rule:    changed on both sides; normalized bodies differ
+  base:    def calc():
+               return 1
+  local:   def calc():
+               return 2
+  move:    'calc' 0.800 < threshold 0.850 (12 and 14 tokens)
+  note:    collision: overlaps a method
+
+`
+	if buf.String() != want {
+		t.Errorf("unexpected explanation text:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Machine-readable output formats.
+const (
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+	FormatJUnit = "junit"
+)
+
+// IsFormat reports whether format is a supported output format.
+func IsFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatSARIF, FormatJUnit:
+		return true
+	}
+	return false
+}
+
+// Write writes the merge result to w in the given format. An empty format
+// means JSON.
+func Write(w io.Writer, result *MergeResult, format string) error {
+	switch format {
+	case "", FormatJSON:
+		return WriteJSON(w, result)
+	case FormatSARIF:
+		return WriteSARIF(w, result)
+	case FormatJUnit:
+		return WriteJUnit(w, result)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// WriteStdout writes the merge result to stdout in the given format.
+func WriteStdout(result *MergeResult, format string) error {
+	return Write(os.Stdout, result, format)
+}
+
+// Unresolved reports whether the conflict is left to conflict markers.
+func (c *ConflictDetail) Unresolved() bool {
+	return c.Resolution == "none" || c.Resolution == "skip"
+}
+
+// UnresolvedCount returns the number of conflicts of the file left to
+// conflict markers. Files without conflict detail (text and binary
+// conflicts) count all their conflicts.
+func (f *FileResult) UnresolvedCount() int {
+	if len(f.Conflicts) == 0 {
+		if f.HasMarkers {
+			return f.ConflictCount
+		}
+		return 0
+	}
+	count := 0
+	for i := range f.Conflicts {
+		if f.Conflicts[i].Unresolved() {
+			count++
+		}
+	}
+	return count
+}
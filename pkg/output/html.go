@@ -0,0 +1,97 @@
+package output
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"definition":   func(c ConflictDetail) string { return c.definition() },
+	"decision":     func(c ConflictDetail) string { return c.decision() },
+	"unresolved":   func(c ConflictDetail) bool { return c.Unresolved() },
+	"moveName":     moveName,
+	"importStatus": importStatus,
+	"percent":      func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"diffClass": func(op byte) string {
+		switch op {
+		case '-':
+			return "del"
+		case '+':
+			return "add"
+		}
+		return "ctx"
+	},
+	"diffOp": func(op byte) string { return string(op) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>G2 merge report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 72em; padding: 0 1em; color: #24292f; }
+h1, h2 { border-bottom: 1px solid #d0d7de; padding-bottom: .3em; }
+table { border-collapse: collapse; width: 100%; margin: 1em 0; }
+th, td { border: 1px solid #d0d7de; padding: .35em .6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+code, pre { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 85%; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: .5em 0; padding: .5em 1em; }
+summary { cursor: pointer; }
+pre { background: #f6f8fa; padding: .5em; overflow-x: auto; margin: .3em 0 1em; }
+pre span { display: block; white-space: pre; }
+.add { background: #dafbe1; }
+.del { background: #ffebe9; }
+.unresolved { color: #cf222e; font-weight: 600; }
+</style>
+</head>
+<body>
+<h1>G2 merge report</h1>
+<p>{{.Summary}}</p>
+{{- if .Conflicts}}
+<h2>Conflicts</h2>
+<table>
+<tr><th>File</th><th>Definition</th><th>Conflict</th><th>Status</th><th>Resolution</th></tr>
+{{- range .Conflicts}}
+<tr><td><code>{{.File}}</code></td><td>{{definition .ConflictDetail}}</td><td>{{.Type}}</td><td>{{.Status}}</td><td{{if unresolved .ConflictDetail}} class="unresolved"{{end}}>{{decision .ConflictDetail}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Decisions}}
+<h2>Merge decisions</h2>
+{{- range .Decisions}}
+<details>
+<summary><code>{{.File}}</code>: {{.Type}} → {{decision .ConflictDetail}}</summary>
+{{- range .Diffs}}
+<p>{{.Title}}</p>
+<pre>{{range .Lines}}<span class="{{diffClass .Op}}">{{diffOp .Op}}{{.Text}}</span>{{end}}</pre>
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+{{- if .Moves}}
+<h2>Moves</h2>
+<table>
+<tr><th>Definition</th><th>From</th><th>To</th><th>Match</th><th>Similarity</th></tr>
+{{- range .Moves}}
+<tr><td>{{moveName .}}</td><td><code>{{.FromFile}}</code></td><td><code>{{.ToFile}}</code></td><td>{{.MatchType}}</td><td>{{percent .Similarity}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Imports}}
+<h2>Import rewrites</h2>
+<table>
+<tr><th>File</th><th>Old</th><th>New</th><th>Applied</th></tr>
+{{- range .Imports}}
+<tr><td><code>{{.File}}:{{.Line}}</code></td><td><code>{{.Old}}</code></td><td><code>{{.New}}</code></td><td>{{importStatus .}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes a self-contained HTML merge report. Each decision is a
+// collapsible <details> block with its three-way diff.
+func WriteHTML(w io.Writer, result *MergeResult) error {
+	return htmlReport.Execute(w, newReport(result))
+}
@@ -2,35 +2,152 @@
 package output
 
 import (
+	_ "embed"
 	"encoding/json"
 	"io"
 	"os"
 )
 
+// SchemaVersion is the version of the JSON output format. It is increased
+// when a field is removed or changes meaning; new fields may be added
+// without a version change.
+const SchemaVersion = 1
+
+// Schema is the JSON Schema of the output format.
+//
+//go:embed schema.json
+var Schema []byte
+
 // FileResult contains the merge result for a single file.
 type FileResult struct {
-	File           string `json:"file"`
-	ConflictCount  int    `json:"conflict_count"`
-	ResolvedCount  int    `json:"resolved_count"`
-	AllAutoMerged  bool   `json:"all_auto_merged"`
-	HasMarkers     bool   `json:"has_markers"`
-	Error          string `json:"error,omitempty"`
+	File          string           `json:"file"`
+	ConflictCount int              `json:"conflict_count"`
+	ResolvedCount int              `json:"resolved_count"`
+	AllAutoMerged bool             `json:"all_auto_merged"`
+	HasMarkers    bool             `json:"has_markers"`
+	Error         string           `json:"error,omitempty"`
+	Rules         []RuleMatch      `json:"rules,omitempty"`
+	Conflicts     []ConflictDetail `json:"conflicts"`
+}
+
+// ConflictDetail describes one conflict of a file and how it was handled.
+type ConflictDetail struct {
+	Name       string       `json:"name"`
+	Kind       string       `json:"kind"`
+	Type       string       `json:"type"`
+	Status     string       `json:"status"`
+	Resolution string       `json:"resolution"`
+	Source     string       `json:"source,omitempty"`
+	Rule       string       `json:"rule,omitempty"`
+	Base       *Range       `json:"base,omitempty"`
+	Local      *Range       `json:"local,omitempty"`
+	Remote     *Range       `json:"remote,omitempty"`
+	Move       *Move        `json:"move,omitempty"`
+	Explain    *Explanation `json:"explain,omitempty"`
+	Bodies     *Bodies      `json:"-"`
+}
+
+// Bodies holds the text of a conflict's definition in each version, for
+// merge reports. Merged is set only for conflicts g2 merged.
+type Bodies struct {
+	Base, Local, Remote string
+	Merged              string
+	HasMerged           bool
+}
+
+// Range locates a definition in one version of a file. Lines are 1-based
+// and inclusive; byte offsets are 0-based and the end is exclusive.
+type Range struct {
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	StartByte int `json:"start_byte"`
+	EndByte   int `json:"end_byte"`
+}
+
+// Move describes where a moved definition came from and went to.
+type Move struct {
+	FromFile    string       `json:"from_file"`
+	ToFile      string       `json:"to_file"`
+	FromName    string       `json:"from_name"`
+	ToName      string       `json:"to_name"`
+	MatchType   string       `json:"match_type"`
+	Similarity  float64      `json:"similarity"`
+	ImportEdits []ImportEdit `json:"import_edits,omitempty"`
+}
+
+// Explanation records how g2 classified a conflict (--explain).
+type Explanation struct {
+	Rule       string     `json:"rule"`
+	Normalized Normalized `json:"normalized"`
+	Move       *MoveScore `json:"move,omitempty"`
+	Notes      []string   `json:"notes,omitempty"`
+}
+
+// Normalized holds the normalized bodies that were compared. A version in
+// which the definition does not exist is omitted.
+type Normalized struct {
+	Base   string `json:"base,omitempty"`
+	Local  string `json:"local,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+// MoveScore is the similarity of a fuzzy move and the threshold it had to
+// reach for the sizes of both bodies.
+type MoveScore struct {
+	Candidate    string  `json:"candidate"`
+	DeleteTokens int     `json:"delete_tokens"`
+	AddTokens    int     `json:"add_tokens"`
+	Similarity   float64 `json:"similarity"`
+	Threshold    float64 `json:"threshold"`
+	Summary      string  `json:"summary"` // e.g. "0.820 >= threshold 0.750 (40 and 44 tokens)"
+}
+
+// RuleMatch records a merge policy rule that decided a conflict.
+type RuleMatch struct {
+	Conflict   string `json:"conflict"`
+	Rule       string `json:"rule"`
+	Resolution string `json:"resolution"`
+}
+
+// Preference is the side kept for conflicts that needed resolution
+// (--prefer, --prefer-kind).
+type Preference struct {
+	Default string            `json:"default,omitempty"` // local or remote
+	Kinds   map[string]string `json:"kinds,omitempty"`   // Side by definition kind
+}
+
+// ImportEdit describes one import rewrite made for a moved definition.
+type ImportEdit struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Kind       string `json:"kind,omitempty"`
+	Definition string `json:"definition,omitempty"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	Applied    bool   `json:"applied"`
+	Error      string `json:"error,omitempty"`
 }
 
 // MergeResult contains the overall merge result.
 type MergeResult struct {
+	SchemaVersion  int          `json:"schema_version"`
 	Success        bool         `json:"success"`
 	TotalConflicts int          `json:"total_conflicts"`
 	ResolvedCount  int          `json:"resolved_count"`
 	Files          []FileResult `json:"files"`
+	ImportEdits    []ImportEdit `json:"import_edits,omitempty"`
 	Error          string       `json:"error,omitempty"`
 	DryRun         bool         `json:"dry_run,omitempty"`
+	Prefer         *Preference  `json:"prefer,omitempty"`
 }
 
 // NewMergeResult creates a new empty MergeResult.
 func NewMergeResult() *MergeResult {
 	return &MergeResult{
-		Files: make([]FileResult, 0),
+		SchemaVersion: SchemaVersion,
+		Files:         make([]FileResult, 0),
 	}
 }
 
@@ -41,6 +158,11 @@ func (r *MergeResult) AddFileResult(file FileResult) {
 	r.ResolvedCount += file.ResolvedCount
 }
 
+// AddImportEdit adds an import rewrite to the merge result.
+func (r *MergeResult) AddImportEdit(edit ImportEdit) {
+	r.ImportEdits = append(r.ImportEdits, edit)
+}
+
 // SetError sets the error message.
 func (r *MergeResult) SetError(err error) {
 	if err != nil {
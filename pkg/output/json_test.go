@@ -0,0 +1,69 @@
+package output
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOutputSchema checks that the published JSON Schema lists exactly the
+// fields of the output types
+func TestOutputSchema(t *testing.T) {
+	type object struct {
+		Properties           map[string]json.RawMessage `json:"properties"`
+		AdditionalProperties *bool                      `json:"additionalProperties"`
+	}
+	var schema struct {
+		object
+		Defs map[string]object `json:"$defs"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	types := map[string]reflect.Type{
+		"":            reflect.TypeOf(MergeResult{}),
+		"file":        reflect.TypeOf(FileResult{}),
+		"rule_match":  reflect.TypeOf(RuleMatch{}),
+		"conflict":    reflect.TypeOf(ConflictDetail{}),
+		"range":       reflect.TypeOf(Range{}),
+		"move":        reflect.TypeOf(Move{}),
+		"import_edit": reflect.TypeOf(ImportEdit{}),
+		"explanation": reflect.TypeOf(Explanation{}),
+		"normalized":  reflect.TypeOf(Normalized{}),
+		"move_score":  reflect.TypeOf(MoveScore{}),
+	}
+	for def, typ := range types {
+		obj := schema.object
+		if def != "" {
+			obj = schema.Defs[def]
+		}
+		properties := obj.Properties
+		if obj.AdditionalProperties == nil || *obj.AdditionalProperties {
+			t.Errorf("%s: schema should not allow additional properties", typ.Name())
+		}
+		var fields []string
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			fields = append(fields, name)
+			if _, ok := properties[name]; !ok {
+				t.Errorf("%s: field %q is missing from the schema", typ.Name(), name)
+			}
+		}
+		if len(properties) != len(fields) {
+			t.Errorf("%s: schema has %d properties, type has %d fields", typ.Name(), len(properties), len(fields))
+		}
+	}
+
+	var version struct{ Const int }
+	if err := json.Unmarshal(schema.Properties["schema_version"], &version); err != nil || version.Const != SchemaVersion {
+		t.Errorf("schema version %d does not match %d", version.Const, SchemaVersion)
+	}
+	if NewMergeResult().SchemaVersion != SchemaVersion {
+		t.Error("new results should carry the schema version")
+	}
+}
@@ -0,0 +1,129 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut *junitText    `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+// WriteJUnit writes the merge result as a JUnit XML report with one test
+// case per file. A file fails when conflicts remain and errors when it
+// could not be merged; the decisions for resolved conflicts are listed in
+// its system-out.
+func WriteJUnit(w io.Writer, result *MergeResult) error {
+	suite := junitTestSuite{Name: "g2"}
+	for _, file := range result.Files {
+		suite.Cases = append(suite.Cases, junitFileCase(file))
+	}
+	if result.Error != "" {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "g2",
+			Classname: "g2",
+			Error:     &junitProblem{Message: result.Error, Type: RuleMergeError},
+		})
+	}
+	for _, c := range suite.Cases {
+		suite.Tests++
+		if c.Failure != nil {
+			suite.Failures++
+		}
+		if c.Error != nil {
+			suite.Errors++
+		}
+	}
+
+	suites := junitTestSuites{
+		Name:     "g2",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitFileCase returns the test case for one file
+func junitFileCase(file FileResult) junitTestCase {
+	tc := junitTestCase{Name: file.File, Classname: "g2", File: file.File}
+
+	var unresolved, resolved []string
+	for _, c := range file.Conflicts {
+		location := file.File
+		if c.Local != nil {
+			location = fmt.Sprintf("%s:%d", file.File, c.Local.StartLine)
+		}
+		if c.Unresolved() {
+			unresolved = append(unresolved, fmt.Sprintf("%s: %s (%s)", location, c.Type, c.Status))
+			continue
+		}
+		decision := c.Resolution
+		if c.Source != "" && c.Source != c.Resolution {
+			decision += " (" + c.Source + ")"
+		}
+		resolved = append(resolved, fmt.Sprintf("%s: %s -> %s", location, c.Type, decision))
+	}
+
+	if count := file.UnresolvedCount(); count > 0 {
+		tc.Failure = &junitProblem{
+			Message: fmt.Sprintf("%d of %d conflict(s) need manual resolution", count, file.ConflictCount),
+			Type:    RuleUnresolvedConflict,
+			Text:    strings.Join(unresolved, "\n"),
+		}
+		if len(file.Conflicts) == 0 {
+			tc.Failure.Type = RuleTextConflict
+		}
+	}
+	if file.Error != "" {
+		tc.Error = &junitProblem{Message: file.Error, Type: RuleMergeError}
+	}
+	if len(resolved) > 0 {
+		tc.SystemOut = &junitText{Text: strings.Join(resolved, "\n")}
+	}
+	return tc
+}
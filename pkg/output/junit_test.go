@@ -0,0 +1,47 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestMergeResultJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, reportResult(), FormatJUnit); err != nil {
+		t.Fatalf("failed to write JUnit: %v", err)
+	}
+
+	var suites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Suites   []struct {
+			Cases []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+					Text    string `xml:",chardata"`
+				} `xml:"failure"`
+				SystemOut string `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid JUnit XML: %v", err)
+	}
+	if suites.Tests != 2 || suites.Failures != 2 || len(suites.Suites) != 1 {
+		t.Fatalf("unexpected report: %s", buf.String())
+	}
+
+	utils := suites.Suites[0].Cases[0]
+	if utils.Name != "utils.py" || utils.Failure == nil || utils.Failure.Message != "1 of 2 conflict(s) need manual resolution" {
+		t.Errorf("unexpected test case: %+v", utils)
+	}
+	if !strings.Contains(utils.Failure.Text, "utils.py:10: Function 'calc' Modified") {
+		t.Errorf("failure should list the conflict, got %q", utils.Failure.Text)
+	}
+	if !strings.Contains(utils.SystemOut, "Function 'fmt' Updated (remote) -> auto") {
+		t.Errorf("system-out should list the auto-merge, got %q", utils.SystemOut)
+	}
+}
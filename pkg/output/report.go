@@ -0,0 +1,231 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Merge report formats, chosen by the file extension of --report.
+const (
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+)
+
+// ReportFormat returns the report format for a file name.
+func ReportFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return ReportMarkdown, nil
+	case ".html", ".htm":
+		return ReportHTML, nil
+	}
+	return "", fmt.Errorf("unknown report format for %s (use .md or .html)", path)
+}
+
+// WriteReport writes a merge report to path in the format its extension
+// names.
+func WriteReport(path string, result *MergeResult) error {
+	format, err := ReportFormat(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if format == ReportHTML {
+		err = WriteHTML(&buf, result)
+	} else {
+		err = WriteMarkdown(&buf, result)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// report is the content shared by the report formats
+type report struct {
+	Summary   string
+	Conflicts []reportConflict
+	Decisions []reportDecision
+	Moves     []*Move
+	Imports   []ImportEdit
+}
+
+type reportConflict struct {
+	File string
+	ConflictDetail
+}
+
+// reportDecision is a conflict merged by g2, with its three-way diff
+type reportDecision struct {
+	reportConflict
+	Diffs []reportDiff
+}
+
+type reportDiff struct {
+	Title string
+	Lines []DiffLine
+}
+
+// newReport collects the report content of a merge result
+func newReport(result *MergeResult) *report {
+	r := &report{Imports: result.ImportEdits}
+
+	files := "files"
+	if len(result.Files) == 1 {
+		files = "file"
+	}
+	r.Summary = fmt.Sprintf("%d %s, %d conflicts, %d resolved.", len(result.Files), files, result.TotalConflicts, result.ResolvedCount)
+	if result.DryRun {
+		r.Summary += " Dry run: nothing was written."
+	}
+	if result.Error != "" {
+		r.Summary += " Error: " + result.Error
+	}
+
+	// Both sides of a move between files report it
+	type moveKey struct{ fromFile, toFile, fromName, toName string }
+	seenMoves := make(map[moveKey]bool)
+	for _, file := range result.Files {
+		for _, c := range file.Conflicts {
+			rc := reportConflict{File: file.File, ConflictDetail: c}
+			r.Conflicts = append(r.Conflicts, rc)
+
+			if c.Move != nil {
+				key := moveKey{c.Move.FromFile, c.Move.ToFile, c.Move.FromName, c.Move.ToName}
+				if !seenMoves[key] {
+					seenMoves[key] = true
+					r.Moves = append(r.Moves, c.Move)
+				}
+			}
+			if c.Bodies != nil && c.Bodies.HasMerged {
+				r.Decisions = append(r.Decisions, reportDecision{
+					reportConflict: rc,
+					Diffs: []reportDiff{
+						{Title: "Base → Local", Lines: Diff(c.Bodies.Base, c.Bodies.Local)},
+						{Title: "Base → Remote", Lines: Diff(c.Bodies.Base, c.Bodies.Remote)},
+						{Title: "Local → Result", Lines: Diff(c.Bodies.Local, c.Bodies.Merged)},
+					},
+				})
+			}
+		}
+	}
+	return r
+}
+
+// definition names the conflict's definition, e.g. "calc (function)"
+func (c *ConflictDetail) definition() string {
+	if c.Name == "" {
+		return "(file)"
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Kind)
+}
+
+// decision describes how the conflict was resolved, e.g. "remote (tui)"
+func (c *ConflictDetail) decision() string {
+	decision := c.Resolution
+	switch {
+	case c.Resolution == "none":
+		return "conflict markers"
+	case c.Rule != "":
+		decision += fmt.Sprintf(" (rule %s)", c.Rule)
+	case c.Source != "" && c.Source != c.Resolution:
+		decision += " (" + c.Source + ")"
+	}
+	return decision
+}
+
+// WriteMarkdown writes a Markdown merge report. Decisions are collapsible
+// <details> blocks, which GitHub and GitLab render.
+func WriteMarkdown(w io.Writer, result *MergeResult) error {
+	r := newReport(result)
+	var b strings.Builder
+
+	b.WriteString("# G2 merge report\n\n")
+	b.WriteString(r.Summary + "\n")
+
+	if len(r.Conflicts) > 0 {
+		b.WriteString("\n## Conflicts\n\n| File | Definition | Conflict | Status | Resolution |\n|---|---|---|---|---|\n")
+		for _, c := range r.Conflicts {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+				markdownCode(c.File), markdownCell(c.definition()), markdownCell(c.Type), markdownCell(c.Status), markdownCell(c.decision()))
+		}
+	}
+
+	if len(r.Decisions) > 0 {
+		b.WriteString("\n## Merge decisions\n")
+		for _, d := range r.Decisions {
+			fmt.Fprintf(&b, "\n<details>\n<summary>%s: %s → %s</summary>\n",
+				html.EscapeString(d.File), html.EscapeString(d.Type), html.EscapeString(d.decision()))
+			for _, diff := range d.Diffs {
+				text := FormatDiff(diff.Lines)
+				fence := codeFence(text)
+				fmt.Fprintf(&b, "\n%s\n\n%sdiff\n%s%s\n", diff.Title, fence, text, fence)
+			}
+			b.WriteString("\n</details>\n")
+		}
+	}
+
+	if len(r.Moves) > 0 {
+		b.WriteString("\n## Moves\n\n| Definition | From | To | Match | Similarity |\n|---|---|---|---|---|\n")
+		for _, m := range r.Moves {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %.0f%% |\n",
+				markdownCell(moveName(m)), markdownCell(m.FromFile), markdownCell(m.ToFile), markdownCell(m.MatchType), m.Similarity*100)
+		}
+	}
+
+	if len(r.Imports) > 0 {
+		b.WriteString("\n## Import rewrites\n\n| File | Old | New | Applied |\n|---|---|---|---|\n")
+		for _, edit := range r.Imports {
+			fmt.Fprintf(&b, "| %s:%d | %s | %s | %s |\n",
+				markdownCell(edit.File), edit.Line, markdownCode(edit.Old), markdownCode(edit.New), markdownCell(importStatus(edit)))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// moveName names a moved definition, with its new name if it was renamed
+func moveName(m *Move) string {
+	if m.FromName == m.ToName {
+		return m.FromName
+	}
+	return m.FromName + " → " + m.ToName
+}
+
+// importStatus describes whether an import rewrite was applied
+func importStatus(edit ImportEdit) string {
+	switch {
+	case edit.Error != "":
+		return "failed: " + edit.Error
+	case edit.Applied:
+		return "yes"
+	}
+	return "no"
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", " ")
+}
+
+// markdownCode formats text as inline code in a table cell
+func markdownCode(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "\n", " ")
+	return "`` " + strings.ReplaceAll(text, "|", `\|`) + " ``"
+}
+
+// codeFence returns a backtick fence longer than any run in text
+func codeFence(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence
+}
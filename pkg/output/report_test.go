@@ -0,0 +1,60 @@
+package output
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeReport(t *testing.T) {
+	result := reportResult()
+	result.Files[0].Conflicts[1].Bodies = &Bodies{
+		Base:      "def fmt():\n    return 1",
+		Local:     "def fmt():\n    return 1",
+		Remote:    "def fmt():\n    return 2",
+		Merged:    "def fmt():\n    return 2",
+		HasMerged: true,
+	}
+	result.Files[0].Conflicts[1].Move = &Move{FromFile: "old.py", ToFile: "utils.py", FromName: "format", ToName: "fmt", MatchType: "Fuzzy Match", Similarity: 0.874}
+
+	var md bytes.Buffer
+	if err := WriteMarkdown(&md, result); err != nil {
+		t.Fatalf("failed to write Markdown: %v", err)
+	}
+	for _, want := range []string{
+		"| `` utils.py `` | calc (function) | Function 'calc' Modified | Needs Resolution | conflict markers |",
+		"<summary>utils.py: Function &#39;fmt&#39; Updated (remote) → auto</summary>",
+		"Local → Result\n\n```diff\n def fmt():\n-    return 1\n+    return 2\n```",
+		"| format → fmt | old.py | utils.py | Fuzzy Match | 87% |",
+		"| main.py:3 |",
+		"failed: permission denied",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown report is missing %q:\n%s", want, md.String())
+		}
+	}
+
+	var page bytes.Buffer
+	if err := WriteHTML(&page, result); err != nil {
+		t.Fatalf("failed to write HTML: %v", err)
+	}
+	for _, want := range []string{
+		"<style>",
+		`<td class="unresolved">conflict markers</td>`,
+		"<details>\n<summary><code>utils.py</code>: Function &#39;fmt&#39; Updated (remote) → auto</summary>",
+		`<span class="add">&#43;    return 2</span>`,
+	} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("HTML report is missing %q:\n%s", want, page.String())
+		}
+	}
+	if strings.Contains(page.String(), "<script") || strings.Contains(page.String(), "<link") {
+		t.Error("HTML report should be self-contained")
+	}
+
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := WriteReport(path, result); err == nil {
+		t.Error("expected an error for an unknown report extension")
+	}
+}
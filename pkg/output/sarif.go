@@ -0,0 +1,222 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SARIF rule IDs. Each unresolved conflict, failed file and failed import
+// rewrite becomes one result.
+const (
+	RuleUnresolvedConflict = "unresolved-conflict"
+	RuleTextConflict       = "text-conflict"
+	RuleMergeError         = "merge-error"
+	RuleImportUpdateFailed = "import-update-failed"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSrcRoot = "%SRCROOT%"
+)
+
+var sarifRules = []sarifRule{
+	{
+		ID:                   RuleUnresolvedConflict,
+		Name:                 "UnresolvedConflict",
+		ShortDescription:     sarifMessage{Text: "A definition was changed incompatibly on both sides and needs manual resolution"},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+	{
+		ID:                   RuleTextConflict,
+		Name:                 "TextConflict",
+		ShortDescription:     sarifMessage{Text: "The file cannot be merged semantically and has a text conflict"},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+	{
+		ID:                   RuleMergeError,
+		Name:                 "MergeError",
+		ShortDescription:     sarifMessage{Text: "The file could not be merged"},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+	{
+		ID:                   RuleImportUpdateFailed,
+		Name:                 "ImportUpdateFailed",
+		ShortDescription:     sarifMessage{Text: "An import of a moved definition could not be rewritten"},
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind,omitempty"`
+}
+
+// WriteSARIF writes the merge result as a SARIF 2.1.0 log. Regions refer to
+// the local version of each file, which is what a dry run leaves checked out.
+func WriteSARIF(w io.Writer, result *MergeResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "g2",
+			InformationURI: "https://github.com/simonkoeck/g2",
+			Rules:          sarifRules,
+		}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: result.Error == ""}},
+		Results:     []sarifResult{},
+	}
+	if result.Error != "" {
+		run.Invocations[0].ToolExecutionNotifications = []sarifNotification{
+			{Level: "error", Message: sarifMessage{Text: result.Error}},
+		}
+	}
+
+	for _, file := range result.Files {
+		run.Results = append(run.Results, sarifFileResults(file)...)
+	}
+	for _, edit := range result.ImportEdits {
+		if edit.Error == "" {
+			continue
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  RuleImportUpdateFailed,
+			Level:   "warning",
+			Message: sarifMessage{Text: fmt.Sprintf("Could not rewrite import of %s from %s to %s: %s", edit.Definition, edit.From, edit.To, edit.Error)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifact(edit.File),
+				Region:           &sarifRegion{StartLine: edit.Line, EndLine: edit.Line},
+			}}},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// sarifFileResults returns the results for one file
+func sarifFileResults(file FileResult) []sarifResult {
+	fileLocation := []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifact(file.File)}}}
+
+	var results []sarifResult
+	if file.Error != "" {
+		results = append(results, sarifResult{
+			RuleID:    RuleMergeError,
+			Level:     "error",
+			Message:   sarifMessage{Text: fmt.Sprintf("Failed to merge %s: %s", file.File, file.Error)},
+			Locations: fileLocation,
+		})
+	}
+	if len(file.Conflicts) == 0 && file.UnresolvedCount() > 0 {
+		results = append(results, sarifResult{
+			RuleID:    RuleTextConflict,
+			Level:     "error",
+			Message:   sarifMessage{Text: fmt.Sprintf("%s has a conflict that needs manual resolution", file.File)},
+			Locations: fileLocation,
+		})
+	}
+
+	for _, c := range file.Conflicts {
+		if !c.Unresolved() {
+			continue
+		}
+		location := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifact(file.File)},
+			LogicalLocations: []sarifLogicalLocation{{Name: c.Name, FullyQualifiedName: file.File + ":" + c.Name, Kind: c.Kind}},
+		}
+		if c.Local != nil {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: c.Local.StartLine, EndLine: c.Local.EndLine}
+		}
+		results = append(results, sarifResult{
+			RuleID:              RuleUnresolvedConflict,
+			Level:               "error",
+			Message:             sarifMessage{Text: fmt.Sprintf("%s (%s)", c.Type, c.Status)},
+			Locations:           []sarifLocation{location},
+			PartialFingerprints: map[string]string{"definition/v1": file.File + ":" + c.Kind + ":" + c.Name},
+			Properties:          map[string]any{"resolution": c.Resolution},
+		})
+	}
+	return results
+}
+
+// sarifArtifact returns the location of a repository file
+func sarifArtifact(file string) sarifArtifactLocation {
+	return sarifArtifactLocation{URI: file, URIBaseID: sarifSrcRoot}
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// reportResult returns a merge result with one unresolved conflict, one
+// auto-merged conflict, a text conflict and a failed import rewrite
+func reportResult() *MergeResult {
+	result := NewMergeResult()
+	result.AddFileResult(FileResult{
+		File:          "utils.py",
+		ConflictCount: 2,
+		ResolvedCount: 1,
+		HasMarkers:    true,
+		Conflicts: []ConflictDetail{
+			{Name: "calc", Kind: "function", Type: "Function 'calc' Modified", Status: "Needs Resolution", Resolution: "none",
+				Local: &Range{StartLine: 10, EndLine: 14}},
+			{Name: "fmt", Kind: "function", Type: "Function 'fmt' Updated (remote)", Status: "Can Auto-merge", Resolution: "auto", Source: "auto"},
+		},
+	})
+	result.AddFileResult(FileResult{File: "data.bin", ConflictCount: 1, HasMarkers: true, Conflicts: []ConflictDetail{}})
+	result.AddImportEdit(ImportEdit{File: "main.py", Line: 3, Definition: "helper", From: "a", To: "b", Error: "permission denied"})
+	result.Finalize()
+	return result
+}
+
+func TestMergeResultSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, reportResult(), FormatSARIF); err != nil {
+		t.Fatalf("failed to write SARIF: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region *struct {
+							StartLine int `json:"startLine"`
+							EndLine   int `json:"endLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %s", buf.String())
+	}
+
+	results := log.Runs[0].Results
+	var rules []string
+	for _, r := range results {
+		rules = append(rules, r.RuleID+"@"+r.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	want := []string{"unresolved-conflict@utils.py", "text-conflict@data.bin", "import-update-failed@main.py"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("expected results %v, got %v", want, rules)
+	}
+	if region := results[0].Locations[0].PhysicalLocation.Region; region == nil || region.StartLine != 10 || region.EndLine != 14 {
+		t.Errorf("unexpected region: %+v", region)
+	}
+	if results[2].Level != "warning" {
+		t.Errorf("expected a warning for the failed import rewrite, got %s", results[2].Level)
+	}
+}
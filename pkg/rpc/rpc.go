@@ -0,0 +1,193 @@
+// Package rpc implements JSON-RPC 2.0 over stdio with the Content-Length
+// framing of the Language Server Protocol, and the subset of LSP types that
+// `g2 rpc` uses to show conflicts in editors.
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error codes defined by JSON-RPC 2.0 and LSP
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeNotInitialized = -32002
+)
+
+// Message is a request, notification or response. Requests have an ID and a
+// Method, notifications only a Method, responses an ID and a Result or Error.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// IsRequest reports whether the message expects a response
+func (m *Message) IsRequest() bool {
+	return m.Method != "" && len(m.ID) > 0
+}
+
+// Error is the error of a failed request
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an error with a JSON-RPC error code
+func Errorf(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conn reads and writes framed messages. Writes are safe for concurrent use.
+type Conn struct {
+	r  *bufio.Reader
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewConn returns a connection reading from r and writing to w
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: bufio.NewReader(r), w: w}
+}
+
+// Read returns the next message. It returns io.EOF when the input is closed
+// between messages.
+func (c *Conn) Read() (*Message, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	var msg Message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, Errorf(CodeParseError, "invalid message: %v", err)
+	}
+	return &msg, nil
+}
+
+// Reply sends the response to a request. A non-nil err is sent as the
+// response's error; errors other than *Error are internal errors.
+func (c *Conn) Reply(id json.RawMessage, result any, err error) error {
+	msg := Message{ID: id}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		msg.Error = rpcErr
+	} else {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = raw
+	}
+	return c.write(msg)
+}
+
+// Notify sends a notification
+func (c *Conn) Notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(Message{Method: method, Params: raw})
+}
+
+// write frames and sends a message
+func (c *Conn) write(msg Message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// Handler handles one request or notification. The result of a
+// notification is discarded.
+type Handler func(method string, params json.RawMessage) (any, error)
+
+// Serve reads messages from conn and passes them to handle until the input
+// is closed or handle returns ErrExit. Responses sent by the client are
+// ignored.
+func Serve(conn *Conn, handle Handler) error {
+	for {
+		msg, err := conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if rpcErr, ok := err.(*Error); ok {
+			// The body was read, so the stream is still in sync
+			conn.Reply(json.RawMessage("null"), nil, rpcErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "" {
+			continue
+		}
+
+		result, err := handle(msg.Method, msg.Params)
+		if err == ErrExit {
+			if msg.IsRequest() {
+				conn.Reply(msg.ID, nil, nil)
+			}
+			return nil
+		}
+		if msg.IsRequest() {
+			if err := conn.Reply(msg.ID, result, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ErrExit is returned by a Handler to stop Serve
+var ErrExit = &Error{Code: 0, Message: "exit"}
+
+// Unmarshal decodes request params, reporting failures as invalid params
+func Unmarshal(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return Errorf(CodeInvalidParams, "invalid params: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// frame returns a message with Content-Length framing
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readAll reads every message written to out
+func readAll(t *testing.T, out *bytes.Buffer) []*Message {
+	t.Helper()
+	var msgs []*Message
+	conn := NewConn(out, nil)
+	for {
+		msg, err := conn.Read()
+		if err == io.EOF {
+			return msgs
+		}
+		if err != nil {
+			t.Fatalf("invalid output: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// TestConn tests reading and writing framed messages
+func TestConn(t *testing.T) {
+	var out bytes.Buffer
+	conn := NewConn(nil, &out)
+	if err := conn.Reply(json.RawMessage("1"), map[string]int{"n": 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Reply(json.RawMessage(`"a"`), nil, Errorf(CodeInvalidParams, "bad id")); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Reply(json.RawMessage("2"), nil, errors.New("disk full")); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Notify("g2/changed", []string{"calc.py"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "Content-Length: ") {
+		t.Fatalf("expected a Content-Length header, got %q", out.String())
+	}
+
+	msgs := readAll(t, &out)
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(msgs))
+	}
+	if m := msgs[0]; m.JSONRPC != "2.0" || string(m.ID) != "1" || string(m.Result) != `{"n":2}` || m.Error != nil {
+		t.Errorf("unexpected result: %+v", m)
+	}
+	if m := msgs[1]; m.Error == nil || m.Error.Code != CodeInvalidParams || m.Error.Message != "bad id" {
+		t.Errorf("unexpected error: %+v", m)
+	}
+	if m := msgs[2]; m.Error == nil || m.Error.Code != CodeInternalError {
+		t.Errorf("other errors should be internal errors: %+v", m)
+	}
+	if m := msgs[3]; m.IsRequest() || m.Method != "g2/changed" || string(m.Params) != `["calc.py"]` {
+		t.Errorf("unexpected notification: %+v", m)
+	}
+
+	for _, input := range []string{
+		"Content-Length: x\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+		"Content-Type: text/plain\r\n",
+	} {
+		if _, err := NewConn(strings.NewReader(input), nil).Read(); err == nil || err == io.EOF {
+			t.Errorf("%q: expected an error, got %v", input, err)
+		}
+	}
+	_, err := NewConn(strings.NewReader(frame("not json")), nil).Read()
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != CodeParseError {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+// TestServe tests dispatching requests and notifications until exit
+func TestServe(t *testing.T) {
+	input := frame(`{"jsonrpc":"2.0","id":1,"method":"echo","params":"hi"}`) +
+		frame(`{"jsonrpc":"2.0","method":"note","params":{}}`) +
+		frame(`not json`) +
+		frame(`{"jsonrpc":"2.0","id":7,"result":null}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"fail"}`) +
+		frame(`{"jsonrpc":"2.0","id":3,"method":"exit"}`) +
+		frame(`{"jsonrpc":"2.0","id":4,"method":"echo","params":"late"}`)
+
+	var out bytes.Buffer
+	var methods []string
+	err := Serve(NewConn(strings.NewReader(input), &out), func(method string, params json.RawMessage) (any, error) {
+		methods = append(methods, method)
+		switch method {
+		case "fail":
+			return nil, Errorf(CodeMethodNotFound, "no")
+		case "exit":
+			return nil, ErrExit
+		}
+		return params, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "echo note fail exit"; strings.Join(methods, " ") != want {
+		t.Errorf("handled %q, want %q", methods, want)
+	}
+
+	msgs := readAll(t, &out)
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 responses, got %d", len(msgs))
+	}
+	if m := msgs[0]; string(m.ID) != "1" || string(m.Result) != `"hi"` {
+		t.Errorf("unexpected echo response: %+v", m)
+	}
+	if m := msgs[1]; string(m.ID) != "null" || m.Error == nil || m.Error.Code != CodeParseError {
+		t.Errorf("expected a parse error for invalid JSON: %+v", m)
+	}
+	if m := msgs[2]; string(m.ID) != "2" || m.Error == nil || m.Error.Code != CodeMethodNotFound {
+		t.Errorf("unexpected error response: %+v", m)
+	}
+	if m := msgs[3]; string(m.ID) != "3" || m.Error != nil {
+		t.Errorf("exit should be answered: %+v", m)
+	}
+
+	if err := Serve(NewConn(strings.NewReader("Content-Length: 5\r\n\r\n{"), io.Discard), nil); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
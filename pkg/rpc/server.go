@@ -0,0 +1,473 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/output"
+	"github.com/simonkoeck/g2/pkg/semantic"
+)
+
+// ResolveCommand is the workspace/executeCommand command of code actions,
+// with arguments [id, resolution]
+const ResolveCommand = "g2.resolve"
+
+// Backend is the merge machinery a Server drives
+type Backend interface {
+	// Load analyzes the conflicting files, relative to the repository root
+	Load(ctx context.Context) ([]string, map[string]*semantic.SynthesisAnalysis, error)
+	// Detail converts a conflict for output
+	Detail(c *semantic.SynthesisConflict) output.ConflictDetail
+	// Resolve applies a resolution by name; "none" undoes it
+	Resolve(c *semantic.SynthesisConflict, resolution string) error
+	// Write synthesizes files, staging those without conflict markers
+	Write(ctx context.Context, files []string, syntheses map[string]*semantic.SynthesisAnalysis) map[string]*semantic.SynthesisResult
+	// FileResult converts the result of writing a file for output
+	FileResult(file string, result *semantic.SynthesisResult) output.FileResult
+}
+
+// Server gives editors the semantic conflicts of the operation in progress,
+// as language server diagnostics and code actions and as g2/* methods.
+// Conflict ids stay stable until g2/reload; files that are written and
+// staged are dropped.
+type Server struct {
+	ctx       context.Context
+	backend   Backend
+	conn      *Conn
+	root      string // Repository root; files are relative to it
+	version   string
+	files     []string
+	syntheses map[string]*semantic.SynthesisAnalysis
+	refs      []conflictRef
+
+	initialized bool // initialize was received
+	shutdown    bool // shutdown was received; only exit is accepted
+}
+
+// conflictRef locates a conflict by its id
+type conflictRef struct {
+	file  string
+	index int // Index in the file's synthesis.Conflicts
+}
+
+// NewServer returns a server for the repository at root, answering on conn
+func NewServer(ctx context.Context, backend Backend, conn *Conn, root, version string) *Server {
+	return &Server{ctx: ctx, backend: backend, conn: conn, root: root, version: version}
+}
+
+// Conflict is a conflict as returned by g2/conflicts and g2/conflict
+type Conflict struct {
+	ID   int    `json:"id"`
+	File string `json:"file"`
+	output.ConflictDetail
+	Bodies *Bodies `json:"bodies,omitempty"` // Only from g2/conflict
+}
+
+// Bodies are the versions of a conflict's definition. Merged is set for
+// conflicts g2 merges itself.
+type Bodies struct {
+	Base   string `json:"base,omitempty"`
+	Local  string `json:"local,omitempty"`
+	Remote string `json:"remote,omitempty"`
+	Merged string `json:"merged,omitempty"`
+}
+
+// Preview is the result of g2/preview
+type Preview struct {
+	File     string `json:"file"`
+	Content  string `json:"content"`
+	Resolved bool   `json:"resolved"` // No conflict markers remain
+}
+
+// params are the params of the g2/* methods
+type params struct {
+	ID         int      `json:"id"`
+	File       string   `json:"file"`
+	Files      []string `json:"files"`
+	Resolution string   `json:"resolution"`
+}
+
+// Load analyzes the conflicting files and numbers their conflicts from 1,
+// in file order
+func (s *Server) Load() error {
+	files, syntheses, err := s.backend.Load(s.ctx)
+	if err != nil {
+		return err
+	}
+	s.files, s.syntheses, s.refs = files, syntheses, nil
+	for _, file := range files {
+		if synthesis, ok := syntheses[file]; ok {
+			for i := range synthesis.Conflicts {
+				s.refs = append(s.refs, conflictRef{file: file, index: i})
+			}
+		}
+	}
+	return nil
+}
+
+// Handle dispatches one request or notification; it is the Handler of Serve.
+// As in LSP, everything but exit is refused before initialize and after
+// shutdown.
+func (s *Server) Handle(method string, raw json.RawMessage) (any, error) {
+	switch {
+	case method == "exit":
+		return nil, ErrExit
+	case s.shutdown:
+		return nil, Errorf(CodeInvalidRequest, "server is shut down")
+	case !s.initialized && method != "initialize":
+		return nil, Errorf(CodeNotInitialized, "server not initialized")
+	}
+
+	var p params
+	switch method {
+	case "initialize":
+		if s.initialized {
+			return nil, Errorf(CodeInvalidRequest, "server already initialized")
+		}
+		s.initialized = true
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				CodeActionProvider:     true,
+				ExecuteCommandProvider: ExecuteCommandOptions{Commands: []string{ResolveCommand}},
+			},
+			ServerInfo: ServerInfo{Name: "g2", Version: s.version},
+		}, nil
+	case "initialized":
+		s.publishDiagnostics(s.files...)
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/codeAction":
+		var cp CodeActionParams
+		if err := Unmarshal(raw, &cp); err != nil {
+			return nil, err
+		}
+		return s.codeActions(cp), nil
+	case "workspace/executeCommand":
+		var ep ExecuteCommandParams
+		if err := Unmarshal(raw, &ep); err != nil {
+			return nil, err
+		}
+		if ep.Command != ResolveCommand || len(ep.Arguments) != 2 {
+			return nil, Errorf(CodeInvalidParams, "unknown command %q", ep.Command)
+		}
+		if err := json.Unmarshal(ep.Arguments[0], &p.ID); err != nil {
+			return nil, Errorf(CodeInvalidParams, "invalid conflict id: %v", err)
+		}
+		if err := json.Unmarshal(ep.Arguments[1], &p.Resolution); err != nil {
+			return nil, Errorf(CodeInvalidParams, "invalid resolution: %v", err)
+		}
+		return s.resolve(p.ID, p.Resolution)
+	}
+
+	if err := Unmarshal(raw, &p); err != nil {
+		return nil, err
+	}
+	switch method {
+	case "g2/reload":
+		if err := s.Load(); err != nil {
+			return nil, err
+		}
+		s.publishDiagnostics(s.files...)
+		return s.conflicts(""), nil
+	case "g2/conflicts":
+		return s.conflicts(p.File), nil
+	case "g2/conflict":
+		c, err := s.lookup(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return s.conflict(p.ID, c, true), nil
+	case "g2/resolve":
+		return s.resolve(p.ID, p.Resolution)
+	case "g2/preview":
+		synthesis, ok := s.syntheses[p.File]
+		if !ok {
+			return nil, Errorf(CodeInvalidParams, "no semantic conflicts in %q", p.File)
+		}
+		content, resolved, err := semantic.SynthesizeToBytes(synthesis)
+		if err != nil {
+			return nil, err
+		}
+		return Preview{File: p.File, Content: string(content), Resolved: resolved}, nil
+	case "g2/write":
+		return s.write(p.Files)
+	}
+	return nil, Errorf(CodeMethodNotFound, "unknown method %q", method)
+}
+
+// lookup returns the conflict with the given id
+func (s *Server) lookup(id int) (*semantic.SynthesisConflict, error) {
+	if id >= 1 && id <= len(s.refs) {
+		ref := s.refs[id-1]
+		if synthesis, ok := s.syntheses[ref.file]; ok {
+			return &synthesis.Conflicts[ref.index], nil
+		}
+	}
+	return nil, Errorf(CodeInvalidParams, "no conflict %d", id)
+}
+
+// conflict converts a conflict for output, with or without its bodies
+func (s *Server) conflict(id int, c *semantic.SynthesisConflict, bodies bool) Conflict {
+	detail := s.backend.Detail(c)
+	conflict := Conflict{ID: id, File: s.refs[id-1].file, ConflictDetail: detail}
+	if bodies && detail.Bodies != nil {
+		conflict.Bodies = &Bodies{Base: detail.Bodies.Base, Local: detail.Bodies.Local, Remote: detail.Bodies.Remote}
+		if detail.Bodies.HasMerged {
+			conflict.Bodies.Merged = detail.Bodies.Merged
+		}
+	}
+	return conflict
+}
+
+// conflicts lists the conflicts of a file, or of all files, without bodies
+func (s *Server) conflicts(file string) []Conflict {
+	list := []Conflict{}
+	for id := 1; id <= len(s.refs); id++ {
+		if file != "" && s.refs[id-1].file != file {
+			continue
+		}
+		if c, err := s.lookup(id); err == nil {
+			list = append(list, s.conflict(id, c, false))
+		}
+	}
+	return list
+}
+
+// resolve applies a resolution and refreshes the file's diagnostics
+func (s *Server) resolve(id int, resolution string) (any, error) {
+	c, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.Resolve(c, resolution); err != nil {
+		return nil, Errorf(CodeInvalidParams, "%v", err)
+	}
+	s.publishDiagnostics(s.refs[id-1].file)
+	return s.conflict(id, c, false), nil
+}
+
+// write synthesizes files (by default all that are still served) with the
+// resolutions so far. Files without conflict markers are staged and no
+// longer served.
+func (s *Server) write(files []string) ([]output.FileResult, error) {
+	if len(files) == 0 {
+		for _, file := range s.files {
+			if _, ok := s.syntheses[file]; ok {
+				files = append(files, file)
+			}
+		}
+	}
+	for _, file := range files {
+		if _, ok := s.syntheses[file]; !ok {
+			return nil, Errorf(CodeInvalidParams, "no semantic conflicts in %q", file)
+		}
+	}
+
+	resultsByFile := s.backend.Write(s.ctx, files, s.syntheses)
+	results := make([]output.FileResult, 0, len(files))
+	for _, file := range files {
+		result := resultsByFile[file]
+		results = append(results, s.backend.FileResult(file, result))
+		if result != nil && result.Staged {
+			delete(s.syntheses, file)
+		}
+	}
+	s.publishDiagnostics(files...)
+	return results, nil
+}
+
+// publishDiagnostics sends the conflicts of files as diagnostics. Files
+// that are no longer served get an empty list.
+func (s *Server) publishDiagnostics(files ...string) {
+	for _, file := range files {
+		if !semantic.IsSemanticFile(file) {
+			continue
+		}
+		diagnostics := []Diagnostic{}
+		if _, ok := s.syntheses[file]; ok {
+			diagnostics = s.diagnostics(file)
+		}
+		s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         FileURI(filepath.Join(s.root, file)),
+			Diagnostics: diagnostics,
+		})
+	}
+}
+
+// diagnostics returns a diagnostic per conflict of a file, located in its
+// working tree version
+func (s *Server) diagnostics(file string) []Diagnostic {
+	content, _ := os.ReadFile(filepath.Join(s.root, file))
+	diagnostics := []Diagnostic{}
+	for id := 1; id <= len(s.refs); id++ {
+		if s.refs[id-1].file != file {
+			continue
+		}
+		c, err := s.lookup(id)
+		if err != nil {
+			continue
+		}
+		d := Diagnostic{
+			Range:   conflictRange(content, c),
+			Code:    id,
+			Source:  "g2",
+			Message: c.UIConflict.ConflictType,
+		}
+		switch {
+		case c.UIConflict.Status != "Needs Resolution":
+			d.Severity = SeverityHint
+			d.Message += " (" + strings.ToLower(c.UIConflict.Status) + ")"
+		case c.UserResolution != semantic.UserResolutionNone:
+			d.Severity = SeverityInformation
+			d.Message += " (resolved: " + c.UserResolution.String() + ")"
+		default:
+			d.Severity = SeverityError
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// codeActions offers each resolution of the unresolved conflicts in range.
+// An action only records the resolution with the g2.resolve command; the
+// buffer, which may hold the user's own edits, is left alone until g2/write.
+func (s *Server) codeActions(p CodeActionParams) []CodeAction {
+	actions := []CodeAction{}
+	path := URIPath(p.TextDocument.URI)
+	file, err := filepath.Rel(s.root, path)
+	if path == "" || err != nil {
+		return actions
+	}
+	if _, ok := s.syntheses[file]; !ok {
+		return actions
+	}
+
+	for _, d := range s.diagnostics(file) {
+		c, _ := s.lookup(d.Code)
+		if d.Severity != SeverityError || !d.Range.Overlaps(p.Range) {
+			continue
+		}
+		resolutions := []semantic.UserResolution{semantic.UserResolutionLocal, semantic.UserResolutionRemote, semantic.UserResolutionBoth}
+		if c.Base != nil {
+			resolutions = append(resolutions, semantic.UserResolutionBase)
+		}
+		for _, r := range resolutions {
+			title := fmt.Sprintf("Accept %s: %s", r, c.UIConflict.ConflictType)
+			actions = append(actions, CodeAction{
+				Title:       title,
+				Kind:        CodeActionQuickFix,
+				Diagnostics: []Diagnostic{d},
+				Command:     &Command{Title: title, Command: ResolveCommand, Arguments: []any{d.Code, r.String()}},
+			})
+		}
+	}
+	return actions
+}
+
+// conflictRange locates a conflict in the working tree version of its file,
+// which has conflict markers, by mapping the recorded range of its local,
+// remote or base definition through the marker regions (mergeSides). A range
+// that reaches into a marker region is widened to the whole region. It falls
+// back to the recorded lines if the side cannot be mapped.
+func conflictRange(content []byte, c *semantic.SynthesisConflict) Range {
+	sides := mergeSides(content)
+	for _, side := range []struct {
+		def   *semantic.Definition
+		lines []int
+	}{{c.Local, sides.local}, {c.Remote, sides.remote}, {c.Base, sides.base}} {
+		def := side.def
+		if def == nil || def.StartLine > def.EndLine || int(def.EndLine) >= len(side.lines) {
+			continue
+		}
+		start, end := side.lines[def.StartLine], side.lines[def.EndLine]
+		for _, region := range sides.regions {
+			if region[0] <= end && start <= region[1] {
+				start, end = min(start, region[0]), max(end, region[1])
+			}
+		}
+		return Range{Start: Position{Line: start}, End: Position{Line: end + 1}}
+	}
+	fallback := semantic.ConflictDefinition(c)
+	if fallback == nil {
+		return Range{}
+	}
+	return Range{
+		Start: Position{Line: int(fallback.StartLine)},
+		End:   Position{Line: int(fallback.EndLine) + 1},
+	}
+}
+
+// mergeLines maps the lines of each side of a merge to lines of the working
+// tree file
+type mergeLines struct {
+	local, base, remote []int    // Working tree line of each line of the side
+	regions             [][2]int // First and last line of each marker region
+}
+
+// mergeSides maps the sides of a merge onto a working tree file with
+// conflict markers. Lines outside marker regions belong to every side;
+// lines inside belong to the section of their side. If a region has no base
+// section (only diff3 and zdiff3 styles write one), the base is dropped.
+func mergeSides(content []byte) mergeLines {
+	var m mergeLines
+	const (
+		outside = iota
+		ours
+		ancestor
+		theirs
+	)
+	section, hasBase, baseLost := outside, true, false
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		switch {
+		case isMarker(line, "<<<<<<<") && section == outside:
+			section = ours
+			m.regions = append(m.regions, [2]int{i, i})
+			hasBase = false
+			continue
+		case isMarker(line, "|||||||") && section == ours:
+			section, hasBase = ancestor, true
+			continue
+		case isMarker(line, "=======") && (section == ours || section == ancestor):
+			section = theirs
+			continue
+		case isMarker(line, ">>>>>>>") && section == theirs:
+			section = outside
+			m.regions[len(m.regions)-1][1] = i
+			baseLost = baseLost || !hasBase
+			continue
+		}
+		switch section {
+		case outside:
+			m.local = append(m.local, i)
+			m.remote = append(m.remote, i)
+			m.base = append(m.base, i)
+		case ours:
+			m.local = append(m.local, i)
+		case ancestor:
+			m.base = append(m.base, i)
+		case theirs:
+			m.remote = append(m.remote, i)
+		}
+	}
+	if section != outside {
+		// An unterminated region: the markers are not git's
+		return mergeLines{}
+	}
+	if baseLost {
+		m.base = nil
+	}
+	return m
+}
+
+// isMarker reports whether line is a conflict marker of the given kind
+func isMarker(line, marker string) bool {
+	rest, ok := strings.CutPrefix(line, marker)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\r')
+}
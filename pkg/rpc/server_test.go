@@ -0,0 +1,317 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simonkoeck/g2/pkg/output"
+	"github.com/simonkoeck/g2/pkg/semantic"
+)
+
+// testBackend serves fixed conflicts and writes files without git; files
+// without conflict markers count as staged
+type testBackend struct {
+	root      string
+	files     []string
+	syntheses map[string]*semantic.SynthesisAnalysis
+}
+
+func (b testBackend) Load(ctx context.Context) ([]string, map[string]*semantic.SynthesisAnalysis, error) {
+	return b.files, b.syntheses, nil
+}
+
+func (b testBackend) Detail(c *semantic.SynthesisConflict) output.ConflictDetail {
+	detail := output.ConflictDetail{
+		Type:       c.UIConflict.ConflictType,
+		Status:     c.UIConflict.Status,
+		Resolution: c.Resolution(),
+		Source:     c.ResolutionSource(),
+		Bodies:     &output.Bodies{},
+	}
+	if c.Local != nil {
+		detail.Bodies.Local = c.Local.Body
+	}
+	if c.Remote != nil {
+		detail.Bodies.Remote = c.Remote.Body
+	}
+	return detail
+}
+
+func (b testBackend) Resolve(c *semantic.SynthesisConflict, name string) error {
+	resolution, ok := semantic.ParseUserResolution(name)
+	if !ok {
+		return fmt.Errorf("unknown resolution %q", name)
+	}
+	c.UserResolution, c.ResolvedBy = resolution, semantic.ResolvedByEditor
+	return nil
+}
+
+func (b testBackend) Write(ctx context.Context, files []string, syntheses map[string]*semantic.SynthesisAnalysis) map[string]*semantic.SynthesisResult {
+	results := make(map[string]*semantic.SynthesisResult)
+	for _, file := range files {
+		content, resolved, err := semantic.SynthesizeToBytes(syntheses[file])
+		if err == nil {
+			err = os.WriteFile(filepath.Join(b.root, file), content, 0644)
+		}
+		results[file] = &semantic.SynthesisResult{File: file, Success: err == nil, Error: err, AllAutoMerged: resolved, Written: err == nil, Staged: err == nil && resolved}
+	}
+	return results
+}
+
+func (b testBackend) FileResult(file string, result *semantic.SynthesisResult) output.FileResult {
+	return output.FileResult{File: file, AllAutoMerged: result.AllAutoMerged, HasMarkers: !result.AllAutoMerged}
+}
+
+// session sends requests (id 0 for notifications) to a server for backend
+// and returns the responses by id and the diagnostics published per URI
+func session(t *testing.T, backend testBackend, requests ...string) (map[string]*Message, map[string][]PublishDiagnosticsParams) {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, r := range requests {
+		in.WriteString(frame(r))
+	}
+	conn := NewConn(&in, &out)
+	server := NewServer(context.Background(), backend, conn, backend.root, "test")
+	if err := server.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Serve(conn, server.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(map[string]*Message)
+	diagnostics := make(map[string][]PublishDiagnosticsParams)
+	for _, msg := range readAll(t, &out) {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var p PublishDiagnosticsParams
+			if err := json.Unmarshal(msg.Params, &p); err != nil {
+				t.Fatal(err)
+			}
+			diagnostics[p.URI] = append(diagnostics[p.URI], p)
+			continue
+		}
+		responses[string(msg.ID)] = msg
+	}
+	return responses, diagnostics
+}
+
+// request returns a request, or a notification if id is 0
+func request(id int, method, params string) string {
+	msg := fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"params":%s`, method, params)
+	if id != 0 {
+		msg += fmt.Sprintf(`,"id":%d`, id)
+	}
+	return msg + "}"
+}
+
+// TestServer tests a session: diagnostics, code actions, resolving,
+// previewing and writing a file
+func TestServer(t *testing.T) {
+	root := t.TempDir()
+	base := []byte("def calc(x):\n    return x\n\n\ndef helper():\n    return 1\n")
+	local := []byte("def calc(x):\n    return x + 1\n\n\ndef helper():\n    return 1\n")
+	remote := []byte("def calc(x):\n    return x + 2\n\n\ndef helper():\n    return 2\n")
+	// The working tree as git leaves it: calc in a marker region, helper merged
+	worktree := "def calc(x):\n<<<<<<< HEAD\n    return x + 1\n=======\n    return x + 2\n>>>>>>> feature\n\n\ndef helper():\n    return 2\n"
+	otherLocal := []byte("def other():\n    return 1\n")
+	for file, content := range map[string]string{"calc.py": worktree, "other.py": string(otherLocal), "README.md": "<<<<<<< HEAD\n"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backend := testBackend{
+		root: root,
+		// README.md is a text conflict without semantic analysis
+		files: []string{"README.md", "calc.py", "other.py"},
+		syntheses: map[string]*semantic.SynthesisAnalysis{
+			"calc.py":  semantic.AnalyzeConflictFromContents("calc.py", base, local, remote),
+			"other.py": semantic.AnalyzeConflictFromContents("other.py", []byte("def other():\n    return 0\n"), otherLocal, []byte("def other():\n    return 2\n")),
+		},
+	}
+	calcID := 0
+	for i, c := range backend.syntheses["calc.py"].Conflicts {
+		if c.Local.Name == "calc" {
+			calcID = i + 1
+		}
+	}
+	if len(backend.syntheses["calc.py"].Conflicts) != 2 || calcID == 0 {
+		t.Fatalf("expected calc and helper conflicts, got %+v", backend.syntheses["calc.py"].Conflicts)
+	}
+
+	uri := FileURI(filepath.Join(root, "calc.py"))
+	otherURI := FileURI(filepath.Join(root, "other.py"))
+	responses, diagnosticsByURI := session(t, backend,
+		request(1, "initialize", `{}`),
+		request(0, "initialized", `{}`),
+		request(2, "g2/conflicts", `{}`),
+		request(3, "g2/conflict", fmt.Sprintf(`{"id":%d}`, calcID)),
+		request(4, "textDocument/codeAction", fmt.Sprintf(`{"textDocument":{"uri":%q},"range":{"start":{"line":3,"character":0},"end":{"line":3,"character":0}}}`, uri)),
+		request(5, "g2/resolve", fmt.Sprintf(`{"id":%d,"resolution":"sideways"}`, calcID)),
+		request(6, "workspace/executeCommand", fmt.Sprintf(`{"command":"g2.resolve","arguments":[%d,"remote"]}`, calcID)),
+		request(7, "g2/preview", `{"file":"calc.py"}`),
+		request(8, "g2/write", `{}`),
+		request(9, "g2/conflicts", `{}`),
+		request(10, "g2/unknown", `{}`),
+		request(11, "g2/write", `{}`),
+		request(12, "shutdown", `null`),
+		request(0, "exit", `null`),
+	)
+	result := func(id string, v any) {
+		t.Helper()
+		msg := responses[id]
+		if msg == nil || msg.Error != nil {
+			t.Fatalf("request %s failed: %+v", id, msg)
+		}
+		if err := json.Unmarshal(msg.Result, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var init InitializeResult
+	result("1", &init)
+	if !init.Capabilities.CodeActionProvider || init.Capabilities.ExecuteCommandProvider.Commands[0] != ResolveCommand || init.ServerInfo.Version != "test" {
+		t.Errorf("unexpected initialize result: %+v", init)
+	}
+
+	// Diagnostics after initialized, after resolving and after writing;
+	// other.py keeps its markers, so it is written again by the second write
+	diagnostics := diagnosticsByURI[uri]
+	if len(diagnostics) != 3 || len(diagnosticsByURI[otherURI]) != 3 || len(diagnosticsByURI) != 2 {
+		t.Fatalf("unexpected diagnostics: %+v", diagnosticsByURI)
+	}
+	for _, d := range diagnostics[0].Diagnostics {
+		if d.Code == calcID && (d.Severity != SeverityError || d.Range.Start.Line != 0 || d.Range.End.Line != 6) {
+			t.Errorf("calc should be an error covering its marker region: %+v", d)
+		}
+		if d.Code != calcID && (d.Severity != SeverityHint || d.Range.Start.Line != 8) {
+			t.Errorf("auto-merged helper should be a hint after the markers: %+v", d)
+		}
+	}
+	if d := diagnostics[1].Diagnostics; len(d) != 2 || !strings.Contains(d[calcID-1].Message, "resolved: remote") {
+		t.Errorf("unexpected diagnostics after resolving: %+v", d)
+	}
+	if len(diagnostics[2].Diagnostics) != 0 {
+		t.Errorf("a staged file should have no diagnostics: %+v", diagnostics[2])
+	}
+
+	var conflicts []Conflict
+	result("2", &conflicts)
+	if len(conflicts) != 3 || conflicts[0].Bodies != nil {
+		t.Errorf("expected 3 conflicts without bodies, got %+v", conflicts)
+	}
+	var conflict Conflict
+	result("3", &conflict)
+	if conflict.File != "calc.py" || !strings.Contains(conflict.Bodies.Local, "x + 1") || !strings.Contains(conflict.Bodies.Remote, "x + 2") {
+		t.Errorf("unexpected conflict: %+v", conflict)
+	}
+
+	var actions []CodeAction
+	result("4", &actions)
+	if len(actions) != 4 || actions[1].Title != "Accept remote: Function 'calc' Modified" {
+		t.Fatalf("unexpected code actions: %+v", actions)
+	}
+	if a := actions[1]; a.Edit != nil || a.Command == nil || a.Command.Command != ResolveCommand || a.Command.Arguments[1] != "remote" {
+		t.Errorf("expected only the g2.resolve command, got %+v", a)
+	}
+
+	if msg := responses["5"]; msg == nil || msg.Error == nil || msg.Error.Code != CodeInvalidParams {
+		t.Errorf("expected invalid params for an unknown resolution, got %+v", msg)
+	}
+	result("6", &conflict)
+	if conflict.Resolution != "remote" || conflict.Source != "editor" {
+		t.Errorf("unexpected resolution: %+v", conflict)
+	}
+	var preview Preview
+	result("7", &preview)
+	if !preview.Resolved || preview.Content != string(remote) {
+		t.Errorf("unexpected preview: %+v", preview)
+	}
+	var written []output.FileResult
+	result("8", &written)
+	if len(written) != 2 || written[0].File != "calc.py" || !written[0].AllAutoMerged || written[1].File != "other.py" || !written[1].HasMarkers {
+		t.Errorf("unexpected write result: %+v", written)
+	}
+	if merged, _ := os.ReadFile(filepath.Join(root, "calc.py")); string(merged) != string(remote) {
+		t.Errorf("expected the remote version on disk, got:\n%s", merged)
+	}
+	result("9", &conflicts)
+	if len(conflicts) != 1 || conflicts[0].File != "other.py" {
+		t.Errorf("staged files should no longer be served, got %+v", conflicts)
+	}
+	if msg := responses["10"]; msg == nil || msg.Error == nil || msg.Error.Code != CodeMethodNotFound {
+		t.Errorf("expected method not found, got %+v", msg)
+	}
+	result("11", &written)
+	if len(written) != 1 || written[0].File != "other.py" {
+		t.Errorf("a second write should only write the files still served, got %+v", written)
+	}
+	if responses["12"] == nil {
+		t.Error("expected a shutdown response")
+	}
+}
+
+// TestServerLifecycle tests that requests are refused before initialize and
+// after shutdown
+func TestServerLifecycle(t *testing.T) {
+	backend := testBackend{root: t.TempDir()}
+	responses, _ := session(t, backend,
+		request(1, "g2/conflicts", `{}`),
+		request(2, "initialize", `{}`),
+		request(3, "initialize", `{}`),
+		request(4, "g2/conflicts", `{}`),
+		request(5, "shutdown", `null`),
+		request(6, "g2/conflicts", `{}`),
+		request(7, "exit", `null`),
+	)
+	for id, code := range map[string]int{"1": CodeNotInitialized, "3": CodeInvalidRequest, "6": CodeInvalidRequest} {
+		if msg := responses[id]; msg == nil || msg.Error == nil || msg.Error.Code != code {
+			t.Errorf("request %s: expected error %d, got %+v", id, code, msg)
+		}
+	}
+	for _, id := range []string{"2", "4", "5", "7"} {
+		if msg := responses[id]; msg == nil || msg.Error != nil {
+			t.Errorf("request %s should succeed, got %+v", id, msg)
+		}
+	}
+}
+
+// TestConflictRange tests locating conflicts through marker regions
+func TestConflictRange(t *testing.T) {
+	def := func(start, end uint32) *semantic.Definition {
+		return &semantic.Definition{StartLine: start, EndLine: end}
+	}
+	merge := "a = 1\n<<<<<<< HEAD\nb = 2\n=======\nb = 3\nc = 4\n>>>>>>> feature\n\ndef f():\n    return 1\n"
+	diff3 := "<<<<<<< HEAD\nx = 1\n||||||| base\nx = 0\n=======\n>>>>>>> feature\ny = 2\n"
+
+	tests := []struct {
+		name       string
+		content    string
+		c          semantic.SynthesisConflict
+		start, end int
+	}{
+		{"local line after a region", merge, semantic.SynthesisConflict{Local: def(3, 4)}, 8, 10},
+		{"local line in a region", merge, semantic.SynthesisConflict{Local: def(1, 1)}, 1, 7},
+		{"remote line in a region", merge, semantic.SynthesisConflict{Remote: def(2, 2)}, 1, 7},
+		{"remote line after a region", merge, semantic.SynthesisConflict{Remote: def(4, 4)}, 8, 9},
+		{"base without a base section", merge, semantic.SynthesisConflict{Base: def(4, 5)}, 4, 6},
+		{"base in a diff3 region", diff3, semantic.SynthesisConflict{Base: def(0, 0)}, 0, 6},
+		{"base after a diff3 region", diff3, semantic.SynthesisConflict{Base: def(1, 1)}, 6, 7},
+		{"local beyond the file", merge, semantic.SynthesisConflict{Local: def(20, 22)}, 20, 23},
+		{"unterminated region", "<<<<<<< HEAD\nx = 1\n", semantic.SynthesisConflict{Local: def(0, 0)}, 0, 1},
+		{"no definition", merge, semantic.SynthesisConflict{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := conflictRange([]byte(tt.content), &tt.c)
+			if r.Start.Line != tt.start || r.End.Line != tt.end {
+				t.Errorf("range = %d-%d, want %d-%d", r.Start.Line, r.End.Line, tt.start, tt.end)
+			}
+		})
+	}
+}
@@ -5,17 +5,13 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	sitter "github.com/smacker/go-tree-sitter"
-	"github.com/smacker/go-tree-sitter/golang"
-	"github.com/smacker/go-tree-sitter/javascript"
-	"github.com/smacker/go-tree-sitter/python"
-	"github.com/smacker/go-tree-sitter/rust"
-	"github.com/smacker/go-tree-sitter/typescript/typescript"
-	"github.com/smacker/go-tree-sitter/yaml"
 
 	"github.com/simonkoeck/g2/pkg/git"
+	"github.com/simonkoeck/g2/pkg/logging"
 	"github.com/simonkoeck/g2/pkg/ui"
 )
 
@@ -54,6 +50,10 @@ type Definition struct {
 	EndLine   uint32
 	StartByte uint32
 	EndByte   uint32
+
+	// Normalized is set by external analyzers: the body with comments and
+	// formatting removed. Empty means the built-in normalization is used.
+	Normalized string `json:",omitempty"`
 }
 
 // FileAnalysis contains parsed definitions from a file
@@ -112,8 +112,16 @@ func GetFileVersionWithContext(ctx context.Context, file string, stage int) ([]b
 	return output, nil
 }
 
-// DetectLanguage determines the language of a file based on extension
+// DetectLanguage determines the language of a file based on extension,
+// unless the repository configuration overrides it for the path
 func DetectLanguage(file string) Language {
+	settings := settingsFor(file)
+	if settings.Analyzer != nil && strings.TrimSpace(*settings.Analyzer) != "" {
+		return pluginLanguage(strings.TrimSpace(*settings.Analyzer))
+	}
+	if lang := settings.Language; lang != nil {
+		return *lang
+	}
 	ext := strings.ToLower(filepath.Ext(file))
 	switch ext {
 	case ".py":
@@ -147,8 +155,41 @@ func IsBinaryFile(content []byte) bool {
 	return bytes.Contains(content[:checkLen], []byte{0})
 }
 
-// ParseFile parses content based on detected language
+// ParseFile parses content based on detected language.
+// Results are served from and stored in the parse cache when one is set.
 func ParseFile(content []byte, lang Language) *FileAnalysis {
+	cache := parseCache
+	if cache == nil || lang == LangUnknown || isPluginLanguage(lang) || hasQueryOverride(lang) {
+		return parseContent(content, lang)
+	}
+
+	if analysis, ok := cache.Get(content, lang); ok {
+		return analysis
+	}
+
+	analysis := parseContent(content, lang)
+	if err := cache.Put(content, lang, analysis); err != nil {
+		logging.Debug("failed to write parse cache entry", "error", err)
+	}
+	return analysis
+}
+
+// parseContent parses content with the tree-sitter grammar for lang. Query
+// files in the repository replace or extend the built-in extraction.
+func parseContent(content []byte, lang Language) *FileAnalysis {
+	q := repoQuery(lang)
+	if q != nil && !q.extends {
+		return parseWithQuery(content, lang, q)
+	}
+	analysis := parseBuiltin(content, lang)
+	if q != nil && analysis.ParseError == nil {
+		return extendDefinitions(analysis, content, lang, q)
+	}
+	return analysis
+}
+
+// parseBuiltin extracts definitions with the built-in query or walker for lang
+func parseBuiltin(content []byte, lang Language) *FileAnalysis {
 	switch lang {
 	case LangPython:
 		return parsePython(content)
@@ -163,142 +204,22 @@ func ParseFile(content []byte, lang Language) *FileAnalysis {
 	case LangRust:
 		return parseRust(content)
 	default:
+		if isPluginLanguage(lang) {
+			return parsePlugin(content, lang)
+		}
 		return &FileAnalysis{ParseError: fmt.Errorf("unsupported language")}
 	}
 }
 
-// parsePython parses Python content
+// parsePython parses Python content with queries/python.scm
 func parsePython(content []byte) *FileAnalysis {
-	parser := sitter.NewParser()
-	parser.SetLanguage(python.GetLanguage())
-
-	tree, err := parser.ParseCtx(context.Background(), nil, content)
-	if err != nil {
-		return &FileAnalysis{ParseError: err}
-	}
-
-	analysis := &FileAnalysis{}
-	rootNode := tree.RootNode()
-
-	for i := 0; i < int(rootNode.NamedChildCount()); i++ {
-		child := rootNode.NamedChild(i)
-		extractPythonDefinitions(child, content, &analysis.Definitions)
-	}
-
-	return analysis
-}
-
-// extractPythonDefinitions extracts Python function and class definitions
-func extractPythonDefinitions(node *sitter.Node, content []byte, defs *[]Definition) {
-	if node == nil {
-		return
-	}
-
-	switch node.Type() {
-	case "function_definition":
-		if def := extractPythonFunction(node, content, ""); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "class_definition":
-		classDefs := extractPythonClassWithMethods(node, content)
-		*defs = append(*defs, classDefs...)
-	case "decorated_definition":
-		for i := 0; i < int(node.NamedChildCount()); i++ {
-			child := node.NamedChild(i)
-			if child.Type() == "function_definition" || child.Type() == "class_definition" {
-				extractPythonDefinitions(child, content, defs)
-			}
-		}
-	}
-}
-
-func extractPythonFunction(node *sitter.Node, content []byte, classPrefix string) *Definition {
-	var name, params string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "identifier":
-			name = child.Content(content)
-		case "parameters":
-			params = child.Content(content)
-		}
-	}
-	if name == "" {
-		return nil
-	}
-
-	// Use full node content as body for accurate reconstruction
-	body := node.Content(content)
-
-	fullName := name
-	kind := "function"
-	if classPrefix != "" {
-		fullName = classPrefix + "." + name
-		kind = "method"
-	}
-
-	return &Definition{
-		Name:      fullName,
-		Kind:      kind,
-		Signature: fmt.Sprintf("def %s%s", name, params),
-		Body:      body,
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-// extractPythonClassWithMethods extracts a class and all its methods as separate definitions
-func extractPythonClassWithMethods(node *sitter.Node, content []byte) []Definition {
-	var defs []Definition
-	var className string
-	var classBody *sitter.Node
-
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "identifier":
-			className = child.Content(content)
-		case "block":
-			classBody = child
-		}
-	}
-
-	if className == "" {
-		return defs
-	}
-
-	// Extract methods from the class body
-	if classBody != nil {
-		for i := 0; i < int(classBody.NamedChildCount()); i++ {
-			child := classBody.NamedChild(i)
-			switch child.Type() {
-			case "function_definition":
-				if def := extractPythonFunction(child, content, className); def != nil {
-					defs = append(defs, *def)
-				}
-			case "decorated_definition":
-				// Handle decorated methods (@staticmethod, @classmethod, @property, etc.)
-				for j := 0; j < int(child.NamedChildCount()); j++ {
-					grandchild := child.NamedChild(j)
-					if grandchild.Type() == "function_definition" {
-						if def := extractPythonFunction(grandchild, content, className); def != nil {
-							defs = append(defs, *def)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return defs
+	return parseWithQuery(content, LangPython, builtinQuery(LangPython))
 }
 
 // parseJavaScript parses JavaScript content
 func parseJavaScript(content []byte) *FileAnalysis {
-	parser := sitter.NewParser()
-	parser.SetLanguage(javascript.GetLanguage())
+	parser := acquireParser(LangJavaScript)
+	defer releaseParser(LangJavaScript, parser)
 
 	tree, err := parser.ParseCtx(context.Background(), nil, content)
 	if err != nil {
@@ -318,8 +239,8 @@ func parseJavaScript(content []byte) *FileAnalysis {
 
 // parseTypeScript parses TypeScript content
 func parseTypeScript(content []byte) *FileAnalysis {
-	parser := sitter.NewParser()
-	parser.SetLanguage(typescript.GetLanguage())
+	parser := acquireParser(LangTypeScript)
+	defer releaseParser(LangTypeScript, parser)
 
 	tree, err := parser.ParseCtx(context.Background(), nil, content)
 	if err != nil {
@@ -673,8 +594,8 @@ func extractTSTypeAlias(node *sitter.Node, content []byte) *Definition {
 
 // parseYAML parses YAML/JSON content and extracts top-level keys
 func parseYAML(content []byte) *FileAnalysis {
-	parser := sitter.NewParser()
-	parser.SetLanguage(yaml.GetLanguage())
+	parser := acquireParser(LangYAML)
+	defer releaseParser(LangYAML, parser)
 
 	tree, err := parser.ParseCtx(context.Background(), nil, content)
 	if err != nil {
@@ -728,427 +649,14 @@ func extractYAMLKeys(node *sitter.Node, content []byte, defs *[]Definition) {
 	}
 }
 
-// parseGo parses Go content
+// parseGo parses Go content with queries/go.scm
 func parseGo(content []byte) *FileAnalysis {
-	parser := sitter.NewParser()
-	parser.SetLanguage(golang.GetLanguage())
-
-	tree, err := parser.ParseCtx(context.Background(), nil, content)
-	if err != nil {
-		return &FileAnalysis{ParseError: err}
-	}
-
-	analysis := &FileAnalysis{}
-	rootNode := tree.RootNode()
-
-	for i := 0; i < int(rootNode.NamedChildCount()); i++ {
-		child := rootNode.NamedChild(i)
-		extractGoDefinitions(child, content, &analysis.Definitions)
-	}
-
-	return analysis
-}
-
-// extractGoDefinitions extracts Go function, method, and type definitions
-func extractGoDefinitions(node *sitter.Node, content []byte, defs *[]Definition) {
-	if node == nil {
-		return
-	}
-
-	switch node.Type() {
-	case "function_declaration":
-		if def := extractGoFunction(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "method_declaration":
-		if def := extractGoMethod(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "type_declaration":
-		// Type declarations contain type_spec children
-		for i := 0; i < int(node.NamedChildCount()); i++ {
-			child := node.NamedChild(i)
-			if child.Type() == "type_spec" {
-				if def := extractGoTypeSpec(child, content); def != nil {
-					*defs = append(*defs, *def)
-				}
-			}
-		}
-	case "const_declaration", "var_declaration":
-		extractGoVarOrConst(node, content, defs)
-	}
-}
-
-func extractGoFunction(node *sitter.Node, content []byte) *Definition {
-	var name, params string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "identifier":
-			name = child.Content(content)
-		case "parameter_list":
-			params = child.Content(content)
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "function",
-		Signature: fmt.Sprintf("func %s%s", name, params),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractGoMethod(node *sitter.Node, content []byte) *Definition {
-	var name, receiver, params string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "field_identifier":
-			name = child.Content(content)
-		case "parameter_list":
-			if receiver == "" {
-				receiver = child.Content(content)
-			} else {
-				params = child.Content(content)
-			}
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "method",
-		Signature: fmt.Sprintf("func %s %s%s", receiver, name, params),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractGoTypeSpec(node *sitter.Node, content []byte) *Definition {
-	var name, kind string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "type_identifier":
-			name = child.Content(content)
-		case "struct_type":
-			kind = "struct"
-		case "interface_type":
-			kind = "interface"
-		default:
-			if kind == "" {
-				kind = "type"
-			}
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	if kind == "" {
-		kind = "type"
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      kind,
-		Signature: fmt.Sprintf("type %s", name),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
+	return parseWithQuery(content, LangGo, builtinQuery(LangGo))
 }
 
-func extractGoVarOrConst(node *sitter.Node, content []byte, defs *[]Definition) {
-	kind := "variable"
-	if node.Type() == "const_declaration" {
-		kind = "const"
-	}
-
-	// Handle both single and grouped declarations
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		if child.Type() == "const_spec" || child.Type() == "var_spec" {
-			// Extract identifier(s) from the spec
-			for j := 0; j < int(child.NamedChildCount()); j++ {
-				specChild := child.NamedChild(j)
-				if specChild.Type() == "identifier" {
-					name := specChild.Content(content)
-					if name != "" {
-						*defs = append(*defs, Definition{
-							Name:      name,
-							Kind:      kind,
-							Signature: fmt.Sprintf("%s %s", kind, name),
-							Body:      child.Content(content),
-							StartLine: child.StartPoint().Row,
-							EndLine:   child.EndPoint().Row,
-							StartByte: child.StartByte(),
-							EndByte:   child.EndByte(),
-						})
-					}
-					break // Only first identifier per spec
-				}
-			}
-		}
-	}
-}
-
-// parseRust parses Rust content
+// parseRust parses Rust content with queries/rust.scm
 func parseRust(content []byte) *FileAnalysis {
-	parser := sitter.NewParser()
-	parser.SetLanguage(rust.GetLanguage())
-
-	tree, err := parser.ParseCtx(context.Background(), nil, content)
-	if err != nil {
-		return &FileAnalysis{ParseError: err}
-	}
-
-	analysis := &FileAnalysis{}
-	rootNode := tree.RootNode()
-
-	for i := 0; i < int(rootNode.NamedChildCount()); i++ {
-		child := rootNode.NamedChild(i)
-		extractRustDefinitions(child, content, &analysis.Definitions)
-	}
-
-	return analysis
-}
-
-// extractRustDefinitions extracts Rust fn, impl, struct, enum, and trait definitions
-func extractRustDefinitions(node *sitter.Node, content []byte, defs *[]Definition) {
-	if node == nil {
-		return
-	}
-
-	switch node.Type() {
-	case "function_item":
-		if def := extractRustFunction(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "impl_item":
-		if def := extractRustImpl(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "struct_item":
-		if def := extractRustStruct(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "enum_item":
-		if def := extractRustEnum(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "trait_item":
-		if def := extractRustTrait(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "type_item":
-		if def := extractRustTypeAlias(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	case "const_item", "static_item":
-		if def := extractRustConstOrStatic(node, content); def != nil {
-			*defs = append(*defs, *def)
-		}
-	}
-}
-
-func extractRustFunction(node *sitter.Node, content []byte) *Definition {
-	var name, params string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "identifier":
-			name = child.Content(content)
-		case "parameters":
-			params = child.Content(content)
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "function",
-		Signature: fmt.Sprintf("fn %s%s", name, params),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractRustImpl(node *sitter.Node, content []byte) *Definition {
-	var typeName, traitName string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		switch child.Type() {
-		case "type_identifier", "generic_type":
-			if typeName == "" {
-				typeName = child.Content(content)
-			}
-		case "trait_bounds":
-			traitName = child.Content(content)
-		}
-	}
-
-	if typeName == "" {
-		return nil
-	}
-
-	name := typeName
-	sig := fmt.Sprintf("impl %s", typeName)
-	if traitName != "" {
-		name = fmt.Sprintf("%s for %s", traitName, typeName)
-		sig = fmt.Sprintf("impl %s for %s", traitName, typeName)
-	}
-
-	return &Definition{
-		Name:      name,
-		Kind:      "impl",
-		Signature: sig,
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractRustStruct(node *sitter.Node, content []byte) *Definition {
-	var name string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		if child.Type() == "type_identifier" {
-			name = child.Content(content)
-			break
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "struct",
-		Signature: fmt.Sprintf("struct %s", name),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractRustEnum(node *sitter.Node, content []byte) *Definition {
-	var name string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		if child.Type() == "type_identifier" {
-			name = child.Content(content)
-			break
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "enum",
-		Signature: fmt.Sprintf("enum %s", name),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractRustTrait(node *sitter.Node, content []byte) *Definition {
-	var name string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		if child.Type() == "type_identifier" {
-			name = child.Content(content)
-			break
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "trait",
-		Signature: fmt.Sprintf("trait %s", name),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractRustTypeAlias(node *sitter.Node, content []byte) *Definition {
-	var name string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		if child.Type() == "type_identifier" {
-			name = child.Content(content)
-			break
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      "type",
-		Signature: fmt.Sprintf("type %s", name),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
-}
-
-func extractRustConstOrStatic(node *sitter.Node, content []byte) *Definition {
-	kind := "const"
-	if node.Type() == "static_item" {
-		kind = "static"
-	}
-
-	var name string
-	for i := 0; i < int(node.NamedChildCount()); i++ {
-		child := node.NamedChild(i)
-		if child.Type() == "identifier" {
-			name = child.Content(content)
-			break
-		}
-	}
-	if name == "" {
-		return nil
-	}
-	return &Definition{
-		Name:      name,
-		Kind:      kind,
-		Signature: fmt.Sprintf("%s %s", kind, name),
-		Body:      node.Content(content),
-		StartLine: node.StartPoint().Row,
-		EndLine:   node.EndPoint().Row,
-		StartByte: node.StartByte(),
-		EndByte:   node.EndByte(),
-	}
+	return parseWithQuery(content, LangRust, builtinQuery(LangRust))
 }
 
 // AnalyzeConflict analyzes a conflicting file and returns semantic conflict info
@@ -1216,20 +724,8 @@ func AnalyzeConflict(file string) *ConflictAnalysis {
 	localDefs := mapDefinitions(localAnalysis.Definitions)
 	remoteDefs := mapDefinitions(remoteAnalysis.Definitions)
 
-	// Find all unique definition names
-	allNames := make(map[string]bool)
-	for name := range baseDefs {
-		allNames[name] = true
-	}
-	for name := range localDefs {
-		allNames[name] = true
-	}
-	for name := range remoteDefs {
-		allNames[name] = true
-	}
-
-	// Analyze each definition
-	for name := range allNames {
+	// Analyze each definition in source order (stable output)
+	for _, name := range sortedDefinitionNames(baseDefs, localDefs, remoteDefs) {
 		baseDef := baseDefs[name]
 		localDef := localDefs[name]
 		remoteDef := remoteDefs[name]
@@ -1261,6 +757,29 @@ func mapDefinitions(defs []Definition) map[string]*Definition {
 	return m
 }
 
+// sortedDefinitionNames returns the union of definition names across all three
+// versions, ordered by position (local, then base, then remote) and then name
+func sortedDefinitionNames(baseDefs, localDefs, remoteDefs map[string]*Definition) []string {
+	position := make(map[string]uint32)
+	for _, defs := range []map[string]*Definition{remoteDefs, baseDefs, localDefs} {
+		for name, def := range defs {
+			position[name] = def.StartByte
+		}
+	}
+
+	names := make([]string, 0, len(position))
+	for name := range position {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if position[names[i]] != position[names[j]] {
+			return position[names[i]] < position[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
 // analyzeDefinitionChange determines what kind of conflict exists for a definition
 func analyzeDefinitionChange(file, name string, base, local, remote *Definition) *ui.Conflict {
 	// Determine the kind (use whichever version has it)
@@ -0,0 +1,151 @@
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/git"
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// ConflictVersions holds the three index stages of a conflicting file.
+// A stage that could not be read (e.g. the file was added on one side only)
+// has a nil content and a non-nil error.
+type ConflictVersions struct {
+	Base, Local, Remote          []byte
+	BaseErr, LocalErr, RemoteErr error
+}
+
+// unmergedEntry is one line of `git ls-files -u` output
+type unmergedEntry struct {
+	Stage int
+	OID   string
+	Path  string
+}
+
+// LoadConflictVersions reads all stages of the given files in one pass.
+// It lists unmerged entries with a single `git ls-files -u` and fetches every
+// blob through one `git cat-file --batch` session. Files that are not listed
+// fall back to per-stage `git show`.
+func LoadConflictVersions(ctx context.Context, files []string) map[string]*ConflictVersions {
+	result := make(map[string]*ConflictVersions, len(files))
+
+	stagesByFile, err := listUnmergedEntries(ctx)
+	if err != nil {
+		logging.Debug("ls-files -u failed, falling back to git show", "error", err)
+		stagesByFile = nil
+	}
+
+	var reader git.BlobReader
+	if len(stagesByFile) > 0 {
+		reader, err = gitExec.CatFileBatch(ctx)
+		if err != nil {
+			logging.Debug("cat-file --batch failed, falling back to git show", "error", err)
+			reader = nil
+		} else {
+			defer reader.Close()
+		}
+	}
+
+	for _, file := range files {
+		stages, ok := stagesByFile[file]
+		if reader == nil || !ok {
+			result[file] = getConflictVersions(ctx, file)
+			continue
+		}
+
+		versions := &ConflictVersions{}
+		versions.Base, versions.BaseErr = readStageBlob(reader, stages, file, 1)
+		versions.Local, versions.LocalErr = readStageBlob(reader, stages, file, 2)
+		versions.Remote, versions.RemoteErr = readStageBlob(reader, stages, file, 3)
+		if err := errors.Join(versions.BaseErr, versions.LocalErr, versions.RemoteErr); errors.Is(err, git.ErrSessionClosed) {
+			// The session broke; read this and the remaining files with git show
+			logging.Debug("cat-file session failed, falling back to git show", "error", err)
+			reader = nil
+			versions = getConflictVersions(ctx, file)
+		}
+		result[file] = versions
+	}
+
+	return result
+}
+
+// getConflictVersions reads the three stages of a file with separate `git show` calls
+func getConflictVersions(ctx context.Context, file string) *ConflictVersions {
+	versions := &ConflictVersions{}
+	versions.Base, versions.BaseErr = GetFileVersionWithContext(ctx, file, 1)
+	versions.Local, versions.LocalErr = GetFileVersionWithContext(ctx, file, 2)
+	versions.Remote, versions.RemoteErr = GetFileVersionWithContext(ctx, file, 3)
+	return versions
+}
+
+// readStageBlob reads one stage of a file from the batch session
+func readStageBlob(reader git.BlobReader, stages map[int]string, file string, stage int) ([]byte, error) {
+	oid, ok := stages[stage]
+	if !ok {
+		return nil, fmt.Errorf("stage %d of %s not present in index", stage, file)
+	}
+
+	// Blobs over the size limit are skipped without being loaded
+	return reader.ReadBlob(oid, MaxFileSize)
+}
+
+// listUnmergedEntries returns the blob id of each stage, keyed by file and stage
+func listUnmergedEntries(ctx context.Context) (map[string]map[int]string, error) {
+	output, err := gitExec.Output(ctx, "ls-files", "-u", "-z", "--full-name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unmerged entries: %w", err)
+	}
+
+	entries, err := parseUnmergedEntries(output)
+	if err != nil {
+		return nil, err
+	}
+
+	stagesByFile := make(map[string]map[int]string)
+	for _, e := range entries {
+		if stagesByFile[e.Path] == nil {
+			stagesByFile[e.Path] = make(map[int]string)
+		}
+		stagesByFile[e.Path][e.Stage] = e.OID
+	}
+	return stagesByFile, nil
+}
+
+// parseUnmergedEntries parses NUL-separated `git ls-files -u -z` output.
+// Each record is "<mode> <object> <stage>\t<path>".
+func parseUnmergedEntries(output []byte) ([]unmergedEntry, error) {
+	var entries []unmergedEntry
+
+	for _, record := range bytes.Split(output, []byte{0}) {
+		if len(record) == 0 {
+			continue
+		}
+		line := string(record)
+
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("malformed ls-files entry %q", line)
+		}
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed ls-files entry %q", line)
+		}
+		stage, err := strconv.Atoi(fields[2])
+		if err != nil || stage < 1 || stage > 3 {
+			return nil, fmt.Errorf("invalid stage in ls-files entry %q", line)
+		}
+
+		entries = append(entries, unmergedEntry{
+			Stage: stage,
+			OID:   fields[1],
+			Path:  line[tab+1:],
+		})
+	}
+
+	return entries, nil
+}
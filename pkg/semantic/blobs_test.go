@@ -0,0 +1,223 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simonkoeck/g2/pkg/git"
+)
+
+// withMockExecutor swaps the package git executor for the duration of a test
+func withMockExecutor(t *testing.T, mock git.Executor) {
+	t.Helper()
+	old := gitExec
+	SetGitExecutor(mock)
+	t.Cleanup(func() { SetGitExecutor(old) })
+}
+
+// TestParseUnmergedEntries tests parsing of `git ls-files -u -z` output
+func TestParseUnmergedEntries(t *testing.T) {
+	output := []byte("100644 aaa 1\tutils.py\x00100644 bbb 2\tutils.py\x00100644 ccc 3\tutils.py\x00100644 ddd 2\tdir/with space.py\x00")
+
+	entries, err := parseUnmergedEntries(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	if entries[1].Stage != 2 || entries[1].OID != "bbb" || entries[1].Path != "utils.py" {
+		t.Errorf("unexpected entry: %+v", entries[1])
+	}
+	if entries[3].Path != "dir/with space.py" {
+		t.Errorf("path with space not preserved: %q", entries[3].Path)
+	}
+
+	if _, err := parseUnmergedEntries([]byte("garbage\x00")); err == nil {
+		t.Error("expected error for malformed entry")
+	}
+	if _, err := parseUnmergedEntries([]byte("100644 aaa 7\tx.py\x00")); err == nil {
+		t.Error("expected error for invalid stage")
+	}
+}
+
+// TestLoadConflictVersions_Batch tests that all stages come from a single batch session
+func TestLoadConflictVersions_Batch(t *testing.T) {
+	mock := git.NewMockExecutor()
+	mock.OnOutput = func(ctx context.Context, args []string) ([]byte, error) {
+		if args[0] == "ls-files" {
+			return []byte("100644 b1 1\ta.py\x00100644 l1 2\ta.py\x00100644 r1 3\ta.py\x00" +
+				"100644 l2 2\tb.py\x00100644 r2 3\tb.py\x00"), nil
+		}
+		t.Errorf("unexpected git call: %v", args)
+		return nil, nil
+	}
+	mock.SetBlob("b1", []byte("base a"))
+	mock.SetBlob("l1", []byte("local a"))
+	mock.SetBlob("r1", []byte("remote a"))
+	mock.SetBlob("l2", []byte("local b"))
+	mock.SetBlob("r2", []byte("remote b"))
+	withMockExecutor(t, mock)
+
+	versions := LoadConflictVersions(context.Background(), []string{"a.py", "b.py"})
+
+	a := versions["a.py"]
+	if string(a.Base) != "base a" || string(a.Local) != "local a" || string(a.Remote) != "remote a" {
+		t.Errorf("unexpected a.py versions: %q %q %q", a.Base, a.Local, a.Remote)
+	}
+
+	b := versions["b.py"]
+	if b.BaseErr == nil {
+		t.Error("b.py has no base stage, expected BaseErr")
+	}
+	if string(b.Local) != "local b" || string(b.Remote) != "remote b" {
+		t.Errorf("unexpected b.py versions: %q %q", b.Local, b.Remote)
+	}
+
+	sessions := 0
+	for _, call := range mock.Calls() {
+		if call.Method == "CatFileBatch" {
+			sessions++
+		}
+		if call.Method == "Output" && call.Args[0] == "show" {
+			t.Errorf("unexpected git show call: %v", call.Args)
+		}
+	}
+	if sessions != 1 {
+		t.Errorf("expected 1 cat-file session, got %d", sessions)
+	}
+}
+
+// TestLoadConflictVersions_Fallback tests fallback to git show for unlisted files
+func TestLoadConflictVersions_Fallback(t *testing.T) {
+	mock := git.NewMockExecutor()
+	mock.OnOutput = func(ctx context.Context, args []string) ([]byte, error) {
+		switch args[0] {
+		case "ls-files":
+			return nil, nil
+		case "show":
+			return []byte("content " + args[1]), nil
+		}
+		return nil, nil
+	}
+	withMockExecutor(t, mock)
+
+	versions := LoadConflictVersions(context.Background(), []string{"a.py"})
+
+	if got := string(versions["a.py"].Remote); got != "content :3:a.py" {
+		t.Errorf("Remote = %q, want fallback content", got)
+	}
+}
+
+// TestLoadConflictVersions_SessionErrors tests oversized blobs and a broken
+// session, whose remaining files are read with git show
+func TestLoadConflictVersions_SessionErrors(t *testing.T) {
+	oldMax := MaxFileSize
+	MaxFileSize = 4
+	t.Cleanup(func() { MaxFileSize = oldMax })
+
+	mock := git.NewMockExecutor()
+	mock.OnOutput = func(ctx context.Context, args []string) ([]byte, error) {
+		switch args[0] {
+		case "ls-files":
+			return []byte("100644 l1 2\ta.py\x00100644 r1 3\ta.py\x00100644 l2 2\tb.py\x00"), nil
+		case "show":
+			return []byte("ok"), nil
+		}
+		return nil, nil
+	}
+	mock.OnReadBlob = func(oid string) ([]byte, error) {
+		switch oid {
+		case "l1":
+			return []byte("abc"), nil
+		case "r1":
+			return nil, fmt.Errorf("%w: 10 bytes (max 4)", git.ErrObjectTooLarge)
+		}
+		return nil, fmt.Errorf("%w: unexpected cat-file header", git.ErrSessionClosed)
+	}
+	withMockExecutor(t, mock)
+
+	versions := LoadConflictVersions(context.Background(), []string{"a.py", "b.py"})
+
+	a := versions["a.py"]
+	if string(a.Local) != "abc" || !errors.Is(a.RemoteErr, git.ErrObjectTooLarge) {
+		t.Errorf("unexpected a.py versions: %q, %v", a.Local, a.RemoteErr)
+	}
+	if b := versions["b.py"]; string(b.Local) != "ok" || b.LocalErr != nil {
+		t.Errorf("expected b.py from git show after the session broke, got %q, %v", b.Local, b.LocalErr)
+	}
+}
+
+// TestLoadConflictVersions_RealRepo tests the cat-file session against a real merge conflict
+func TestLoadConflictVersions_RealRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil && args[0] != "merge" {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "utils.py"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q", "-b", "main")
+	write("def foo():\n    return 1\n")
+	run("add", ".")
+	run("commit", "-qm", "base")
+	run("checkout", "-qb", "feature")
+	write("def foo():\n    return 3\n")
+	run("commit", "-qam", "feature")
+	run("checkout", "-q", "main")
+	write("def foo():\n    return 2\n")
+	run("commit", "-qam", "main")
+	run("merge", "-q", "feature")
+
+	executor := git.NewDefaultExecutor()
+	executor.Dir = dir
+	withMockExecutor(t, executor)
+
+	versions := LoadConflictVersions(context.Background(), []string{"utils.py"})["utils.py"]
+	if !strings.Contains(string(versions.Base), "return 1") {
+		t.Errorf("Base = %q", versions.Base)
+	}
+	if !strings.Contains(string(versions.Local), "return 2") {
+		t.Errorf("Local = %q", versions.Local)
+	}
+	if !strings.Contains(string(versions.Remote), "return 3") {
+		t.Errorf("Remote = %q", versions.Remote)
+	}
+
+	// An oversized blob is skipped and the session stays in sync
+	stages, err := listUnmergedEntries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := executor.CatFileBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadBlob(stages["utils.py"][2], 4); !errors.Is(err, git.ErrObjectTooLarge) {
+		t.Errorf("expected ErrObjectTooLarge, got %v", err)
+	}
+	if content, err := reader.ReadBlob(stages["utils.py"][3], 0); err != nil || !strings.Contains(string(content), "return 3") {
+		t.Errorf("read after skip = %q, %v", content, err)
+	}
+}
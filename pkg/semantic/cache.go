@@ -0,0 +1,272 @@
+package semantic
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// extractorVersion must be bumped whenever definition extraction changes in a
+// way that alters the cached FileAnalysis for the same input.
+const extractorVersion = 4
+
+// Parse cache defaults
+const (
+	DefaultParseCacheMaxBytes      int64         = 64 * 1024 * 1024
+	DefaultParseCacheMaxAge        time.Duration = 30 * 24 * time.Hour
+	DefaultParseCachePruneInterval time.Duration = 24 * time.Hour
+)
+
+// parseCacheSuffix is the file extension of cache entries
+const parseCacheSuffix = ".json"
+
+// parseCachePruneMarker records when the cache was last pruned
+const parseCachePruneMarker = ".last-prune"
+
+// parseCache is the cache consulted by ParseFile; nil disables caching
+var parseCache *ParseCache
+
+// SetParseCache sets the cache used by ParseFile. Pass nil to disable caching.
+func SetParseCache(cache *ParseCache) {
+	parseCache = cache
+}
+
+// ParseCacheDir returns the cache directory for a repository's git directory
+func ParseCacheDir(gitDir string) string {
+	return filepath.Join(gitDir, "g2", "cache")
+}
+
+// ParseCache stores FileAnalysis results on disk, keyed by blob object id,
+// language and grammar version. Entries are evicted least recently used
+// first once the cache exceeds MaxBytes, and when older than MaxAge.
+type ParseCache struct {
+	Dir           string
+	MaxBytes      int64         // Maximum total size of all entries
+	MaxAge        time.Duration // Entries not used for this long are evicted
+	PruneInterval time.Duration // Minimum time between automatic prunes
+
+	mu sync.Mutex // Serializes pruning and clearing
+}
+
+// NewParseCache creates a cache rooted at dir with default limits
+func NewParseCache(dir string) *ParseCache {
+	return &ParseCache{
+		Dir:           dir,
+		MaxBytes:      DefaultParseCacheMaxBytes,
+		MaxAge:        DefaultParseCacheMaxAge,
+		PruneInterval: DefaultParseCachePruneInterval,
+	}
+}
+
+// parseCacheEntry is the on-disk format of a cached FileAnalysis
+type parseCacheEntry struct {
+	OID         string       `json:"oid"`
+	Language    Language     `json:"language"`
+	Grammar     string       `json:"grammar"`
+	Definitions []Definition `json:"definitions"`
+}
+
+// grammarVersion identifies the parser and extractor that produced an entry,
+// so upgrading either invalidates old entries
+var grammarVersion = sync.OnceValue(func() string {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/smacker/go-tree-sitter" {
+				version = dep.Version
+				if dep.Replace != nil {
+					version = dep.Replace.Path + "@" + dep.Replace.Version
+				}
+				break
+			}
+		}
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", version, extractorVersion)))
+	return hex.EncodeToString(hash[:])[:12]
+})
+
+// blobOID returns the git object id of content stored as a blob
+func blobOID(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// entryPath returns the file that holds the entry for a blob and language
+func (c *ParseCache) entryPath(oid string, lang Language) string {
+	name := fmt.Sprintf("%s-%d-%s%s", oid[2:], lang, grammarVersion(), parseCacheSuffix)
+	return filepath.Join(c.Dir, oid[:2], name)
+}
+
+// Get returns the cached analysis of content, if present
+func (c *ParseCache) Get(content []byte, lang Language) (*FileAnalysis, bool) {
+	oid := blobOID(content)
+	path := c.entryPath(oid, lang)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry parseCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.OID != oid || entry.Language != lang || entry.Grammar != grammarVersion() {
+		logging.Debug("discarding invalid parse cache entry", "path", path)
+		os.Remove(path)
+		return nil, false
+	}
+
+	// Mark the entry as recently used for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return &FileAnalysis{Definitions: entry.Definitions}, true
+}
+
+// Put stores the analysis of content. Analyses with a parse error are not cached.
+func (c *ParseCache) Put(content []byte, lang Language, analysis *FileAnalysis) error {
+	if analysis == nil || analysis.ParseError != nil {
+		return nil
+	}
+
+	oid := blobOID(content)
+	data, err := json.Marshal(parseCacheEntry{
+		OID:         oid,
+		Language:    lang,
+		Grammar:     grammarVersion(),
+		Definitions: analysis.Definitions,
+	})
+	if err != nil {
+		return err
+	}
+
+	path := c.entryPath(oid, lang)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so concurrent readers never see a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Prune evicts entries older than MaxAge, then the least recently used
+// entries until the cache fits in MaxBytes. It returns the number of
+// entries removed.
+func (c *ParseCache) Prune() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []cacheFile
+	var total int64
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, parseCacheSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan parse cache: %w", err)
+	}
+
+	// Oldest first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	removed := 0
+	cutoff := time.Now().Add(-c.MaxAge)
+	for _, f := range files {
+		expired := c.MaxAge > 0 && f.modTime.Before(cutoff)
+		oversized := c.MaxBytes > 0 && total > c.MaxBytes
+		if !expired && !oversized {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		total -= f.size
+		removed++
+	}
+
+	return removed, nil
+}
+
+// PruneIfDue prunes the cache if PruneInterval has passed since the last prune
+func (c *ParseCache) PruneIfDue() {
+	marker := filepath.Join(c.Dir, parseCachePruneMarker)
+	if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) < c.PruneInterval {
+		return
+	}
+
+	removed, err := c.Prune()
+	if err != nil {
+		logging.Debug("parse cache prune failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		logging.Debug("pruned parse cache", "removed", removed)
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err == nil {
+		os.WriteFile(marker, nil, 0644)
+	}
+}
+
+// Clear removes every cache entry
+func (c *ParseCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return fmt.Errorf("failed to clear parse cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package semantic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withParseCache installs a cache in a temp directory for the duration of a test
+func withParseCache(t *testing.T) *ParseCache {
+	t.Helper()
+	cache := NewParseCache(filepath.Join(t.TempDir(), "cache"))
+	old := parseCache
+	SetParseCache(cache)
+	t.Cleanup(func() { SetParseCache(old) })
+	return cache
+}
+
+// TestBlobOID verifies object ids match git's blob hashing
+func TestBlobOID(t *testing.T) {
+	// git hash-object /dev/null
+	if got := blobOID(nil); got != "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391" {
+		t.Errorf("unexpected empty blob id %s", got)
+	}
+	// printf 'hello\n' | git hash-object --stdin
+	if got := blobOID([]byte("hello\n")); got != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("unexpected blob id %s", got)
+	}
+}
+
+func TestParseCache_RoundTrip(t *testing.T) {
+	cache := withParseCache(t)
+	content := []byte("def greet(name):\n    return 'hi ' + name\n")
+
+	if _, ok := cache.Get(content, LangPython); ok {
+		t.Fatal("expected cache miss on empty cache")
+	}
+
+	parsed := ParseFile(content, LangPython)
+	if parsed.ParseError != nil || len(parsed.Definitions) != 1 {
+		t.Fatalf("unexpected parse result: %+v", parsed)
+	}
+
+	cached, ok := cache.Get(content, LangPython)
+	if !ok {
+		t.Fatal("expected cache hit after ParseFile")
+	}
+	if len(cached.Definitions) != 1 || cached.Definitions[0] != parsed.Definitions[0] {
+		t.Errorf("cached definitions differ: %+v vs %+v", cached.Definitions, parsed.Definitions)
+	}
+
+	// The same blob in another language is a separate entry
+	if _, ok := cache.Get(content, LangJavaScript); ok {
+		t.Error("expected cache miss for a different language")
+	}
+}
+
+func TestParseCache_ServesCachedAnalysis(t *testing.T) {
+	cache := withParseCache(t)
+	content := []byte("def real():\n    pass\n")
+
+	fake := &FileAnalysis{Definitions: []Definition{{Name: "from_cache", Kind: "function"}}}
+	if err := cache.Put(content, LangPython, fake); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	analysis := ParseFile(content, LangPython)
+	if len(analysis.Definitions) != 1 || analysis.Definitions[0].Name != "from_cache" {
+		t.Errorf("expected cached definitions, got %+v", analysis.Definitions)
+	}
+}
+
+func TestParseCache_DiscardsCorruptEntry(t *testing.T) {
+	cache := withParseCache(t)
+	content := []byte("def f():\n    pass\n")
+
+	path := cache.entryPath(blobOID(content), LangPython)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.Get(content, LangPython); ok {
+		t.Error("expected corrupt entry to be a miss")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected corrupt entry to be removed")
+	}
+}
+
+func TestParseCache_SkipsParseErrors(t *testing.T) {
+	cache := withParseCache(t)
+	content := []byte("x")
+
+	if err := cache.Put(content, LangPython, &FileAnalysis{ParseError: os.ErrInvalid}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, ok := cache.Get(content, LangPython); ok {
+		t.Error("analyses with parse errors should not be cached")
+	}
+}
+
+func TestParseCache_Prune(t *testing.T) {
+	cache := withParseCache(t)
+
+	var paths []string
+	for i, src := range []string{"def a():\n    pass\n", "def b():\n    pass\n", "def c():\n    pass\n"} {
+		content := []byte(src)
+		ParseFile(content, LangPython)
+		path := cache.entryPath(blobOID(content), LangPython)
+		// Oldest entry first
+		modTime := time.Now().Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	info, err := os.Stat(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for two entries: the least recently used one goes
+	cache.MaxBytes = 2*info.Size() + 1
+	removed, err := cache.Prune()
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 entry removed, got %d", removed)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Error("expected oldest entry to be evicted")
+	}
+
+	// Age limit evicts entries not used recently
+	cache.MaxBytes = 0
+	cache.MaxAge = 90 * time.Minute
+	removed, err = cache.Prune()
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 expired entry removed, got %d", removed)
+	}
+	if _, err := os.Stat(paths[2]); err != nil {
+		t.Error("expected most recent entry to be kept")
+	}
+}
+
+func TestParseCache_Clear(t *testing.T) {
+	cache := withParseCache(t)
+	content := []byte("def f():\n    pass\n")
+	ParseFile(content, LangPython)
+
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if _, err := os.Stat(cache.Dir); !os.IsNotExist(err) {
+		t.Error("expected cache directory to be removed")
+	}
+
+	// Clearing a missing cache is not an error
+	if err := cache.Clear(); err != nil {
+		t.Errorf("Clear on empty cache failed: %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package semantic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/ui"
+)
+
+// containerName returns the class a member belongs to ("Order.total" → "Order"),
+// or "" for top-level definitions
+func containerName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// memberName returns a member's name without its class prefix
+func memberName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// containerGroup collects the orphan members of one class in one file
+type containerGroup struct {
+	file    string
+	name    string
+	members []int    // Indices into the orphan list
+	tokens  []string // Tokens of all member bodies
+}
+
+// groupContainers groups orphan members by file and class, in order of first
+// appearance. Classes with fewer than minMembers orphans are left out.
+func groupContainers(files, names, bodies []string, minMembers int) []*containerGroup {
+	byKey := make(map[string]*containerGroup)
+	var groups []*containerGroup
+	for i, name := range names {
+		container := containerName(name)
+		if container == "" {
+			continue
+		}
+		key := files[i] + "\x00" + container
+		g, ok := byKey[key]
+		if !ok {
+			g = &containerGroup{file: files[i], name: container}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.members = append(g.members, i)
+		g.tokens = append(g.tokens, tokenize(bodies[i])...)
+	}
+
+	var result []*containerGroup
+	for _, g := range groups {
+		if len(g.members) >= minMembers {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// containerPair is a matched source and destination class
+type containerPair struct {
+	del, add   *containerGroup
+	similarity float64
+	members    [][2]int // Matched (delete, add) orphan indices
+	memberSims []float64
+}
+
+// matchContainers pairs classes whose members, taken together, are similar
+// enough to count as one moved unit. allowed filters candidate pairs (e.g.
+// different file or different class name). Members are then paired within
+// each matched class using the lower ContainerMemberThreshold, preferring
+// members that kept their name.
+func matchContainers(delGroups, addGroups []*containerGroup, delNames, addNames, delBodies, addBodies []string, allowed func(del, add *containerGroup) bool, config MoveDetectionConfig) []containerPair {
+	var pairs []containerPair
+	usedAdds := make(map[*containerGroup]bool)
+
+	for _, del := range delGroups {
+		// Skip tiny classes (boilerplate guard)
+		if len(del.tokens) < config.MinTokenCount {
+			continue
+		}
+
+		var best *containerGroup
+		bestSimilarity := 0.0
+		for _, add := range addGroups {
+			if usedAdds[add] || !allowed(del, add) {
+				continue
+			}
+			threshold := getThresholdForSize(len(del.tokens), config)
+			if t := getThresholdForSize(len(add.tokens), config); t > threshold {
+				threshold = t
+			}
+			similarity := calculateSimilarity(del.tokens, add.tokens, config)
+			if similarity >= threshold && similarity > bestSimilarity {
+				best = add
+				bestSimilarity = similarity
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		pair := containerPair{del: del, add: best, similarity: bestSimilarity}
+		usedMembers := make(map[int]bool)
+		for _, d := range del.members {
+			delTokens := tokenize(delBodies[d])
+			bestMember, bestMemberSim, bestSameName := -1, 0.0, false
+			for _, a := range best.members {
+				if usedMembers[a] {
+					continue
+				}
+				sim := calculateSimilarity(delTokens, tokenize(addBodies[a]), config)
+				if sim < config.ContainerMemberThreshold {
+					continue
+				}
+				sameName := memberName(delNames[d]) == memberName(addNames[a])
+				if (sameName && !bestSameName) || (sameName == bestSameName && sim > bestMemberSim) {
+					bestMember, bestMemberSim, bestSameName = a, sim, sameName
+				}
+			}
+			if bestMember >= 0 {
+				usedMembers[bestMember] = true
+				pair.members = append(pair.members, [2]int{d, bestMember})
+				pair.memberSims = append(pair.memberSims, bestMemberSim)
+			}
+		}
+
+		if len(pair.members) >= config.ContainerMinMembers {
+			usedAdds[best] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// joinMemberDefinitions combines member definitions into one definition
+// named after their class, spanning all of them
+func joinMemberDefinitions(name string, defs []*Definition) *Definition {
+	if len(defs) == 0 {
+		return nil
+	}
+	joined := joinDefinitions(defs)
+	joined.Name = name
+	joined.Kind = "class"
+	for _, d := range defs {
+		if d.StartByte < joined.StartByte {
+			joined.StartByte, joined.StartLine = d.StartByte, d.StartLine
+		}
+		if d.EndByte > joined.EndByte {
+			joined.EndByte, joined.EndLine = d.EndByte, d.EndLine
+		}
+	}
+	return joined
+}
+
+// formatContainerMoveType formats the conflict type for a class renamed
+// within a file
+func formatContainerMoveType(deleteName, addName string, members int, similarity float64) string {
+	return fmt.Sprintf("Class '%s' Renamed+Moved to '%s' (%d members, %.0f%% Match)", deleteName, addName, members, similarity*100)
+}
+
+// findContainerMoves detects classes renamed within a file as a
+// whole. Each match becomes one conflict whose Members hold the per-member
+// move conflicts used for synthesis.
+func findContainerMoves(deletes, adds []*SynthesisConflict, matchedDeletes, matchedAdds map[int]bool, config MoveDetectionConfig) []SynthesisConflict {
+	delFiles, delNames, delBodies := make([]string, len(deletes)), make([]string, len(deletes)), make([]string, len(deletes))
+	for i, del := range deletes {
+		delFiles[i], delNames[i], delBodies[i] = del.UIConflict.File, del.Base.Name, normalize(del.Base.Body)
+	}
+	addFiles, addNames, addBodies := make([]string, len(adds)), make([]string, len(adds)), make([]string, len(adds))
+	for i, add := range adds {
+		addFiles[i], addNames[i], addBodies[i] = add.UIConflict.File, getAddName(add), getAddBody(add)
+	}
+
+	delGroups := groupContainers(delFiles, delNames, delBodies, config.ContainerMinMembers)
+	addGroups := groupContainers(addFiles, addNames, addBodies, config.ContainerMinMembers)
+	if len(delGroups) == 0 || len(addGroups) == 0 {
+		return nil
+	}
+
+	renamed := func(del, add *containerGroup) bool { return del.name != add.name }
+	var moves []SynthesisConflict
+	for _, pair := range matchContainers(delGroups, addGroups, delNames, addNames, delBodies, addBodies, renamed, config) {
+		var members []SynthesisConflict
+		var baseDefs, localDefs, remoteDefs []*Definition
+		for i, m := range pair.members {
+			del, add := deletes[m[0]], adds[m[1]]
+			matchType := "Fuzzy Match"
+			if pair.memberSims[i] == 1.0 {
+				matchType = "Exact Match"
+			}
+			members = append(members, createMoveConflict(del, add, matchType, pair.memberSims[i]))
+			baseDefs = append(baseDefs, del.Base)
+			if add.Local != nil {
+				localDefs = append(localDefs, add.Local)
+			}
+			if add.Remote != nil {
+				remoteDefs = append(remoteDefs, add.Remote)
+			}
+			matchedDeletes[m[0]] = true
+			matchedAdds[m[1]] = true
+		}
+
+		moves = append(moves, SynthesisConflict{
+			UIConflict: ui.Conflict{
+				File:         deletes[pair.members[0][0]].UIConflict.File,
+				ConflictType: formatContainerMoveType(pair.del.name, pair.add.name, len(members), pair.similarity),
+				Status:       "Can Auto-merge",
+			},
+			Base:    joinMemberDefinitions(pair.del.name, baseDefs),
+			Local:   joinMemberDefinitions(pair.add.name, localDefs),
+			Remote:  joinMemberDefinitions(pair.add.name, remoteDefs),
+			Members: members,
+		})
+	}
+	return moves
+}
+
+// findContainerInterFileMoves detects classes moved to another file as a
+// whole. Each match becomes one InterFileMove whose Members hold the
+// per-member moves.
+func findContainerInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, matchedAdds map[int]bool, config MoveDetectionConfig) []InterFileMove {
+	delFiles, delNames, delBodies := make([]string, len(deletes)), make([]string, len(deletes)), make([]string, len(deletes))
+	for i, del := range deletes {
+		delFiles[i], delNames[i], delBodies[i] = del.File, del.Conflict.Base.Name, normalize(del.Conflict.Base.Body)
+	}
+	addFiles, addNames, addBodies := make([]string, len(adds)), make([]string, len(adds)), make([]string, len(adds))
+	for i, add := range adds {
+		addFiles[i], addNames[i], addBodies[i] = add.File, getAddName(add.Conflict), getAddBody(add.Conflict)
+	}
+
+	delGroups := groupContainers(delFiles, delNames, delBodies, config.ContainerMinMembers)
+	addGroups := groupContainers(addFiles, addNames, addBodies, config.ContainerMinMembers)
+	if len(delGroups) == 0 || len(addGroups) == 0 {
+		return nil
+	}
+
+	otherFile := func(del, add *containerGroup) bool { return del.file != add.file }
+	var moves []InterFileMove
+	for _, pair := range matchContainers(delGroups, addGroups, delNames, addNames, delBodies, addBodies, otherFile, config) {
+		var members []InterFileMove
+		var baseDefs, localDefs, remoteDefs []*Definition
+		for i, m := range pair.members {
+			del, add := deletes[m[0]], adds[m[1]]
+			matchType := "Fuzzy Match"
+			if pair.memberSims[i] == 1.0 {
+				matchType = "Exact Match"
+			}
+			members = append(members, InterFileMove{
+				SourceFile:     del.File,
+				DestFile:       add.File,
+				SourceConflict: del.Conflict,
+				DestConflict:   add.Conflict,
+				MatchType:      matchType,
+				Similarity:     pair.memberSims[i],
+			})
+			baseDefs = append(baseDefs, del.Conflict.Base)
+			if add.Conflict.Local != nil {
+				localDefs = append(localDefs, add.Conflict.Local)
+			}
+			if add.Conflict.Remote != nil {
+				remoteDefs = append(remoteDefs, add.Conflict.Remote)
+			}
+			matchedDeletes[m[0]] = true
+			matchedAdds[m[1]] = true
+		}
+
+		moves = append(moves, InterFileMove{
+			SourceFile: pair.del.file,
+			DestFile:   pair.add.file,
+			SourceConflict: &SynthesisConflict{
+				UIConflict: ui.Conflict{File: pair.del.file},
+				Base:       joinMemberDefinitions(pair.del.name, baseDefs),
+			},
+			DestConflict: &SynthesisConflict{
+				UIConflict: ui.Conflict{File: pair.add.file},
+				Local:      joinMemberDefinitions(pair.add.name, localDefs),
+				Remote:     joinMemberDefinitions(pair.add.name, remoteDefs),
+			},
+			MatchType:  "Container Match",
+			Similarity: pair.similarity,
+			Members:    members,
+		})
+	}
+	return moves
+}
+
+// collapseContainerMoves replaces the member conflicts of inter-file
+// container moves with one conflict per class in each affected file
+func collapseContainerMoves(analyses []*SynthesisAnalysis, moves []InterFileMove) {
+	owner := make(map[*SynthesisConflict]*SynthesisConflict)
+	for _, move := range moves {
+		for _, member := range move.Members {
+			owner[member.SourceConflict] = move.SourceConflict
+			owner[member.DestConflict] = move.DestConflict
+		}
+	}
+	if len(owner) == 0 {
+		return
+	}
+
+	for _, analysis := range analyses {
+		// First gather members so each container is complete before it is copied
+		touched := false
+		for i := range analysis.Conflicts {
+			if container, ok := owner[&analysis.Conflicts[i]]; ok {
+				container.Members = append(container.Members, analysis.Conflicts[i])
+				touched = true
+			}
+		}
+		if !touched {
+			continue
+		}
+
+		emitted := make(map[*SynthesisConflict]bool)
+		var conflicts []SynthesisConflict
+		for i := range analysis.Conflicts {
+			container, ok := owner[&analysis.Conflicts[i]]
+			if !ok {
+				conflicts = append(conflicts, analysis.Conflicts[i])
+				continue
+			}
+			if !emitted[container] {
+				emitted[container] = true
+				conflicts = append(conflicts, *container)
+			}
+		}
+		analysis.Conflicts = conflicts
+	}
+}
+
+// expandContainerMoves replaces container conflicts with their member
+// conflicts for synthesis. A resolution chosen for the class applies to
+// every member.
+func expandContainerMoves(conflicts []SynthesisConflict) []SynthesisConflict {
+	expanded := make([]SynthesisConflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		if len(c.Members) == 0 {
+			expanded = append(expanded, c)
+			continue
+		}
+		for _, m := range c.Members {
+			if c.UserResolution != UserResolutionNone {
+				m.UserResolution = c.UserResolution
+				m.ResolvedBy = c.ResolvedBy
+			}
+			expanded = append(expanded, m)
+		}
+	}
+	return expanded
+}
+
+// crossKindCompatible reports whether definitions of two different kinds may
+// be matched as a move (a function becoming a method or vice versa)
+func crossKindCompatible(kind1, kind2 string) bool {
+	callable := map[string]bool{"function": true, "method": true}
+	return callable[kind1] && callable[kind2]
+}
+
+// kindMatchThreshold returns whether two kinds may be matched and the
+// minimum similarity required on top of the size threshold
+func kindMatchThreshold(delKind, addKind string, config MoveDetectionConfig) (bool, float64) {
+	if delKind == addKind {
+		return true, 0
+	}
+	if config.EnableCrossKindMatch && crossKindCompatible(delKind, addKind) {
+		return true, config.CrossKindThreshold
+	}
+	return false, 0
+}
@@ -0,0 +1,224 @@
+package semantic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/simonkoeck/g2/pkg/ui"
+)
+
+var orderMembers = map[string]string{
+	"total":    "def total(self):\n    return sum(item.price * item.qty for item in self.items)",
+	"describe": "def describe(self):\n    return f\"Order {self.id} for {self.customer.name} with {len(self.items)} items\"",
+	"validate": "def validate(self):\n    if not self.items:\n        raise ValueError(\"order has no items\")\n    if self.customer is None:\n        raise ValueError(\"order has no customer\")",
+}
+
+// makeMemberConflict creates an orphan delete or local add of a method
+func makeMemberConflict(file, name, body string, deleted bool) SynthesisConflict {
+	def := &Definition{Name: name, Kind: "method", Body: body}
+	c := SynthesisConflict{
+		UIConflict: ui.Conflict{File: file, Status: "Needs Resolution"},
+	}
+	if deleted {
+		c.UIConflict.ConflictType = "Method '" + name + "' Deleted"
+		c.Base = def
+	} else {
+		c.UIConflict.ConflictType = "Method '" + name + "' Added"
+		c.Local = def
+	}
+	return c
+}
+
+func TestDetectMoves_ContainerRename(t *testing.T) {
+	conflicts := []SynthesisConflict{
+		makeMemberConflict("test.py", "Order.total", orderMembers["total"], true),
+		makeMemberConflict("test.py", "Order.describe", orderMembers["describe"], true),
+		makeMemberConflict("test.py", "Order.validate", orderMembers["validate"], true),
+		makeMemberConflict("test.py", "PurchaseOrder.total", orderMembers["total"], false),
+		// Renamed and slightly changed along with the class
+		makeMemberConflict("test.py", "PurchaseOrder.summary",
+			strings.Replace(strings.Replace(orderMembers["describe"], "describe", "summary", 1), "Order {", "Purchase order {", 1), false),
+		makeMemberConflict("test.py", "PurchaseOrder.validate", orderMembers["validate"], false),
+	}
+
+	result := DetectMoves(conflicts)
+
+	if len(result) != 1 {
+		t.Fatalf("expected 1 container move, got %d", len(result))
+	}
+	c := result[0]
+	if !strings.HasPrefix(c.UIConflict.ConflictType, "Class 'Order' Renamed+Moved to 'PurchaseOrder' (3 members,") {
+		t.Errorf("unexpected conflict type: %s", c.UIConflict.ConflictType)
+	}
+	if c.UIConflict.Status != "Can Auto-merge" {
+		t.Errorf("expected Can Auto-merge, got %s", c.UIConflict.Status)
+	}
+	if len(c.Members) != 3 {
+		t.Fatalf("expected 3 member moves, got %d", len(c.Members))
+	}
+	for _, m := range c.Members {
+		if m.Base.Name == "Order.describe" && m.Local.Name != "PurchaseOrder.summary" {
+			t.Errorf("expected describe to pair with summary, got %s", m.Local.Name)
+		}
+	}
+}
+
+func TestDetectMoves_ContainerDisabled(t *testing.T) {
+	conflicts := []SynthesisConflict{
+		makeMemberConflict("test.py", "Order.total", orderMembers["total"], true),
+		makeMemberConflict("test.py", "Order.validate", orderMembers["validate"], true),
+		makeMemberConflict("test.py", "PurchaseOrder.total", orderMembers["total"], false),
+		makeMemberConflict("test.py", "PurchaseOrder.validate", orderMembers["validate"], false),
+	}
+
+	config := DefaultMoveDetectionConfig()
+	config.EnableContainerMoves = false
+	result := DetectMovesWithConfig(conflicts, config)
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 individual moves, got %d", len(result))
+	}
+	for _, c := range result {
+		if len(c.Members) != 0 {
+			t.Errorf("expected no container move, got %s", c.UIConflict.ConflictType)
+		}
+	}
+}
+
+func TestDetectMoves_ContainerTooFewMembers(t *testing.T) {
+	conflicts := []SynthesisConflict{
+		makeMemberConflict("test.py", "Order.validate", orderMembers["validate"], true),
+		makeMemberConflict("test.py", "PurchaseOrder.validate", orderMembers["validate"], false),
+	}
+
+	result := DetectMoves(conflicts)
+
+	if len(result) != 1 || len(result[0].Members) != 0 {
+		t.Fatalf("expected a plain method move, got %+v", result)
+	}
+}
+
+func TestDetectMoves_CrossKind(t *testing.T) {
+	helper := "def apply_discount(order, rate):\n    if rate <= 0 or rate >= 1:\n        raise ValueError(\"invalid discount rate\")\n    discount = round(order.total() * rate, 2)\n    order.adjustments.append(discount)\n    return order.total() - discount"
+	method := "def apply_discount(self, rate):\n    if rate <= 0 or rate >= 1:\n        raise ValueError(\"invalid discount rate\")\n    discount = round(self.total() * rate, 2)\n    self.adjustments.append(discount)\n    return self.total() - discount"
+
+	newConflicts := func() []SynthesisConflict {
+		return []SynthesisConflict{
+			makeDeleteConflict("apply_discount", helper, 0, 100),
+			makeMemberConflict("test.py", "Order.apply_discount", method, false),
+		}
+	}
+
+	// The method differs by the self parameter, so this scores below 1.0
+	similarity := calculateSimilarity(tokenize(normalize(helper)), tokenize(normalize(method)), DefaultMoveDetectionConfig())
+
+	// Cross-kind matching is opt-in, so default results are unchanged
+	if result := DetectMoves(newConflicts()); len(result) != 2 {
+		t.Errorf("expected no match by default, got %d conflicts", len(result))
+	}
+
+	config := DefaultMoveDetectionConfig()
+	config.EnableCrossKindMatch = true
+	result := DetectMovesWithConfig(newConflicts(), config)
+	if len(result) != 1 {
+		t.Fatalf("expected function→method move (%.2f similarity), got %d conflicts", similarity, len(result))
+	}
+	if !strings.Contains(result[0].UIConflict.ConflictType, "Renamed+Moved to 'Order.apply_discount'") {
+		t.Errorf("unexpected conflict type: %s", result[0].UIConflict.ConflictType)
+	}
+
+	config.CrossKindThreshold = similarity + 0.01
+	if result := DetectMovesWithConfig(newConflicts(), config); len(result) != 2 {
+		t.Errorf("expected cross-kind threshold to reject a %.2f match, got %d conflicts", similarity, len(result))
+	}
+}
+
+func TestKindMatchThreshold(t *testing.T) {
+	config := DefaultMoveDetectionConfig()
+	if ok, _ := kindMatchThreshold("function", "method", config); ok {
+		t.Error("function→method should not match by default")
+	}
+	config.EnableCrossKindMatch = true
+
+	if ok, threshold := kindMatchThreshold("function", "function", config); !ok || threshold != 0 {
+		t.Errorf("same kind: got %v, %v", ok, threshold)
+	}
+	if ok, threshold := kindMatchThreshold("function", "method", config); !ok || threshold != config.CrossKindThreshold {
+		t.Errorf("function→method: got %v, %v", ok, threshold)
+	}
+	if ok, _ := kindMatchThreshold("function", "class", config); ok {
+		t.Error("function→class should not match")
+	}
+}
+
+func TestDetectInterFileMoves_Container(t *testing.T) {
+	var deletes, adds []SynthesisConflict
+	for _, name := range []string{"total", "describe", "validate"} {
+		deletes = append(deletes, makeMemberConflict("models.py", "Order."+name, orderMembers[name], true))
+		adds = append(adds, makeMemberConflict("orders.py", "Order."+name, orderMembers[name], false))
+	}
+	analyses := []*SynthesisAnalysis{
+		makeAnalysis("models.py", append(deletes, makeDeleteConflictForFile("models.py", "unrelated", "def unrelated():\n    return 1"))),
+		makeAnalysis("orders.py", adds),
+	}
+
+	moves := DetectInterFileMoves(analyses)
+	if len(moves) != 1 {
+		t.Fatalf("expected 1 container move, got %d", len(moves))
+	}
+	move := moves[0]
+	if move.MatchType != "Container Match" || len(move.Members) != 3 {
+		t.Fatalf("unexpected move: %s with %d members", move.MatchType, len(move.Members))
+	}
+	if move.SourceConflict.Base.Name != "Order" {
+		t.Errorf("expected source to be named after the class, got %s", move.SourceConflict.Base.Name)
+	}
+
+	ApplyInterFileMoves(analyses, moves)
+
+	if len(analyses[0].Conflicts) != 2 {
+		t.Fatalf("expected container plus unrelated conflict in models.py, got %d", len(analyses[0].Conflicts))
+	}
+	src := analyses[0].Conflicts[0]
+	if src.UIConflict.ConflictType != "Class 'Order' Moved to orders.py (3 members, 100% Match)" {
+		t.Errorf("unexpected source conflict type: %s", src.UIConflict.ConflictType)
+	}
+	if len(src.Members) != 3 || src.Members[0].UIConflict.Status != "Can Auto-merge" {
+		t.Errorf("expected 3 auto-mergeable members, got %+v", src.Members)
+	}
+	if analyses[0].Conflicts[1].Base.Name != "unrelated" {
+		t.Errorf("expected unrelated conflict to be kept, got %s", analyses[0].Conflicts[1].Base.Name)
+	}
+
+	if len(analyses[1].Conflicts) != 1 {
+		t.Fatalf("expected 1 container conflict in orders.py, got %d", len(analyses[1].Conflicts))
+	}
+	if got := analyses[1].Conflicts[0].UIConflict.ConflictType; got != "Class 'Order' Moved from models.py (3 members, 100% Match)" {
+		t.Errorf("unexpected dest conflict type: %s", got)
+	}
+}
+
+func TestExpandContainerMoves(t *testing.T) {
+	container := SynthesisConflict{
+		UserResolution: UserResolutionLocal,
+		Members: []SynthesisConflict{
+			makeMemberConflict("test.py", "Order.total", orderMembers["total"], true),
+			makeMemberConflict("test.py", "Order.validate", orderMembers["validate"], true),
+		},
+	}
+	other := makeDeleteConflict("foo", "def foo(): pass", 0, 10)
+
+	expanded := expandContainerMoves([]SynthesisConflict{other, container})
+
+	if len(expanded) != 3 {
+		t.Fatalf("expected 3 conflicts, got %d", len(expanded))
+	}
+	for _, c := range expanded[1:] {
+		if c.UserResolution != UserResolutionLocal {
+			t.Errorf("expected member to inherit resolution, got %v", c.UserResolution)
+		}
+	}
+	if expanded[0].Base.Name != "foo" {
+		t.Errorf("expected other conflict first, got %s", expanded[0].Base.Name)
+	}
+}
@@ -0,0 +1,109 @@
+package semantic
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Explanation records how g2 arrived at a conflict's classification. It is
+// only kept with MergeConfig.Explain; later passes add to conflicts that
+// carry one and leave the others alone.
+type Explanation struct {
+	Rule             string // The classification rule that fired
+	BaseNormalized   string // Normalized bodies that were compared ("" if absent)
+	LocalNormalized  string
+	RemoteNormalized string
+	Move             *MoveScore // Set for fuzzy moves
+	Notes            []string   // Later decisions in order: moves, policy rules, preferences, collisions
+}
+
+// MoveScore is the similarity of a fuzzy move and the threshold it was held to
+type MoveScore struct {
+	Candidate    string  // Name of the matched (or nearest rejected) add
+	DeleteTokens int     // Token count of the deleted body
+	AddTokens    int     // Token count of the added body
+	Similarity   float64 // 0.0-1.0
+	Threshold    float64 // Stricter getThresholdForSize of both bodies, raised for cross-kind pairs
+}
+
+// explain records the rule that classified the conflict. Analysis drops the
+// explanation again unless MergeConfig.Explain is set.
+func (c *SynthesisConflict) explain(rule, baseNorm, localNorm, remoteNorm string) *SynthesisConflict {
+	c.Explain = &Explanation{
+		Rule:             rule,
+		BaseNormalized:   baseNorm,
+		LocalNormalized:  localNorm,
+		RemoteNormalized: remoteNorm,
+	}
+	return c
+}
+
+// note appends a later decision to the conflict's explanation, if it has
+// one. A decision already noted is skipped, since the policy is applied
+// again after inter-file moves. The explanation is copied first since
+// conflicts are copied by value between passes and may share it.
+func (c *SynthesisConflict) note(format string, args ...any) {
+	if c.Explain == nil {
+		return
+	}
+	text := fmt.Sprintf(format, args...)
+	if slices.Contains(c.Explain.Notes, text) {
+		return
+	}
+	e := *c.Explain
+	e.Notes = append(slices.Clone(e.Notes), text)
+	c.Explain = &e
+}
+
+// explainMove records the explanation of a move built from a delete and an
+// add, keeping the normalized forms each side was analyzed with
+func explainMove(move *SynthesisConflict, del, add *SynthesisConflict) {
+	if del.Explain == nil && add.Explain == nil {
+		return
+	}
+	e := &Explanation{}
+	if move.Move != nil {
+		m := move.Move
+		if m.MatchType == "Exact Match" {
+			e.Rule = fmt.Sprintf("delete of '%s' matched add of '%s': normalized bodies are identical", m.SourceName, m.DestName)
+		} else {
+			e.Rule = fmt.Sprintf("delete of '%s' matched add of '%s' (%s)", m.SourceName, m.DestName, m.MatchType)
+		}
+	}
+	if del.Explain != nil {
+		e.BaseNormalized = del.Explain.BaseNormalized
+		e.Notes = append(e.Notes, del.Explain.Notes...)
+	}
+	if add.Explain != nil {
+		e.LocalNormalized = add.Explain.LocalNormalized
+		e.RemoteNormalized = add.Explain.RemoteNormalized
+		e.Notes = append(e.Notes, add.Explain.Notes...)
+	}
+	move.Explain = e
+}
+
+// explainInterFileMove notes a move between files on both of its sides
+func explainInterFileMove(move InterFileMove, info *MoveInfo) {
+	match := move.MatchType
+	if move.Score != nil {
+		match += ", " + move.Score.String()
+	} else if move.MatchType == "Exact Match" {
+		match += ", normalized bodies are identical"
+	}
+	for _, c := range []*SynthesisConflict{move.SourceConflict, move.DestConflict} {
+		if c.Explain == nil {
+			continue
+		}
+		c.note("moved across files: %s:%s -> %s:%s (%s)", info.SourceFile, info.SourceName, info.DestFile, info.DestName, match)
+		c.Explain.Move = move.Score
+	}
+}
+
+// String describes the score, e.g. "0.820 >= threshold 0.750 (40 and 44 tokens)"
+func (s *MoveScore) String() string {
+	op := ">="
+	if s.Similarity < s.Threshold {
+		op = "<"
+	}
+	return fmt.Sprintf("%.3f %s threshold %.3f (%d and %d tokens)", s.Similarity, op, s.Threshold, s.DeleteTokens, s.AddTokens)
+}
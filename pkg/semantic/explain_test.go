@@ -0,0 +1,136 @@
+package semantic
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// explained gives a conflict an empty explanation, as analysis does with
+// MergeConfig.Explain, so that later passes note their decisions on it
+func explained(c SynthesisConflict) SynthesisConflict {
+	c.Explain = &Explanation{}
+	return c
+}
+
+// TestExplainClassification tests that the rule and normalized bodies are recorded
+func TestExplainClassification(t *testing.T) {
+	base := []byte("def calc(x):\n    return x + 1\n")
+	local := []byte("def calc(x):\n    # add one\n    return x + 1\n")
+	remote := []byte("def calc(x):\n    # increment\n    return x + 1\n")
+
+	versions := &ConflictVersions{Base: base, Local: local, Remote: remote}
+	config := DefaultMergeConfig()
+	analysis := AnalyzeVersionsForSynthesis("calc.py", versions, config)
+	if len(analysis.Conflicts) != 1 || analysis.Conflicts[0].Explain != nil {
+		t.Fatalf("explanations should only be recorded with MergeConfig.Explain, got %+v", analysis.Conflicts)
+	}
+
+	config.Explain = true
+	analysis = AnalyzeVersionsForSynthesis("calc.py", versions, config)
+	if len(analysis.Conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(analysis.Conflicts))
+	}
+	e := analysis.Conflicts[0].Explain
+	if e == nil {
+		t.Fatal("expected an explanation")
+	}
+	if !strings.Contains(e.Rule, "comments differ") {
+		t.Errorf("expected the comment-change rule, got %q", e.Rule)
+	}
+	if e.LocalNormalized == "" || e.LocalNormalized != e.RemoteNormalized || e.LocalNormalized != e.BaseNormalized {
+		t.Errorf("normalized bodies should all match, got %q / %q / %q", e.BaseNormalized, e.LocalNormalized, e.RemoteNormalized)
+	}
+	if strings.Contains(e.LocalNormalized, "#") {
+		t.Errorf("normalized body should not contain comments: %q", e.LocalNormalized)
+	}
+}
+
+// TestExplainFuzzyMove tests that fuzzy moves record their score and threshold
+func TestExplainFuzzyMove(t *testing.T) {
+	deleteBody := "def foo():\n    x = calculate_value(input_param)\n    y = process_data(x, config)\n    z = transform_result(y)\n    return x + y + z"
+	addBody := "def foo():\n    x = calculate_value(input_param)\n    y = process_data(x, config)\n    z = transform_result(y)\n    return x * y * z"
+
+	result := DetectMoves([]SynthesisConflict{
+		explained(makeDeleteConflict("foo", deleteBody, 0, 100)),
+		explained(makeAddConflict("foo", addBody, 200, 300)),
+	})
+	if len(result) != 1 {
+		t.Fatalf("expected 1 move, got %d", len(result))
+	}
+	e := result[0].Explain
+	if e == nil || e.Move == nil {
+		t.Fatalf("expected a move score, got %+v", e)
+	}
+	config := DefaultMoveDetectionConfig()
+	want := pairThreshold(e.Move.DeleteTokens, e.Move.AddTokens, 0, config)
+	if e.Move.Threshold != want {
+		t.Errorf("threshold = %v, want %v", e.Move.Threshold, want)
+	}
+	if e.Move.Similarity < e.Move.Threshold || e.Move.Similarity != result[0].Move.Similarity {
+		t.Errorf("unexpected similarity %v (threshold %v)", e.Move.Similarity, e.Move.Threshold)
+	}
+	if !strings.Contains(e.Rule, "matched add of 'foo'") {
+		t.Errorf("unexpected rule %q", e.Rule)
+	}
+}
+
+// TestExplainRejectedMove tests that an unmatched delete notes its nearest candidate
+func TestExplainRejectedMove(t *testing.T) {
+	deleteBody := "def foo():\n    x = calculate_value(input_param)\n    y = process_data(x, config)\n    return x + y"
+	addBody := "def bar():\n    items = load_items(path)\n    total = sum_items(items, config)\n    return total"
+
+	result := DetectMoves([]SynthesisConflict{
+		explained(makeDeleteConflict("foo", deleteBody, 0, 100)),
+		explained(makeAddConflict("bar", addBody, 200, 300)),
+	})
+	if len(result) != 2 {
+		t.Fatalf("expected no move, got %d conflicts", len(result))
+	}
+	for _, c := range result {
+		if c.Base == nil {
+			continue
+		}
+		if c.Explain == nil || len(c.Explain.Notes) != 1 {
+			t.Fatalf("expected one note on the delete, got %+v", c.Explain)
+		}
+		if note := c.Explain.Notes[0]; !strings.Contains(note, "nearest add 'bar'") || !strings.Contains(note, "< threshold") {
+			t.Errorf("unexpected note %q", note)
+		}
+	}
+}
+
+// TestExplainCollision tests that the outer conflict of a collision notes the inner one
+func TestExplainCollision(t *testing.T) {
+	conflicts := []SynthesisConflict{
+		explained(makeConflict("MyClass", 0, 200)),
+		explained(makeConflict("myMethod", 50, 100)),
+	}
+	result := handleCollisions(conflicts, detectCollisions(conflicts))
+	if len(result) != 1 || result[0].Explain == nil {
+		t.Fatalf("expected an explained outer conflict, got %+v", result)
+	}
+	if note := result[0].Explain.Notes[0]; !strings.Contains(note, "Function 'myMethod' Modified") {
+		t.Errorf("unexpected note %q", note)
+	}
+	if len(conflicts[0].Explain.Notes) != 0 {
+		t.Error("handling collisions should not change the input conflicts")
+	}
+}
+
+// TestExplainPolicyRule tests that a deciding policy rule is noted once
+func TestExplainPolicyRule(t *testing.T) {
+	c := explained(makeConflict("calc", 0, 100))
+	rule := &PolicyRule{Name: "keep-local", Action: ActionLocal}
+	applyRuleResolution(&c, rule, UserResolutionLocal)
+	applyRuleResolution(&c, rule, UserResolutionLocal)
+	if want := []string{`policy rule "keep-local": resolved as local`}; !slices.Equal(c.Explain.Notes, want) {
+		t.Errorf("notes = %q, want %q", c.Explain.Notes, want)
+	}
+
+	c = makeConflict("calc", 0, 100)
+	applyRuleResolution(&c, rule, UserResolutionLocal)
+	if c.Explain != nil {
+		t.Error("conflicts without an explanation should not get one")
+	}
+}
@@ -0,0 +1,318 @@
+package semantic
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// goMove is a definition moved between Go packages
+type goMove struct {
+	Name     string // Exported identifier
+	FromPath string // Import path of the source package
+	FromName string // Package name of the source
+	ToPath   string // Import path of the destination package
+	ToName   string // Package name of the destination
+}
+
+// Go source patterns
+var (
+	// module example.com/project
+	goModuleRe = regexp.MustCompile(`^\s*module\s+"?([^\s"]+)"?`)
+	// package name
+	goPackageRe = regexp.MustCompile(`^\s*package\s+(\w+)`)
+	// import alias "path" (single-line form)
+	goImportLineRe = regexp.MustCompile(`^(\s*import\s+)(?:([\w.]+)\s+)?"([^"]+)"(.*)$`)
+	// alias "path" (inside an import block)
+	goImportSpecRe = regexp.MustCompile(`^(\s*)(?:([\w.]+)\s+)?"([^"]+)"(.*)$`)
+)
+
+// goModule describes the go.mod that owns a directory
+type goModule struct {
+	Dir  string // Directory of go.mod, relative to the repository root
+	Path string // Module path
+}
+
+// goModuleFor returns the module owning dir (relative to the repository
+// root) by looking for the nearest go.mod above it
+func (s *importScope) goModuleFor(dir string) (goModule, bool) {
+	for {
+		if mod, ok := s.goModules[dir]; ok {
+			return mod, mod.Path != ""
+		}
+
+		mod := goModule{Dir: dir}
+		if content, err := os.ReadFile(filepath.Join(s.root, dir, "go.mod")); err == nil {
+			scanner := bufio.NewScanner(strings.NewReader(string(content)))
+			for scanner.Scan() {
+				if m := goModuleRe.FindStringSubmatch(scanner.Text()); m != nil {
+					mod.Path = m[1]
+					break
+				}
+			}
+		}
+		if mod.Path != "" {
+			s.goModules[dir] = mod
+			return mod, true
+		}
+		if dir == "." || dir == "" {
+			s.goModules[dir] = mod
+			return mod, false
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// goImportPath returns the import path of the package containing file, or
+// "" if the file is not inside a Go module
+func (s *importScope) goImportPath(file string) string {
+	dir := filepath.Dir(file)
+	mod, ok := s.goModuleFor(dir)
+	if !ok {
+		return ""
+	}
+	rel, err := filepath.Rel(mod.Dir, dir)
+	if err != nil {
+		return ""
+	}
+	if rel == "." {
+		return mod.Path
+	}
+	return mod.Path + "/" + filepath.ToSlash(rel)
+}
+
+// goPackageName returns the package name declared in file, falling back to
+// the last element of its import path
+func (s *importScope) goPackageName(file, importPath string) string {
+	if content, err := os.ReadFile(filepath.Join(s.root, file)); err == nil {
+		scanner := bufio.NewScanner(strings.NewReader(string(content)))
+		for scanner.Scan() {
+			if m := goPackageRe.FindStringSubmatch(scanner.Text()); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return path.Base(importPath)
+}
+
+// goMoves returns the moves that change the package of an exported Go
+// definition. Methods are left out; they move with their receiver type.
+func (s *importScope) goMoves(moves []InterFileMove) []goMove {
+	var result []goMove
+	for _, move := range moves {
+		if DetectLanguage(move.SourceFile) != LangGo || DetectLanguage(move.DestFile) != LangGo {
+			continue
+		}
+		def := move.SourceConflict.Base
+		if def == nil || def.Kind == "method" || !isExportedGoName(def.Name) {
+			continue
+		}
+		from, to := s.goImportPath(move.SourceFile), s.goImportPath(move.DestFile)
+		if from == "" || to == "" || from == to {
+			continue
+		}
+		result = append(result, goMove{
+			Name:     def.Name,
+			FromPath: from,
+			FromName: s.goPackageName(move.SourceFile, from),
+			ToPath:   to,
+			ToName:   s.goPackageName(move.DestFile, to),
+		})
+	}
+	return result
+}
+
+// isExportedGoName reports whether name can be referenced from other packages
+func isExportedGoName(name string) bool {
+	for _, r := range name {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+// goImportSpec is one imported package in a Go file
+type goImportSpec struct {
+	Line   int    // 0-based line index
+	Alias  string // Explicit alias, if any
+	Path   string
+	Indent string // Leading whitespace in a block, or the "import " prefix
+}
+
+// qualifier returns the name the file uses to refer to the package
+func (spec goImportSpec) qualifier(defaultName string) string {
+	if spec.Alias != "" {
+		return spec.Alias
+	}
+	return defaultName
+}
+
+// parseGoImports finds the import specs of a Go file
+func parseGoImports(lines []string) []goImportSpec {
+	var specs []goImportSpec
+	inBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if inBlock {
+			if strings.HasPrefix(trimmed, ")") {
+				inBlock = false
+				continue
+			}
+			if m := goImportSpecRe.FindStringSubmatch(line); m != nil {
+				specs = append(specs, goImportSpec{Line: i, Alias: m[2], Path: m[3], Indent: m[1]})
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "import (") || trimmed == "import(" {
+			inBlock = true
+			continue
+		}
+		if m := goImportLineRe.FindStringSubmatch(line); m != nil {
+			specs = append(specs, goImportSpec{Line: i, Alias: m[2], Path: m[3], Indent: m[1]})
+		}
+		// Imports must precede all other declarations
+		if strings.HasPrefix(trimmed, "func ") || strings.HasPrefix(trimmed, "type ") ||
+			strings.HasPrefix(trimmed, "var ") || strings.HasPrefix(trimmed, "const ") {
+			break
+		}
+	}
+	return specs
+}
+
+// findGoImportUpdates finds import paths and package qualifiers that need
+// updating in a Go file. filePath is the import path of the file's own
+// package; references into that package lose their qualifier.
+func findGoImportUpdates(file string, content []byte, moves []goMove, filePath string) []ImportUpdate {
+	if len(moves) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	specs := parseGoImports(lines)
+	if len(specs) == 0 {
+		return nil
+	}
+
+	imported := make(map[string]goImportSpec)
+	importLines := make(map[int]bool)
+	for _, spec := range specs {
+		imported[spec.Path] = spec
+		importLines[spec.Line] = true
+	}
+
+	// Rewrite qualified references in code, line by line
+	refs := qualifiedRefs(LangGo, content)
+	rewritten := make([]bool, len(refs))
+	edits := make(map[int]*lineEdit)
+	movedNames := make(map[string][]string) // old import path -> names
+	added := make(map[string][]string)      // old import path -> new import paths it brings in
+	addedPaths := make(map[string]bool)
+	packageNames := make(map[string]string) // old import path -> package name
+	for _, move := range moves {
+		spec, ok := imported[move.FromPath]
+		if !ok || spec.Alias == "_" || spec.Alias == "." {
+			continue
+		}
+		oldQual := spec.qualifier(move.FromName)
+		packageNames[move.FromPath] = move.FromName
+
+		// References into the file's own package need no qualifier
+		replacement := ""
+		if move.ToPath != filePath {
+			if dest, ok := imported[move.ToPath]; ok {
+				replacement = dest.qualifier(move.ToName) + "."
+			} else {
+				replacement = move.ToName + "."
+			}
+		}
+
+		found := false
+		for k, ref := range refs {
+			if rewritten[k] || ref.Qual != oldQual || ref.Name != move.Name {
+				continue
+			}
+			rewritten[k] = true
+			edit, ok := edits[ref.Line]
+			if !ok {
+				edit = &lineEdit{from: move.FromPath, to: move.ToPath}
+				edits[ref.Line] = edit
+			}
+			if n := len(edit.names); n == 0 || edit.names[n-1] != move.Name {
+				edit.names = append(edit.names, move.Name)
+			}
+			edit.spans = append(edit.spans, lineSpan{start: ref.Start, end: ref.NameStart, text: replacement})
+			found = true
+		}
+		if !found {
+			continue
+		}
+
+		movedNames[move.FromPath] = append(movedNames[move.FromPath], move.Name)
+		if _, ok := imported[move.ToPath]; !ok && move.ToPath != filePath && !addedPaths[move.ToPath] {
+			addedPaths[move.ToPath] = true
+			added[move.FromPath] = append(added[move.FromPath], move.ToPath)
+		}
+	}
+	if len(movedNames) == 0 {
+		return nil
+	}
+
+	var updates []ImportUpdate
+
+	// Update the import of each package that lost a definition: drop it if
+	// nothing else uses it, and bring in the destination packages
+	for _, spec := range specs {
+		names, ok := movedNames[spec.Path]
+		if !ok {
+			continue
+		}
+
+		// References left as they are keep the package in use
+		qual := spec.qualifier(packageNames[spec.Path])
+		stillUsed := false
+		for k, ref := range refs {
+			if !rewritten[k] && ref.Qual == qual {
+				stillUsed = true
+				break
+			}
+		}
+
+		var newLines []string
+		if stillUsed {
+			newLines = append(newLines, lines[spec.Line])
+		}
+		for _, p := range added[spec.Path] {
+			newLines = append(newLines, spec.Indent+`"`+p+`"`)
+		}
+		if stillUsed && len(newLines) == 1 {
+			continue
+		}
+
+		toModule := filePath
+		if len(added[spec.Path]) > 0 {
+			toModule = strings.Join(added[spec.Path], ", ")
+		}
+		updates = append(updates, ImportUpdate{
+			File:       file,
+			OldImport:  lines[spec.Line],
+			NewImport:  strings.Join(newLines, "\n"),
+			LineNumber: spec.Line + 1,
+			Definition: strings.Join(names, ", "),
+			FromModule: spec.Path,
+			ToModule:   toModule,
+			Kind:       ImportEditImport,
+		})
+	}
+
+	// Then the qualified references
+	for i, edit := range edits {
+		edit.text = edit.apply(lines[i])
+	}
+	updates = append(updates, lineEditUpdates(file, lines, edits)...)
+
+	setImportUpdateOffsets(updates, lines)
+	return updates
+}
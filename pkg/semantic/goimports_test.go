@@ -0,0 +1,180 @@
+package semantic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRepoFiles creates files (path -> content) under root
+func writeRepoFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// makeInterFileMove creates a move of a named definition between files
+func makeInterFileMove(from, to, name, kind string) InterFileMove {
+	return InterFileMove{
+		SourceFile:     from,
+		DestFile:       to,
+		SourceConflict: &SynthesisConflict{Base: &Definition{Name: name, Kind: kind}},
+		DestConflict:   &SynthesisConflict{},
+		MatchType:      "Exact Match",
+		Similarity:     1.0,
+	}
+}
+
+func TestImportScope_GoImportPath(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"go.mod":           "module example.com/app\n\ngo 1.24\n",
+		"tools/gen/go.mod": "module example.com/tools\n",
+	})
+	scope := newImportScope(root)
+
+	tests := map[string]string{
+		"main.go":               "example.com/app",
+		"internal/util/util.go": "example.com/app/internal/util",
+		"tools/gen/main.go":     "example.com/tools",
+		"tools/gen/sub/x.go":    "example.com/tools/sub",
+	}
+	for file, want := range tests {
+		if got := scope.goImportPath(file); got != want {
+			t.Errorf("goImportPath(%q) = %q, want %q", file, got, want)
+		}
+	}
+
+	if got := newImportScope(t.TempDir()).goImportPath("main.go"); got != "" {
+		t.Errorf("expected no import path without go.mod, got %q", got)
+	}
+}
+
+func TestFindGoImportUpdates(t *testing.T) {
+	moves := []goMove{{
+		Name:     "CalcTotal",
+		FromPath: "example.com/app/internal/util",
+		FromName: "util",
+		ToPath:   "example.com/app/internal/pricing",
+		ToName:   "pricing",
+	}}
+
+	t.Run("replaces unused import", func(t *testing.T) {
+		content := "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/internal/util\"\n)\n\nfunc main() {\n\tfmt.Println(util.CalcTotal(nil))\n}\n"
+		updates := findGoImportUpdates("main.go", []byte(content), moves, "example.com/app")
+
+		if len(updates) != 2 {
+			t.Fatalf("expected import and call site updates, got %d: %+v", len(updates), updates)
+		}
+		if updates[0].NewImport != "\t\"example.com/app/internal/pricing\"" || updates[0].LineNumber != 6 {
+			t.Errorf("unexpected import update: %+v", updates[0])
+		}
+		if updates[1].NewImport != "\tfmt.Println(pricing.CalcTotal(nil))" {
+			t.Errorf("unexpected call site update: %q", updates[1].NewImport)
+		}
+	})
+
+	t.Run("keeps import still in use", func(t *testing.T) {
+		content := "package main\n\nimport \"example.com/app/internal/util\"\n\nvar a = util.CalcTotal(nil)\nvar b = util.Round(a)\n"
+		updates := findGoImportUpdates("main.go", []byte(content), moves, "example.com/app")
+
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d", len(updates))
+		}
+		want := "import \"example.com/app/internal/util\"\nimport \"example.com/app/internal/pricing\""
+		if updates[0].NewImport != want {
+			t.Errorf("NewImport = %q, want %q", updates[0].NewImport, want)
+		}
+	})
+
+	t.Run("aliased import", func(t *testing.T) {
+		content := "package main\n\nimport (\n\tu \"example.com/app/internal/util\"\n)\n\nvar a = u.CalcTotal(nil)\n"
+		updates := findGoImportUpdates("main.go", []byte(content), moves, "example.com/app")
+
+		if len(updates) != 2 || updates[1].NewImport != "var a = pricing.CalcTotal(nil)" {
+			t.Fatalf("unexpected updates: %+v", updates)
+		}
+	})
+
+	t.Run("caller in destination package", func(t *testing.T) {
+		content := "package pricing\n\nimport \"example.com/app/internal/util\"\n\nvar a = util.CalcTotal(nil)\n"
+		updates := findGoImportUpdates("internal/pricing/x.go", []byte(content), moves, "example.com/app/internal/pricing")
+
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d", len(updates))
+		}
+		if updates[0].NewImport != "" {
+			t.Errorf("expected unused import to be removed, got %q", updates[0].NewImport)
+		}
+		if updates[1].NewImport != "var a = CalcTotal(nil)" {
+			t.Errorf("expected qualifier to be dropped, got %q", updates[1].NewImport)
+		}
+	})
+
+	t.Run("unrelated selector", func(t *testing.T) {
+		content := "package main\n\nimport \"example.com/app/internal/util\"\n\nvar a = x.util.CalcTotal(nil)\nvar b = util.Round(1)\n"
+		if updates := findGoImportUpdates("main.go", []byte(content), moves, "example.com/app"); len(updates) != 0 {
+			t.Errorf("expected no updates, got %+v", updates)
+		}
+	})
+
+	t.Run("skips strings and comments", func(t *testing.T) {
+		content := "package main\n\nimport \"example.com/app/internal/util\"\n\n// util.CalcTotal sums items\nvar a = util.CalcTotal(nil) // see util.CalcTotal\nvar b = \"util.CalcTotal\"\n"
+		updates := findGoImportUpdates("main.go", []byte(content), moves, "example.com/app")
+
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d: %+v", len(updates), updates)
+		}
+		if updates[0].NewImport != "import \"example.com/app/internal/pricing\"" {
+			t.Errorf("expected comments to leave the import unused, got %q", updates[0].NewImport)
+		}
+		if want := "var a = pricing.CalcTotal(nil) // see util.CalcTotal"; updates[1].NewImport != want || updates[1].LineNumber != 6 {
+			t.Errorf("NewImport = %q (line %d), want %q", updates[1].NewImport, updates[1].LineNumber, want)
+		}
+	})
+
+	t.Run("qualified type", func(t *testing.T) {
+		typeMoves := []goMove{{Name: "Cart", FromPath: moves[0].FromPath, FromName: "util", ToPath: moves[0].ToPath, ToName: "pricing"}}
+		content := "package main\n\nimport \"example.com/app/internal/util\"\n\nfunc total(c *util.Cart, d util.Cart) {}\n"
+		updates := findGoImportUpdates("main.go", []byte(content), typeMoves, "example.com/app")
+
+		if len(updates) != 2 || updates[1].NewImport != "func total(c *pricing.Cart, d pricing.Cart) {}" {
+			t.Fatalf("unexpected updates: %+v", updates)
+		}
+	})
+}
+
+func TestFindImportUpdates_Go(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"go.mod":                     "module example.com/app\n",
+		"internal/util/util.go":      "package util\n",
+		"internal/pricing/totals.go": "package pricing\n\nfunc CalcTotal(items []int) int { return 0 }\n",
+		"main.go":                    "package main\n\nimport (\n\t\"example.com/app/internal/util\"\n)\n\nfunc main() {\n\t_ = util.CalcTotal(nil)\n}\n",
+	})
+
+	moves := []InterFileMove{makeInterFileMove("internal/util/util.go", "internal/pricing/totals.go", "CalcTotal", "function")}
+	updates, err := FindImportUpdates(moves, root)
+	if err != nil {
+		t.Fatalf("FindImportUpdates failed: %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 updates, got %d", len(updates))
+	}
+
+	ApplyImportUpdates(updates, root, MergeConfig{})
+	content, _ := os.ReadFile(filepath.Join(root, "main.go"))
+	if !strings.Contains(string(content), "\t\"example.com/app/internal/pricing\"\n") ||
+		!strings.Contains(string(content), "_ = pricing.CalcTotal(nil)") ||
+		strings.Contains(string(content), "internal/util") {
+		t.Errorf("unexpected result:\n%s", content)
+	}
+}
@@ -3,12 +3,16 @@ package semantic
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
+	sitter "github.com/smacker/go-tree-sitter"
+
 	"github.com/simonkoeck/g2/pkg/logging"
 )
 
@@ -23,8 +27,18 @@ type ImportUpdate struct {
 	Definition string // Name of the definition that moved
 	FromModule string // Original module
 	ToModule   string // New module
+	Kind       string // What was edited (ImportEdit*)
 }
 
+// Kinds of import edits
+const (
+	ImportEditImport        = "import"         // An import or use statement
+	ImportEditUsage         = "usage"          // A module-qualified reference (utils.helper)
+	ImportEditReExport      = "re-export"      // An export ... from statement
+	ImportEditRequire       = "require"        // A require() call
+	ImportEditDynamicImport = "dynamic import" // An import() call
+)
+
 // ImportUpdateResult contains the outcome of applying import updates
 type ImportUpdateResult struct {
 	File         string
@@ -35,6 +49,12 @@ type ImportUpdateResult struct {
 // FindImportUpdates scans the repository for files that need import updates
 // based on detected inter-file moves
 func FindImportUpdates(moves []InterFileMove, repoRoot string) ([]ImportUpdate, error) {
+	return FindImportUpdatesWithContext(context.Background(), moves, repoRoot)
+}
+
+// FindImportUpdatesWithContext scans the repository for files that need
+// import updates using context. Only files git knows about are scanned.
+func FindImportUpdatesWithContext(ctx context.Context, moves []InterFileMove, repoRoot string) ([]ImportUpdate, error) {
 	if len(moves) == 0 {
 		return nil, nil
 	}
@@ -51,8 +71,93 @@ func FindImportUpdates(moves []InterFileMove, repoRoot string) ([]ImportUpdate,
 		moveMap[struct{ sourceModule, defName string }{sourceModule, defName}] = destModule
 	}
 
-	// Find all source files to scan
-	var filesToScan []string
+	scope := newImportScope(repoRoot)
+	goMoves := scope.goMoves(moves)
+	rustMoves := scope.rustMoves(moves)
+
+	filesToScan, err := listSourceFiles(ctx, repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []ImportUpdate
+
+	for _, file := range filesToScan {
+		if !settingsFor(file).importUpdatesEnabled() {
+			continue
+		}
+		fullPath := filepath.Join(repoRoot, file)
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			logging.Debug("failed to read file for import scanning", "file", file, "error", err)
+			continue // Skip files we can't read
+		}
+
+		lang := DetectLanguage(file)
+		var fileUpdates []ImportUpdate
+
+		switch lang {
+		case LangPython:
+			fileUpdates = findPythonImportUpdates(file, content, moveMap)
+		case LangJavaScript, LangTypeScript:
+			fileUpdates = findJSImportUpdates(file, content, moveMap, scope.tsAliasesFor(filepath.Dir(file)))
+		case LangGo:
+			if len(goMoves) > 0 {
+				fileUpdates = findGoImportUpdates(file, content, goMoves, scope.goImportPath(file))
+			}
+		case LangRust:
+			if crate, module, ok := scope.rustModulePath(file); ok && len(rustMoves) > 0 {
+				fileUpdates = findRustImportUpdates(file, content, rustMoves, crate, module)
+			}
+		}
+
+		updates = append(updates, fileUpdates...)
+	}
+
+	return updates, nil
+}
+
+// listSourceFiles returns the supported source files to scan for imports,
+// relative to repoRoot. The list comes from git so untracked and ignored
+// files and paths outside a sparse checkout are left alone; outside a git
+// repository the directory tree is walked instead.
+func listSourceFiles(ctx context.Context, repoRoot string) ([]string, error) {
+	output, err := gitExec.Output(ctx, "-C", repoRoot, "ls-files", "-z", "-t", "--cached")
+	if err != nil {
+		logging.Debug("git ls-files failed, walking the tree instead", "root", repoRoot, "error", err)
+		return walkSourceFiles(repoRoot)
+	}
+	return parseLsFiles(output, repoRoot), nil
+}
+
+// parseLsFiles parses NUL-separated `git ls-files -z -t` output into the
+// supported source files present in the working tree. Entries marked
+// skip-worktree (S) are outside the sparse checkout; unmerged entries repeat
+// once per stage.
+func parseLsFiles(output []byte, repoRoot string) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if len(entry) < 3 || entry[1] != ' ' {
+			continue
+		}
+		tag, path := entry[0], entry[2:]
+		if tag == 'S' || seen[path] || !IsSemanticFile(path) {
+			continue
+		}
+		seen[path] = true
+		if _, err := os.Stat(filepath.Join(repoRoot, path)); err != nil {
+			continue
+		}
+		files = append(files, filepath.FromSlash(path))
+	}
+	return files
+}
+
+// walkSourceFiles finds supported source files by walking the directory
+// tree, skipping hidden and common dependency directories
+func walkSourceFiles(repoRoot string) ([]string, error) {
+	var files []string
 	var walkErrors []error
 	err := filepath.Walk(repoRoot, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -63,7 +168,7 @@ func FindImportUpdates(moves []InterFileMove, repoRoot string) ([]ImportUpdate,
 		if info.IsDir() {
 			// Skip hidden directories and common non-source directories
 			name := info.Name()
-			if strings.HasPrefix(name, ".") || name == "node_modules" || name == "__pycache__" || name == "venv" || name == "vendor" {
+			if path != repoRoot && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "__pycache__" || name == "venv" || name == "vendor") {
 				return filepath.SkipDir
 			}
 			return nil
@@ -75,7 +180,7 @@ func FindImportUpdates(moves []InterFileMove, repoRoot string) ([]ImportUpdate,
 				logging.Debug("failed to get relative path", "path", path, "error", relErr)
 				return nil
 			}
-			filesToScan = append(filesToScan, relPath)
+			files = append(files, relPath)
 		}
 		return nil
 	})
@@ -85,31 +190,165 @@ func FindImportUpdates(moves []InterFileMove, repoRoot string) ([]ImportUpdate,
 	if len(walkErrors) > 0 {
 		logging.Warn("encountered errors while scanning repository", "error_count", len(walkErrors))
 	}
+	return files, nil
+}
 
-	var updates []ImportUpdate
+// importScope caches the project files that decide how imports resolve:
+// go.mod module paths, Cargo.toml crate roots and tsconfig path aliases
+type importScope struct {
+	root       string
+	goModules  map[string]goModule       // Directory -> owning module
+	rustCrates map[string]string         // Directory -> crate directory ("" if none)
+	tsAliases  map[string]*tsPathAliases // Directory -> aliases of the nearest tsconfig (nil if none)
+}
 
-	for _, file := range filesToScan {
-		fullPath := filepath.Join(repoRoot, file)
-		content, err := os.ReadFile(fullPath)
-		if err != nil {
-			logging.Debug("failed to read file for import scanning", "file", file, "error", err)
-			continue // Skip files we can't read
+// newImportScope creates the scope for a repository
+func newImportScope(repoRoot string) *importScope {
+	return &importScope{
+		root:       repoRoot,
+		goModules:  make(map[string]goModule),
+		rustCrates: make(map[string]string),
+		tsAliases:  make(map[string]*tsPathAliases),
+	}
+}
+
+// setImportUpdateOffsets fills in the byte range of each update's line
+func setImportUpdateOffsets(updates []ImportUpdate, lines []string) {
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line) + 1
+	}
+	for i := range updates {
+		idx := updates[i].LineNumber - 1
+		updates[i].StartByte = offsets[idx]
+		updates[i].EndByte = offsets[idx] + len(lines[idx])
+	}
+}
+
+// qualifiedRef is a reference qual.Name to a name in another module
+type qualifiedRef struct {
+	Line      int // 0-based line index
+	Start     int // Byte column of the qualifier
+	NameStart int // Byte column of the name
+	Qual      string
+	Name      string
+}
+
+// qualifiedRefFields are the node types of qualified references per
+// language, with the fields holding the qualifier and the name
+var qualifiedRefFields = map[Language]map[string][2]string{
+	LangGo: {
+		"selector_expression": {"operand", "field"},
+		"qualified_type":      {"package", "name"},
+	},
+	LangPython: {
+		"attribute": {"object", "attribute"},
+	},
+	LangJavaScript: {
+		"member_expression": {"object", "property"},
+	},
+	LangTypeScript: {
+		"member_expression":      {"object", "property"},
+		"nested_type_identifier": {"module", "name"},
+	},
+}
+
+// qualifierRe matches a qualifier made of plain names, e.g. "utils" or
+// "os.path"
+var qualifierRe = regexp.MustCompile(`^[\w$]+(?:\.[\w$]+)*$`)
+
+// qualifiedRefs finds the qualified references in the code of a file whose
+// qualifier is a name or a dotted chain of names. Comments and string
+// literals are not code, so they are never matched.
+func qualifiedRefs(lang Language, content []byte) []qualifiedRef {
+	fields, ok := qualifiedRefFields[lang]
+	if !ok {
+		return nil
+	}
+	parser := acquireParser(lang)
+	defer releaseParser(lang, parser)
+	tree, err := parser.ParseCtx(context.Background(), nil, content)
+	if err != nil {
+		return nil
+	}
+	defer tree.Close()
+
+	var refs []qualifiedRef
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		if f, ok := fields[node.Type()]; ok {
+			qual, name := node.ChildByFieldName(f[0]), node.ChildByFieldName(f[1])
+			if qual != nil && name != nil && qual.StartPoint().Row == name.StartPoint().Row {
+				text := strings.Join(strings.Fields(qual.Content(content)), "")
+				if qualifierRe.MatchString(text) {
+					refs = append(refs, qualifiedRef{
+						Line:      int(qual.StartPoint().Row),
+						Start:     int(qual.StartPoint().Column),
+						NameStart: int(name.StartPoint().Column),
+						Qual:      text,
+						Name:      name.Content(content),
+					})
+				}
+			}
+		}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			walk(node.NamedChild(i))
 		}
+	}
+	walk(tree.RootNode())
+	return refs
+}
 
-		lang := DetectLanguage(file)
-		var fileUpdates []ImportUpdate
+// lineEdit is the rewritten text of one line referencing moved definitions
+type lineEdit struct {
+	text     string
+	from, to string // Modules of the first move on the line
+	names    []string
+	spans    []lineSpan
+}
 
-		switch lang {
-		case LangPython:
-			fileUpdates = findPythonImportUpdates(file, content, moveMap)
-		case LangJavaScript, LangTypeScript:
-			fileUpdates = findJSImportUpdates(file, content, moveMap)
+// lineSpan replaces the qualifier of one reference, e.g. "util." with
+// "pricing." or with nothing
+type lineSpan struct {
+	start, end int // Byte columns; end is the start of the name
+	text       string
+}
+
+// apply returns line with the edit's spans replaced
+func (e *lineEdit) apply(line string) string {
+	spans := append([]lineSpan(nil), e.spans...)
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for _, span := range spans {
+		if span.start <= span.end && span.end <= len(line) {
+			line = line[:span.start] + span.text + line[span.end:]
 		}
+	}
+	return line
+}
 
-		updates = append(updates, fileUpdates...)
+// lineEditUpdates returns the usage updates for edited lines, in line order
+func lineEditUpdates(file string, lines []string, edits map[int]*lineEdit) []ImportUpdate {
+	var editLines []int
+	for i := range edits {
+		editLines = append(editLines, i)
 	}
+	sort.Ints(editLines)
 
-	return updates, nil
+	var updates []ImportUpdate
+	for _, i := range editLines {
+		edit := edits[i]
+		updates = append(updates, ImportUpdate{
+			File:       file,
+			OldImport:  lines[i],
+			NewImport:  edit.text,
+			LineNumber: i + 1,
+			Definition: strings.Join(edit.names, ", "),
+			FromModule: edit.from,
+			ToModule:   edit.to,
+			Kind:       ImportEditUsage,
+		})
+	}
+	return updates
 }
 
 // fileToModule converts a file path to a module name
@@ -148,9 +387,21 @@ var (
 	pyImportRe = regexp.MustCompile(`^(\s*import\s+)([\w.]+)(.*)$`)
 )
 
-// findPythonImportUpdates finds imports that need updating in a Python file
+// pyModuleBinding is a module bound by "import module [as alias]"
+type pyModuleBinding struct {
+	line      int    // 0-based line index
+	module    string // Imported module
+	qualifier string // Name used in code
+}
+
+// findPythonImportUpdates finds imports that need updating in a Python file,
+// including module-qualified references such as utils.helper()
 func findPythonImportUpdates(file string, content []byte, moveMap map[struct{ sourceModule, defName string }]string) []ImportUpdate {
 	var updates []ImportUpdate
+	var bindings []pyModuleBinding
+
+	// Only lines that start an import statement (not strings or comments)
+	importLines := queryImportLines(content, LangPython)
 
 	scanner := bufio.NewScanner(bytes.NewReader(content))
 	lineNum := 0
@@ -161,6 +412,11 @@ func findPythonImportUpdates(file string, content []byte, moveMap map[struct{ so
 		line := scanner.Text()
 		lineBytes := len(scanner.Bytes()) + 1 // +1 for newline
 
+		if importLines != nil && !importLines[lineNum-1] {
+			byteOffset += lineBytes
+			continue
+		}
+
 		// Check "from X import Y" style
 		if matches := pyFromImportRe.FindStringSubmatch(line); matches != nil {
 			prefix := matches[1]   // "from "
@@ -213,16 +469,145 @@ func findPythonImportUpdates(file string, content []byte, moveMap map[struct{ so
 					Definition: strings.Join(movedNames, ", "),
 					FromModule: module,
 					ToModule:   getFirstKey(destModules),
+					Kind:       ImportEditImport,
 				})
 			}
+		} else if matches := pyImportRe.FindStringSubmatch(line); matches != nil {
+			// Check "import X [as Y], Z" style
+			bindings = append(bindings, parsePythonModuleBindings(lineNum-1, matches[2]+matches[3])...)
 		}
 
 		byteOffset += lineBytes
 	}
 
+	if len(bindings) > 0 {
+		lines := strings.Split(string(content), "\n")
+		usageUpdates := findPythonQualifiedUpdates(file, lines, qualifiedRefs(LangPython, content), bindings, moveMap)
+		setImportUpdateOffsets(usageUpdates, lines)
+		updates = append(updates, usageUpdates...)
+	}
+
 	return updates
 }
 
+// parsePythonModuleBindings parses "a.b as c, d  # comment" into bindings
+func parsePythonModuleBindings(line int, modules string) []pyModuleBinding {
+	if idx := strings.Index(modules, "#"); idx >= 0 {
+		modules = modules[:idx]
+	}
+	var bindings []pyModuleBinding
+	for _, part := range strings.Split(modules, ",") {
+		fields := strings.Fields(part)
+		switch {
+		case len(fields) == 1:
+			bindings = append(bindings, pyModuleBinding{line: line, module: fields[0], qualifier: fields[0]})
+		case len(fields) == 3 && fields[1] == "as":
+			bindings = append(bindings, pyModuleBinding{line: line, module: fields[0], qualifier: fields[2]})
+		}
+	}
+	return bindings
+}
+
+// findPythonQualifiedUpdates rewrites module.name references (refs) to moved
+// definitions. The destination module is imported next to the original
+// import unless the file already imports it; references from inside the
+// destination module lose their qualifier.
+func findPythonQualifiedUpdates(file string, lines []string, refs []qualifiedRef, bindings []pyModuleBinding, moveMap map[struct{ sourceModule, defName string }]string) []ImportUpdate {
+	fileModule := fileToModule(file)
+	qualifiers := make(map[string]string) // Imported module -> qualifier
+	importLines := make(map[int]bool)
+	for _, b := range bindings {
+		qualifiers[b.module] = b.qualifier
+		importLines[b.line] = true
+	}
+
+	// Moved names per source module, in a stable order
+	var keys []struct{ sourceModule, defName string }
+	for key := range moveMap {
+		if _, ok := qualifiers[key.sourceModule]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].sourceModule != keys[j].sourceModule {
+			return keys[i].sourceModule < keys[j].sourceModule
+		}
+		return keys[i].defName < keys[j].defName
+	})
+
+	edits := make(map[int]*lineEdit)
+	added := make(map[string]bool)
+	addedByLine := make(map[int][]string)
+	for _, key := range keys {
+		dest := moveMap[key]
+		qual := qualifiers[key.sourceModule]
+
+		replacement := ""
+		destQual, imported := qualifiers[dest]
+		if dest != fileModule {
+			if !imported {
+				destQual = dest
+			}
+			replacement = destQual + "."
+		}
+
+		found := false
+		for _, ref := range refs {
+			if importLines[ref.Line] || ref.Qual != qual || ref.Name != key.defName {
+				continue
+			}
+			edit, ok := edits[ref.Line]
+			if !ok {
+				edit = &lineEdit{from: key.sourceModule, to: dest}
+				edits[ref.Line] = edit
+			}
+			if n := len(edit.names); n == 0 || edit.names[n-1] != key.defName {
+				edit.names = append(edit.names, key.defName)
+			}
+			edit.spans = append(edit.spans, lineSpan{start: ref.Start, end: ref.NameStart, text: replacement})
+			found = true
+		}
+
+		if found && dest != fileModule && !imported && !added[dest] {
+			added[dest] = true
+			for _, b := range bindings {
+				if b.module == key.sourceModule {
+					addedByLine[b.line] = append(addedByLine[b.line], dest)
+					break
+				}
+			}
+		}
+	}
+
+	var updates []ImportUpdate
+	var importLineIdx []int
+	for i := range addedByLine {
+		importLineIdx = append(importLineIdx, i)
+	}
+	sort.Ints(importLineIdx)
+	for _, i := range importLineIdx {
+		indent := leadingWhitespace(lines[i])
+		newLines := []string{lines[i]}
+		for _, dest := range addedByLine[i] {
+			newLines = append(newLines, indent+"import "+dest)
+		}
+		updates = append(updates, ImportUpdate{
+			File:       file,
+			OldImport:  lines[i],
+			NewImport:  strings.Join(newLines, "\n"),
+			LineNumber: i + 1,
+			FromModule: strings.TrimSpace(lines[i]),
+			ToModule:   strings.Join(addedByLine[i], ", "),
+			Kind:       ImportEditImport,
+		})
+	}
+
+	for i, edit := range edits {
+		edit.text = edit.apply(lines[i])
+	}
+	return append(updates, lineEditUpdates(file, lines, edits)...)
+}
+
 // parseImportNames parses "name1, name2 as alias, name3" into ["name1", "name2", "name3"]
 func parseImportNames(names string) []string {
 	var result []string
@@ -252,89 +637,87 @@ var (
 	jsDefaultImportRe = regexp.MustCompile(`^(\s*import\s+)(\w+)(\s+from\s*['"])([^'"]+)(['"].*)$`)
 )
 
-// findJSImportUpdates finds imports that need updating in a JS/TS file
-func findJSImportUpdates(file string, content []byte, moveMap map[struct{ sourceModule, defName string }]string) []ImportUpdate {
-	var updates []ImportUpdate
-
-	// Get the directory of the current file for relative import resolution
-	fileDir := filepath.Dir(file)
-
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	lineNum := 0
-	byteOffset := 0
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		lineBytes := len(scanner.Bytes()) + 1
-
-		// Check named imports: import { x, y } from 'module'
-		if matches := jsNamedImportRe.FindStringSubmatch(line); matches != nil {
-			prefix := matches[1]     // "import {"
-			names := matches[2]      // "name, name2"
-			middle := matches[3]     // "} from '"
-			modulePath := matches[4] // module path
-			suffix := matches[5]     // "'" or "';"
-
-			// Resolve the module path to a normalized form
-			resolvedModule := resolveJSModulePath(fileDir, modulePath)
+// findJSImportUpdates finds imports, re-exports, require() and dynamic
+// import() calls that need updating in a JS/TS file, plus usages through
+// namespace imports and require bindings. Non-relative imports are resolved
+// through aliases (may be nil).
+func findJSImportUpdates(file string, content []byte, moveMap map[struct{ sourceModule, defName string }]string, aliases *tsPathAliases) []ImportUpdate {
+	r := &jsImportRewriter{
+		file:    file,
+		fileDir: filepath.Dir(file),
+		moveMap: moveMap,
+		aliases: aliases,
+	}
 
-			// Parse the imported names
-			importedNames := parseJSImportNames(names)
+	lines := strings.Split(string(content), "\n")
 
-			// Check if any imported name was moved
-			var movedNames []string
-			var remainingNames []string
-			destModules := make(map[string][]string)
+	// Modules this file already re-exports wholesale
+	reexported := make(map[string]bool)
+	for _, line := range lines {
+		if m := jsExportAllRe.FindStringSubmatch(line); m != nil {
+			reexported[resolveJSModulePath(r.fileDir, m[2])] = true
+		}
+	}
 
-			for _, name := range importedNames {
-				key := struct{ sourceModule, defName string }{resolvedModule, name}
-				if destModule, moved := moveMap[key]; moved {
-					movedNames = append(movedNames, name)
-					// Convert back to relative path for the import
-					relPath := makeRelativeJSImport(fileDir, destModule)
-					destModules[relPath] = append(destModules[relPath], name)
-				} else {
-					remainingNames = append(remainingNames, name)
-				}
+	var updates []ImportUpdate
+	var bindings []jsBinding
+	statementLines := make(map[int]bool)
+
+	for i, line := range lines {
+		var update ImportUpdate
+		var ok bool
+
+		if m := jsNamedImportRe.FindStringSubmatch(line); m != nil {
+			// import { x, y } from 'module'
+			update, ok = r.rewriteNamedList(i, line, m[1:], ImportEditImport, func(specs []string, path string) string {
+				return "import { " + strings.Join(specs, ", ") + " } from '" + path + "'"
+			})
+		} else if m := jsNamedExportRe.FindStringSubmatch(line); m != nil {
+			// export { x } from 'module'
+			update, ok = r.rewriteNamedList(i, line, m[1:], ImportEditReExport, func(specs []string, path string) string {
+				return "export { " + strings.Join(specs, ", ") + " } from '" + path + "'"
+			})
+		} else if m := jsExportAllRe.FindStringSubmatch(line); m != nil {
+			// export * from 'module'
+			update, ok = r.rewriteExportAll(i, line, m[2], reexported)
+		} else if m := jsDestructureRe.FindStringSubmatch(line); m != nil {
+			// const { x } = require('module') / await import('module')
+			keyword, kind := m[4], ImportEditRequire
+			if keyword == "import" {
+				kind = ImportEditDynamicImport
 			}
+			statement := strings.TrimRight(m[3][:strings.Index(m[3], keyword)], " \t")
+			update, ok = r.rewriteNamedList(i, line, []string{m[1], m[2], m[3], m[5], m[6]}, kind, func(specs []string, path string) string {
+				return m[1] + " " + strings.Join(specs, ", ") + " " + statement + " " + keyword + "('" + path + "')"
+			})
+		} else if m := jsNamespaceImportRe.FindStringSubmatch(line); m != nil {
+			bindings = append(bindings, jsBinding{line: i, name: m[2], modulePath: m[4], style: "import", indent: leadingWhitespace(line)})
+			statementLines[i] = true
+		} else if m := jsRequireBindingRe.FindStringSubmatch(line); m != nil {
+			bindings = append(bindings, jsBinding{line: i, name: m[2], modulePath: m[4], style: "require", indent: leadingWhitespace(line)})
+			statementLines[i] = true
+		}
 
-			if len(movedNames) > 0 {
-				var newImport string
-
-				// Keep remaining names in original import
-				if len(remainingNames) > 0 {
-					newImport = prefix + " " + strings.Join(remainingNames, ", ") + " " + middle + modulePath + suffix
-				}
-
-				// Add new imports for moved names
-				for destPath, names := range destModules {
-					if newImport != "" {
-						newImport += "\n"
-					}
-					newImport += "import { " + strings.Join(names, ", ") + " } from '" + destPath + "'"
-				}
-
-				updates = append(updates, ImportUpdate{
-					File:       file,
-					OldImport:  line,
-					NewImport:  newImport,
-					LineNumber: lineNum,
-					StartByte:  byteOffset,
-					EndByte:    byteOffset + len(line),
-					Definition: strings.Join(movedNames, ", "),
-					FromModule: modulePath,
-					ToModule:   getFirstKey(destModules),
-				})
-			}
+		if ok {
+			updates = append(updates, update)
+			statementLines[i] = true
 		}
+	}
 
-		byteOffset += lineBytes
+	if len(bindings) > 0 {
+		refs := qualifiedRefs(DetectLanguage(file), content)
+		updates = append(updates, r.rewriteQualifiedUsages(lines, refs, bindings, statementLines)...)
 	}
 
+	setImportUpdateOffsets(updates, lines)
 	return updates
 }
 
+// leadingWhitespace returns the indentation of line
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
 // parseJSImportNames parses "name, name2 as alias" into ["name", "name2"]
 func parseJSImportNames(names string) []string {
 	var result []string
@@ -366,6 +749,42 @@ func resolveJSModulePath(fromDir, importPath string) string {
 	return importPath
 }
 
+// isRelativeJSImport reports whether an import path is relative to the importing file
+func isRelativeJSImport(importPath string) bool {
+	return strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../")
+}
+
+// resolveJSModuleCandidates returns the normalized modules an import may
+// refer to: the relative resolution, then any tsconfig alias targets
+func resolveJSModuleCandidates(fromDir, importPath string, aliases *tsPathAliases) []string {
+	candidates := []string{resolveJSModulePath(fromDir, importPath)}
+	if !isRelativeJSImport(importPath) {
+		for _, file := range aliases.resolve(importPath) {
+			candidates = append(candidates, fileToModule(file))
+		}
+	}
+	return candidates
+}
+
+// lookupJSMove finds where name moved, trying each candidate module and its
+// index file
+func lookupJSMove(moveMap map[struct{ sourceModule, defName string }]string, modules []string, name string) (string, bool) {
+	for _, module := range modules {
+		for _, m := range []string{module, module + ".index"} {
+			if dest, ok := moveMap[struct{ sourceModule, defName string }{m, name}]; ok {
+				return dest, true
+			}
+		}
+	}
+	return "", false
+}
+
+// moduleToPath converts a normalized module back to a slash-separated path
+// without extension
+func moduleToPath(module string) string {
+	return strings.ReplaceAll(module, ".", "/")
+}
+
 // makeRelativeJSImport creates a relative import path from one file to another
 func makeRelativeJSImport(fromDir, toModule string) string {
 	toPath := moduleToFile(toModule, LangJavaScript)
@@ -392,19 +811,26 @@ func getFirstKey(m map[string][]string) string {
 	return ""
 }
 
-// ApplyImportUpdates applies the import updates to files
-func ApplyImportUpdates(updates []ImportUpdate, repoRoot string) []ImportUpdateResult {
+// ApplyImportUpdates applies import updates to files. Files are written
+// atomically (with a .orig backup if config.CreateBackup is set); in dry-run
+// mode a diff is printed instead and nothing is written.
+func ApplyImportUpdates(updates []ImportUpdate, repoRoot string, config MergeConfig) []ImportUpdateResult {
 	// Group updates by file
 	updatesByFile := make(map[string][]ImportUpdate)
+	var files []string
 	for _, update := range updates {
+		if _, ok := updatesByFile[update.File]; !ok {
+			files = append(files, update.File)
+		}
 		updatesByFile[update.File] = append(updatesByFile[update.File], update)
 	}
+	sort.Strings(files)
 
 	var results []ImportUpdateResult
 
-	for file, fileUpdates := range updatesByFile {
+	for _, file := range files {
 		fullPath := filepath.Join(repoRoot, file)
-		result := applyFileImportUpdates(fullPath, fileUpdates)
+		result := applyFileImportUpdates(fullPath, file, updatesByFile[file], config)
 		result.File = file
 		results = append(results, result)
 	}
@@ -413,31 +839,52 @@ func ApplyImportUpdates(updates []ImportUpdate, repoRoot string) []ImportUpdateR
 }
 
 // applyFileImportUpdates applies import updates to a single file
-func applyFileImportUpdates(filePath string, updates []ImportUpdate) ImportUpdateResult {
+func applyFileImportUpdates(filePath, file string, updates []ImportUpdate, config MergeConfig) ImportUpdateResult {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return ImportUpdateResult{Error: fmt.Errorf("failed to read file: %w", err)}
 	}
 
-	lines := strings.Split(string(content), "\n")
+	newContent := rewriteImportLines(content, updates)
 
-	// Sort updates by line number descending (apply from bottom to top)
-	// to avoid offset issues
-	for i := len(updates) - 1; i >= 0; i-- {
-		for j := 0; j < i; j++ {
-			if updates[j].LineNumber < updates[j+1].LineNumber {
-				updates[j], updates[j+1] = updates[j+1], updates[j]
-			}
+	if config.DryRun {
+		if !config.JSONOutput {
+			printDryRunDiff(filePath, content, newContent)
 		}
+		return ImportUpdateResult{UpdatesCount: len(updates)}
 	}
 
-	// Apply updates
-	for _, update := range updates {
+	createBackup := config.CreateBackup && settingsFor(file).backupEnabled()
+	if err := atomicWriteWithBackup(filePath, newContent, createBackup); err != nil {
+		return ImportUpdateResult{Error: err}
+	}
+
+	return ImportUpdateResult{UpdatesCount: len(updates)}
+}
+
+// rewriteImportLines returns content with the updates applied
+func rewriteImportLines(content []byte, updates []ImportUpdate) []byte {
+	lines := strings.Split(string(content), "\n")
+
+	// Apply from bottom to top to avoid offset issues
+	sorted := make([]ImportUpdate, len(updates))
+	copy(sorted, updates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].LineNumber > sorted[j].LineNumber
+	})
+
+	for _, update := range sorted {
 		lineIdx := update.LineNumber - 1 // Convert to 0-based
 		if lineIdx < 0 || lineIdx >= len(lines) {
 			continue
 		}
 
+		// An empty replacement removes the line (e.g. a Go import no longer used)
+		if update.NewImport == "" {
+			lines = append(lines[:lineIdx], lines[lineIdx+1:]...)
+			continue
+		}
+
 		// Handle multi-line new imports
 		newLines := strings.Split(update.NewImport, "\n")
 		if len(newLines) == 1 {
@@ -452,13 +899,7 @@ func applyFileImportUpdates(filePath string, updates []ImportUpdate) ImportUpdat
 		}
 	}
 
-	// Write back
-	newContent := strings.Join(lines, "\n")
-	if err := os.WriteFile(filePath, []byte(newContent), 0644); err != nil {
-		return ImportUpdateResult{Error: fmt.Errorf("failed to write file: %w", err)}
-	}
-
-	return ImportUpdateResult{UpdatesCount: len(updates)}
+	return []byte(strings.Join(lines, "\n"))
 }
 
 // FormatImportUpdateSummary returns a human-readable summary of import updates
@@ -476,10 +917,29 @@ func FormatImportUpdateSummary(updates []ImportUpdate) string {
 		byFile[u.File] = append(byFile[u.File], u)
 	}
 
-	for file, fileUpdates := range byFile {
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		fileUpdates := byFile[file]
+		sort.SliceStable(fileUpdates, func(i, j int) bool {
+			return fileUpdates[i].LineNumber < fileUpdates[j].LineNumber
+		})
+
 		sb.WriteString(fmt.Sprintf("  %s:\n", file))
 		for _, u := range fileUpdates {
-			sb.WriteString(fmt.Sprintf("    Line %d: %s -> %s\n", u.LineNumber, u.FromModule, u.ToModule))
+			kind := ""
+			if u.Kind != "" {
+				kind = " (" + u.Kind + ")"
+			}
+			definition := ""
+			if u.Definition != "" {
+				definition = " [" + u.Definition + "]"
+			}
+			sb.WriteString(fmt.Sprintf("    Line %d%s: %s -> %s%s\n", u.LineNumber, kind, u.FromModule, u.ToModule, definition))
 		}
 	}
 
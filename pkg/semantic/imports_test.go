@@ -154,7 +154,7 @@ func TestFindJSImportUpdates(t *testing.T) {
 
 	t.Run("named import", func(t *testing.T) {
 		content := []byte("import { helper } from './utils'\n")
-		updates := findJSImportUpdates("src/app.js", content, moveMap)
+		updates := findJSImportUpdates("src/app.js", content, moveMap, nil)
 
 		if len(updates) != 1 {
 			t.Fatalf("expected 1 update, got %d", len(updates))
@@ -171,7 +171,7 @@ func TestFindJSImportUpdates(t *testing.T) {
 
 	t.Run("multiple named imports - one moves", func(t *testing.T) {
 		content := []byte("import { helper, otherFunc } from './utils'\n")
-		updates := findJSImportUpdates("src/app.js", content, moveMap)
+		updates := findJSImportUpdates("src/app.js", content, moveMap, nil)
 
 		if len(updates) != 1 {
 			t.Fatalf("expected 1 update, got %d", len(updates))
@@ -194,7 +194,7 @@ func TestFindJSImportUpdates(t *testing.T) {
 			{"utils", "helper"}: "newutils",
 		}
 		content := []byte("import { helper } from './utils'\n")
-		updates := findJSImportUpdates("app.js", content, rootMoveMap)
+		updates := findJSImportUpdates("app.js", content, rootMoveMap, nil)
 
 		if len(updates) != 1 {
 			t.Fatalf("expected 1 update, got %d", len(updates))
@@ -206,7 +206,7 @@ func TestFindJSImportUpdates(t *testing.T) {
 
 	t.Run("no matching imports", func(t *testing.T) {
 		content := []byte("import { something } from './other'\n")
-		updates := findJSImportUpdates("src/app.js", content, moveMap)
+		updates := findJSImportUpdates("src/app.js", content, moveMap, nil)
 
 		if len(updates) != 0 {
 			t.Errorf("expected 0 updates, got %d", len(updates))
@@ -214,6 +214,65 @@ func TestFindJSImportUpdates(t *testing.T) {
 	})
 }
 
+// TestFindPythonImportUpdates_Qualified tests module-qualified usages
+func TestFindPythonImportUpdates_Qualified(t *testing.T) {
+	moveMap := map[struct{ sourceModule, defName string }]string{
+		{"utils", "calc_total"}: "billing",
+	}
+
+	t.Run("adds destination import", func(t *testing.T) {
+		content := []byte("import utils\n\ntotal = utils.calc_total(items)\nname = utils.slugify(title)\n")
+		updates := findPythonImportUpdates("app.py", content, moveMap)
+
+		if len(updates) != 2 {
+			t.Fatalf("expected import and usage updates, got %d: %+v", len(updates), updates)
+		}
+		if updates[0].NewImport != "import utils\nimport billing" || updates[0].Kind != ImportEditImport {
+			t.Errorf("unexpected import update: %+v", updates[0])
+		}
+		if updates[1].NewImport != "total = billing.calc_total(items)" || updates[1].Kind != ImportEditUsage {
+			t.Errorf("unexpected usage update: %+v", updates[1])
+		}
+	})
+
+	t.Run("uses existing alias", func(t *testing.T) {
+		content := []byte("import utils as u, billing as b\n\ntotal = u.calc_total(items)\n")
+		updates := findPythonImportUpdates("app.py", content, moveMap)
+
+		if len(updates) != 1 || updates[0].NewImport != "total = b.calc_total(items)" {
+			t.Fatalf("unexpected updates: %+v", updates)
+		}
+	})
+
+	t.Run("inside destination module", func(t *testing.T) {
+		content := []byte("import utils\n\ndef invoice(items):\n    return utils.calc_total(items)\n")
+		updates := findPythonImportUpdates("billing.py", content, moveMap)
+
+		if len(updates) != 1 || updates[0].NewImport != "    return calc_total(items)" {
+			t.Fatalf("unexpected updates: %+v", updates)
+		}
+	})
+
+	t.Run("unrelated attribute", func(t *testing.T) {
+		content := []byte("import utils\n\ntotal = self.utils.calc_total(items)\n")
+		if updates := findPythonImportUpdates("app.py", content, moveMap); len(updates) != 0 {
+			t.Errorf("expected no updates, got %+v", updates)
+		}
+	})
+
+	t.Run("skips strings and comments", func(t *testing.T) {
+		content := []byte("import utils\n\n# utils.calc_total sums items\nlabel = \"utils.calc_total\"\ntotal = utils.calc_total(items)  # utils.calc_total\n")
+		updates := findPythonImportUpdates("app.py", content, moveMap)
+
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d: %+v", len(updates), updates)
+		}
+		if want := "total = billing.calc_total(items)  # utils.calc_total"; updates[1].NewImport != want || updates[1].LineNumber != 5 {
+			t.Errorf("NewImport = %q (line %d), want %q", updates[1].NewImport, updates[1].LineNumber, want)
+		}
+	})
+}
+
 // TestApplyImportUpdates tests applying updates to files
 func TestApplyImportUpdates(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "g2-import-test-*")
@@ -236,7 +295,7 @@ func TestApplyImportUpdates(t *testing.T) {
 			},
 		}
 
-		results := ApplyImportUpdates(updates, tmpDir)
+		results := ApplyImportUpdates(updates, tmpDir, MergeConfig{})
 
 		if len(results) != 1 {
 			t.Fatalf("expected 1 result, got %d", len(results))
@@ -265,7 +324,7 @@ func TestApplyImportUpdates(t *testing.T) {
 			},
 		}
 
-		results := ApplyImportUpdates(updates, tmpDir)
+		results := ApplyImportUpdates(updates, tmpDir, MergeConfig{})
 
 		if results[0].Error != nil {
 			t.Errorf("unexpected error: %v", results[0].Error)
@@ -279,6 +338,71 @@ func TestApplyImportUpdates(t *testing.T) {
 			t.Errorf("file should have new import, got:\n%s", newContent)
 		}
 	})
+
+	t.Run("dry run leaves file unchanged", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test3.py")
+		content := "from utils import helper\n"
+		os.WriteFile(testFile, []byte(content), 0644)
+
+		updates := []ImportUpdate{
+			{File: "test3.py", OldImport: "from utils import helper", NewImport: "from newutils import helper", LineNumber: 1},
+		}
+
+		results := ApplyImportUpdates(updates, tmpDir, MergeConfig{DryRun: true, JSONOutput: true})
+
+		if results[0].Error != nil || results[0].UpdatesCount != 1 {
+			t.Errorf("unexpected result: %+v", results[0])
+		}
+		newContent, _ := os.ReadFile(testFile)
+		if string(newContent) != content {
+			t.Errorf("dry run should not modify the file, got:\n%s", newContent)
+		}
+	})
+
+	t.Run("creates backup and keeps permissions", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test4.py")
+		content := "from utils import helper\n"
+		os.WriteFile(testFile, []byte(content), 0755)
+
+		updates := []ImportUpdate{
+			{File: "test4.py", OldImport: "from utils import helper", NewImport: "from newutils import helper", LineNumber: 1},
+		}
+
+		results := ApplyImportUpdates(updates, tmpDir, MergeConfig{CreateBackup: true})
+
+		if results[0].Error != nil {
+			t.Fatalf("unexpected error: %v", results[0].Error)
+		}
+		backup, err := os.ReadFile(testFile + ".orig")
+		if err != nil || string(backup) != content {
+			t.Errorf("expected backup with original content, got %q (%v)", backup, err)
+		}
+		info, _ := os.Stat(testFile)
+		if info.Mode().Perm() != 0755 {
+			t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+		}
+	})
+}
+
+// TestParseLsFiles tests turning git ls-files output into files to scan
+func TestParseLsFiles(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"app.py":        "",
+		"lib/utils.py":  "",
+		"conflicted.py": "",
+		"README.md":     "",
+	})
+
+	output := "H app.py\x00H lib/utils.py\x00S sparse/other.py\x00M conflicted.py\x00M conflicted.py\x00" +
+		"H README.md\x00H deleted.py\x00? new.py\x00"
+	writeRepoFiles(t, root, map[string]string{"new.py": ""})
+
+	files := parseLsFiles([]byte(output), root)
+	expected := []string{"app.py", filepath.FromSlash("lib/utils.py"), "conflicted.py", "new.py"}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
 }
 
 // TestFindImportUpdates_Integration tests the full flow
@@ -355,6 +479,24 @@ func TestFormatImportUpdateSummary(t *testing.T) {
 			t.Errorf("should show module change, got: %q", result)
 		}
 	})
+
+	t.Run("lists each edit", func(t *testing.T) {
+		updates := []ImportUpdate{
+			{File: "b.py", LineNumber: 3, FromModule: "utils", ToModule: "billing", Definition: "calc_total", Kind: ImportEditUsage},
+			{File: "b.py", LineNumber: 1, FromModule: "import utils", ToModule: "billing", Kind: ImportEditImport},
+			{File: "a.js", LineNumber: 2, FromModule: "./utils", ToModule: "./money", Definition: "formatPrice", Kind: ImportEditReExport},
+		}
+		result := FormatImportUpdateSummary(updates)
+		want := "Found 3 import(s) to update:\n" +
+			"  a.js:\n" +
+			"    Line 2 (re-export): ./utils -> ./money [formatPrice]\n" +
+			"  b.py:\n" +
+			"    Line 1 (import): import utils -> billing\n" +
+			"    Line 3 (usage): utils -> billing [calc_total]\n"
+		if result != want {
+			t.Errorf("got:\n%s\nwant:\n%s", result, want)
+		}
+	})
 }
 
 // BenchmarkFindPythonImportUpdates benchmarks Python import scanning
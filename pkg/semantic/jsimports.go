@@ -0,0 +1,279 @@
+package semantic
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// More JS/TS import and export patterns
+var (
+	// export { name, name2 } from 'module'
+	jsNamedExportRe = regexp.MustCompile(`^(\s*export\s*\{)([^}]+)(\}\s*from\s*['"])([^'"]+)(['"].*)$`)
+	// export * from 'module'
+	jsExportAllRe = regexp.MustCompile(`^(\s*export\s*\*\s*from\s*['"])([^'"]+)(['"].*)$`)
+	// const { name } = require('module') or = await import('module')
+	jsDestructureRe = regexp.MustCompile(`^(\s*(?:const|let|var)\s*\{)([^}]+)(\}\s*=\s*(?:await\s+)?(require|import)\s*\(\s*['"])([^'"]+)(['"]\s*\).*)$`)
+	// import * as name from 'module'
+	jsNamespaceImportRe = regexp.MustCompile(`^(\s*import\s*\*\s*as\s+)(\w+)(\s+from\s*['"])([^'"]+)(['"].*)$`)
+	// const name = require('module')
+	jsRequireBindingRe = regexp.MustCompile(`^(\s*(?:const|let|var)\s+)(\w+)(\s*=\s*require\s*\(\s*['"])([^'"]+)(['"]\s*\).*)$`)
+)
+
+// jsImportRewriter rewrites the imports and re-exports of one JS/TS file
+type jsImportRewriter struct {
+	file    string
+	fileDir string
+	moveMap map[struct{ sourceModule, defName string }]string
+	aliases *tsPathAliases
+}
+
+// destination returns the import path that now provides name, if it moved
+// out of the module imported as modulePath
+func (r *jsImportRewriter) destination(modulePath, name string) (string, bool) {
+	destModule, moved := lookupJSMove(r.moveMap, resolveJSModuleCandidates(r.fileDir, modulePath, r.aliases), name)
+	if !moved {
+		return "", false
+	}
+	return r.importPathFor(modulePath, destModule), true
+}
+
+// importPathFor returns how the file should import destModule. Aliased
+// imports stay aliased; otherwise a relative path is used.
+func (r *jsImportRewriter) importPathFor(modulePath, destModule string) string {
+	if !isRelativeJSImport(modulePath) {
+		if alias, ok := r.aliases.aliasFor(moduleToPath(destModule)); ok {
+			return alias
+		}
+	}
+	return makeRelativeJSImport(r.fileDir, destModule)
+}
+
+// movedFrom returns every name that moved out of the module imported as
+// modulePath, sorted
+func (r *jsImportRewriter) movedFrom(modulePath string) []string {
+	modules := make(map[string]bool)
+	for _, module := range resolveJSModuleCandidates(r.fileDir, modulePath, r.aliases) {
+		modules[module] = true
+		modules[module+".index"] = true
+	}
+	var names []string
+	for key := range r.moveMap {
+		if modules[key.sourceModule] {
+			names = append(names, key.defName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsSpecifierName returns the exported name of a specifier such as
+// "helper", "helper as h", "helper: h" or "type Props"
+func jsSpecifierName(spec string) string {
+	spec = strings.TrimPrefix(strings.TrimSpace(spec), "type ")
+	if idx := strings.IndexAny(spec, " :"); idx > 0 {
+		spec = spec[:idx]
+	}
+	return strings.TrimSpace(spec)
+}
+
+// jsSpecifierSplit is a specifier list divided by where each name now lives
+type jsSpecifierSplit struct {
+	remaining []string
+	moved     []string            // Moved names
+	byDest    map[string][]string // Destination import path -> specifiers
+	destOrder []string
+}
+
+// splitSpecifiers divides the specifiers of a named import, re-export or
+// destructuring by destination, keeping aliases intact
+func (r *jsImportRewriter) splitSpecifiers(specs, modulePath string) jsSpecifierSplit {
+	split := jsSpecifierSplit{byDest: make(map[string][]string)}
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		name := jsSpecifierName(spec)
+		dest, moved := r.destination(modulePath, name)
+		if !moved {
+			split.remaining = append(split.remaining, spec)
+			continue
+		}
+		split.moved = append(split.moved, name)
+		if _, seen := split.byDest[dest]; !seen {
+			split.destOrder = append(split.destOrder, dest)
+		}
+		split.byDest[dest] = append(split.byDest[dest], spec)
+	}
+	return split
+}
+
+// rewriteNamedList rewrites a line with a braced specifier list (import,
+// re-export or destructuring). m holds prefix, specifiers, middle, module
+// path and suffix; format builds the statement for a destination.
+func (r *jsImportRewriter) rewriteNamedList(lineIdx int, line string, m []string, kind string, format func(specs []string, path string) string) (ImportUpdate, bool) {
+	prefix, specs, middle, modulePath, suffix := m[0], m[1], m[2], m[3], m[4]
+	split := r.splitSpecifiers(specs, modulePath)
+	if len(split.moved) == 0 {
+		return ImportUpdate{}, false
+	}
+
+	var newLines []string
+	if len(split.remaining) > 0 {
+		newLines = append(newLines, prefix+" "+strings.Join(split.remaining, ", ")+" "+middle+modulePath+suffix)
+	}
+	for _, dest := range split.destOrder {
+		newLines = append(newLines, format(split.byDest[dest], dest))
+	}
+
+	return ImportUpdate{
+		File:       r.file,
+		OldImport:  line,
+		NewImport:  strings.Join(newLines, "\n"),
+		LineNumber: lineIdx + 1,
+		Definition: strings.Join(split.moved, ", "),
+		FromModule: modulePath,
+		ToModule:   split.destOrder[0],
+		Kind:       kind,
+	}, true
+}
+
+// jsBinding is a name bound to a whole module (namespace import or require)
+type jsBinding struct {
+	line       int
+	name       string
+	modulePath string
+	style      string // "import" or "require"
+	indent     string
+}
+
+// statement returns the binding statement for another module in the same style
+func (b jsBinding) statement(name, path string) string {
+	if b.style == "require" {
+		return b.indent + "const " + name + " = require('" + path + "')"
+	}
+	return b.indent + "import * as " + name + " from '" + path + "'"
+}
+
+// jsBindingName derives an identifier for a module from its path
+func jsBindingName(path string) string {
+	base := path[strings.LastIndex(path, "/")+1:]
+	var sb strings.Builder
+	upper := false
+	for _, r := range base {
+		switch {
+		case r == '-' || r == '.' || r == '@':
+			upper = sb.Len() > 0
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == '$' || (sb.Len() > 0 && r >= '0' && r <= '9'):
+			if upper && r >= 'a' && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			upper = false
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return "module"
+	}
+	return sb.String()
+}
+
+// rewriteQualifiedUsages rewrites binding.name references (refs) to moved
+// names. A binding for each destination is added next to the original one
+// unless the file already has one.
+func (r *jsImportRewriter) rewriteQualifiedUsages(lines []string, refs []qualifiedRef, bindings []jsBinding, skip map[int]bool) []ImportUpdate {
+	boundPaths := make(map[string]string) // Normalized module -> binding name
+	for _, b := range bindings {
+		boundPaths[resolveJSModulePath(r.fileDir, b.modulePath)] = b.name
+	}
+
+	edits := make(map[int]*lineEdit)
+	var updates []ImportUpdate
+	for _, b := range bindings {
+		var added []string
+		addedNames := make(map[string]bool)
+		for _, name := range r.movedFrom(b.modulePath) {
+			dest, _ := r.destination(b.modulePath, name)
+			destName, bound := boundPaths[resolveJSModulePath(r.fileDir, dest)]
+			if !bound {
+				destName = jsBindingName(dest)
+			}
+
+			found := false
+			for _, ref := range refs {
+				if skip[ref.Line] || ref.Qual != b.name || ref.Name != name {
+					continue
+				}
+				edit, ok := edits[ref.Line]
+				if !ok {
+					edit = &lineEdit{from: b.name + "." + name, to: destName + "." + name}
+					edits[ref.Line] = edit
+				}
+				if n := len(edit.names); n == 0 || edit.names[n-1] != name {
+					edit.names = append(edit.names, name)
+				}
+				edit.spans = append(edit.spans, lineSpan{start: ref.Start, end: ref.NameStart, text: destName + "."})
+				found = true
+			}
+			if found && !bound && !addedNames[destName] {
+				addedNames[destName] = true
+				added = append(added, b.statement(destName, dest))
+			}
+		}
+
+		if len(added) > 0 {
+			updates = append(updates, ImportUpdate{
+				File:       r.file,
+				OldImport:  lines[b.line],
+				NewImport:  strings.Join(append([]string{lines[b.line]}, added...), "\n"),
+				LineNumber: b.line + 1,
+				FromModule: b.modulePath,
+				ToModule:   strings.Join(added, "; "),
+				Kind:       ImportEditImport,
+			})
+		}
+	}
+
+	for i, edit := range edits {
+		edit.text = edit.apply(lines[i])
+	}
+	return append(updates, lineEditUpdates(r.file, lines, edits)...)
+}
+
+// rewriteExportAll adds explicit re-exports to a barrel that re-exports a
+// module some definitions moved out of, so importers of the barrel keep
+// working. Destinations the barrel already re-exports are left alone.
+func (r *jsImportRewriter) rewriteExportAll(lineIdx int, line, modulePath string, reexported map[string]bool) (ImportUpdate, bool) {
+	byDest := make(map[string][]string)
+	var destOrder, moved []string
+	for _, name := range r.movedFrom(modulePath) {
+		dest, _ := r.destination(modulePath, name)
+		if reexported[resolveJSModulePath(r.fileDir, dest)] {
+			continue
+		}
+		if _, seen := byDest[dest]; !seen {
+			destOrder = append(destOrder, dest)
+		}
+		byDest[dest] = append(byDest[dest], name)
+		moved = append(moved, name)
+	}
+	if len(moved) == 0 {
+		return ImportUpdate{}, false
+	}
+
+	newLines := []string{line}
+	for _, dest := range destOrder {
+		newLines = append(newLines, "export { "+strings.Join(byDest[dest], ", ")+" } from '"+dest+"'")
+	}
+	return ImportUpdate{
+		File:       r.file,
+		OldImport:  line,
+		NewImport:  strings.Join(newLines, "\n"),
+		LineNumber: lineIdx + 1,
+		Definition: strings.Join(moved, ", "),
+		FromModule: modulePath,
+		ToModule:   destOrder[0],
+		Kind:       ImportEditReExport,
+	}, true
+}
@@ -0,0 +1,150 @@
+package semantic
+
+import (
+	"testing"
+)
+
+func TestFindJSImportUpdates_ReExports(t *testing.T) {
+	moveMap := map[struct{ sourceModule, defName string }]string{
+		{"src.utils", "formatPrice"}: "src.money",
+	}
+
+	t.Run("named re-export", func(t *testing.T) {
+		content := []byte("export { formatPrice, slugify } from './utils';\n")
+		updates := findJSImportUpdates("src/index.ts", content, moveMap, nil)
+
+		if len(updates) != 1 {
+			t.Fatalf("expected 1 update, got %d", len(updates))
+		}
+		want := "export { slugify } from './utils';\nexport { formatPrice } from './money'"
+		if updates[0].NewImport != want || updates[0].Kind != ImportEditReExport {
+			t.Errorf("got %q (%s), want %q", updates[0].NewImport, updates[0].Kind, want)
+		}
+	})
+
+	t.Run("barrel export star", func(t *testing.T) {
+		content := []byte("export * from './utils';\n")
+		updates := findJSImportUpdates("src/index.ts", content, moveMap, nil)
+
+		if len(updates) != 1 {
+			t.Fatalf("expected 1 update, got %d", len(updates))
+		}
+		want := "export * from './utils';\nexport { formatPrice } from './money'"
+		if updates[0].NewImport != want {
+			t.Errorf("got %q, want %q", updates[0].NewImport, want)
+		}
+	})
+
+	t.Run("barrel already re-exports destination", func(t *testing.T) {
+		content := []byte("export * from './utils';\nexport * from './money';\n")
+		if updates := findJSImportUpdates("src/index.ts", content, moveMap, nil); len(updates) != 0 {
+			t.Errorf("expected no updates, got %+v", updates)
+		}
+	})
+
+	t.Run("importers of the barrel are unchanged", func(t *testing.T) {
+		content := []byte("import { formatPrice } from './index';\n")
+		if updates := findJSImportUpdates("src/cart.ts", content, moveMap, nil); len(updates) != 0 {
+			t.Errorf("expected no updates, got %+v", updates)
+		}
+	})
+}
+
+func TestFindJSImportUpdates_RequireAndDynamicImport(t *testing.T) {
+	moveMap := map[struct{ sourceModule, defName string }]string{
+		{"src.utils", "formatPrice"}: "src.money",
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+		kind string
+	}{
+		{
+			"require destructuring",
+			"const { formatPrice, slugify } = require('./utils');",
+			"const { slugify } = require('./utils');\nconst { formatPrice } = require('./money')",
+			ImportEditRequire,
+		},
+		{
+			"renamed destructuring",
+			"const { formatPrice: fmt } = require('./utils');",
+			"const { formatPrice: fmt } = require('./money')",
+			ImportEditRequire,
+		},
+		{
+			"dynamic import",
+			"  const { formatPrice } = await import('./utils');",
+			"  const { formatPrice } = await import('./money')",
+			ImportEditDynamicImport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := findJSImportUpdates("src/cart.js", []byte(tt.line+"\n"), moveMap, nil)
+			if len(updates) != 1 {
+				t.Fatalf("expected 1 update, got %d", len(updates))
+			}
+			if updates[0].NewImport != tt.want || updates[0].Kind != tt.kind {
+				t.Errorf("got %q (%s), want %q (%s)", updates[0].NewImport, updates[0].Kind, tt.want, tt.kind)
+			}
+		})
+	}
+}
+
+func TestFindJSImportUpdates_QualifiedUsages(t *testing.T) {
+	moveMap := map[struct{ sourceModule, defName string }]string{
+		{"src.utils", "formatPrice"}: "src.money",
+	}
+
+	t.Run("namespace import", func(t *testing.T) {
+		content := []byte("import * as utils from './utils';\n\nconst label = utils.formatPrice(total);\n")
+		updates := findJSImportUpdates("src/cart.ts", content, moveMap, nil)
+
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d: %+v", len(updates), updates)
+		}
+		if updates[0].NewImport != "import * as utils from './utils';\nimport * as money from './money'" {
+			t.Errorf("unexpected import update: %q", updates[0].NewImport)
+		}
+		if updates[1].NewImport != "const label = money.formatPrice(total);" || updates[1].Kind != ImportEditUsage {
+			t.Errorf("unexpected usage update: %+v", updates[1])
+		}
+	})
+
+	t.Run("require binding with existing destination", func(t *testing.T) {
+		content := []byte("const utils = require('./utils');\nconst m = require('./money');\n\nutils.formatPrice(total);\n")
+		updates := findJSImportUpdates("src/cart.js", content, moveMap, nil)
+
+		if len(updates) != 1 || updates[0].NewImport != "m.formatPrice(total);" {
+			t.Fatalf("unexpected updates: %+v", updates)
+		}
+	})
+
+	t.Run("skips strings and comments", func(t *testing.T) {
+		content := []byte("import * as utils from './utils';\n\n// utils.formatPrice rounds\nconst key = 'utils.formatPrice';\nconst label = `${utils.formatPrice(total)}`; /* utils.formatPrice */\n")
+		updates := findJSImportUpdates("src/cart.ts", content, moveMap, nil)
+
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d: %+v", len(updates), updates)
+		}
+		if want := "const label = `${money.formatPrice(total)}`; /* utils.formatPrice */"; updates[1].NewImport != want || updates[1].LineNumber != 5 {
+			t.Errorf("NewImport = %q (line %d), want %q", updates[1].NewImport, updates[1].LineNumber, want)
+		}
+	})
+}
+
+func TestJSBindingName(t *testing.T) {
+	tests := map[string]string{
+		"./money":           "money",
+		"../lib/date-fns":   "dateFns",
+		"@/utils/format.v2": "formatV2",
+		"./123":             "module",
+	}
+	for path, want := range tests {
+		if got := jsBindingName(path); got != want {
+			t.Errorf("jsBindingName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
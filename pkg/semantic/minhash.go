@@ -0,0 +1,167 @@
+package semantic
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// lshMissProbability is the highest acceptable chance that a pair whose
+// Jaccard similarity equals the lowest fuzzy threshold is not proposed as a
+// candidate. Pairs above the threshold are missed even less often.
+const lshMissProbability = 1e-6
+
+// lshMaxHashes bounds the signature length (bands × rows)
+const lshMaxHashes = 160
+
+// lshParams is the banding layout of MinHash signatures
+type lshParams struct {
+	Bands int
+	Rows  int
+}
+
+// lshParamsFor picks the most selective banding whose miss probability at
+// the given Jaccard threshold stays below lshMissProbability.
+// A pair with similarity s shares at least one band with probability
+// 1 - (1 - s^rows)^bands.
+func lshParamsFor(threshold float64) lshParams {
+	if threshold <= 0 || threshold >= 1 {
+		// Degenerate thresholds: a single one-row band still finds exact matches
+		return lshParams{Bands: 1, Rows: 1}
+	}
+
+	best := lshParams{Bands: 1, Rows: 1}
+	for rows := 1; rows <= lshMaxHashes; rows++ {
+		p := math.Pow(threshold, float64(rows))
+		bands := int(math.Ceil(math.Log(lshMissProbability) / math.Log(1-p)))
+		if bands < 1 {
+			bands = 1
+		}
+		if bands*rows > lshMaxHashes {
+			break
+		}
+		best = lshParams{Bands: bands, Rows: rows}
+	}
+	return best
+}
+
+// minHasher computes MinHash signatures over token sets
+type minHasher struct {
+	seeds []uint64
+}
+
+// newMinHasher creates a hasher with n deterministic hash functions
+func newMinHasher(n int) *minHasher {
+	seeds := make([]uint64, n)
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		state = splitmix64(state)
+		seeds[i] = state
+	}
+	return &minHasher{seeds: seeds}
+}
+
+// splitmix64 is a fast 64-bit mixing function
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// signature returns the MinHash signature of the token set, or nil if empty
+func (h *minHasher) signature(tokens []string) []uint64 {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	sig := make([]uint64, len(h.seeds))
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+
+		f := fnv.New64a()
+		f.Write([]byte(token))
+		base := f.Sum64()
+		for i, seed := range h.seeds {
+			if v := splitmix64(base ^ seed); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// lshIndex proposes candidate pairs whose MinHash signatures agree on at
+// least one band. It is a pre-filter only; callers verify every candidate
+// with the exact similarity measure.
+type lshIndex struct {
+	params  lshParams
+	hasher  *minHasher
+	buckets []map[uint64][]int // One bucket map per band
+	empty   []int              // Items with no tokens
+}
+
+// newLSHIndex creates an empty index with the given banding
+func newLSHIndex(params lshParams) *lshIndex {
+	buckets := make([]map[uint64][]int, params.Bands)
+	for i := range buckets {
+		buckets[i] = make(map[uint64][]int)
+	}
+	return &lshIndex{
+		params:  params,
+		hasher:  newMinHasher(params.Bands * params.Rows),
+		buckets: buckets,
+	}
+}
+
+// bandKey hashes one band of a signature
+func (x *lshIndex) bandKey(sig []uint64, band int) uint64 {
+	key := uint64(band)
+	for _, v := range sig[band*x.params.Rows : (band+1)*x.params.Rows] {
+		key = splitmix64(key ^ v)
+	}
+	return key
+}
+
+// add indexes the token set of item id
+func (x *lshIndex) add(id int, tokens []string) {
+	sig := x.hasher.signature(tokens)
+	if sig == nil {
+		x.empty = append(x.empty, id)
+		return
+	}
+	for band := range x.buckets {
+		key := x.bandKey(sig, band)
+		x.buckets[band][key] = append(x.buckets[band][key], id)
+	}
+}
+
+// candidates returns the ids of indexed items that may be similar to tokens,
+// in ascending order
+func (x *lshIndex) candidates(tokens []string) []int {
+	sig := x.hasher.signature(tokens)
+	if sig == nil {
+		return append([]int(nil), x.empty...)
+	}
+
+	seen := make(map[int]bool)
+	var ids []int
+	for band := range x.buckets {
+		for _, id := range x.buckets[band][x.bandKey(sig, band)] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,257 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestLSHParamsFor verifies the banding stays within budget and keeps the
+// miss probability at the threshold below lshMissProbability
+func TestLSHParamsFor(t *testing.T) {
+	for _, threshold := range []float64{0.5, 0.65, 0.75, 0.85, 0.95} {
+		params := lshParamsFor(threshold)
+		if params.Bands*params.Rows > lshMaxHashes {
+			t.Errorf("threshold %.2f: %d hashes exceeds budget", threshold, params.Bands*params.Rows)
+		}
+		miss := math.Pow(1-math.Pow(threshold, float64(params.Rows)), float64(params.Bands))
+		if miss > lshMissProbability {
+			t.Errorf("threshold %.2f: miss probability %g too high (%+v)", threshold, miss, params)
+		}
+	}
+}
+
+func TestLSHIndex_Candidates(t *testing.T) {
+	index := newLSHIndex(lshParamsFor(0.65))
+
+	a := tokenize("def total(items): return sum(item.price * item.qty for item in items)")
+	b := tokenize("def grand_total(items): return sum(item.price * item.qty for item in items)")
+	c := tokenize("class Parser: def parse(self, text): return json.loads(text)")
+
+	index.add(0, a)
+	index.add(1, c)
+	index.add(2, nil)
+
+	got := index.candidates(b)
+	if len(got) == 0 || got[0] != 0 {
+		t.Errorf("expected similar body as first candidate, got %v", got)
+	}
+
+	if got := index.candidates(a); !containsInt(got, 0) {
+		t.Errorf("identical token set must always be a candidate, got %v", got)
+	}
+
+	if got := index.candidates(nil); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("expected empty bodies to match each other, got %v", got)
+	}
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// moveCorpus generates deletes and adds where some adds are mutated copies
+// of deletes with similarities spread around the move thresholds
+func moveCorpus(seed int64, n int) (deletes, adds []string) {
+	rng := rand.New(rand.NewSource(seed))
+
+	vocab := make([]string, 3000)
+	for i := range vocab {
+		vocab[i] = fmt.Sprintf("ident%d", i)
+	}
+	keywords := []string{"if", "return", "for", "in", "self", "None", "not", "and"}
+
+	body := func() []string {
+		size := 15 + rng.Intn(140)
+		tokens := make([]string, size)
+		for i := range tokens {
+			if rng.Intn(4) == 0 {
+				tokens[i] = keywords[rng.Intn(len(keywords))]
+			} else {
+				tokens[i] = vocab[rng.Intn(len(vocab))]
+			}
+		}
+		return tokens
+	}
+
+	for i := 0; i < n; i++ {
+		tokens := body()
+		deletes = append(deletes, strings.Join(tokens, " "))
+
+		switch rng.Intn(3) {
+		case 0:
+			// Unrelated add
+			adds = append(adds, strings.Join(body(), " "))
+		default:
+			// Mutated copy: replace up to 40% of tokens
+			mutated := append([]string(nil), tokens...)
+			changes := rng.Intn(len(mutated)*2/5 + 1)
+			for j := 0; j < changes; j++ {
+				mutated[rng.Intn(len(mutated))] = vocab[rng.Intn(len(vocab))]
+			}
+			adds = append(adds, strings.Join(mutated, " "))
+		}
+	}
+
+	rng.Shuffle(len(adds), func(i, j int) { adds[i], adds[j] = adds[j], adds[i] })
+	return deletes, adds
+}
+
+func moveCorpusConflicts(deletes, adds []string) []SynthesisConflict {
+	var conflicts []SynthesisConflict
+	for i, body := range deletes {
+		conflicts = append(conflicts, makeDeleteConflict(fmt.Sprintf("old_%d", i), body, uint32(i*10), uint32(i*10+5)))
+	}
+	for i, body := range adds {
+		conflicts = append(conflicts, makeAddConflict(fmt.Sprintf("new_%d", i), body, uint32(100000+i*10), uint32(100000+i*10+5)))
+	}
+	return conflicts
+}
+
+func moveCorpusAnalyses(deletes, adds []string) []*SynthesisAnalysis {
+	var analyses []*SynthesisAnalysis
+	for f := 0; f < 4; f++ {
+		var del, add []SynthesisConflict
+		for i := f; i < len(deletes); i += 4 {
+			del = append(del, makeDeleteConflictForFile(fmt.Sprintf("src%d.py", f), fmt.Sprintf("old_%d", i), deletes[i]))
+		}
+		for i := f; i < len(adds); i += 4 {
+			add = append(add, makeAddConflictForFile(fmt.Sprintf("dst%d.py", f), fmt.Sprintf("new_%d", i), adds[i]))
+		}
+		analyses = append(analyses,
+			makeAnalysis(fmt.Sprintf("src%d.py", f), del),
+			makeAnalysis(fmt.Sprintf("dst%d.py", f), add))
+	}
+	return analyses
+}
+
+func conflictTypes(conflicts []SynthesisConflict) []string {
+	types := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		types[i] = c.UIConflict.ConflictType
+	}
+	return types
+}
+
+// TestDetectMoves_LSHEquivalence verifies the index finds exactly the moves
+// the exhaustive comparison finds
+func TestDetectMoves_LSHEquivalence(t *testing.T) {
+	exhaustive := DefaultMoveDetectionConfig()
+	exhaustive.EnableLSHIndex = false
+	indexed := DefaultMoveDetectionConfig()
+	indexed.LSHMinPairs = 0
+
+	for seed := int64(1); seed <= 3; seed++ {
+		deletes, adds := moveCorpus(seed, 120)
+
+		want := DetectMovesWithConfig(moveCorpusConflicts(deletes, adds), exhaustive)
+		got := DetectMovesWithConfig(moveCorpusConflicts(deletes, adds), indexed)
+
+		if len(want) == len(deletes)+len(adds) {
+			t.Fatalf("seed %d: corpus produced no moves", seed)
+		}
+		if !reflect.DeepEqual(conflictTypes(want), conflictTypes(got)) {
+			t.Errorf("seed %d: LSH results differ from exhaustive comparison", seed)
+		}
+	}
+}
+
+func TestDetectInterFileMoves_LSHEquivalence(t *testing.T) {
+	exhaustive := DefaultMoveDetectionConfig()
+	exhaustive.EnableLSHIndex = false
+	indexed := DefaultMoveDetectionConfig()
+	indexed.LSHMinPairs = 0
+
+	summarize := func(moves []InterFileMove) []string {
+		var out []string
+		for _, m := range moves {
+			out = append(out, fmt.Sprintf("%s:%s->%s:%s %.4f", m.SourceFile, m.SourceConflict.Base.Name, m.DestFile, getAddName(m.DestConflict), m.Similarity))
+		}
+		return out
+	}
+
+	for seed := int64(1); seed <= 3; seed++ {
+		deletes, adds := moveCorpus(seed, 120)
+
+		want := summarize(DetectInterFileMovesWithConfig(moveCorpusAnalyses(deletes, adds), exhaustive))
+		got := summarize(DetectInterFileMovesWithConfig(moveCorpusAnalyses(deletes, adds), indexed))
+
+		if len(want) == 0 {
+			t.Fatalf("seed %d: corpus produced no moves", seed)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("seed %d: LSH results differ from exhaustive comparison\nwant %v\ngot  %v", seed, want, got)
+		}
+	}
+}
+
+// TestUseLSHIndex tests when the index is used
+func TestUseLSHIndex(t *testing.T) {
+	config := DefaultMoveDetectionConfig()
+	if useLSHIndex(10, 10, config) {
+		t.Error("small inputs should be compared exhaustively")
+	}
+	if !useLSHIndex(100, 100, config) {
+		t.Error("large inputs should use the index")
+	}
+
+	config.UseWeightedSimilarity = true
+	if useLSHIndex(100, 100, config) {
+		t.Error("weighted similarity must not use the index")
+	}
+}
+
+func benchmarkDetectMovesCorpus(b *testing.B, n int, enableIndex bool) {
+	deletes, adds := moveCorpus(42, n)
+	conflicts := moveCorpusConflicts(deletes, adds)
+	config := DefaultMoveDetectionConfig()
+	config.EnableLSHIndex = enableIndex
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DetectMovesWithConfig(conflicts, config)
+	}
+}
+
+func BenchmarkDetectMoves_Exhaustive100(b *testing.B) { benchmarkDetectMovesCorpus(b, 100, false) }
+func BenchmarkDetectMoves_LSH100(b *testing.B)        { benchmarkDetectMovesCorpus(b, 100, true) }
+func BenchmarkDetectMoves_Exhaustive500(b *testing.B) { benchmarkDetectMovesCorpus(b, 500, false) }
+func BenchmarkDetectMoves_LSH500(b *testing.B)        { benchmarkDetectMovesCorpus(b, 500, true) }
+
+func benchmarkDetectInterFileMovesCorpus(b *testing.B, n int, enableIndex bool) {
+	deletes, adds := moveCorpus(42, n)
+	analyses := moveCorpusAnalyses(deletes, adds)
+	config := DefaultMoveDetectionConfig()
+	config.EnableLSHIndex = enableIndex
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DetectInterFileMovesWithConfig(analyses, config)
+	}
+}
+
+func BenchmarkDetectInterFileMoves_Exhaustive500(b *testing.B) {
+	benchmarkDetectInterFileMovesCorpus(b, 500, false)
+}
+
+func BenchmarkDetectInterFileMoves_LSH500(b *testing.B) {
+	benchmarkDetectInterFileMovesCorpus(b, 500, true)
+}
+
+func BenchmarkMinHashSignature(b *testing.B) {
+	hasher := newMinHasher(lshMaxHashes)
+	tokens := tokenize(strings.Repeat("def calculate_total(items): return sum(item.price for item in items)\n", 10))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hasher.signature(tokens)
+	}
+}
@@ -2,6 +2,7 @@ package semantic
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/simonkoeck/g2/pkg/ui"
 )
@@ -21,6 +22,27 @@ type MoveDetectionConfig struct {
 
 	// Weighted similarity (gives lower weight to common keywords)
 	UseWeightedSimilarity bool // Use weighted Jaccard instead of standard (default: false)
+
+	// Structural similarity (compares AST shape with alpha-renamed identifiers)
+	UseStructuralSimilarity bool // Use subtree-hash similarity instead of token sets (default: false)
+
+	// Candidate generation for large inputs
+	EnableLSHIndex bool // Use a MinHash/LSH index to pick fuzzy candidates (default: true)
+	LSHMinPairs    int  // Delete×add pairs above which the index is used (default: 1024)
+
+	// Split/extract and inline/merge detection
+	EnableSplitDetection bool    // Detect one-to-many and many-to-one refactors (default: true)
+	SplitCoverage        float64 // Minimum share of the whole's tokens covered by the parts (default: 0.8)
+	FragmentContainment  float64 // Minimum share of each part's tokens found in the whole (default: 0.6)
+
+	// Container (class with members) moves
+	EnableContainerMoves     bool    // Match classes moved or renamed as a whole (default: true)
+	ContainerMinMembers      int     // Minimum matched members for a container move (default: 2)
+	ContainerMemberThreshold float64 // Minimum similarity to pair members within a matched class (default: 0.5)
+
+	// Matches between functions and methods
+	EnableCrossKindMatch bool    // Allow function↔method matches (default: false)
+	CrossKindThreshold   float64 // Minimum similarity for cross-kind fuzzy matches (default: 0.8)
 }
 
 // DefaultMoveDetectionConfig returns default configuration
@@ -34,6 +56,19 @@ func DefaultMoveDetectionConfig() MoveDetectionConfig {
 		SmallBodyThreshold: 0.85,
 		LargeBodyTokens:    100,
 		LargeBodyThreshold: 0.65,
+		EnableLSHIndex:     true,
+		LSHMinPairs:        1024,
+
+		EnableSplitDetection: true,
+		SplitCoverage:        0.8,
+		FragmentContainment:  0.6,
+
+		EnableContainerMoves:     true,
+		ContainerMinMembers:      2,
+		ContainerMemberThreshold: 0.5,
+
+		EnableCrossKindMatch: false,
+		CrossKindThreshold:   0.8,
 	}
 }
 
@@ -48,6 +83,91 @@ func getThresholdForSize(tokenCount int, config MoveDetectionConfig) float64 {
 	return config.FuzzyThreshold
 }
 
+// minFuzzyThreshold returns the lowest threshold any pair can be held to
+func minFuzzyThreshold(config MoveDetectionConfig) float64 {
+	return math.Min(config.FuzzyThreshold, math.Min(config.SmallBodyThreshold, config.LargeBodyThreshold))
+}
+
+// fuzzyCandidates holds the tokenized orphan add bodies and proposes which
+// of them to compare against each delete. Large inputs go through a
+// MinHash/LSH index; every candidate is still verified with the exact
+// similarity measure, so the index can only skip pairs below the threshold.
+type fuzzyCandidates struct {
+	tokens [][]string
+	shapes []structuralShape // Set when structural similarity is enabled
+	index  *lshIndex         // nil compares every delete against every add
+}
+
+// newFuzzyCandidates tokenizes raw add bodies and builds the index if
+// worthwhile. addFiles gives the file of each add, used to pick the grammar for
+// structural similarity.
+func newFuzzyCandidates(addBodies, addFiles []string, deleteCount int, config MoveDetectionConfig) *fuzzyCandidates {
+	f := &fuzzyCandidates{tokens: make([][]string, len(addBodies))}
+	for i, body := range addBodies {
+		f.tokens[i] = tokenize(body)
+	}
+
+	if config.UseStructuralSimilarity {
+		f.shapes = make([]structuralShape, len(addBodies))
+		for i, body := range addBodies {
+			f.shapes[i] = structuralFeatures(body, DetectLanguage(addFiles[i]))
+		}
+	}
+
+	if !useLSHIndex(deleteCount, len(addBodies), config) {
+		return f
+	}
+
+	f.index = newLSHIndex(lshParamsFor(minFuzzyThreshold(config)))
+	for i, tokens := range f.tokens {
+		f.index.add(i, tokens)
+	}
+	return f
+}
+
+// useLSHIndex reports whether candidate generation should use the index.
+// MinHash estimates plain Jaccard similarity over token sets, so weighted
+// and structural similarity always compare every pair.
+func useLSHIndex(deleteCount, addCount int, config MoveDetectionConfig) bool {
+	if !config.EnableLSHIndex || config.UseWeightedSimilarity || config.UseStructuralSimilarity {
+		return false
+	}
+	if minFuzzyThreshold(config) <= 0 {
+		return false
+	}
+	return deleteCount*addCount > config.LSHMinPairs
+}
+
+// forDelete returns the add indices to compare against a delete, ascending
+func (f *fuzzyCandidates) forDelete(delTokens []string) []int {
+	if f.index != nil {
+		return f.index.candidates(delTokens)
+	}
+	all := make([]int, len(f.tokens))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// deleteShape returns the structural shape of a delete body when structural
+// similarity is enabled
+func deleteShape(body, file string, config MoveDetectionConfig) structuralShape {
+	if !config.UseStructuralSimilarity {
+		return nil
+	}
+	return structuralFeatures(body, DetectLanguage(file))
+}
+
+// similarity compares a delete against add i using the configured metric.
+// Structural similarity falls back to tokens when either body has no shape.
+func (f *fuzzyCandidates) similarity(delTokens []string, delShape structuralShape, i int, config MoveDetectionConfig) float64 {
+	if delShape != nil && f.shapes != nil && f.shapes[i] != nil {
+		return calculateStructuralSimilarity(delShape, f.shapes[i])
+	}
+	return calculateSimilarity(delTokens, f.tokens[i], config)
+}
+
 // calculateSimilarity computes similarity using the configured method
 func calculateSimilarity(tokens1, tokens2 []string, config MoveDetectionConfig) float64 {
 	if config.UseWeightedSimilarity {
@@ -62,8 +182,15 @@ func DetectMoves(conflicts []SynthesisConflict) []SynthesisConflict {
 	return DetectMovesWithConfig(conflicts, DefaultMoveDetectionConfig())
 }
 
-// DetectMovesWithConfig identifies moves with custom configuration
+// DetectMovesWithConfig identifies moves with custom configuration.
+// One-to-one moves are matched first; the remaining deletes and adds are
+// then checked for splits and merges.
 func DetectMovesWithConfig(conflicts []SynthesisConflict, config MoveDetectionConfig) []SynthesisConflict {
+	return DetectRefactors(detectOneToOneMoves(conflicts, config), config)
+}
+
+// detectOneToOneMoves pairs single deletes with single adds
+func detectOneToOneMoves(conflicts []SynthesisConflict, config MoveDetectionConfig) []SynthesisConflict {
 	if len(conflicts) < 2 {
 		return conflicts
 	}
@@ -81,9 +208,14 @@ func DetectMovesWithConfig(conflicts []SynthesisConflict, config MoveDetectionCo
 	matchedAdds := make(map[int]bool)
 	var moveConflicts []SynthesisConflict
 
+	// Pass 0: Classes moved as a whole
+	if config.EnableContainerMoves {
+		moveConflicts = append(moveConflicts, findContainerMoves(orphanDeletes, orphanAdds, matchedDeletes, matchedAdds, config)...)
+	}
+
 	// Pass 1: Exact Match
 	if config.EnableExactMatch {
-		exactMatches := findExactMatches(orphanDeletes, orphanAdds, matchedDeletes, matchedAdds)
+		exactMatches := findExactMatches(orphanDeletes, orphanAdds, matchedDeletes, matchedAdds, config)
 		moveConflicts = append(moveConflicts, exactMatches...)
 	}
 
@@ -113,7 +245,7 @@ func classifyConflicts(conflicts []SynthesisConflict) (deletes, adds []*Synthesi
 }
 
 // findExactMatches finds conflicts with identical bodies using hash comparison
-func findExactMatches(deletes, adds []*SynthesisConflict, matchedDeletes, matchedAdds map[int]bool) []SynthesisConflict {
+func findExactMatches(deletes, adds []*SynthesisConflict, matchedDeletes, matchedAdds map[int]bool, config MoveDetectionConfig) []SynthesisConflict {
 	var matches []SynthesisConflict
 
 	// Build hash index of orphan adds
@@ -143,8 +275,8 @@ func findExactMatches(deletes, adds []*SynthesisConflict, matchedDeletes, matche
 
 			add := adds[addIdx]
 
-			// Verify kinds match
-			if del.Base.Kind != getAddKind(add) {
+			// Verify kinds are compatible
+			if ok, _ := kindMatchThreshold(del.Base.Kind, getAddKind(add), config); !ok {
 				continue
 			}
 
@@ -162,6 +294,14 @@ func findExactMatches(deletes, adds []*SynthesisConflict, matchedDeletes, matche
 func findFuzzyMatches(deletes, adds []*SynthesisConflict, matchedDeletes, matchedAdds map[int]bool, config MoveDetectionConfig) []SynthesisConflict {
 	var matches []SynthesisConflict
 
+	addBodies := make([]string, len(adds))
+	addFiles := make([]string, len(adds))
+	for i, add := range adds {
+		addBodies[i] = getAddRawBody(add)
+		addFiles[i] = add.UIConflict.File
+	}
+	candidates := newFuzzyCandidates(addBodies, addFiles, len(deletes), config)
+
 	for delIdx, del := range deletes {
 		if matchedDeletes[delIdx] {
 			continue
@@ -172,63 +312,83 @@ func findFuzzyMatches(deletes, adds []*SynthesisConflict, matchedDeletes, matche
 
 		// Skip small bodies (boilerplate guard)
 		if len(delTokens) < config.MinTokenCount {
+			del.note("not fuzzy-matched as a move: %d tokens < minimum %d", len(delTokens), config.MinTokenCount)
 			continue
 		}
 
-		bestIdx, bestSimilarity := findBestFuzzyMatch(del, adds, delTokens, matchedAdds, config)
+		delShape := deleteShape(del.Base.Body, del.UIConflict.File, config)
+		bestIdx, score := findBestFuzzyMatch(del, adds, delTokens, delShape, candidates, matchedAdds, config)
 
 		if bestIdx >= 0 {
 			add := adds[bestIdx]
-			matches = append(matches, createMoveConflict(del, add, "Fuzzy Match", bestSimilarity))
+			move := createMoveConflict(del, add, "Fuzzy Match", score.Similarity)
+			if move.Explain != nil {
+				move.Explain.Move = &score
+			}
+			matches = append(matches, move)
 			matchedDeletes[delIdx] = true
 			matchedAdds[bestIdx] = true
+		} else if score.Candidate != "" {
+			del.note("no move within the file: nearest add '%s' scored %s", score.Candidate, &score)
 		}
 	}
 
 	return matches
 }
 
-// findBestFuzzyMatch finds the best fuzzy match for a delete among adds
-func findBestFuzzyMatch(del *SynthesisConflict, adds []*SynthesisConflict, delTokens []string, matchedAdds map[int]bool, config MoveDetectionConfig) (int, float64) {
+// findBestFuzzyMatch finds the best fuzzy match for a delete among adds.
+// The score is that of the match, or of the most similar candidate below
+// its threshold if there is none.
+func findBestFuzzyMatch(del *SynthesisConflict, adds []*SynthesisConflict, delTokens []string, delShape structuralShape, candidates *fuzzyCandidates, matchedAdds map[int]bool, config MoveDetectionConfig) (int, MoveScore) {
 	bestIdx := -1
-	bestSimilarity := 0.0
+	var best, nearest MoveScore
 
-	// Get size-appropriate threshold for the delete body
-	delThreshold := getThresholdForSize(len(delTokens), config)
-
-	for addIdx, add := range adds {
+	for _, addIdx := range candidates.forDelete(delTokens) {
 		if matchedAdds[addIdx] {
 			continue
 		}
+		add := adds[addIdx]
 
-		// Verify kinds match
-		if del.Base.Kind != getAddKind(add) {
+		// Verify kinds are compatible
+		kindOK, kindThreshold := kindMatchThreshold(del.Base.Kind, getAddKind(add), config)
+		if !kindOK {
 			continue
 		}
 
-		addBody := getAddBody(add)
-		addTokens := tokenize(addBody)
+		addTokens := candidates.tokens[addIdx]
 
 		// Skip small bodies
 		if len(addTokens) < config.MinTokenCount {
 			continue
 		}
 
-		// Use the more restrictive threshold of the two bodies
-		addThreshold := getThresholdForSize(len(addTokens), config)
-		threshold := delThreshold
-		if addThreshold > threshold {
-			threshold = addThreshold
+		score := MoveScore{
+			Candidate:    getAddName(add),
+			DeleteTokens: len(delTokens),
+			AddTokens:    len(addTokens),
+			Similarity:   candidates.similarity(delTokens, delShape, addIdx, config),
+			Threshold:    pairThreshold(len(delTokens), len(addTokens), kindThreshold, config),
 		}
-
-		similarity := calculateSimilarity(delTokens, addTokens, config)
-		if similarity >= threshold && similarity > bestSimilarity {
+		if score.Similarity >= score.Threshold && score.Similarity > best.Similarity {
 			bestIdx = addIdx
-			bestSimilarity = similarity
+			best = score
+		} else if score.Similarity > nearest.Similarity {
+			nearest = score
 		}
 	}
 
-	return bestIdx, bestSimilarity
+	if bestIdx < 0 {
+		return bestIdx, nearest
+	}
+	return bestIdx, best
+}
+
+// pairThreshold returns the similarity a delete/add pair must reach: the
+// more restrictive size threshold of the two bodies, raised to the kind
+// threshold for function↔method pairs
+func pairThreshold(delTokens, addTokens int, kindThreshold float64, config MoveDetectionConfig) float64 {
+	threshold := math.Max(getThresholdForSize(delTokens, config), getThresholdForSize(addTokens, config))
+	return math.Max(threshold, kindThreshold)
 }
 
 // assembleResult combines all conflict types into the final result
@@ -277,6 +437,18 @@ func getAddBody(c *SynthesisConflict) string {
 	return ""
 }
 
+// getAddRawBody returns the body from an orphan add conflict as written,
+// for parsing where whitespace is significant
+func getAddRawBody(c *SynthesisConflict) string {
+	if c.Local != nil {
+		return c.Local.Body
+	}
+	if c.Remote != nil {
+		return c.Remote.Body
+	}
+	return ""
+}
+
 // getAddKind returns the kind from an orphan add conflict
 func getAddKind(c *SynthesisConflict) string {
 	if c.Local != nil {
@@ -307,7 +479,7 @@ func createMoveConflict(del, add *SynthesisConflict, matchType string, similarit
 
 	conflictType := formatMoveConflictType(kind, deleteName, addName, matchType, similarity)
 
-	return SynthesisConflict{
+	move := SynthesisConflict{
 		UIConflict: ui.Conflict{
 			File:         del.UIConflict.File,
 			ConflictType: conflictType,
@@ -316,7 +488,17 @@ func createMoveConflict(del, add *SynthesisConflict, matchType string, similarit
 		Base:   del.Base,
 		Local:  add.Local,
 		Remote: add.Remote,
+		Move: &MoveInfo{
+			SourceFile: del.UIConflict.File,
+			DestFile:   del.UIConflict.File,
+			SourceName: deleteName,
+			DestName:   addName,
+			MatchType:  matchType,
+			Similarity: similarity,
+		},
 	}
+	explainMove(&move, del, add)
+	return move
 }
 
 // formatMoveConflictType formats the conflict type string for a move
@@ -335,14 +517,26 @@ func formatMoveConflictType(kind, deleteName, addName, matchType string, similar
 	return fmt.Sprintf("%s '%s' Renamed+Moved to '%s' (%.0f%% Match)", kind, deleteName, addName, similarity*100)
 }
 
+// MoveInfo records where a moved definition came from and went to
+type MoveInfo struct {
+	SourceFile string  // File the definition was deleted from
+	DestFile   string  // File the definition was added to
+	SourceName string  // Name in base
+	DestName   string  // Name after the move (differs if renamed)
+	MatchType  string  // "Exact Match", "Fuzzy Match" or "Container Match"
+	Similarity float64 // 1.0 for exact, 0.0-1.0 for fuzzy
+}
+
 // InterFileMove represents a definition moved between files
 type InterFileMove struct {
 	SourceFile     string             // File where definition was deleted
 	DestFile       string             // File where definition was added
 	SourceConflict *SynthesisConflict // The orphan delete
 	DestConflict   *SynthesisConflict // The orphan add
-	MatchType      string             // "Exact Match" or "Fuzzy Match"
+	MatchType      string             // "Exact Match", "Fuzzy Match" or "Container Match"
 	Similarity     float64            // 1.0 for exact, 0.0-1.0 for fuzzy
+	Members        []InterFileMove    // Member moves of a container move
+	Score          *MoveScore         // Similarity and threshold of a fuzzy match
 }
 
 // CrossFileOrphan represents an orphan conflict from a specific file
@@ -354,7 +548,7 @@ type CrossFileOrphan struct {
 // DetectInterFileMoves identifies definitions moved between files
 // It looks for orphan deletes in one file that match orphan adds in another file
 func DetectInterFileMoves(analyses []*SynthesisAnalysis) []InterFileMove {
-	return DetectInterFileMovesWithConfig(analyses, DefaultMoveDetectionConfig())
+	return DetectInterFileMovesWithConfig(analyses, repoMoveConfig())
 }
 
 // DetectInterFileMovesWithConfig identifies inter-file moves with custom configuration
@@ -389,9 +583,14 @@ func DetectInterFileMovesWithConfig(analyses []*SynthesisAnalysis, config MoveDe
 	matchedAdds := make(map[int]bool)
 	var moves []InterFileMove
 
+	// Pass 0: Classes moved as a whole
+	if config.EnableContainerMoves {
+		moves = append(moves, findContainerInterFileMoves(orphanDeletes, orphanAdds, matchedDeletes, matchedAdds, config)...)
+	}
+
 	// Pass 1: Exact Match (across different files only)
 	if config.EnableExactMatch {
-		exactMoves := findExactInterFileMoves(orphanDeletes, orphanAdds, matchedDeletes, matchedAdds)
+		exactMoves := findExactInterFileMoves(orphanDeletes, orphanAdds, matchedDeletes, matchedAdds, config)
 		moves = append(moves, exactMoves...)
 	}
 
@@ -405,7 +604,7 @@ func DetectInterFileMovesWithConfig(analyses []*SynthesisAnalysis, config MoveDe
 }
 
 // findExactInterFileMoves finds inter-file moves with identical bodies
-func findExactInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, matchedAdds map[int]bool) []InterFileMove {
+func findExactInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, matchedAdds map[int]bool, config MoveDetectionConfig) []InterFileMove {
 	var moves []InterFileMove
 
 	// Build hash index of orphan adds
@@ -440,8 +639,8 @@ func findExactInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, ma
 				continue
 			}
 
-			// Verify kinds match
-			if del.Conflict.Base.Kind != getAddKind(add.Conflict) {
+			// Verify kinds are compatible
+			if ok, _ := kindMatchThreshold(del.Conflict.Base.Kind, getAddKind(add.Conflict), config); !ok {
 				continue
 			}
 
@@ -466,6 +665,14 @@ func findExactInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, ma
 func findFuzzyInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, matchedAdds map[int]bool, config MoveDetectionConfig) []InterFileMove {
 	var moves []InterFileMove
 
+	addBodies := make([]string, len(adds))
+	addFiles := make([]string, len(adds))
+	for i, add := range adds {
+		addBodies[i] = getAddRawBody(add.Conflict)
+		addFiles[i] = add.File
+	}
+	candidates := newFuzzyCandidates(addBodies, addFiles, len(deletes), config)
+
 	for delIdx, del := range deletes {
 		if matchedDeletes[delIdx] {
 			continue
@@ -479,46 +686,47 @@ func findFuzzyInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, ma
 			continue
 		}
 
-		// Get size-appropriate threshold for the delete body
-		delThreshold := getThresholdForSize(len(delTokens), config)
+		delShape := deleteShape(del.Conflict.Base.Body, del.File, config)
 
 		bestIdx := -1
-		bestSimilarity := 0.0
+		var best, nearest MoveScore
 
-		for addIdx, add := range adds {
+		for _, addIdx := range candidates.forDelete(delTokens) {
 			if matchedAdds[addIdx] {
 				continue
 			}
+			add := adds[addIdx]
 
 			// Only match across different files
 			if del.File == add.File {
 				continue
 			}
 
-			// Verify kinds match
-			if del.Conflict.Base.Kind != getAddKind(add.Conflict) {
+			// Verify kinds are compatible
+			kindOK, kindThreshold := kindMatchThreshold(del.Conflict.Base.Kind, getAddKind(add.Conflict), config)
+			if !kindOK {
 				continue
 			}
 
-			addBody := getAddBody(add.Conflict)
-			addTokens := tokenize(addBody)
+			addTokens := candidates.tokens[addIdx]
 
 			// Skip small bodies
 			if len(addTokens) < config.MinTokenCount {
 				continue
 			}
 
-			// Use the more restrictive threshold of the two bodies
-			addThreshold := getThresholdForSize(len(addTokens), config)
-			threshold := delThreshold
-			if addThreshold > threshold {
-				threshold = addThreshold
+			score := MoveScore{
+				Candidate:    add.File + ":" + getAddName(add.Conflict),
+				DeleteTokens: len(delTokens),
+				AddTokens:    len(addTokens),
+				Similarity:   candidates.similarity(delTokens, delShape, addIdx, config),
+				Threshold:    pairThreshold(len(delTokens), len(addTokens), kindThreshold, config),
 			}
-
-			similarity := calculateSimilarity(delTokens, addTokens, config)
-			if similarity >= threshold && similarity > bestSimilarity {
+			if score.Similarity >= score.Threshold && score.Similarity > best.Similarity {
 				bestIdx = addIdx
-				bestSimilarity = similarity
+				best = score
+			} else if score.Similarity > nearest.Similarity {
+				nearest = score
 			}
 		}
 
@@ -530,42 +738,81 @@ func findFuzzyInterFileMoves(deletes, adds []CrossFileOrphan, matchedDeletes, ma
 				SourceConflict: del.Conflict,
 				DestConflict:   add.Conflict,
 				MatchType:      "Fuzzy Match",
-				Similarity:     bestSimilarity,
+				Similarity:     best.Similarity,
+				Score:          &best,
 			})
 			matchedDeletes[delIdx] = true
 			matchedAdds[bestIdx] = true
+		} else if nearest.Candidate != "" {
+			del.Conflict.note("no move across files: nearest add '%s' scored %s", nearest.Candidate, &nearest)
 		}
 	}
 
 	return moves
 }
 
-// ApplyInterFileMoves updates conflicts to reflect detected inter-file moves
+// ApplyInterFileMoves updates conflicts to reflect detected inter-file moves.
+// Members of a container move are folded into one conflict per class.
 func ApplyInterFileMoves(analyses []*SynthesisAnalysis, moves []InterFileMove) {
 	for _, move := range moves {
-		kind := capitalizeFirst(move.SourceConflict.Base.Kind)
-		name := move.SourceConflict.Base.Name
-
-		// Format the match suffix
-		var matchSuffix string
-		if move.MatchType == "Exact Match" {
-			matchSuffix = "Exact Match"
-		} else {
-			matchSuffix = fmt.Sprintf("%.0f%% Match", move.Similarity*100)
+		labelInterFileMove(move)
+		for _, member := range move.Members {
+			labelInterFileMove(member)
 		}
+	}
+	collapseContainerMoves(analyses, moves)
 
-		// Update source conflict (the delete)
-		move.SourceConflict.UIConflict.Status = "Can Auto-merge"
-		move.SourceConflict.UIConflict.ConflictType = fmt.Sprintf(
-			"%s '%s' Moved to %s (%s)",
-			kind, name, move.DestFile, matchSuffix,
-		)
-
-		// Update dest conflict (the add)
-		move.DestConflict.UIConflict.Status = "Can Auto-merge"
-		move.DestConflict.UIConflict.ConflictType = fmt.Sprintf(
-			"%s '%s' Moved from %s (%s)",
-			kind, name, move.SourceFile, matchSuffix,
-		)
+	// Moves are labeled auto-mergeable above; re-check the repository policy
+	for _, analysis := range analyses {
+		ApplyPolicy(analysis)
 	}
 }
+
+// labelInterFileMove marks both sides of a move as auto-mergeable
+func labelInterFileMove(move InterFileMove) {
+	kind := capitalizeFirst(move.SourceConflict.Base.Kind)
+	name := move.SourceConflict.Base.Name
+
+	// Format the match suffix
+	var matchSuffix string
+	switch move.MatchType {
+	case "Exact Match":
+		matchSuffix = "Exact Match"
+	case "Container Match":
+		matchSuffix = fmt.Sprintf("%d members, %.0f%% Match", len(move.Members), move.Similarity*100)
+	default:
+		matchSuffix = fmt.Sprintf("%.0f%% Match", move.Similarity*100)
+	}
+
+	info := &MoveInfo{
+		SourceFile: move.SourceFile,
+		DestFile:   move.DestFile,
+		SourceName: name,
+		DestName:   getAddName(move.DestConflict),
+		MatchType:  move.MatchType,
+		Similarity: move.Similarity,
+	}
+
+	// Update source conflict (the delete); policy rules are evaluated again
+	move.SourceConflict.UIConflict.Status = "Can Auto-merge"
+	move.SourceConflict.UIConflict.Rule = ""
+	move.SourceConflict.UserResolution = UserResolutionNone
+	move.SourceConflict.ResolvedBy = ""
+	move.SourceConflict.Move = info
+	move.SourceConflict.UIConflict.ConflictType = fmt.Sprintf(
+		"%s '%s' Moved to %s (%s)",
+		kind, name, move.DestFile, matchSuffix,
+	)
+
+	// Update dest conflict (the add)
+	move.DestConflict.UIConflict.Status = "Can Auto-merge"
+	move.DestConflict.UIConflict.Rule = ""
+	move.DestConflict.UserResolution = UserResolutionNone
+	move.DestConflict.ResolvedBy = ""
+	move.DestConflict.Move = info
+	move.DestConflict.UIConflict.ConflictType = fmt.Sprintf(
+		"%s '%s' Moved from %s (%s)",
+		kind, name, move.SourceFile, matchSuffix,
+	)
+	explainInterFileMove(move, info)
+}
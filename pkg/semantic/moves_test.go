@@ -761,9 +761,8 @@ func TestDefaultMoveDetectionConfig_WeightedSimilarity(t *testing.T) {
 	}
 }
 
-// BenchmarkDetectMoves_LargeFile benchmarks move detection with many conflicts
-func BenchmarkDetectMoves_LargeFile(b *testing.B) {
-	// Create 50 conflicts (25 deletes, 25 adds, 10 matching pairs)
+// largeFileMoveConflicts creates 50 conflicts (25 deletes, 25 adds, 10 matching pairs)
+func largeFileMoveConflicts() []SynthesisConflict {
 	var conflicts []SynthesisConflict
 
 	for i := 0; i < 25; i++ {
@@ -781,12 +780,89 @@ func BenchmarkDetectMoves_LargeFile(b *testing.B) {
 		conflicts = append(conflicts, makeAddConflict("different"+string(rune('A'+i)), body, uint32(3000+i*100), uint32(3000+i*100+50)))
 	}
 
+	return conflicts
+}
+
+// BenchmarkDetectMoves_LargeFile benchmarks move detection with many conflicts
+func BenchmarkDetectMoves_LargeFile(b *testing.B) {
+	conflicts := largeFileMoveConflicts()
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		DetectMoves(conflicts)
 	}
 }
 
+// BenchmarkDetectMoves_LargeFile_Structural benchmarks the same corpus with
+// structural similarity
+func BenchmarkDetectMoves_LargeFile_Structural(b *testing.B) {
+	conflicts := largeFileMoveConflicts()
+	config := DefaultMoveDetectionConfig()
+	config.UseStructuralSimilarity = true
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DetectMovesWithConfig(conflicts, config)
+	}
+}
+
+// crudHandler returns a Python handler; handlers of one shape differ only in
+// their names, callees and attributes
+func crudHandler(name, param, local, model, method, serializer string) string {
+	return "def " + name + "(" + param + "):\n" +
+		"    data = " + param + ".json()\n" +
+		"    " + local + " = " + model + "." + method + "(data)\n" +
+		"    return " + local + "." + serializer + "()\n"
+}
+
+// TestDetectMoves_StructuralAccuracy checks structural move detection over
+// the benchmark corpus and same-shape CRUD handlers: every true move is found
+// and no handler is paired with one that calls different functions
+func TestDetectMoves_StructuralAccuracy(t *testing.T) {
+	config := DefaultMoveDetectionConfig()
+	config.UseStructuralSimilarity = true
+
+	moved := func(result []SynthesisConflict) []string {
+		var types []string
+		for _, c := range result {
+			if strings.Contains(c.UIConflict.ConflictType, "Moved") {
+				types = append(types, c.UIConflict.ConflictType)
+			}
+		}
+		return types
+	}
+
+	if got := moved(DetectMovesWithConfig(largeFileMoveConflicts(), config)); len(got) != 10 {
+		t.Errorf("benchmark corpus: expected 10 moves, got %d: %v", len(got), got)
+	}
+
+	conflicts := []SynthesisConflict{
+		makeDeleteConflict("create_user", crudHandler("create_user", "request", "user", "User", "create", "to_dict"), 0, 100),
+		makeDeleteConflict("delete_order", crudHandler("delete_order", "request", "order", "Order", "delete", "serialize"), 100, 200),
+		makeDeleteConflict("update_invoice", crudHandler("update_invoice", "request", "invoice", "Invoice", "update", "as_json"), 200, 300),
+		// True moves: renamed with renamed locals
+		makeAddConflict("add_user", crudHandler("add_user", "req", "u", "User", "create", "to_dict"), 1000, 1100),
+		makeAddConflict("remove_order", crudHandler("remove_order", "req", "o", "Order", "delete", "serialize"), 1100, 1200),
+		// Same shape, different model and callees
+		makeAddConflict("archive_customer", crudHandler("archive_customer", "request", "customer", "Customer", "archive", "summary"), 1200, 1300),
+	}
+	got := moved(DetectMovesWithConfig(conflicts, config))
+	if len(got) != 2 {
+		t.Fatalf("CRUD handlers: expected 2 moves, got %d: %v", len(got), got)
+	}
+	for _, pair := range [][2]string{{"create_user", "add_user"}, {"delete_order", "remove_order"}} {
+		found := false
+		for _, conflictType := range got {
+			if strings.Contains(conflictType, "'"+pair[0]+"'") && strings.Contains(conflictType, "'"+pair[1]+"'") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %s to move to %s, got %v", pair[0], pair[1], got)
+		}
+	}
+}
+
 // =============================================================================
 // Inter-File Move Detection Tests
 // =============================================================================
@@ -1195,6 +1271,14 @@ func TestApplyInterFileMoves_UpdatesConflicts(t *testing.T) {
 	if !strings.Contains(dstConflict.UIConflict.ConflictType, "Moved from utils.py") {
 		t.Errorf("dest conflict type should mention source file, got '%s'", dstConflict.UIConflict.ConflictType)
 	}
+
+	// Both sides record the move
+	want := MoveInfo{SourceFile: "utils.py", DestFile: "newutils.py", SourceName: "helper", DestName: "helper", MatchType: "Exact Match", Similarity: 1}
+	for _, c := range []*SynthesisConflict{srcConflict, dstConflict} {
+		if c.Move == nil || *c.Move != want {
+			t.Errorf("unexpected move info: %+v", c.Move)
+		}
+	}
 }
 
 // TestApplyInterFileMoves_FuzzyMatchPercentage tests fuzzy match percentage in conflict type
@@ -1436,9 +1520,8 @@ func TestDetectInterFileMoves_WeightedSimilarity(t *testing.T) {
 	})
 }
 
-// BenchmarkDetectInterFileMoves benchmarks inter-file move detection
-func BenchmarkDetectInterFileMoves(b *testing.B) {
-	// Create 10 files with 5 orphan conflicts each
+// interFileMoveAnalyses creates 10 files with 5 orphan conflicts each
+func interFileMoveAnalyses() []*SynthesisAnalysis {
 	var analyses []*SynthesisAnalysis
 
 	for i := 0; i < 5; i++ {
@@ -1467,8 +1550,28 @@ func BenchmarkDetectInterFileMoves(b *testing.B) {
 		analyses = append(analyses, makeAnalysis("file"+string(rune('0'+i))+".py", conflicts))
 	}
 
+	return analyses
+}
+
+// BenchmarkDetectInterFileMoves benchmarks inter-file move detection
+func BenchmarkDetectInterFileMoves(b *testing.B) {
+	analyses := interFileMoveAnalyses()
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		DetectInterFileMoves(analyses)
 	}
 }
+
+// BenchmarkDetectInterFileMoves_Structural benchmarks the same corpus with
+// structural similarity
+func BenchmarkDetectInterFileMoves_Structural(b *testing.B) {
+	analyses := interFileMoveAnalyses()
+	config := DefaultMoveDetectionConfig()
+	config.UseStructuralSimilarity = true
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DetectInterFileMovesWithConfig(analyses, config)
+	}
+}
@@ -0,0 +1,85 @@
+package semantic
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultJobs returns the default number of files processed in parallel
+func DefaultJobs() int {
+	return runtime.NumCPU()
+}
+
+// runParallel calls fn for every index in [0, n) on at most jobs goroutines
+// and returns once all calls have finished. Results should be written by
+// index so that output order does not depend on scheduling.
+func runParallel(n, jobs int, fn func(i int)) {
+	if jobs > n {
+		jobs = n
+	}
+	if jobs <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// AnalyzeFilesForSynthesis analyzes conflicting files in parallel using
+// stage contents from LoadConflictVersions. The returned analyses are in the
+// same order as files. Inter-file move detection should run on the result
+// once all files are analyzed. Files are analyzed on config.Jobs workers,
+// which report each file to config.OnAnalyzed.
+func AnalyzeFilesForSynthesis(files []string, versions map[string]*ConflictVersions, config MergeConfig) []*SynthesisAnalysis {
+	analyses := make([]*SynthesisAnalysis, len(files))
+	runParallel(len(files), config.Jobs, func(i int) {
+		fileVersions := versions[files[i]]
+		if fileVersions == nil {
+			analyses[i] = AnalyzeConflictForSynthesis(files[i], config)
+		} else {
+			analyses[i] = AnalyzeVersionsForSynthesis(files[i], fileVersions, config)
+		}
+		if config.OnAnalyzed != nil {
+			config.OnAnalyzed(analyses[i])
+		}
+	})
+	return analyses
+}
+
+// SynthesizeFiles merges files in memory in parallel using config.Jobs
+// workers, then formats, writes and stages them (or runs their regenerate
+// rules) one at a time in order, so git and external commands never run
+// concurrently. Each result is reported to config.OnSynthesized once its file
+// is done. Results are in the same order as analyses.
+func SynthesizeFiles(analyses []*SynthesisAnalysis, config MergeConfig) []*SynthesisResult {
+	pending := make([]*pendingSynthesis, len(analyses))
+	runParallel(len(analyses), config.Jobs, func(i int) {
+		pending[i] = prepareSynthesis(analyses[i], config)
+	})
+
+	results := make([]*SynthesisResult, len(analyses))
+	for i, analysis := range analyses {
+		results[i] = finishSynthesis(analysis, config, pending[i])
+		if config.OnSynthesized != nil {
+			config.OnSynthesized(results[i])
+		}
+	}
+	return results
+}
@@ -0,0 +1,140 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/git"
+)
+
+// TestRunParallel verifies every index is visited exactly once
+func TestRunParallel(t *testing.T) {
+	for _, jobs := range []int{0, 1, 3, 16} {
+		visits := make([]int32, 10)
+		runParallel(len(visits), jobs, func(i int) {
+			atomic.AddInt32(&visits[i], 1)
+		})
+		for i, v := range visits {
+			if v != 1 {
+				t.Errorf("jobs=%d: index %d visited %d times", jobs, i, v)
+			}
+		}
+	}
+}
+
+// TestAnalyzeFilesForSynthesis_Deterministic verifies parallel analysis
+// yields the same conflicts, in the same order, as sequential analysis
+func TestAnalyzeFilesForSynthesis_Deterministic(t *testing.T) {
+	base := "def a():\n    return 1\n\ndef b():\n    return 2\n\ndef c():\n    return 3\n"
+	local := "def a():\n    return 10\n\ndef b():\n    return 20\n\ndef c():\n    return 30\n"
+	remote := "def a():\n    return 100\n\ndef b():\n    return 200\n\ndef c():\n    return 300\n"
+
+	var files []string
+	versions := make(map[string]*ConflictVersions)
+	for i := 0; i < 8; i++ {
+		file := fmt.Sprintf("mod%d.py", i)
+		files = append(files, file)
+		versions[file] = &ConflictVersions{
+			Base:   []byte(base),
+			Local:  []byte(local),
+			Remote: []byte(remote),
+		}
+	}
+
+	summarize := func(analyses []*SynthesisAnalysis) []string {
+		var out []string
+		for _, a := range analyses {
+			for _, c := range a.Conflicts {
+				out = append(out, a.File+":"+c.UIConflict.ConflictType)
+			}
+		}
+		return out
+	}
+
+	config := DefaultMergeConfig()
+	config.Jobs = 1
+	sequential := summarize(AnalyzeFilesForSynthesis(files, versions, config))
+	config.Jobs = 4
+	var analyzed int32
+	config.OnAnalyzed = func(*SynthesisAnalysis) { atomic.AddInt32(&analyzed, 1) }
+	parallel := summarize(AnalyzeFilesForSynthesis(files, versions, config))
+	if analyzed != int32(len(files)) {
+		t.Errorf("OnAnalyzed called %d times, want %d", analyzed, len(files))
+	}
+
+	if len(sequential) != 24 {
+		t.Fatalf("expected 24 conflicts, got %d: %v", len(sequential), sequential)
+	}
+	if !reflect.DeepEqual(sequential, parallel) {
+		t.Errorf("parallel analysis differs from sequential:\n%v\n%v", sequential, parallel)
+	}
+}
+
+// TestSynthesizeFiles_StagesSequentially verifies files are staged one at a
+// time and in order when synthesized on several workers
+func TestSynthesizeFiles_StagesSequentially(t *testing.T) {
+	base := "def a():\n    return 1\n\ndef b():\n    return 2\n"
+	local := "def a():\n    return 10\n\ndef b():\n    return 2\n"
+	remote := "def a():\n    return 1\n\ndef b():\n    return 20\n"
+
+	dir := t.TempDir()
+	var analyses []*SynthesisAnalysis
+	var files []string
+	for i := 0; i < 8; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("mod%d.py", i))
+		if err := os.WriteFile(file, []byte(local), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+		analyses = append(analyses, AnalyzeConflictFromContents(file, []byte(base), []byte(local), []byte(remote)))
+	}
+
+	var running, overlaps int32
+	var staged []string
+	mock := git.NewMockExecutor()
+	mock.OnRun = func(ctx context.Context, args []string) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+		time.Sleep(time.Millisecond)
+		if args[0] == "add" {
+			staged = append(staged, args[1])
+		}
+		return nil
+	}
+	withMockExecutor(t, mock)
+
+	config := DefaultMergeConfig()
+	config.CreateBackup = false
+	config.Jobs = 4
+	var reported []string
+	config.OnSynthesized = func(r *SynthesisResult) {
+		if !r.Staged {
+			t.Errorf("%s: reported before it was staged", r.File)
+		}
+		reported = append(reported, r.File)
+	}
+	results := SynthesizeFiles(analyses, config)
+
+	for _, r := range results {
+		if !r.Success || !r.Staged {
+			t.Errorf("%s: not staged: %+v", r.File, r)
+		}
+	}
+	if overlaps != 0 {
+		t.Errorf("git ran concurrently %d times", overlaps)
+	}
+	if !reflect.DeepEqual(staged, files) {
+		t.Errorf("staged %v, want %v", staged, files)
+	}
+	if !reflect.DeepEqual(reported, files) {
+		t.Errorf("reported %v, want %v", reported, files)
+	}
+}
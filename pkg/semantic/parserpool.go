@@ -0,0 +1,56 @@
+package semantic
+
+import (
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+	"github.com/smacker/go-tree-sitter/yaml"
+)
+
+// grammars are the tree-sitter grammars of the built-in languages
+var grammars = map[Language]func() *sitter.Language{
+	LangPython:     python.GetLanguage,
+	LangJavaScript: javascript.GetLanguage,
+	LangTypeScript: typescript.GetLanguage,
+	LangYAML:       yaml.GetLanguage,
+	LangGo:         golang.GetLanguage,
+	LangRust:       rust.GetLanguage,
+}
+
+// parserPools keeps configured tree-sitter parsers per language so that
+// concurrent analyses reuse parsers instead of building one per parse.
+var parserPools = func() map[Language]*sync.Pool {
+	pools := make(map[Language]*sync.Pool, len(grammars))
+	for lang, grammar := range grammars {
+		pools[lang] = newParserPool(grammar)
+	}
+	return pools
+}()
+
+// newParserPool creates a pool of parsers for one grammar
+func newParserPool(language func() *sitter.Language) *sync.Pool {
+	return &sync.Pool{
+		New: func() any {
+			parser := sitter.NewParser()
+			parser.SetLanguage(language())
+			return parser
+		},
+	}
+}
+
+// acquireParser returns a parser for lang from its pool.
+// Callers must hand it back with releaseParser when done.
+func acquireParser(lang Language) *sitter.Parser {
+	return parserPools[lang].Get().(*sitter.Parser)
+}
+
+// releaseParser resets a parser and returns it to the pool for lang
+func releaseParser(lang Language, parser *sitter.Parser) {
+	parser.Reset()
+	parserPools[lang].Put(parser)
+}
@@ -0,0 +1,132 @@
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/analyzer"
+)
+
+// langPluginBase is the first Language assigned to external analyzers.
+// Each analyzer command gets its own Language for the life of the process.
+const langPluginBase Language = 1000
+
+// analyzerTimeout bounds how long an external analyzer may take per file
+const analyzerTimeout = 30 * time.Second
+
+var (
+	pluginMu        sync.Mutex
+	pluginLanguages = make(map[string]Language) // Analyzer command -> language
+	pluginCommands  []string                    // Commands by language - langPluginBase
+)
+
+// pluginLanguage returns the Language of an external analyzer command
+func pluginLanguage(command string) Language {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	if lang, ok := pluginLanguages[command]; ok {
+		return lang
+	}
+	lang := langPluginBase + Language(len(pluginCommands))
+	pluginLanguages[command] = lang
+	pluginCommands = append(pluginCommands, command)
+	return lang
+}
+
+// pluginCommand returns the analyzer command of a plugin language
+func pluginCommand(lang Language) (string, bool) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	i := int(lang - langPluginBase)
+	if lang < langPluginBase || i >= len(pluginCommands) {
+		return "", false
+	}
+	return pluginCommands[i], true
+}
+
+// isPluginLanguage reports whether lang is handled by an external analyzer
+func isPluginLanguage(lang Language) bool {
+	return lang >= langPluginBase
+}
+
+// parsePlugin runs an external analyzer over content (see package analyzer
+// for the protocol) and converts its definitions
+func parsePlugin(content []byte, lang Language) *FileAnalysis {
+	command, ok := pluginCommand(lang)
+	if !ok {
+		return &FileAnalysis{ParseError: fmt.Errorf("unsupported language")}
+	}
+
+	request, err := json.Marshal(analyzer.Request{
+		Protocol: analyzer.ProtocolVersion,
+		Content:  string(content),
+	})
+	if err != nil {
+		return &FileAnalysis{ParseError: err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), analyzerTimeout)
+	defer cancel()
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("G2_ANALYZER_PROTOCOL=%d", analyzer.ProtocolVersion))
+	cmd.Stdin = bytes.NewReader(request)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return &FileAnalysis{ParseError: fmt.Errorf("analyzer %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))}
+	}
+
+	var response analyzer.Response
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return &FileAnalysis{ParseError: fmt.Errorf("analyzer %q returned invalid JSON: %w", command, err)}
+	}
+	if response.Error != "" {
+		return &FileAnalysis{ParseError: fmt.Errorf("analyzer %q: %s", command, response.Error)}
+	}
+
+	defs, err := pluginDefinitions(response.Definitions, content)
+	if err != nil {
+		return &FileAnalysis{ParseError: fmt.Errorf("analyzer %q: %w", command, err)}
+	}
+	return &FileAnalysis{Definitions: defs}
+}
+
+// pluginDefinitions checks the definitions returned by an analyzer and takes
+// their bodies and line numbers from content
+func pluginDefinitions(defs []analyzer.Definition, content []byte) ([]Definition, error) {
+	result := make([]Definition, 0, len(defs))
+	seen := make(map[string]bool)
+	for _, d := range defs {
+		if d.Name == "" || d.Kind == "" {
+			return nil, fmt.Errorf("definition without name or kind at byte %d", d.StartByte)
+		}
+		if d.StartByte < 0 || d.EndByte < d.StartByte || d.EndByte > len(content) {
+			return nil, fmt.Errorf("definition %q has invalid byte range %d-%d", d.Name, d.StartByte, d.EndByte)
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("duplicate definition %q", d.Name)
+		}
+		seen[d.Name] = true
+
+		result = append(result, Definition{
+			Name:       d.Name,
+			Kind:       d.Kind,
+			Signature:  d.Signature,
+			Body:       string(content[d.StartByte:d.EndByte]),
+			StartLine:  uint32(bytes.Count(content[:d.StartByte], []byte("\n"))),
+			EndLine:    uint32(bytes.Count(content[:d.EndByte], []byte("\n"))),
+			StartByte:  uint32(d.StartByte),
+			EndByte:    uint32(d.EndByte),
+			Normalized: d.Normalized,
+		})
+	}
+	return result, nil
+}
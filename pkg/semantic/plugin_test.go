@@ -0,0 +1,135 @@
+package semantic
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simonkoeck/g2/pkg/analyzer"
+)
+
+// buildExamplePlugin builds plugins/ini-analyzer and returns its path
+func buildExamplePlugin(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	bin := filepath.Join(t.TempDir(), "ini-analyzer")
+	out, err := exec.Command("go", "build", "-o", bin, "github.com/simonkoeck/g2/plugins/ini-analyzer").CombinedOutput()
+	if err != nil {
+		t.Fatalf("building the example analyzer failed: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// TestPluginAnalyzer tests parsing and merging with the example analyzer
+func TestPluginAnalyzer(t *testing.T) {
+	bin := buildExamplePlugin(t)
+	withRepoConfig(t, &RepoConfig{Sections: []PathSection{
+		{Patterns: []string{"*.ini"}, Settings: PathSettings{Analyzer: &bin}},
+	}})
+	withParseCache(t) // Plugin results are never cached
+
+	if !IsSemanticFile("conf/app.ini") || !isPluginLanguage(DetectLanguage("conf/app.ini")) {
+		t.Fatal("*.ini files should use the analyzer")
+	}
+	if DetectLanguage("conf/app.ini") != DetectLanguage("other.ini") {
+		t.Error("the same command should map to the same language")
+	}
+
+	base := "; settings\nname = app\n\n[server]\nport = 80\n\n[db]\nhost = localhost\n"
+	local := "; settings\nname = app\n\n[server]\nport=80\n\n[db]\nhost = localhost\n"
+	remote := "; settings\nname = app\n\n[server]\n; the port\nport   =  80\n\n[db]\nhost = db.internal\n"
+
+	t.Run("definitions", func(t *testing.T) {
+		analysis := ParseFile([]byte(base), DetectLanguage("app.ini"))
+		if analysis.ParseError != nil {
+			t.Fatalf("unexpected parse error: %v", analysis.ParseError)
+		}
+		var names []string
+		for _, def := range analysis.Definitions {
+			names = append(names, def.Name)
+		}
+		if strings.Join(names, ",") != "(global),server,db" {
+			t.Fatalf("unexpected definitions: %v", names)
+		}
+		server := analysis.Definitions[1]
+		if server.Kind != "section" || server.Body != "[server]\nport = 80" || server.StartLine != 3 || server.EndLine != 4 {
+			t.Errorf("unexpected server section: %+v", server)
+		}
+		if server.Normalized != "[server]\nport=80" {
+			t.Errorf("unexpected normalized form: %q", server.Normalized)
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		analysis := AnalyzeConflictFromContents("app.ini", []byte(base), []byte(local), []byte(remote))
+		types := make(map[string]string)
+		for _, c := range analysis.Conflicts {
+			types[c.UIConflict.ConflictType] = c.UIConflict.Status
+		}
+		if types["Section 'server' Comment Change"] != "Can Auto-merge" || types["Section 'db' Updated (remote)"] != "Can Auto-merge" {
+			t.Fatalf("unexpected conflicts: %v", types)
+		}
+
+		merged, allAutoMerged, err := SynthesizeToBytes(analysis)
+		if err != nil || !allAutoMerged {
+			t.Fatalf("expected a clean merge, got %v (%v)", allAutoMerged, err)
+		}
+		// Comment changes take the remote body, as for built-in languages
+		if string(merged) != remote {
+			t.Errorf("unexpected merge result:\n%s", merged)
+		}
+	})
+
+	t.Run("analyzer error", func(t *testing.T) {
+		analysis := ParseFile([]byte("[broken\n"), DetectLanguage("app.ini"))
+		if analysis.ParseError == nil || !strings.Contains(analysis.ParseError.Error(), "unterminated section header") {
+			t.Errorf("expected the analyzer's error, got %v", analysis.ParseError)
+		}
+	})
+}
+
+// TestPluginAnalyzer_Failures tests analyzers that crash or break the protocol
+func TestPluginAnalyzer_Failures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	tests := map[string]string{
+		"echo boom >&2; exit 2":                 "boom",
+		"echo not json":                         "invalid JSON",
+		`echo '{"definitions":[{"name":"a"}]}'`: "without name or kind",
+		`echo '{"definitions":[{"name":"a","kind":"k","start_byte":0,"end_byte":99}]}'`: "invalid byte range",
+	}
+	for command, want := range tests {
+		analysis := ParseFile([]byte("content\n"), pluginLanguage(command))
+		if analysis.ParseError == nil || !strings.Contains(analysis.ParseError.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", command, want, analysis.ParseError)
+		}
+	}
+}
+
+// TestPluginDefinitions tests converting analyzer definitions
+func TestPluginDefinitions(t *testing.T) {
+	content := []byte("one\ntwo\nthree\n")
+	defs, err := pluginDefinitions([]analyzer.Definition{
+		{Name: "b", Kind: "line", StartByte: 4, EndByte: 13},
+	}, content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if defs[0].Body != "two\nthree" || defs[0].StartLine != 1 || defs[0].EndLine != 2 {
+		t.Errorf("unexpected definition: %+v", defs[0])
+	}
+
+	if _, err := pluginDefinitions([]analyzer.Definition{
+		{Name: "a", Kind: "line", StartByte: 0, EndByte: 3},
+		{Name: "a", Kind: "line", StartByte: 4, EndByte: 7},
+	}, content); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("expected a duplicate error, got %v", err)
+	}
+}
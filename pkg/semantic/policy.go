@@ -0,0 +1,426 @@
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// PolicyAction is what a policy rule does with the conflicts it matches
+type PolicyAction string
+
+// Policy actions
+const (
+	ActionLocal        PolicyAction = "local"         // Keep the local version
+	ActionRemote       PolicyAction = "remote"        // Keep the remote version
+	ActionBoth         PolicyAction = "both"          // Keep both versions, local first
+	ActionBase         PolicyAction = "base"          // Keep the base version
+	ActionManual       PolicyAction = "manual"        // Never auto-merge, always ask
+	ActionHigherSemver PolicyAction = "higher-semver" // Keep the side with the higher version number
+	ActionRegenerate   PolicyAction = "regenerate"    // Rebuild the whole file with a command
+)
+
+// PolicyRule maps matching conflicts to a resolution. Empty match lists
+// match everything; all non-empty lists must match.
+type PolicyRule struct {
+	Name        string
+	Patterns    []string // Path globs, as in paths sections
+	Kinds       []string // Definition kind globs, e.g. "function", "class"
+	Definitions []string // Definition name globs, e.g. "VERSION", "test_*"
+	Conflicts   []string // Conflict type globs, case-insensitive, e.g. "*deleted*"
+	Action      PolicyAction
+	Command     string // Command run by ActionRegenerate
+	Source      string // Where the rule was defined, for messages
+}
+
+// PolicyMatch records a rule that decided a conflict
+type PolicyMatch struct {
+	Conflict   string // Conflict type
+	Rule       string // Rule name
+	Resolution string // Side kept, "manual" or "regenerate"
+}
+
+// regenerateTimeout bounds how long a regenerate command may run
+const regenerateTimeout = 5 * time.Minute
+
+// loadYAMLRules applies the "rules" list of a .g2.yaml document
+func (c *RepoConfig) loadYAMLRules(value *yamlValue, source string) error {
+	if value.Kind != yamlSequence {
+		return fmt.Errorf("%s:%d: rules must be a list of rules", source, value.Line)
+	}
+
+	var errs []error
+	for _, item := range value.Items {
+		if item.Kind != yamlMapping {
+			errs = append(errs, fmt.Errorf("%s:%d: a rule must be a mapping", source, item.Line))
+			continue
+		}
+		rule, err := parseYAMLRule(item, source)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", len(c.Rules)+1)
+		}
+		// Without the command, what the rule matches is left for resolution
+		if rule.Action == ActionRegenerate && !c.TrustRepoConfig {
+			warnUntrustedCommand("the regenerate command of "+rule.Name, rule.Source)
+			rule.Action, rule.Command = ActionManual, ""
+		}
+		c.Rules = append(c.Rules, rule)
+	}
+	return errors.Join(errs...)
+}
+
+// parseYAMLRule reads one entry of the "rules" list
+func parseYAMLRule(item *yamlValue, source string) (PolicyRule, error) {
+	rule := PolicyRule{Source: fmt.Sprintf("%s:%d", source, item.Line)}
+	for _, pair := range item.Pairs {
+		values, err := pair.Value.scalars()
+		if err != nil {
+			return rule, fmt.Errorf("%s: %s: %w", source, pair.Key, err)
+		}
+		switch normalizeConfigKey(pair.Key) {
+		case "name":
+			rule.Name = strings.Join(values, " ")
+		case "match":
+			rule.Patterns = append(rule.Patterns, values...)
+		case "kind":
+			rule.Kinds = append(rule.Kinds, values...)
+		case "definition":
+			rule.Definitions = append(rule.Definitions, values...)
+		case "conflict":
+			rule.Conflicts = append(rule.Conflicts, values...)
+		case "resolve":
+			rule.Action = PolicyAction(strings.ToLower(strings.Join(values, "")))
+		case "command":
+			rule.Command = strings.Join(values, " ")
+		default:
+			return rule, fmt.Errorf("%s:%d: %s: unknown rule setting", source, pair.Value.Line, pair.Key)
+		}
+	}
+
+	switch rule.Action {
+	case ActionLocal, ActionRemote, ActionBoth, ActionBase, ActionManual, ActionHigherSemver:
+	case ActionRegenerate:
+		if strings.TrimSpace(rule.Command) == "" {
+			return rule, fmt.Errorf("%s: regenerate rule without command", rule.Source)
+		}
+	case "":
+		return rule, fmt.Errorf("%s: rule without resolve", rule.Source)
+	default:
+		return rule, fmt.Errorf("%s: unknown resolution %q (use local, remote, both, base, manual, higher-semver or regenerate)", rule.Source, rule.Action)
+	}
+	for _, pattern := range append(append(append([]string{}, rule.Kinds...), rule.Definitions...), rule.Conflicts...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return rule, fmt.Errorf("%s: invalid pattern %q", rule.Source, pattern)
+		}
+	}
+	return rule, nil
+}
+
+// ApplyPolicy applies the repository's merge policy to an analysis: the
+// auto-merge classes of the file's settings, then the policy rules
+func ApplyPolicy(analysis *SynthesisAnalysis) {
+	ApplyAutoMergePolicy(analysis)
+	applyPolicyRules(analysis)
+}
+
+// applyPolicyRules decides conflicts with the first rule that matches them
+// and can decide them. A regenerate rule takes over the whole file.
+func applyPolicyRules(analysis *SynthesisAnalysis) {
+	analysis.Regenerate = nil
+	if repoConfig == nil || len(repoConfig.Rules) == 0 {
+		return
+	}
+
+	for i := range analysis.Conflicts {
+		c := &analysis.Conflicts[i]
+		for j := range repoConfig.Rules {
+			rule := &repoConfig.Rules[j]
+			if !rule.matches(analysis.File, c) {
+				continue
+			}
+			if rule.Action == ActionRegenerate {
+				regenerateWithRule(analysis, rule)
+				return
+			}
+			resolution, ok := rule.decide(c)
+			if !ok {
+				continue
+			}
+			logging.Debug("policy rule matched", "file", analysis.File, "conflict", c.UIConflict.ConflictType, "rule", rule.Name)
+			applyRuleResolution(c, rule, resolution)
+			break
+		}
+	}
+}
+
+// regenerateWithRule hands every conflict of the file to a regenerate rule
+func regenerateWithRule(analysis *SynthesisAnalysis, rule *PolicyRule) {
+	logging.Debug("policy rule regenerates file", "file", analysis.File, "rule", rule.Name)
+	analysis.Regenerate = rule
+	for i := range analysis.Conflicts {
+		applyRuleResolution(&analysis.Conflicts[i], rule, UserResolutionNone)
+	}
+}
+
+// applyRuleResolution records the rule on a conflict and its members
+func applyRuleResolution(c *SynthesisConflict, rule *PolicyRule, resolution UserResolution) {
+	status := "Resolved by Policy"
+	resolvedBy := ResolvedByPolicy
+	if rule.Action == ActionManual {
+		status, resolvedBy = "Needs Resolution", ""
+	}
+	c.UserResolution = resolution
+	c.ResolvedBy = resolvedBy
+	c.UIConflict.Status = status
+	c.UIConflict.Rule = rule.Name
+	switch rule.Action {
+	case ActionManual:
+		c.note("policy rule %q: left for manual resolution", rule.Name)
+	case ActionRegenerate:
+		c.note("policy rule %q: file is regenerated with %q", rule.Name, rule.Command)
+	default:
+		c.note("policy rule %q: resolved as %s", rule.Name, resolution)
+	}
+	for i := range c.Members {
+		c.Members[i].UserResolution = resolution
+		c.Members[i].ResolvedBy = resolvedBy
+		c.Members[i].UIConflict.Status = status
+		c.Members[i].UIConflict.Rule = rule.Name
+	}
+}
+
+// matches reports whether the rule applies to a conflict in file
+func (r *PolicyRule) matches(file string, c *SynthesisConflict) bool {
+	if len(r.Patterns) > 0 && !matchAnyPath(r.Patterns, file) {
+		return false
+	}
+	if len(r.Conflicts) > 0 && !matchAnyGlob(r.Conflicts, strings.ToLower(c.UIConflict.ConflictType), true) {
+		return false
+	}
+	if len(r.Kinds) == 0 && len(r.Definitions) == 0 {
+		return true
+	}
+
+	def := ConflictDefinition(c)
+	if def == nil {
+		return false
+	}
+	if len(r.Kinds) > 0 && !matchAnyGlob(r.Kinds, strings.ToLower(def.Kind), true) {
+		return false
+	}
+	return len(r.Definitions) == 0 || matchAnyGlob(r.Definitions, def.Name, false)
+}
+
+// decide returns the resolution of a matching conflict. It reports false
+// when the rule cannot decide, and the next matching rule is tried.
+func (r *PolicyRule) decide(c *SynthesisConflict) (UserResolution, bool) {
+	if r.Action == ActionManual {
+		return UserResolutionNone, true
+	}
+	if ConflictDefinition(c) == nil {
+		// Binary files, parse errors and missing files have nothing to pick from
+		return UserResolutionNone, false
+	}
+
+	switch r.Action {
+	case ActionLocal:
+		return UserResolutionLocal, true
+	case ActionRemote:
+		return UserResolutionRemote, true
+	case ActionBoth:
+		return UserResolutionBoth, true
+	case ActionBase:
+		return UserResolutionBase, c.Base != nil
+	case ActionHigherSemver:
+		if c.Local == nil || c.Remote == nil {
+			return UserResolutionNone, false
+		}
+		local, localOK := findSemver(c.Local.Body)
+		remote, remoteOK := findSemver(c.Remote.Body)
+		if !localOK || !remoteOK {
+			return UserResolutionNone, false
+		}
+		if compareSemver(remote, local) > 0 {
+			return UserResolutionRemote, true
+		}
+		return UserResolutionLocal, true
+	}
+	return UserResolutionNone, false
+}
+
+// ConflictDefinition returns whichever definition of a conflict exists,
+// preferring the local version
+func ConflictDefinition(c *SynthesisConflict) *Definition {
+	switch {
+	case c.Local != nil:
+		return c.Local
+	case c.Remote != nil:
+		return c.Remote
+	default:
+		return c.Base
+	}
+}
+
+// matchAnyPath reports whether file matches any of the path globs
+func matchAnyPath(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchPathGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyGlob reports whether value matches any of the globs
+func matchAnyGlob(patterns []string, value string, foldCase bool) bool {
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// semverPattern finds version numbers such as 1.2, v1.2.3 or 2.0.0-rc.1
+var semverPattern = regexp.MustCompile(`\bv?(\d+)\.(\d+)(?:\.(\d+))?(?:-([0-9A-Za-z.]+))?`)
+
+// semver is a parsed version number
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+// findSemver returns the first version number in text
+func findSemver(text string) (semver, bool) {
+	m := semverPattern.FindStringSubmatch(text)
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	for i := 0; i < 3; i++ {
+		v.parts[i], _ = strconv.Atoi(m[i+1])
+	}
+	v.prerelease = m[4]
+	return v, true
+}
+
+// compareSemver returns -1, 0 or 1 as a is lower than, equal to or higher
+// than b. A prerelease is lower than its release.
+func compareSemver(a, b semver) int {
+	for i := range a.parts {
+		if a.parts[i] != b.parts[i] {
+			if a.parts[i] < b.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case a.prerelease == "":
+		return 1
+	case b.prerelease == "":
+		return -1
+	case a.prerelease < b.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// policyMatches lists the rules that decided the conflicts of an analysis
+func policyMatches(analysis *SynthesisAnalysis) []PolicyMatch {
+	var matches []PolicyMatch
+	for _, c := range analysis.Conflicts {
+		if c.UIConflict.Rule == "" {
+			continue
+		}
+		resolution := string(ActionManual)
+		switch {
+		case analysis.Regenerate != nil:
+			resolution = string(ActionRegenerate)
+		case c.UserResolution != UserResolutionNone:
+			resolution = c.UserResolution.String()
+		}
+		matches = append(matches, PolicyMatch{Conflict: c.UIConflict.ConflictType, Rule: c.UIConflict.Rule, Resolution: resolution})
+	}
+	return matches
+}
+
+// regenerateFile runs the regenerate command of the file's rule instead of
+// synthesizing it. The command runs in the shell with G2_FILE set and must
+// leave the file without conflict markers; the file is then staged.
+func regenerateFile(analysis *SynthesisAnalysis, config MergeConfig, result *SynthesisResult) *SynthesisResult {
+	rule := analysis.Regenerate
+	result.Conflicts = expandContainerMoves(analysis.Conflicts)
+	result.ConflictCount = len(result.Conflicts)
+
+	if config.DryRun {
+		if !config.JSONOutput {
+			fmt.Printf("\n=== Dry Run: %s ===\nWould regenerate with %q (rule %q)\n", analysis.File, rule.Command, rule.Name)
+		}
+		return result
+	}
+
+	if config.CreateBackup && settingsFor(analysis.File).backupEnabled() {
+		if err := backupFile(analysis.File); err != nil {
+			result.Success = false
+			result.Error = err
+			return result
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), regenerateTimeout)
+	defer cancel()
+	cmd := shellCommand(ctx, rule.Command)
+	cmd.Env = append(os.Environ(), "G2_FILE="+analysis.File)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		result.Success = false
+		result.Error = fmt.Errorf("regenerate command %q failed: %w: %s", rule.Command, err, strings.TrimSpace(stderr.String()))
+		return result
+	}
+
+	content, err := os.ReadFile(analysis.File)
+	if err != nil {
+		result.Success = false
+		result.Error = formatWriteError(analysis.File, err, "read regenerated file")
+		return result
+	}
+	if bytes.Contains(content, []byte("<<<<<<< ")) || bytes.Contains(content, []byte(">>>>>>> ")) {
+		result.Success = false
+		result.Error = fmt.Errorf("regenerate command %q left conflict markers in %s", rule.Command, analysis.File)
+		return result
+	}
+	result.Written = true
+
+	if err := stageFile(context.Background(), analysis.File); err != nil {
+		logging.Error("failed to stage file", "file", analysis.File, "error", err)
+		result.Success = false
+		result.Error = fmt.Errorf("failed to stage file: %w", err)
+		return result
+	}
+
+	result.Staged = true
+	result.AutoMergeCount = result.ConflictCount
+	result.AllAutoMerged = true
+	return result
+}
@@ -0,0 +1,285 @@
+package semantic
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simonkoeck/g2/pkg/git"
+	"github.com/simonkoeck/g2/pkg/ui"
+)
+
+// TestLoadRepoConfig_Rules tests reading policy rules from .g2.yaml
+func TestLoadRepoConfig_Rules(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		".g2.yaml": `rules:
+  - name: generated code
+    match: "generated/**"
+    resolve: remote
+  - match: "*_pb2.py"
+    resolve: regenerate
+    command: make proto
+  - definition: VERSION
+    kind: [variable, key]
+    resolve: higher-semver
+  - match: a.py
+    resolve: sideways
+  - resolve: regenerate
+  - conflict: "*deleted*"
+`,
+	})
+	mock := git.NewMockExecutor()
+	mock.SetDefaultError(&exec.ExitError{})
+	withMockExecutor(t, mock)
+
+	config, err := LoadRepoConfig(context.Background(), root)
+	if err == nil {
+		t.Fatal("expected errors for the invalid rules")
+	}
+	for _, want := range []string{"sideways", "without command", "without resolve"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+
+	if len(config.Rules) != 3 {
+		t.Fatalf("expected 3 valid rules, got %d", len(config.Rules))
+	}
+	if config.Rules[0].Name != "generated code" || config.Rules[0].Action != ActionRemote {
+		t.Errorf("unexpected first rule: %+v", config.Rules[0])
+	}
+	if config.Rules[1].Name != "rule 2" || config.Rules[1].Action != ActionManual || config.Rules[1].Command != "" {
+		t.Errorf("expected the untrusted regenerate rule to become manual: %+v", config.Rules[1])
+	}
+	if strings.Join(config.Rules[2].Kinds, ",") != "variable,key" {
+		t.Errorf("unexpected kinds: %v", config.Rules[2].Kinds)
+	}
+
+	mock = git.NewMockExecutor()
+	mock.SetResponse("config", []byte("g2.trustrepoconfig\x00"), nil)
+	withMockExecutor(t, mock)
+	config, _ = LoadRepoConfig(context.Background(), root)
+	if len(config.Rules) != 3 || config.Rules[1].Action != ActionRegenerate || config.Rules[1].Command != "make proto" {
+		t.Errorf("expected the trusted regenerate rule, got %+v", config.Rules)
+	}
+}
+
+// newPolicyConflict builds a conflict on a definition changed on both sides
+func newPolicyConflict(file, kind, name, status, local, remote string) SynthesisConflict {
+	return SynthesisConflict{
+		UIConflict: ui.Conflict{File: file, ConflictType: capitalizeFirst(kind) + " '" + name + "' Modified", Status: status},
+		Base:       &Definition{Name: name, Kind: kind, Body: "base"},
+		Local:      &Definition{Name: name, Kind: kind, Body: local},
+		Remote:     &Definition{Name: name, Kind: kind, Body: remote},
+	}
+}
+
+// TestApplyPolicyRules tests matching rules and the resolutions they choose
+func TestApplyPolicyRules(t *testing.T) {
+	withRepoConfig(t, &RepoConfig{Rules: []PolicyRule{
+		{Name: "versions", Definitions: []string{"VERSION"}, Action: ActionHigherSemver},
+		{Name: "generated", Patterns: []string{"generated/**"}, Action: ActionRemote},
+		{Name: "migrations", Patterns: []string{"migrations/*.py"}, Action: ActionManual},
+		{Name: "tests", Kinds: []string{"function"}, Definitions: []string{"test_*"}, Action: ActionBoth},
+	}})
+
+	tests := []struct {
+		name       string
+		file       string
+		conflict   SynthesisConflict
+		rule       string
+		status     string
+		resolution UserResolution
+	}{
+		{
+			name:       "higher remote version",
+			file:       "app/version.py",
+			conflict:   newPolicyConflict("app/version.py", "variable", "VERSION", "Needs Resolution", `VERSION = "1.9.0"`, `VERSION = "1.10.0"`),
+			rule:       "versions",
+			status:     "Resolved by Policy",
+			resolution: UserResolutionRemote,
+		},
+		{
+			name:       "prerelease is lower",
+			file:       "app/version.py",
+			conflict:   newPolicyConflict("app/version.py", "variable", "VERSION", "Needs Resolution", `VERSION = "v2.0.0"`, `VERSION = "2.0.0-rc.1"`),
+			rule:       "versions",
+			status:     "Resolved by Policy",
+			resolution: UserResolutionLocal,
+		},
+		{
+			name:       "no version falls through",
+			file:       "generated/version.py",
+			conflict:   newPolicyConflict("generated/version.py", "variable", "VERSION", "Needs Resolution", `VERSION = "dev"`, `VERSION = "2.0"`),
+			rule:       "generated",
+			status:     "Resolved by Policy",
+			resolution: UserResolutionRemote,
+		},
+		{
+			name:     "manual overrides auto-merge",
+			file:     "migrations/0001_init.py",
+			conflict: newPolicyConflict("migrations/0001_init.py", "function", "forwards", "Can Auto-merge", "a", "b"),
+			rule:     "migrations",
+			status:   "Needs Resolution",
+		},
+		{
+			name:     "nested paths do not match a single star",
+			file:     "migrations/old/0001_init.py",
+			conflict: newPolicyConflict("migrations/old/0001_init.py", "function", "forwards", "Can Auto-merge", "a", "b"),
+			status:   "Can Auto-merge",
+		},
+		{
+			name:       "kind and definition patterns",
+			file:       "tests/test_app.py",
+			conflict:   newPolicyConflict("tests/test_app.py", "function", "test_login", "Needs Resolution", "a", "b"),
+			rule:       "tests",
+			status:     "Resolved by Policy",
+			resolution: UserResolutionBoth,
+		},
+		{
+			name:     "kind must match",
+			file:     "tests/test_app.py",
+			conflict: newPolicyConflict("tests/test_app.py", "class", "test_Case", "Needs Resolution", "a", "b"),
+			status:   "Needs Resolution",
+		},
+		{
+			name:     "definition rules skip conflicts without definitions",
+			file:     "generated/logo.png",
+			conflict: SynthesisConflict{UIConflict: ui.Conflict{File: "generated/logo.png", ConflictType: "Binary Conflict", Status: "Needs Resolution"}},
+			status:   "Needs Resolution",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := &SynthesisAnalysis{File: tt.file, Conflicts: []SynthesisConflict{tt.conflict}}
+			ApplyPolicy(analysis)
+			c := analysis.Conflicts[0]
+			if c.UIConflict.Rule != tt.rule || c.UIConflict.Status != tt.status || c.UserResolution != tt.resolution {
+				t.Errorf("expected rule %q, status %q, resolution %s; got %q, %q, %s",
+					tt.rule, tt.status, tt.resolution, c.UIConflict.Rule, c.UIConflict.Status, c.UserResolution)
+			}
+		})
+	}
+}
+
+// TestApplyPolicyRules_Conflicts tests matching on the conflict type and
+// resolving container members
+func TestApplyPolicyRules_Conflicts(t *testing.T) {
+	withRepoConfig(t, &RepoConfig{Rules: []PolicyRule{
+		{Name: "keep moves local", Conflicts: []string{"*moved*"}, Action: ActionLocal},
+	}})
+
+	analysis := &SynthesisAnalysis{File: "a.py", Conflicts: []SynthesisConflict{
+		{
+			UIConflict: ui.Conflict{File: "a.py", ConflictType: "Class 'A' Moved (2 members, 90% Match)", Status: "Can Auto-merge"},
+			Local:      &Definition{Name: "A", Kind: "class"},
+			Members: []SynthesisConflict{
+				{UIConflict: ui.Conflict{File: "a.py", ConflictType: "Method 'A.x' Moved (Exact Match)", Status: "Can Auto-merge"}},
+			},
+		},
+		newPolicyConflict("a.py", "function", "f", "Needs Resolution", "a", "b"),
+	}}
+	ApplyPolicy(analysis)
+
+	member := analysis.Conflicts[0].Members[0]
+	if member.UIConflict.Status != "Resolved by Policy" || member.UserResolution != UserResolutionLocal {
+		t.Errorf("members should follow the container: %+v", member)
+	}
+	if analysis.Conflicts[1].UIConflict.Rule != "" {
+		t.Errorf("other conflict types should not match: %+v", analysis.Conflicts[1].UIConflict)
+	}
+
+	matches := policyMatches(analysis)
+	if len(matches) != 1 || matches[0].Rule != "keep moves local" || matches[0].Resolution != "local" {
+		t.Errorf("unexpected policy matches: %+v", matches)
+	}
+}
+
+// TestSynthesizeFile_PolicyResolution tests that policy resolutions are applied
+func TestSynthesizeFile_PolicyResolution(t *testing.T) {
+	withRepoConfig(t, &RepoConfig{Rules: []PolicyRule{
+		{Name: "generated", Patterns: []string{"*.py"}, Action: ActionRemote},
+	}})
+
+	local := "def f():\n    return 1\n"
+	analysis := AnalyzeConflictFromContents("gen.py",
+		[]byte("def f():\n    return 0\n"),
+		[]byte(local),
+		[]byte("def f():\n    return 2\n"))
+	if len(analysis.Conflicts) != 1 || analysis.Conflicts[0].UIConflict.Rule != "generated" {
+		t.Fatalf("expected the rule to decide the conflict: %+v", analysis.Conflicts)
+	}
+
+	merged, allAutoMerged, err := SynthesizeToBytes(analysis)
+	if err != nil || !allAutoMerged {
+		t.Fatalf("expected a clean merge, got %v (%v)", allAutoMerged, err)
+	}
+	if !strings.Contains(string(merged), "return 2") {
+		t.Errorf("expected the remote version, got:\n%s", merged)
+	}
+}
+
+// TestSynthesizeFile_Regenerate tests running a regenerate rule
+func TestSynthesizeFile_Regenerate(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "api_pb2.py")
+	conflicted := "<<<<<<< ours\nx = 1\n=======\nx = 2\n>>>>>>> theirs\n"
+	if err := os.WriteFile(file, []byte(conflicted), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mock := git.NewMockExecutor()
+	withMockExecutor(t, mock)
+
+	newAnalysis := func(command string) *SynthesisAnalysis {
+		withRepoConfig(t, &RepoConfig{Rules: []PolicyRule{
+			{Name: "protobuf", Patterns: []string{"*_pb2.py"}, Action: ActionRegenerate, Command: command},
+		}})
+		analysis := &SynthesisAnalysis{File: file, LocalContent: []byte("x = 1\n"), Conflicts: []SynthesisConflict{
+			newPolicyConflict(file, "variable", "x", "Needs Resolution", "x = 1", "x = 2"),
+		}}
+		ApplyPolicy(analysis)
+		return analysis
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		result := SynthesizeFile(newAnalysis(`echo regenerated > "$G2_FILE"`), MergeConfig{DryRun: true, JSONOutput: true})
+		if result.Error != nil || result.AllAutoMerged {
+			t.Errorf("unexpected dry-run result: %+v", result)
+		}
+		if content, _ := os.ReadFile(file); string(content) != conflicted {
+			t.Errorf("dry run should not run the command, got %q", content)
+		}
+	})
+
+	t.Run("markers left", func(t *testing.T) {
+		result := SynthesizeFile(newAnalysis("true"), MergeConfig{})
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "conflict markers") {
+			t.Errorf("expected a conflict marker error, got %v", result.Error)
+		}
+	})
+
+	t.Run("regenerated", func(t *testing.T) {
+		result := SynthesizeFile(newAnalysis(`echo "x = 3" > "$G2_FILE"`), MergeConfig{CreateBackup: true})
+		if result.Error != nil || !result.AllAutoMerged || result.AutoMergeCount != 1 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+		if content, _ := os.ReadFile(file); string(content) != "x = 3\n" {
+			t.Errorf("expected the regenerated file, got %q", content)
+		}
+		if backup, _ := os.ReadFile(file + ".orig"); string(backup) != conflicted {
+			t.Errorf("expected a backup of the conflicted file, got %q", backup)
+		}
+		if len(result.Rules) != 1 || result.Rules[0].Resolution != "regenerate" {
+			t.Errorf("expected the rule to be recorded, got %+v", result.Rules)
+		}
+	})
+}
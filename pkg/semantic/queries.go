@@ -0,0 +1,399 @@
+package semantic
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Definitions can be extracted with tree-sitter queries instead of
+// hand-written walkers. A query file uses these captures:
+//
+//	@definition.<kind>  the definition node; its text is the body
+//	@name               the definition name
+//	@container.name     prefixes the name as "<container>.<name>"
+//	@signature          the signature runs from the definition start to the
+//	                    end of this node (default: "<kind> <name>")
+//	@import             an import statement
+//
+// A pattern can instead build the name or signature from its captures with
+// (#set! name "...") or (#set! signature "..."), where {capture} stands for
+// the text of @capture, e.g. (#set! signature "def {name}{params}").
+//
+// When several patterns capture the same definition node, the earliest
+// pattern in the file wins.
+
+//go:embed queries/*.scm
+var builtinQueryFiles embed.FS
+
+// QueryDir is the directory, relative to the repository root, with query
+// files that replace or extend the built-in extraction: <language>.scm
+const QueryDir = ".g2/queries"
+
+// queryExtendsDirective as the first line of a query file adds its
+// definitions to the built-in ones instead of replacing them
+const queryExtendsDirective = "; extends"
+
+// definitionQuery is a compiled query file
+type definitionQuery struct {
+	query     *sitter.Query
+	extends   bool                         // Add to the built-in extraction instead of replacing it
+	templates map[uint32]map[string]string // #set! name/signature templates per pattern
+}
+
+// The built-in queries are compiled on first use and kept for the life of
+// the process
+var (
+	builtinQueriesOnce sync.Once
+	builtinQueries     map[Language]*definitionQuery
+	builtinQueriesErr  error
+)
+
+// languageNames are the file names of the query files per language
+var languageNames = map[Language]string{
+	LangPython:     "python",
+	LangJavaScript: "javascript",
+	LangTypeScript: "typescript",
+	LangYAML:       "yaml",
+	LangGo:         "go",
+	LangRust:       "rust",
+}
+
+// loadBuiltinQueries compiles the embedded query files. A language whose
+// query fails to compile has no built-in query; LoadRepoConfig reports the
+// error.
+func loadBuiltinQueries() error {
+	builtinQueriesOnce.Do(func() {
+		builtinQueries, builtinQueriesErr = compileQueryFiles(builtinQueryFiles, "queries")
+	})
+	return builtinQueriesErr
+}
+
+// compileQueryFiles compiles the <language>.scm files in dir of fsys.
+// Languages without a file are left out.
+func compileQueryFiles(fsys fs.FS, dir string) (map[Language]*definitionQuery, error) {
+	queries := make(map[Language]*definitionQuery)
+	var errs []error
+	for lang, name := range languageNames {
+		source, err := fs.ReadFile(fsys, path.Join(dir, name+".scm"))
+		if err != nil {
+			continue
+		}
+		q, err := compileQuery(source, lang, name+".scm")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("built-in query %w", err))
+			continue
+		}
+		queries[lang] = q
+	}
+	return queries, errors.Join(errs...)
+}
+
+// builtinQuery returns the embedded query of a language, if it has one
+func builtinQuery(lang Language) *definitionQuery {
+	loadBuiltinQueries()
+	return builtinQueries[lang]
+}
+
+// compileQuery compiles query source for a language
+func compileQuery(source []byte, lang Language, name string) (*definitionQuery, error) {
+	grammar, ok := grammars[lang]
+	if !ok {
+		return nil, fmt.Errorf("%s: no grammar for language", name)
+	}
+	q, err := sitter.NewQuery(source, grammar())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	firstLine, _, _ := strings.Cut(string(source), "\n")
+	return &definitionQuery{
+		query:     q,
+		extends:   strings.TrimSpace(firstLine) == queryExtendsDirective,
+		templates: queryTemplates(q),
+	}, nil
+}
+
+// queryTemplates collects the (#set! name "...") and (#set! signature "...")
+// properties of each pattern
+func queryTemplates(q *sitter.Query) map[uint32]map[string]string {
+	templates := make(map[uint32]map[string]string)
+	for i := uint32(0); i < q.PatternCount(); i++ {
+		for _, steps := range q.PredicatesForPattern(i) {
+			// (#set! key "value") ends with a done step
+			if len(steps) != 4 || steps[2].Type != sitter.QueryPredicateStepTypeString ||
+				q.StringValueForId(steps[0].ValueId) != "set!" {
+				continue
+			}
+			key := q.StringValueForId(steps[1].ValueId)
+			if key != "name" && key != "signature" {
+				continue
+			}
+			if templates[i] == nil {
+				templates[i] = make(map[string]string)
+			}
+			templates[i][key] = q.StringValueForId(steps[2].ValueId)
+		}
+	}
+	return templates
+}
+
+// captureTemplateRe matches a {capture} placeholder in a template
+var captureTemplateRe = regexp.MustCompile(`\{([\w.]+)\}`)
+
+// expandTemplate replaces the {capture} placeholders of a template with the
+// captured text; captures missing from the match are left empty
+func expandTemplate(template string, captures map[string]string) string {
+	return captureTemplateRe.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return captures[placeholder[1:len(placeholder)-1]]
+	})
+}
+
+// loadQueries compiles the query files of a repository. Errors in the
+// built-in queries are reported here too.
+func (c *RepoConfig) loadQueries(repoRoot string) error {
+	var errs []error
+	if err := loadBuiltinQueries(); err != nil {
+		errs = append(errs, err)
+	}
+	for lang, name := range languageNames {
+		file := filepath.ToSlash(filepath.Join(QueryDir, name+".scm"))
+		source, err := os.ReadFile(filepath.Join(repoRoot, file))
+		if err != nil {
+			continue
+		}
+		q, err := compileQuery(source, lang, file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if c.Queries == nil {
+			c.Queries = make(map[Language]*definitionQuery)
+		}
+		c.Queries[lang] = q
+	}
+	return errors.Join(errs...)
+}
+
+// close releases the compiled query
+func (q *definitionQuery) close() {
+	q.query.Close()
+}
+
+// Close releases the compiled query files of the configuration
+func (c *RepoConfig) Close() {
+	for _, q := range c.Queries {
+		q.close()
+	}
+	c.Queries = nil
+}
+
+// repoQuery returns the repository's query file for a language, if any
+func repoQuery(lang Language) *definitionQuery {
+	if repoConfig == nil {
+		return nil
+	}
+	return repoConfig.Queries[lang]
+}
+
+// parseWithQuery parses content and extracts definitions with a query
+func parseWithQuery(content []byte, lang Language, q *definitionQuery) *FileAnalysis {
+	if q == nil {
+		return &FileAnalysis{ParseError: fmt.Errorf("no definition query for %s", languageNames[lang])}
+	}
+	parser := acquireParser(lang)
+	defer releaseParser(lang, parser)
+
+	tree, err := parser.ParseCtx(context.Background(), nil, content)
+	if err != nil {
+		return &FileAnalysis{ParseError: err}
+	}
+	defer tree.Close()
+
+	return &FileAnalysis{Definitions: q.definitions(tree.RootNode(), content)}
+}
+
+// queryMatch is a definition found by a query, with the pattern that found it
+type queryMatch struct {
+	def     Definition
+	pattern uint16
+}
+
+// definitions runs the query over a syntax tree and returns the definitions
+// in source order
+func (q *definitionQuery) definitions(root *sitter.Node, content []byte) []Definition {
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(q.query, root)
+
+	type nodeRange struct{ start, end uint32 }
+	found := make(map[nodeRange]queryMatch)
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		match = cursor.FilterPredicates(match, content)
+		def, ok := q.definition(match, content)
+		if !ok {
+			continue
+		}
+		key := nodeRange{def.StartByte, def.EndByte}
+		if prev, seen := found[key]; seen && prev.pattern <= match.PatternIndex {
+			continue
+		}
+		found[key] = queryMatch{def: def, pattern: match.PatternIndex}
+	}
+
+	defs := make([]Definition, 0, len(found))
+	for _, m := range found {
+		defs = append(defs, m.def)
+	}
+	sort.SliceStable(defs, func(i, j int) bool {
+		if defs[i].StartByte != defs[j].StartByte {
+			return defs[i].StartByte < defs[j].StartByte
+		}
+		return defs[i].EndByte > defs[j].EndByte
+	})
+	return defs
+}
+
+// definition builds a Definition from the captures of one match
+func (q *definitionQuery) definition(match *sitter.QueryMatch, content []byte) (Definition, bool) {
+	var node, signature *sitter.Node
+	var kind, name, container string
+	captures := make(map[string]string, len(match.Captures))
+	for _, capture := range match.Captures {
+		captureName := q.query.CaptureNameForId(capture.Index)
+		if _, seen := captures[captureName]; !seen {
+			captures[captureName] = capture.Node.Content(content)
+		}
+		switch {
+		case strings.HasPrefix(captureName, "definition."):
+			node = capture.Node
+			kind = strings.TrimPrefix(captureName, "definition.")
+		case captureName == "name":
+			name = capture.Node.Content(content)
+		case captureName == "container.name":
+			container = capture.Node.Content(content)
+		case captureName == "signature":
+			signature = capture.Node
+		}
+	}
+	template := q.templates[uint32(match.PatternIndex)]
+	if nameTemplate, ok := template["name"]; ok {
+		name = expandTemplate(nameTemplate, captures)
+	}
+	name = strings.Trim(name, "\"'")
+	if node == nil || name == "" || kind == "" {
+		return Definition{}, false
+	}
+
+	def := Definition{
+		Name:      name,
+		Kind:      kind,
+		Signature: fmt.Sprintf("%s %s", kind, name),
+		Body:      node.Content(content),
+		StartLine: node.StartPoint().Row,
+		EndLine:   node.EndPoint().Row,
+		StartByte: node.StartByte(),
+		EndByte:   node.EndByte(),
+	}
+	if container != "" {
+		def.Name = container + "." + name
+	}
+	if signature != nil && signature.EndByte() > node.StartByte() && signature.EndByte() <= node.EndByte() {
+		def.Signature = strings.Join(strings.Fields(string(content[node.StartByte():signature.EndByte()])), " ")
+	}
+	if signatureTemplate, ok := template["signature"]; ok {
+		def.Signature = expandTemplate(signatureTemplate, captures)
+	}
+	return def, true
+}
+
+// queryImportLines returns the 0-based lines on which the import statements
+// captured by a language's query start, or nil if the query has none
+func queryImportLines(content []byte, lang Language) map[int]bool {
+	q := repoQuery(lang)
+	if q == nil || q.extends {
+		q = builtinQuery(lang)
+	}
+	if q == nil || !q.hasCapture("import") {
+		return nil
+	}
+
+	parser := acquireParser(lang)
+	defer releaseParser(lang, parser)
+	tree, err := parser.ParseCtx(context.Background(), nil, content)
+	if err != nil {
+		return nil
+	}
+	defer tree.Close()
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(q.query, tree.RootNode())
+
+	lines := make(map[int]bool)
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		for _, capture := range cursor.FilterPredicates(match, content).Captures {
+			if q.query.CaptureNameForId(capture.Index) == "import" {
+				lines[int(capture.Node.StartPoint().Row)] = true
+			}
+		}
+	}
+	return lines
+}
+
+// hasCapture reports whether the query uses a capture name
+func (q *definitionQuery) hasCapture(name string) bool {
+	for i := uint32(0); i < q.query.CaptureCount(); i++ {
+		if q.query.CaptureNameForId(i) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// extendDefinitions adds the definitions of an extending query to the
+// built-in ones; names already defined are kept
+func extendDefinitions(analysis *FileAnalysis, content []byte, lang Language, q *definitionQuery) *FileAnalysis {
+	extra := parseWithQuery(content, lang, q)
+	if extra.ParseError != nil {
+		return extra
+	}
+	known := make(map[string]bool, len(analysis.Definitions))
+	for _, def := range analysis.Definitions {
+		known[def.Name] = true
+	}
+	merged := append([]Definition{}, analysis.Definitions...)
+	for _, def := range extra.Definitions {
+		if !known[def.Name] {
+			merged = append(merged, def)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartByte < merged[j].StartByte
+	})
+	return &FileAnalysis{Definitions: merged}
+}
+
+// hasQueryOverride reports whether the repository changes the extraction
+// of a language, so cached analyses cannot be used
+func hasQueryOverride(lang Language) bool {
+	return repoQuery(lang) != nil
+}
@@ -0,0 +1,48 @@
+; Go definitions. Earlier patterns win when several match the same node, so
+; struct and interface types come before the general type pattern.
+
+(source_file
+  (function_declaration
+    name: (identifier) @name
+    parameters: (parameter_list) @params) @definition.function
+  (#set! signature "func {name}{params}"))
+
+(source_file
+  (method_declaration
+    receiver: (parameter_list) @receiver
+    name: (field_identifier) @name
+    parameters: (parameter_list) @params) @definition.method
+  (#set! signature "func {receiver} {name}{params}"))
+
+(source_file
+  (type_declaration
+    (type_spec
+      name: (type_identifier) @name
+      type: (struct_type)) @definition.struct)
+  (#set! signature "type {name}"))
+
+(source_file
+  (type_declaration
+    (type_spec
+      name: (type_identifier) @name
+      type: (interface_type)) @definition.interface)
+  (#set! signature "type {name}"))
+
+(source_file
+  (type_declaration
+    (type_spec
+      name: (type_identifier) @name) @definition.type)
+  (#set! signature "type {name}"))
+
+; One definition per spec, named after its first identifier
+(source_file
+  (const_declaration
+    (const_spec
+      .
+      name: (identifier) @name) @definition.const))
+
+(source_file
+  (var_declaration
+    (var_spec
+      .
+      name: (identifier) @name) @definition.variable))
@@ -0,0 +1,49 @@
+; Python definitions. Top-level functions, and methods of top-level classes
+; named Class.method. Decorators are not part of the definition, and async
+; functions keep the plain "def name(...)" signature.
+
+(module
+  [
+    (function_definition
+      name: (identifier) @name
+      parameters: (parameters) @params) @definition.function
+    (decorated_definition
+      definition: (function_definition
+        name: (identifier) @name
+        parameters: (parameters) @params) @definition.function)
+  ]
+  (#set! signature "def {name}{params}"))
+
+(module
+  [
+    (class_definition
+      name: (identifier) @container.name
+      body: (block
+        [
+          (function_definition
+            name: (identifier) @name
+            parameters: (parameters) @params) @definition.method
+          (decorated_definition
+            definition: (function_definition
+              name: (identifier) @name
+              parameters: (parameters) @params) @definition.method)
+        ]))
+    (decorated_definition
+      definition: (class_definition
+        name: (identifier) @container.name
+        body: (block
+          [
+            (function_definition
+              name: (identifier) @name
+              parameters: (parameters) @params) @definition.method
+            (decorated_definition
+              definition: (function_definition
+                name: (identifier) @name
+                parameters: (parameters) @params) @definition.method)
+          ])))
+  ]
+  (#set! signature "def {name}{params}"))
+
+; Import statements, at any depth
+(import_statement) @import
+(import_from_statement) @import
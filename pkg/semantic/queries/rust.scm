@@ -0,0 +1,26 @@
+; Rust definitions at the top level of a file. An impl is named after its
+; first plain or generic type: the trait if it is one ("impl Default for
+; Point" is "Default"), otherwise the implementing type.
+
+(source_file
+  (function_item
+    name: (identifier) @name
+    parameters: (parameters) @params) @definition.function
+  (#set! signature "fn {name}{params}"))
+
+(source_file
+  (impl_item
+    trait: [(type_identifier) (generic_type)] @name) @definition.impl
+  (#set! signature "impl {name}"))
+
+(source_file
+  (impl_item
+    type: [(type_identifier) (generic_type)] @name) @definition.impl
+  (#set! signature "impl {name}"))
+
+(source_file (struct_item name: (type_identifier) @name) @definition.struct)
+(source_file (enum_item name: (type_identifier) @name) @definition.enum)
+(source_file (trait_item name: (type_identifier) @name) @definition.trait)
+(source_file (type_item name: (type_identifier) @name) @definition.type)
+(source_file (const_item name: (identifier) @name) @definition.const)
+(source_file (static_item name: (identifier) @name) @definition.static)
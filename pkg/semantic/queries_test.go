@@ -0,0 +1,196 @@
+package semantic
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/simonkoeck/g2/pkg/git"
+)
+
+// definitionSummary lists "kind name" for each definition
+func definitionSummary(defs []Definition) string {
+	var parts []string
+	for _, def := range defs {
+		parts = append(parts, def.Kind+" "+def.Name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// TestBuiltinQueries tests the embedded Python, Go and Rust queries
+func TestBuiltinQueries(t *testing.T) {
+	t.Run("python", func(t *testing.T) {
+		content := []byte(`import os
+
+async def fetch(url,
+                timeout=1):
+    def inner():
+        pass
+    return inner
+
+@dataclass
+class Config:
+    class Meta:
+        def hidden(self): pass
+
+    @property
+    def name(self):
+        return "x"
+`)
+		analysis := parsePython(content)
+		if got := definitionSummary(analysis.Definitions); got != "function fetch, method Config.name" {
+			t.Fatalf("unexpected definitions: %s", got)
+		}
+		if sig := analysis.Definitions[0].Signature; sig != "def fetch(url,\n                timeout=1)" {
+			t.Errorf("unexpected signature: %q", sig)
+		}
+		if sig := analysis.Definitions[1].Signature; sig != "def name(self)" {
+			t.Errorf("unexpected method signature: %q", sig)
+		}
+		if body := analysis.Definitions[1].Body; !strings.HasPrefix(body, "def name") {
+			t.Errorf("decorators should not be part of the body: %q", body)
+		}
+	})
+
+	t.Run("go", func(t *testing.T) {
+		content := []byte(`package p
+
+type (
+	Point struct{ X int }
+	Reader interface{ Read() }
+	ID int
+)
+
+const a, b = 1, 2
+
+func (p Point) Len() int { return p.X }
+
+func New[T any](x T) Point { return Point{} }
+`)
+		analysis := parseGo(content)
+		want := "struct Point, interface Reader, type ID, const a, method Len, function New"
+		if got := definitionSummary(analysis.Definitions); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+		wantSigs := []string{"type Point", "type Reader", "type ID", "const a", "func (p Point) Len()", "func New(x T)"}
+		for i, def := range analysis.Definitions {
+			if def.Signature != wantSigs[i] {
+				t.Errorf("%s: signature %q, want %q", def.Name, def.Signature, wantSigs[i])
+			}
+		}
+	})
+
+	// Impls keep the names of the hand-written walker: the first plain or
+	// generic type, so a path trait gives way to the implementing type
+	t.Run("rust", func(t *testing.T) {
+		content := []byte(`struct Point { x: i32 }
+
+impl Point {
+    fn new() -> Self { Point { x: 0 } }
+}
+
+impl fmt::Display for Point {}
+impl Default for Point {}
+
+fn origin(p: &Point) -> bool { p.x == 0 }
+
+const MAX: i32 = 10;
+`)
+		analysis := parseRust(content)
+		want := "struct Point, impl Point, impl Point, impl Default, function origin, const MAX"
+		if got := definitionSummary(analysis.Definitions); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+		wantSigs := []string{"struct Point", "impl Point", "impl Point", "impl Default", "fn origin(p: &Point)", "const MAX"}
+		for i, def := range analysis.Definitions {
+			if def.Signature != wantSigs[i] {
+				t.Errorf("%s: signature %q, want %q", def.Name, def.Signature, wantSigs[i])
+			}
+		}
+	})
+}
+
+// TestRepoQueries tests query files that extend or replace the built-ins
+func TestRepoQueries(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		".g2/queries/python.scm": `; extends
+; Module constants
+(module
+  (expression_statement
+    (assignment left: (identifier) @name) @definition.constant)
+  (#match? @name "^[A-Z][A-Z0-9_]*$"))
+`,
+		".g2/queries/typescript.scm": `(program (enum_declaration name: (identifier) @name) @definition.enum)
+`,
+		".g2/queries/rust.scm": "(function_item name: @name",
+	})
+	mock := git.NewMockExecutor()
+	mock.SetDefaultError(&exec.ExitError{})
+	withMockExecutor(t, mock)
+
+	config, err := LoadRepoConfig(context.Background(), root)
+	if err == nil || !strings.Contains(err.Error(), ".g2/queries/rust.scm") {
+		t.Errorf("expected an error for the invalid rust query, got %v", err)
+	}
+	if config.Queries[LangRust] != nil || config.Queries[LangPython] == nil || !config.Queries[LangPython].extends {
+		t.Fatalf("unexpected queries: %+v", config.Queries)
+	}
+	withRepoConfig(t, config)
+
+	python := ParseFile([]byte("VERSION = \"1.0\"\nlower = 1\n\ndef main():\n    pass\n"), LangPython)
+	if got := definitionSummary(python.Definitions); got != "constant VERSION, function main" {
+		t.Errorf("extending query: unexpected definitions: %s", got)
+	}
+
+	ts := ParseFile([]byte("enum Color { Red, Green }\nfunction paint() {}\n"), LangTypeScript)
+	if got := definitionSummary(ts.Definitions); got != "enum Color" {
+		t.Errorf("replacing query: unexpected definitions: %s", got)
+	}
+}
+
+// TestCompileQueryFiles tests that a query failing to compile is reported
+// instead of taking the process down, and that the others still load
+func TestCompileQueryFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/go.scm":   {Data: []byte("(source_file (function_declaration name: (identifier) @name) @definition.function)\n")},
+		"queries/rust.scm": {Data: []byte("(function_item name: @name")},
+	}
+	queries, err := compileQueryFiles(fsys, "queries")
+	if err == nil || !strings.Contains(err.Error(), "built-in query rust.scm") {
+		t.Errorf("expected an error for rust.scm, got %v", err)
+	}
+	if queries[LangGo] == nil || queries[LangRust] != nil || queries[LangPython] != nil {
+		t.Fatalf("unexpected queries: %+v", queries)
+	}
+	queries[LangGo].close()
+
+	if analysis := parseWithQuery([]byte("fn main() {}\n"), LangRust, nil); analysis.ParseError == nil {
+		t.Error("expected a parse error without a query")
+	}
+
+	if err := loadBuiltinQueries(); err != nil {
+		t.Errorf("built-in queries should compile: %v", err)
+	}
+}
+
+// TestFindPythonImportUpdates_ImportNodes tests that only import statements
+// are rewritten, not text that looks like one
+func TestFindPythonImportUpdates_ImportNodes(t *testing.T) {
+	moveMap := map[struct{ sourceModule, defName string }]string{
+		{"utils", "helper"}: "newutils",
+	}
+	content := []byte(`"""Usage:
+from utils import helper
+"""
+def f():
+    from utils import helper
+    return helper
+`)
+	updates := findPythonImportUpdates("test.py", content, moveMap)
+	if len(updates) != 1 || updates[0].LineNumber != 5 {
+		t.Fatalf("expected only the import on line 5 to change, got %+v", updates)
+	}
+}
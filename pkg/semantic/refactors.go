@@ -0,0 +1,501 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/ui"
+)
+
+// Refactor kinds
+const (
+	RefactorSplit = "split" // One definition split into several (extract method)
+	RefactorMerge = "merge" // Several definitions merged into one (inline)
+)
+
+// RefactorInfo describes a one-to-many or many-to-one refactor
+type RefactorInfo struct {
+	Kind    string   // RefactorSplit or RefactorMerge
+	Side    string   // "local" or "remote": the branch that did the refactor
+	Sources []string // Definitions that existed in base
+	Targets []string // Definitions that replaced them
+	// ChangedFragment names the definition the other branch's change to the
+	// originals belongs to (empty if the other branch did not modify them)
+	ChangedFragment string
+	Coverage        float64 // Share of tokens accounted for by the fragments
+}
+
+// refactorSide identifies which branch of a conflict a definition lives on
+type refactorSide int
+
+const (
+	sideLocal refactorSide = iota
+	sideRemote
+)
+
+func (s refactorSide) String() string {
+	if s == sideLocal {
+		return "local"
+	}
+	return "remote"
+}
+
+// other returns the opposite side
+func (s refactorSide) other() refactorSide {
+	if s == sideLocal {
+		return sideRemote
+	}
+	return sideLocal
+}
+
+// definitionOn returns the definition of a conflict on one side
+func definitionOn(c *SynthesisConflict, side refactorSide) *Definition {
+	if side == sideLocal {
+		return c.Local
+	}
+	return c.Remote
+}
+
+// isRefactorOriginal reports whether c existed in base and was removed on side
+func isRefactorOriginal(c *SynthesisConflict, side refactorSide) bool {
+	return c.Base != nil && definitionOn(c, side) == nil
+}
+
+// isRefactorFragment reports whether c was added on side only
+func isRefactorFragment(c *SynthesisConflict, side refactorSide) bool {
+	return c.Base == nil && definitionOn(c, side) != nil && definitionOn(c, side.other()) == nil
+}
+
+// refactorTokens returns the token set of a definition body
+func refactorTokens(def *Definition) map[string]bool {
+	set := make(map[string]bool)
+	for _, t := range tokenize(normalize(def.Body)) {
+		set[t] = true
+	}
+	return set
+}
+
+// tokenSets memoizes refactorTokens per definition
+type tokenSets map[*Definition]map[string]bool
+
+func (ts tokenSets) get(def *Definition) map[string]bool {
+	set, ok := ts[def]
+	if !ok {
+		set = refactorTokens(def)
+		ts[def] = set
+	}
+	return set
+}
+
+// changedOn reports whether side modified the base definition of c
+func changedOn(c *SynthesisConflict, side refactorSide) bool {
+	def := definitionOn(c, side)
+	return def != nil && normalize(def.Body) != normalize(c.Base.Body)
+}
+
+// tokenOverlap counts tokens of a that also appear in b
+func tokenOverlap(a, b map[string]bool) int {
+	n := 0
+	for t := range a {
+		if b[t] {
+			n++
+		}
+	}
+	return n
+}
+
+// refactorCandidate is a definition that may take part in a split or merge
+type refactorCandidate struct {
+	index   int // Index into the conflict list
+	tokens  map[string]bool
+	overlap int
+}
+
+// selectFragments picks candidates that are mostly made of whole's tokens
+// and together cover it. Candidates are taken greedily by overlap; each must
+// add tokens not yet covered. It returns the chosen candidates in conflict
+// order and the share of whole's tokens they cover.
+func selectFragments(whole map[string]bool, candidates []refactorCandidate, config MoveDetectionConfig) ([]refactorCandidate, float64) {
+	var eligible []refactorCandidate
+	for _, c := range candidates {
+		if len(c.tokens) == 0 || len(c.tokens) < config.MinTokenCount/2 {
+			continue
+		}
+		c.overlap = tokenOverlap(c.tokens, whole)
+		if float64(c.overlap)/float64(len(c.tokens)) >= config.FragmentContainment {
+			eligible = append(eligible, c)
+		}
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return eligible[i].overlap > eligible[j].overlap
+	})
+
+	covered := make(map[string]bool)
+	var chosen []refactorCandidate
+	for _, c := range eligible {
+		added := 0
+		for t := range c.tokens {
+			if whole[t] && !covered[t] {
+				covered[t] = true
+				added++
+			}
+		}
+		if added > 0 {
+			chosen = append(chosen, c)
+		}
+	}
+
+	sort.Slice(chosen, func(i, j int) bool {
+		return chosen[i].index < chosen[j].index
+	})
+	return chosen, float64(len(covered)) / float64(len(whole))
+}
+
+// DetectRefactors finds definitions split into several (extract method) or
+// several definitions merged into one (inline), judged by token coverage.
+// Each refactor is consolidated into a single conflict carrying RefactorInfo;
+// when the other branch modified an original, the conflict needs resolution
+// and names the fragment the change belongs to.
+func DetectRefactors(conflicts []SynthesisConflict, config MoveDetectionConfig) []SynthesisConflict {
+	if !config.EnableSplitDetection || len(conflicts) < 3 {
+		return conflicts
+	}
+
+	consumed := make(map[int]bool)
+	replaced := make(map[int]SynthesisConflict)
+	tokens := make(tokenSets)
+
+	// One-to-many: an original removed on one side, its code spread over
+	// several definitions added on that side
+	for i := range conflicts {
+		for _, side := range []refactorSide{sideLocal, sideRemote} {
+			if consumed[i] || !isRefactorOriginal(&conflicts[i], side) {
+				continue
+			}
+			if split, parts := detectSplit(conflicts, i, side, consumed, tokens, config); split != nil {
+				for _, idx := range parts {
+					consumed[idx] = true
+				}
+				consumed[i] = true
+				replaced[i] = *split
+			}
+		}
+	}
+
+	// Many-to-one: several originals removed on one side, their code joined
+	// into one definition added on that side
+	for i := range conflicts {
+		for _, side := range []refactorSide{sideLocal, sideRemote} {
+			if consumed[i] || !isRefactorFragment(&conflicts[i], side) {
+				continue
+			}
+			merged, first, rest := detectMerge(conflicts, i, side, consumed, tokens, config)
+			if merged == nil {
+				continue
+			}
+			consumed[i] = true
+			consumed[first] = true
+			replaced[first] = *merged
+			for _, idx := range rest {
+				consumed[idx] = true
+				// A source removed on the local side is already gone from the
+				// canvas; keep it only if the remote change needs resolving
+				if side == sideLocal && !changedOn(&conflicts[idx], side.other()) {
+					continue
+				}
+				replaced[idx] = relabelMergedSource(conflicts[idx], merged, side)
+			}
+		}
+	}
+
+	if len(replaced) == 0 {
+		return conflicts
+	}
+
+	result := make([]SynthesisConflict, 0, len(conflicts))
+	for i, c := range conflicts {
+		if r, ok := replaced[i]; ok {
+			result = append(result, r)
+		} else if !consumed[i] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// detectSplit checks whether the original at index orig was split into
+// definitions added on side. It returns the consolidated conflict and the
+// indices of the fragments.
+func detectSplit(conflicts []SynthesisConflict, orig int, side refactorSide, consumed map[int]bool, tokens tokenSets, config MoveDetectionConfig) (*SynthesisConflict, []int) {
+	original := &conflicts[orig]
+	whole := tokens.get(original.Base)
+	if len(whole) < config.MinTokenCount {
+		return nil, nil
+	}
+
+	var candidates []refactorCandidate
+	for j := range conflicts {
+		if consumed[j] || !isRefactorFragment(&conflicts[j], side) {
+			continue
+		}
+		def := definitionOn(&conflicts[j], side)
+		if def.Kind != original.Base.Kind {
+			continue
+		}
+		candidates = append(candidates, refactorCandidate{index: j, tokens: tokens.get(def)})
+	}
+
+	chosen, coverage := selectFragments(whole, candidates, config)
+	if len(chosen) < 2 || coverage < config.SplitCoverage {
+		return nil, nil
+	}
+
+	var fragments []*Definition
+	var indices []int
+	for _, c := range chosen {
+		fragments = append(fragments, definitionOn(&conflicts[c.index], side))
+		indices = append(indices, c.index)
+	}
+
+	otherSide := definitionOn(original, side.other())
+	changed := changedOn(original, side.other())
+
+	info := &RefactorInfo{
+		Kind:     RefactorSplit,
+		Side:     side.String(),
+		Sources:  []string{original.Base.Name},
+		Targets:  definitionNames(fragments),
+		Coverage: coverage,
+	}
+
+	// Pick the fragment the other side's change belongs to
+	target := fragments[0]
+	if changed {
+		target = fragmentForChange(original.Base, otherSide, fragments)
+		info.ChangedFragment = target.Name
+	}
+
+	split := &SynthesisConflict{
+		UIConflict: ui.Conflict{
+			File:         original.UIConflict.File,
+			ConflictType: formatRefactorConflictType(original.Base.Kind, info, side.other()),
+			Status:       "Can Auto-merge",
+		},
+		Base:     original.Base,
+		Refactor: info,
+	}
+	if changed {
+		split.UIConflict.Status = "Needs Resolution"
+	}
+
+	if side == sideLocal {
+		// Canvas already holds the fragments; conflicts go around the one
+		// the remote change belongs to
+		split.Local = target
+		split.Remote = otherSide
+	} else {
+		// Canvas holds the local original; it is replaced by all fragments
+		split.Local = otherSide
+		split.Remote = joinDefinitions(fragments)
+	}
+
+	return split, indices
+}
+
+// detectMerge checks whether the definition added at index add joins several
+// originals removed on side. It returns the consolidated conflict, the index
+// of the first original (which the conflict replaces) and the indices of the
+// remaining originals.
+func detectMerge(conflicts []SynthesisConflict, add int, side refactorSide, consumed map[int]bool, tokens tokenSets, config MoveDetectionConfig) (*SynthesisConflict, int, []int) {
+	merged := definitionOn(&conflicts[add], side)
+	whole := tokens.get(merged)
+	if len(whole) < config.MinTokenCount {
+		return nil, -1, nil
+	}
+
+	var candidates []refactorCandidate
+	for j := range conflicts {
+		if consumed[j] || !isRefactorOriginal(&conflicts[j], side) {
+			continue
+		}
+		if conflicts[j].Base.Kind != merged.Kind {
+			continue
+		}
+		candidates = append(candidates, refactorCandidate{index: j, tokens: tokens.get(conflicts[j].Base)})
+	}
+
+	chosen, coverage := selectFragments(whole, candidates, config)
+	if len(chosen) < 2 || coverage < config.SplitCoverage {
+		return nil, -1, nil
+	}
+
+	var sources []*Definition
+	var changedSources []string
+	for _, c := range chosen {
+		original := &conflicts[c.index]
+		sources = append(sources, original.Base)
+		if changedOn(original, side.other()) {
+			changedSources = append(changedSources, original.Base.Name)
+		}
+	}
+
+	info := &RefactorInfo{
+		Kind:            RefactorMerge,
+		Side:            side.String(),
+		Sources:         definitionNames(sources),
+		Targets:         []string{merged.Name},
+		ChangedFragment: strings.Join(changedSources, ", "),
+		Coverage:        coverage,
+	}
+
+	first := &conflicts[chosen[0].index]
+	otherFirst := definitionOn(first, side.other())
+	firstChanged := changedOn(first, side.other())
+
+	result := &SynthesisConflict{
+		UIConflict: ui.Conflict{
+			File:         first.UIConflict.File,
+			ConflictType: formatRefactorConflictType(merged.Kind, info, side.other()),
+			Status:       "Can Auto-merge",
+		},
+		Base:     first.Base,
+		Refactor: info,
+	}
+	if firstChanged {
+		result.UIConflict.Status = "Needs Resolution"
+	}
+
+	if side == sideLocal {
+		result.Local = merged
+		result.Remote = otherFirst
+	} else {
+		// The merged definition takes the place of the first original;
+		// the other originals are removed by their own conflicts
+		result.Local = otherFirst
+		result.Remote = merged
+	}
+
+	var rest []int
+	for _, c := range chosen[1:] {
+		rest = append(rest, c.index)
+	}
+	return result, chosen[0].index, rest
+}
+
+// relabelMergedSource marks a non-first original of a merge as part of it.
+// The conflict keeps its definitions, so synthesis still removes it (or asks
+// for resolution if the other side modified it).
+func relabelMergedSource(c SynthesisConflict, merged *SynthesisConflict, side refactorSide) SynthesisConflict {
+	kind := capitalizeFirst(c.Base.Kind)
+	conflictType := fmt.Sprintf("%s '%s' Merged into '%s'", kind, c.Base.Name, merged.Refactor.Targets[0])
+	if changedOn(&c, side.other()) {
+		conflictType += fmt.Sprintf(" (%s change in '%s')", side.other(), c.Base.Name)
+	}
+	c.UIConflict.ConflictType = conflictType
+	c.Refactor = merged.Refactor
+	return c
+}
+
+// formatRefactorConflictType formats the conflict type for a split or merge
+func formatRefactorConflictType(kind string, info *RefactorInfo, otherSide refactorSide) string {
+	kind = capitalizeFirst(kind)
+	var s string
+	if info.Kind == RefactorSplit {
+		s = fmt.Sprintf("%s '%s' Split into %s", kind, info.Sources[0], quoteNames(info.Targets))
+	} else {
+		s = fmt.Sprintf("%s %s Merged into '%s'", kind, quoteNames(info.Sources), info.Targets[0])
+	}
+	if info.ChangedFragment != "" {
+		s += fmt.Sprintf(" (%s change in '%s')", otherSide, info.ChangedFragment)
+	}
+	return s
+}
+
+// quoteNames formats names as 'a', 'b'
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = "'" + n + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// definitionNames returns the names of defs
+func definitionNames(defs []*Definition) []string {
+	names := make([]string, len(defs))
+	for i, d := range defs {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// joinDefinitions combines fragments into one definition whose body holds
+// all of them, in order, separated by a blank line
+func joinDefinitions(defs []*Definition) *Definition {
+	bodies := make([]string, len(defs))
+	for i, d := range defs {
+		bodies[i] = d.Body
+	}
+	return &Definition{
+		Name:      strings.Join(definitionNames(defs), ", "),
+		Kind:      defs[0].Kind,
+		Body:      strings.Join(bodies, "\n\n"),
+		StartLine: defs[0].StartLine,
+		EndLine:   defs[len(defs)-1].EndLine,
+		StartByte: defs[0].StartByte,
+		EndByte:   defs[len(defs)-1].EndByte,
+	}
+}
+
+// fragmentForChange returns the fragment that holds the code the other
+// branch changed. Changed lines are the base lines the other branch removed
+// or rewrote; for pure insertions, the base line just before the insertion.
+func fragmentForChange(base, changed *Definition, fragments []*Definition) *Definition {
+	baseLines := make(map[string]bool)
+	for _, line := range strings.Split(base.Body, "\n") {
+		baseLines[strings.TrimSpace(line)] = true
+	}
+	changedLines := make(map[string]bool)
+	for _, line := range strings.Split(changed.Body, "\n") {
+		changedLines[strings.TrimSpace(line)] = true
+	}
+
+	var touched []string
+	for _, line := range strings.Split(base.Body, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !changedLines[trimmed] {
+			touched = append(touched, trimmed)
+		}
+	}
+	if len(touched) == 0 {
+		// Pure insertion: anchor on the preceding unchanged line
+		anchor := ""
+		for _, line := range strings.Split(changed.Body, "\n") {
+			trimmed := strings.TrimSpace(line)
+			if baseLines[trimmed] {
+				anchor = trimmed
+			} else if trimmed != "" && anchor != "" {
+				touched = append(touched, anchor)
+			}
+		}
+	}
+
+	touchedTokens := make(map[string]bool)
+	for _, line := range touched {
+		for _, t := range tokenize(line) {
+			touchedTokens[t] = true
+		}
+	}
+
+	best := fragments[0]
+	bestScore := -1
+	for _, f := range fragments {
+		score := tokenOverlap(touchedTokens, refactorTokens(f))
+		if score > bestScore {
+			best = f
+			bestScore = score
+		}
+	}
+	return best
+}
@@ -0,0 +1,206 @@
+package semantic
+
+import (
+	"strings"
+	"testing"
+)
+
+const splitBase = `def process_order(order, gateway):
+    if not order.items:
+        raise ValueError("order has no items")
+    if order.customer is None:
+        raise ValueError("order has no customer")
+    total = sum(item.price * item.qty for item in order.items)
+    receipt = gateway.charge(order.customer.card, total)
+    order.mark_paid(receipt.transaction_id)
+    return receipt
+`
+
+const splitLocal = `def validate_order(order):
+    if not order.items:
+        raise ValueError("order has no items")
+    if order.customer is None:
+        raise ValueError("order has no customer")
+
+
+def charge_order(order, gateway):
+    total = sum(item.price * item.qty for item in order.items)
+    receipt = gateway.charge(order.customer.card, total)
+    order.mark_paid(receipt.transaction_id)
+    return receipt
+`
+
+// Remote changes the charging part of the original
+const splitRemoteModified = `def process_order(order, gateway):
+    if not order.items:
+        raise ValueError("order has no items")
+    if order.customer is None:
+        raise ValueError("order has no customer")
+    total = sum(item.price * item.qty for item in order.items)
+    receipt = gateway.charge(order.customer.card, total, currency=order.currency)
+    order.mark_paid(receipt.transaction_id)
+    return receipt
+`
+
+func findRefactorConflict(t *testing.T, conflicts []SynthesisConflict) *SynthesisConflict {
+	t.Helper()
+	for i := range conflicts {
+		if conflicts[i].Refactor != nil {
+			return &conflicts[i]
+		}
+	}
+	t.Fatalf("expected a refactor conflict, got %d conflicts", len(conflicts))
+	return nil
+}
+
+func TestDetectRefactors_SplitLocal(t *testing.T) {
+	analysis := AnalyzeConflictFromContents("orders.py", []byte(splitBase), []byte(splitLocal), []byte(splitBase))
+
+	if len(analysis.Conflicts) != 1 {
+		t.Fatalf("expected 1 consolidated conflict, got %d", len(analysis.Conflicts))
+	}
+	c := findRefactorConflict(t, analysis.Conflicts)
+
+	if c.Refactor.Kind != RefactorSplit || c.Refactor.Side != "local" {
+		t.Errorf("unexpected refactor info: %+v", c.Refactor)
+	}
+	want := "Function 'process_order' Split into 'validate_order', 'charge_order'"
+	if c.UIConflict.ConflictType != want {
+		t.Errorf("expected %q, got %q", want, c.UIConflict.ConflictType)
+	}
+	if c.UIConflict.Status != "Can Auto-merge" {
+		t.Errorf("expected auto-merge when remote left the original alone, got %s", c.UIConflict.Status)
+	}
+
+	merged, allAutoMerged, err := SynthesizeToBytes(analysis)
+	if err != nil {
+		t.Fatalf("synthesis failed: %v", err)
+	}
+	if !allAutoMerged || string(merged) != splitLocal {
+		t.Errorf("expected local split to be kept as-is, got:\n%s", merged)
+	}
+}
+
+func TestDetectRefactors_SplitLocalWithRemoteChange(t *testing.T) {
+	analysis := AnalyzeConflictFromContents("orders.py", []byte(splitBase), []byte(splitLocal), []byte(splitRemoteModified))
+
+	c := findRefactorConflict(t, analysis.Conflicts)
+	if c.UIConflict.Status != "Needs Resolution" {
+		t.Errorf("expected remote modification to need resolution, got %s", c.UIConflict.Status)
+	}
+	if c.Refactor.ChangedFragment != "charge_order" {
+		t.Errorf("expected change to belong to charge_order, got %q", c.Refactor.ChangedFragment)
+	}
+	if !strings.HasSuffix(c.UIConflict.ConflictType, "(remote change in 'charge_order')") {
+		t.Errorf("expected conflict type to name the fragment, got %q", c.UIConflict.ConflictType)
+	}
+	if c.Local == nil || c.Local.Name != "charge_order" {
+		t.Errorf("expected conflict markers around charge_order, got %+v", c.Local)
+	}
+}
+
+func TestDetectRefactors_SplitRemote(t *testing.T) {
+	analysis := AnalyzeConflictFromContents("orders.py", []byte(splitBase), []byte(splitBase), []byte(splitLocal))
+
+	c := findRefactorConflict(t, analysis.Conflicts)
+	if c.Refactor.Side != "remote" || c.UIConflict.Status != "Can Auto-merge" {
+		t.Fatalf("unexpected conflict: %s %s %+v", c.UIConflict.ConflictType, c.UIConflict.Status, c.Refactor)
+	}
+
+	merged, allAutoMerged, err := SynthesizeToBytes(analysis)
+	if err != nil {
+		t.Fatalf("synthesis failed: %v", err)
+	}
+	if !allAutoMerged {
+		t.Error("expected remote split to auto-merge")
+	}
+	out := string(merged)
+	if strings.Contains(out, "def process_order") {
+		t.Errorf("expected original to be replaced, got:\n%s", out)
+	}
+	if !strings.Contains(out, "def validate_order") || !strings.Contains(out, "def charge_order") {
+		t.Errorf("expected both fragments in output, got:\n%s", out)
+	}
+}
+
+func TestDetectRefactors_MergeLocal(t *testing.T) {
+	// Base has the split version; local inlines both into one function
+	analysis := AnalyzeConflictFromContents("orders.py", []byte(splitLocal), []byte(splitBase), []byte(splitLocal))
+
+	c := findRefactorConflict(t, analysis.Conflicts)
+	if c.Refactor.Kind != RefactorMerge || c.Refactor.Side != "local" {
+		t.Fatalf("unexpected refactor info: %+v", c.Refactor)
+	}
+	want := "Function 'validate_order', 'charge_order' Merged into 'process_order'"
+	if c.UIConflict.ConflictType != want {
+		t.Errorf("expected %q, got %q", want, c.UIConflict.ConflictType)
+	}
+
+	merged, allAutoMerged, err := SynthesizeToBytes(analysis)
+	if err != nil {
+		t.Fatalf("synthesis failed: %v", err)
+	}
+	if !allAutoMerged || string(merged) != splitBase {
+		t.Errorf("expected local merge to be kept, got:\n%s", merged)
+	}
+}
+
+func TestDetectRefactors_MergeRemote(t *testing.T) {
+	analysis := AnalyzeConflictFromContents("orders.py", []byte(splitLocal), []byte(splitLocal), []byte(splitBase))
+
+	merged, allAutoMerged, err := SynthesizeToBytes(analysis)
+	if err != nil {
+		t.Fatalf("synthesis failed: %v", err)
+	}
+	if !allAutoMerged {
+		t.Errorf("expected remote merge to auto-merge, conflicts: %+v", conflictTypes(analysis.Conflicts))
+	}
+	out := string(merged)
+	if strings.Contains(out, "def validate_order") || strings.Contains(out, "def charge_order") {
+		t.Errorf("expected sources to be removed, got:\n%s", out)
+	}
+	if !strings.Contains(out, "def process_order") {
+		t.Errorf("expected merged function in output, got:\n%s", out)
+	}
+}
+
+func TestDetectRefactors_Disabled(t *testing.T) {
+	config := DefaultMoveDetectionConfig()
+	config.EnableSplitDetection = false
+
+	raw := []SynthesisConflict{
+		makeDeleteConflict("process_order", splitBase, 0, 100),
+		makeAddConflict("validate_order", strings.Split(splitLocal, "\n\n\n")[0], 0, 50),
+		makeAddConflict("charge_order", strings.Split(splitLocal, "\n\n\n")[1], 50, 100),
+	}
+	if result := DetectMovesWithConfig(raw, config); len(result) != 3 {
+		t.Errorf("expected no consolidation when disabled, got %d conflicts", len(result))
+	}
+	if result := DetectMoves(raw); len(result) != 1 {
+		t.Errorf("expected split to be detected by default, got %d conflicts", len(result))
+	}
+}
+
+func TestDetectRefactors_UnrelatedAdds(t *testing.T) {
+	conflicts := []SynthesisConflict{
+		makeDeleteConflict("process_order", splitBase, 0, 100),
+		makeAddConflict("render_page", "def render_page(request):\n    template = loader.get('page.html')\n    return template.render(context=request.context)\n", 0, 50),
+		makeAddConflict("send_email", "def send_email(to, subject):\n    smtp = SMTP(settings.HOST)\n    smtp.send(to, subject, body=settings.FOOTER)\n", 50, 100),
+	}
+	if result := DetectMoves(conflicts); len(result) != 3 {
+		t.Errorf("expected unrelated adds to stay separate, got %v", conflictTypes(result))
+	}
+}
+
+func TestFragmentForChange(t *testing.T) {
+	base := &Definition{Body: splitBase}
+	changed := &Definition{Body: strings.Replace(splitBase, "    return receipt\n", "    notify(order)\n    return receipt\n", 1)}
+	fragments := []*Definition{
+		{Name: "validate_order", Body: strings.Split(splitLocal, "\n\n\n")[0]},
+		{Name: "charge_order", Body: strings.Split(splitLocal, "\n\n\n")[1]},
+	}
+
+	if got := fragmentForChange(base, changed, fragments); got.Name != "charge_order" {
+		t.Errorf("expected inserted line to belong to charge_order, got %s", got.Name)
+	}
+}
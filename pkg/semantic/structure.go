@@ -0,0 +1,203 @@
+package semantic
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// structuralShape is a multiset of subtree hashes describing the AST of a
+// definition body. Parameters and locally bound names are alpha-renamed in
+// order of first appearance, so renaming them does not change the shape.
+// Free names (called functions, classes, globals) and attribute names keep
+// their text, so handlers with the same shape but different callees differ.
+type structuralShape map[uint64]int
+
+// bindingSites maps node types that bind names to the field holding the
+// bound names; "*" binds every name in the node, e.g. a parameter list.
+// Node types shared between grammars bind the same way in each.
+var bindingSites = map[string]string{
+	// Python
+	"function_definition":  "name",
+	"class_definition":     "name",
+	"parameters":           "*",
+	"lambda_parameters":    "*",
+	"assignment":           "left",
+	"augmented_assignment": "left",
+	"for_statement":        "left",
+	"for_in_clause":        "left",
+	"as_pattern":           "alias",
+	"named_expression":     "name",
+	// Go
+	"function_declaration":  "name",
+	"parameter_list":        "*",
+	"short_var_declaration": "left",
+	"var_spec":              "name",
+	"const_spec":            "name",
+	"range_clause":          "left",
+	// JavaScript and TypeScript
+	"formal_parameters":   "*",
+	"variable_declarator": "name",
+	"arrow_function":      "parameter",
+	"catch_clause":        "parameter",
+	"for_in_statement":    "left",
+	"class_declaration":   "name",
+	// Rust
+	"function_item":      "name",
+	"closure_parameters": "*",
+	"let_declaration":    "pattern",
+	"for_expression":     "pattern",
+}
+
+// nonBindingFields hold expressions inside a binding site, such as default
+// values and type annotations, whose names are not bound
+var nonBindingFields = map[string]bool{
+	"type": true, "value": true, "default": true, "right": true, "body": true, "return_type": true,
+}
+
+// nonBindingNodes are targets that assign to an existing object rather than
+// binding a name, e.g. self.x = 1 or items[0] = 1
+var nonBindingNodes = map[string]bool{
+	"attribute": true, "subscript": true, "call": true,
+	"member_expression": true, "subscript_expression": true,
+	"selector_expression": true, "index_expression": true, "field_expression": true,
+}
+
+// memberNameFields are identifier children that name a member of another
+// object, so they keep their text even if a local has the same name
+var memberNameFields = map[[2]string]bool{
+	{"attribute", "attribute"}:   true, // obj.name
+	{"keyword_argument", "name"}: true, // f(name=value)
+}
+
+// structuralFeatures parses a definition body and returns its shape, or nil
+// if the language has no grammar or the body cannot be parsed
+func structuralFeatures(body string, lang Language) structuralShape {
+	if _, ok := parserPools[lang]; !ok || body == "" {
+		return nil
+	}
+
+	content := []byte(body)
+	parser := acquireParser(lang)
+	defer releaseParser(lang, parser)
+
+	tree, err := parser.ParseCtx(context.Background(), nil, content)
+	if err != nil {
+		return nil
+	}
+	defer tree.Close()
+
+	bound := make(map[string]bool)
+	collectBoundNames(tree.RootNode(), content, bound)
+
+	shape := make(structuralShape)
+	renames := make(map[string]string)
+	hashSubtree(tree.RootNode(), content, bound, renames, false, shape)
+	return shape
+}
+
+// collectBoundNames adds the names bound anywhere under node to bound
+func collectBoundNames(node *sitter.Node, content []byte, bound map[string]bool) {
+	field, isSite := bindingSites[node.Type()]
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if child == nil {
+			continue
+		}
+		if isSite && (field == "*" || node.FieldNameForChild(i) == field) {
+			addBindingNames(child, content, bound)
+		}
+		collectBoundNames(child, content, bound)
+	}
+}
+
+// addBindingNames adds the identifiers of a binding target, such as a
+// parameter, a tuple pattern or a declared variable, to bound
+func addBindingNames(node *sitter.Node, content []byte, bound map[string]bool) {
+	if node.Type() == "identifier" {
+		bound[node.Content(content)] = true
+		return
+	}
+	if nonBindingNodes[node.Type()] {
+		return
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child != nil && !nonBindingFields[node.FieldNameForChild(i)] {
+			addBindingNames(child, content, bound)
+		}
+	}
+}
+
+// hashSubtree hashes node from its type and its children's hashes and adds
+// every named subtree to shape. Bound identifiers hash by their canonical
+// name; other leaves (literals, free names, member names) by their text.
+// member is set when node names a member of another object.
+func hashSubtree(node *sitter.Node, content []byte, bound map[string]bool, renames map[string]string, member bool, shape structuralShape) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(node.Type()))
+
+	count := int(node.ChildCount())
+	if count == 0 && node.IsNamed() {
+		text := node.Content(content)
+		if node.Type() == "identifier" && !member && bound[text] {
+			canonical, ok := renames[text]
+			if !ok {
+				canonical = "$" + strconv.Itoa(len(renames))
+				renames[text] = canonical
+			}
+			text = canonical
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(text))
+	}
+
+	var buf [8]byte
+	for i := 0; i < count; i++ {
+		child := node.Child(i)
+		if child == nil {
+			continue
+		}
+		childMember := memberNameFields[[2]string{node.Type(), node.FieldNameForChild(i)}]
+		childHash := hashSubtree(child, content, bound, renames, childMember, shape)
+		for b := range buf {
+			buf[b] = byte(childHash >> (8 * b))
+		}
+		h.Write(buf[:])
+	}
+
+	sum := h.Sum64()
+	if node.IsNamed() {
+		shape[sum]++
+	}
+	return sum
+}
+
+// calculateStructuralSimilarity computes the weighted min-max similarity of
+// two shapes: matching subtrees count once per shared occurrence
+func calculateStructuralSimilarity(shape1, shape2 structuralShape) float64 {
+	if len(shape1) == 0 && len(shape2) == 0 {
+		return 1.0
+	}
+	if len(shape1) == 0 || len(shape2) == 0 {
+		return 0.0
+	}
+
+	var intersection, union int
+	for hash, count1 := range shape1 {
+		count2 := shape2[hash]
+		intersection += min(count1, count2)
+		union += max(count1, count2)
+	}
+	for hash, count2 := range shape2 {
+		if _, ok := shape1[hash]; !ok {
+			union += count2
+		}
+	}
+
+	if union == 0 {
+		return 0.0
+	}
+	return float64(intersection) / float64(union)
+}
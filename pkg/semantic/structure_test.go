@@ -0,0 +1,174 @@
+package semantic
+
+import (
+	"testing"
+)
+
+const structureOriginal = `def process_order(order, inventory):
+    total = 0
+    for item in order.items:
+        stock = inventory.get(item.sku)
+        if stock is None or stock.count < item.qty:
+            raise ValueError("out of stock: " + item.sku)
+        total += item.price * item.qty
+    return total
+`
+
+// Same function with every local renamed
+const structureRenamedLocals = `def handle_purchase(purchase, warehouse):
+    amount = 0
+    for line in purchase.items:
+        available = warehouse.get(line.sku)
+        if available is None or available.count < line.qty:
+            raise ValueError("out of stock: " + line.sku)
+        amount += line.price * line.qty
+    return amount
+`
+
+// Unrelated handler sharing most identifiers with the original
+const structureSameTokens = `def process_order(order, inventory):
+    if order.items is None:
+        return 0
+    stock = inventory.get(order.sku)
+    total = stock.count
+    item = order.items
+    raise ValueError(item.price, item.qty, total)
+`
+
+func TestStructuralFeatures_AlphaRenaming(t *testing.T) {
+	original := structuralFeatures(structureOriginal, LangPython)
+	renamed := structuralFeatures(structureRenamedLocals, LangPython)
+	if original == nil || renamed == nil {
+		t.Fatal("expected shapes for Python bodies")
+	}
+
+	if sim := calculateStructuralSimilarity(original, renamed); sim != 1.0 {
+		t.Errorf("expected renamed locals to have identical shape, got %.2f", sim)
+	}
+
+	tokenSim := calculateJaccard(tokenize(structureOriginal), tokenize(structureRenamedLocals))
+	if tokenSim >= DefaultMoveDetectionConfig().FuzzyThreshold {
+		t.Errorf("expected token similarity below threshold for renamed locals, got %.2f", tokenSim)
+	}
+}
+
+func TestStructuralFeatures_DifferentShape(t *testing.T) {
+	original := structuralFeatures(structureOriginal, LangPython)
+	unrelated := structuralFeatures(structureSameTokens, LangPython)
+
+	structural := calculateStructuralSimilarity(original, unrelated)
+	tokens := calculateJaccard(tokenize(structureOriginal), tokenize(structureSameTokens))
+
+	if structural >= tokens {
+		t.Errorf("expected structure to separate bodies better than tokens: structural %.2f, tokens %.2f", structural, tokens)
+	}
+	if structural >= DefaultMoveDetectionConfig().FuzzyThreshold {
+		t.Errorf("expected structural similarity below threshold, got %.2f", structural)
+	}
+}
+
+// TestStructuralFeatures_FreeNames checks that only bound names are renamed:
+// callees, classes and attribute names keep their text
+func TestStructuralFeatures_FreeNames(t *testing.T) {
+	create := "def create_user(request):\n    data = request.json()\n    user = User.create(data)\n    return user.to_dict()\n"
+	remove := "def delete_order(request):\n    data = request.args()\n    order = Order.delete(data)\n    return order.serialize()\n"
+	if sim := calculateStructuralSimilarity(structuralFeatures(create, LangPython), structuralFeatures(remove, LangPython)); sim >= DefaultMoveDetectionConfig().FuzzyThreshold {
+		t.Errorf("expected handlers with different callees to differ, got %.2f", sim)
+	}
+
+	// A parameter named like an attribute is renamed; the attribute is not
+	shadowing := "def lookup(get):\n    return cache.get(get)\n"
+	renamed := "def lookup(key):\n    return cache.get(key)\n"
+	if sim := calculateStructuralSimilarity(structuralFeatures(shadowing, LangPython), structuralFeatures(renamed, LangPython)); sim != 1.0 {
+		t.Errorf("expected renamed parameter to keep the shape, got %.2f", sim)
+	}
+
+	for lang, bodies := range map[Language][2]string{
+		LangGo: {
+			"func Create(w http.ResponseWriter, r *http.Request) {\n\tdata, err := decode(r)\n\tif err != nil {\n\t\treturn\n\t}\n\tstore.Save(data)\n}\n",
+			"func Make(out http.ResponseWriter, req *http.Request) {\n\tpayload, e := decode(req)\n\tif e != nil {\n\t\treturn\n\t}\n\tstore.Save(payload)\n}\n",
+		},
+		LangJavaScript: {
+			"function create(req, res) {\n  const data = parse(req.body);\n  for (const k in data) { log(k); }\n  return db.insert(data);\n}\n",
+			"function make(r, s) {\n  const payload = parse(r.body);\n  for (const key in payload) { log(key); }\n  return db.insert(payload);\n}\n",
+		},
+		LangRust: {
+			"fn create(req: &Request) -> Result<()> {\n    let data = parse(req)?;\n    for item in data.iter() { save(item); }\n    Ok(())\n}\n",
+			"fn make(r: &Request) -> Result<()> {\n    let payload = parse(r)?;\n    for x in payload.iter() { save(x); }\n    Ok(())\n}\n",
+		},
+	} {
+		if sim := calculateStructuralSimilarity(structuralFeatures(bodies[0], lang), structuralFeatures(bodies[1], lang)); sim != 1.0 {
+			t.Errorf("language %d: expected renamed locals to keep the shape, got %.2f", lang, sim)
+		}
+	}
+}
+
+func TestStructuralFeatures_Unsupported(t *testing.T) {
+	if shape := structuralFeatures("anything", LangUnknown); shape != nil {
+		t.Error("expected no shape for unknown language")
+	}
+	if shape := structuralFeatures("", LangPython); shape != nil {
+		t.Error("expected no shape for empty body")
+	}
+}
+
+func TestCalculateStructuralSimilarity(t *testing.T) {
+	a := structuralShape{1: 2, 2: 1}
+	b := structuralShape{1: 1, 3: 1}
+
+	// intersection = min(2,1) = 1; union = 2 + 1 + 1 = 4
+	if sim := calculateStructuralSimilarity(a, b); sim != 0.25 {
+		t.Errorf("expected 0.25, got %f", sim)
+	}
+	if sim := calculateStructuralSimilarity(nil, nil); sim != 1.0 {
+		t.Errorf("expected 1.0 for two empty shapes, got %f", sim)
+	}
+	if sim := calculateStructuralSimilarity(a, nil); sim != 0.0 {
+		t.Errorf("expected 0.0 against empty shape, got %f", sim)
+	}
+}
+
+func TestDetectMoves_StructuralSimilarity(t *testing.T) {
+	conflicts := []SynthesisConflict{
+		makeDeleteConflict("process_order", structureOriginal, 0, 100),
+		makeAddConflict("handle_purchase", structureRenamedLocals, 200, 300),
+	}
+
+	tokenConfig := DefaultMoveDetectionConfig()
+	if result := DetectMovesWithConfig(conflicts, tokenConfig); len(result) != 2 {
+		t.Fatalf("expected token similarity to miss the move, got %d conflicts", len(result))
+	}
+
+	structConfig := DefaultMoveDetectionConfig()
+	structConfig.UseStructuralSimilarity = true
+	result := DetectMovesWithConfig(conflicts, structConfig)
+	if len(result) != 1 {
+		t.Fatalf("expected structural similarity to find the move, got %d conflicts", len(result))
+	}
+	if result[0].UIConflict.Status != "Can Auto-merge" {
+		t.Errorf("expected auto-merge status, got %s", result[0].UIConflict.Status)
+	}
+}
+
+func TestDetectInterFileMoves_StructuralSimilarity(t *testing.T) {
+	analyses := []*SynthesisAnalysis{
+		makeAnalysis("orders.py", []SynthesisConflict{makeDeleteConflictForFile("orders.py", "process_order", structureOriginal)}),
+		makeAnalysis("purchases.py", []SynthesisConflict{makeAddConflictForFile("purchases.py", "handle_purchase", structureRenamedLocals)}),
+	}
+
+	config := DefaultMoveDetectionConfig()
+	config.UseStructuralSimilarity = true
+	moves := DetectInterFileMovesWithConfig(analyses, config)
+	if len(moves) != 1 {
+		t.Fatalf("expected 1 inter-file move, got %d", len(moves))
+	}
+	if moves[0].Similarity != 1.0 {
+		t.Errorf("expected similarity 1.0, got %.2f", moves[0].Similarity)
+	}
+}
+
+func BenchmarkStructuralFeatures(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		structuralFeatures(structureOriginal, LangPython)
+	}
+}
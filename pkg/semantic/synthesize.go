@@ -25,15 +25,120 @@ const (
 	UserResolutionBoth                         // Keep both versions
 	UserResolutionBase                         // Keep base version
 	UserResolutionSkip                         // Leave conflict markers for manual editing
+	UserResolutionCustom                       // Replace with SynthesisConflict.CustomBody
 )
 
+// String returns the name of the resolution as used in output
+func (r UserResolution) String() string {
+	switch r {
+	case UserResolutionLocal:
+		return "local"
+	case UserResolutionRemote:
+		return "remote"
+	case UserResolutionBoth:
+		return "both"
+	case UserResolutionBase:
+		return "base"
+	case UserResolutionSkip:
+		return "skip"
+	case UserResolutionCustom:
+		return "custom"
+	default:
+		return "none"
+	}
+}
+
+// ParseUserResolution returns the resolution named by String. Custom is not
+// accepted since it needs a body.
+func ParseUserResolution(name string) (UserResolution, bool) {
+	for r := UserResolutionNone; r <= UserResolutionSkip; r++ {
+		if r.String() == name {
+			return r, true
+		}
+	}
+	return UserResolutionNone, false
+}
+
 // SynthesisConflict carries full definition data for synthesis
 type SynthesisConflict struct {
 	UIConflict     ui.Conflict
-	Local          *Definition    // nil if deleted locally
-	Remote         *Definition    // nil if deleted remotely
-	Base           *Definition    // nil if added in both
-	UserResolution UserResolution // User's resolution choice (if any)
+	Local          *Definition         // nil if deleted locally
+	Remote         *Definition         // nil if deleted remotely
+	Base           *Definition         // nil if added in both
+	UserResolution UserResolution      // User's resolution choice (if any)
+	ResolvedBy     string              // Who chose UserResolution (ResolvedBy*)
+	Refactor       *RefactorInfo       // Set for split/merge refactors
+	Move           *MoveInfo           // Set for moved definitions
+	Members        []SynthesisConflict // Member conflicts of a class handled as one unit
+	Explain        *Explanation        // Decision trace, set with MergeConfig.Explain
+	CustomBody     string              // Replacement of UserResolutionCustom
+}
+
+// Sources of a conflict's resolution
+const (
+	ResolvedByAuto    = "auto"    // Classified as safe and merged automatically
+	ResolvedByTUI     = "tui"     // Chosen in the interactive resolver
+	ResolvedByPolicy  = "policy"  // Decided by a merge policy rule
+	ResolvedByScript  = "script"  // Given with --resolve or --resolutions
+	ResolvedByCommand = "command" // Sent over the --commands channel
+	ResolvedByEditor  = "editor"  // Applied by an editor through g2 rpc
+	ResolvedByPrefer  = "prefer"  // Default side from --prefer or --prefer-kind
+)
+
+// Resolution describes how the conflict is merged: "auto", a user
+// resolution ("local", "remote", "both", "base", "skip", "custom"), "regenerate" or
+// "none" when it is left to conflict markers
+func (c *SynthesisConflict) Resolution() string {
+	switch {
+	case c.UIConflict.Status == "Can Auto-merge":
+		return "auto"
+	case c.UserResolution != UserResolutionNone:
+		return c.UserResolution.String()
+	case c.UIConflict.Status == "Resolved by Policy":
+		return "regenerate"
+	}
+	return "none"
+}
+
+// MergedBody returns the text that replaces the local definition after
+// synthesis ("" if the definition is deleted). ok is false if the conflict
+// is not merged by g2 (conflict markers or a regenerate rule).
+func (c *SynthesisConflict) MergedBody() (body string, ok bool) {
+	resolution := c.Resolution()
+	if resolution == "none" || resolution == "skip" || resolution == "regenerate" {
+		return "", false
+	}
+
+	// Apply the conflict to a canvas holding only the local definition
+	var canvas []byte
+	single := *c
+	if c.Local != nil {
+		local := *c.Local
+		canvas = []byte(local.Body)
+		local.StartByte, local.EndByte = 0, uint32(len(canvas))
+		single.Local = &local
+	}
+	if c.Base != nil {
+		base := *c.Base
+		base.StartByte, base.EndByte = 0, 0
+		single.Base = &base
+	}
+	if resolution == "auto" {
+		return string(applyAutoMerge(canvas, &single)), true
+	}
+	return string(applyUserResolution(canvas, &single)), true
+}
+
+// ResolutionSource returns who resolved the conflict (ResolvedBy*), or ""
+// if it is unresolved
+func (c *SynthesisConflict) ResolutionSource() string {
+	if c.UIConflict.Status == "Can Auto-merge" {
+		return ResolvedByAuto
+	}
+	if c.Resolution() == "none" {
+		return ""
+	}
+	return c.ResolvedBy
 }
 
 // SynthesisAnalysis contains all data needed to synthesize a file
@@ -41,7 +146,8 @@ type SynthesisAnalysis struct {
 	File         string
 	Language     Language
 	Conflicts    []SynthesisConflict
-	LocalContent []byte // the "canvas" for synthesis
+	LocalContent []byte      // the "canvas" for synthesis
+	Regenerate   *PolicyRule // Set when a regenerate rule takes over the file
 }
 
 // SynthesisResult contains the outcome of synthesizing a file
@@ -52,6 +158,10 @@ type SynthesisResult struct {
 	Error          error
 	ConflictCount  int
 	AutoMergeCount int
+	Written        bool                // The merged file was written to the working tree
+	Staged         bool                // The merged file was staged
+	Rules          []PolicyMatch       // Policy rules that decided conflicts
+	Conflicts      []SynthesisConflict // Conflicts as synthesized (containers expanded)
 }
 
 // MergeConfig controls synthesis behavior
@@ -59,10 +169,25 @@ type MergeConfig struct {
 	DryRun       bool          // If true, print proposed changes but don't write
 	CreateBackup bool          // If true, create .orig backup (default: true)
 	Verbose      bool          // If true, print detailed progress
-	JSONOutput   bool          // If true, output JSON instead of human-readable text
+	JSONOutput   bool          // If true, output JSON (or Format) instead of human-readable text
+	Format       string        // Machine-readable output format: json, sarif or junit
+	Report       string        // Path of a Markdown or HTML merge report to write
+	Explain      bool          // If true, record and print how each conflict was classified
+	Events       string        // Progress event stream format (ndjson), empty to disable
+	EventsFD     int           // File descriptor for events (0 = stdout)
+	Commands     string        // Command channel format on stdin (ndjson), empty to disable
+	Interactive  string        // Interactive resolver: tui (default) or prompt
+	Resolve      []string      // --resolve values, FILE:NAME=RESOLUTION
+	Resolutions  string        // Path of a --resolutions file
+	Prefer       string        // Side kept for unresolved conflicts (--prefer), local or remote
+	PreferKinds  []string      // --prefer-kind values, KIND:SIDE
 	LogLevel     string        // Log level: debug, info, warn, error
 	GitTimeout   time.Duration // Timeout for git operations (0 = use default)
 	MaxFileSize  int64         // Maximum file size to process (0 = unlimited)
+	Jobs         int           // Files analyzed/synthesized in parallel (default: CPU count)
+
+	OnAnalyzed    func(*SynthesisAnalysis) // Called by the workers as each file is analyzed (may run concurrently)
+	OnSynthesized func(*SynthesisResult)   // Called as each file is written and staged, in order
 }
 
 // DefaultMergeConfig returns safe defaults
@@ -75,6 +200,7 @@ func DefaultMergeConfig() MergeConfig {
 		LogLevel:     "warn",
 		GitTimeout:   git.DefaultTimeout,
 		MaxFileSize:  0,
+		Jobs:         DefaultJobs(),
 	}
 }
 
@@ -85,16 +211,29 @@ type RangeCollision struct {
 }
 
 // AnalyzeConflictForSynthesis analyzes a conflicting file and returns full synthesis data
-func AnalyzeConflictForSynthesis(file string) *SynthesisAnalysis {
+func AnalyzeConflictForSynthesis(file string, config MergeConfig) *SynthesisAnalysis {
+	return AnalyzeVersionsForSynthesis(file, getConflictVersions(context.Background(), file), config)
+}
+
+// AnalyzeVersionsForSynthesis analyzes a conflicting file from already-loaded
+// stage contents (see LoadConflictVersions) and returns full synthesis data.
+// Conflicts carry an Explanation if config.Explain is set.
+func AnalyzeVersionsForSynthesis(file string, versions *ConflictVersions, config MergeConfig) *SynthesisAnalysis {
+	result := analyzeVersions(file, versions, config.Explain)
+	ApplyPolicy(result)
+	return result
+}
+
+// analyzeVersions analyzes the stage contents of a conflicting file
+func analyzeVersions(file string, versions *ConflictVersions, explain bool) *SynthesisAnalysis {
 	result := &SynthesisAnalysis{
 		File:     file,
 		Language: DetectLanguage(file),
 	}
 
-	// Get all three versions
-	baseContent, baseErr := GetFileVersion(file, 1)
-	localContent, localErr := GetFileVersion(file, 2)
-	remoteContent, remoteErr := GetFileVersion(file, 3)
+	baseContent, baseErr := versions.Base, versions.BaseErr
+	localContent, localErr := versions.Local, versions.LocalErr
+	remoteContent, remoteErr := versions.Remote, versions.RemoteErr
 
 	// Store local content as the canvas
 	if localErr == nil {
@@ -164,32 +303,23 @@ func AnalyzeConflictForSynthesis(file string) *SynthesisAnalysis {
 	localDefs := mapDefinitions(localAnalysis.Definitions)
 	remoteDefs := mapDefinitions(remoteAnalysis.Definitions)
 
-	// Find all unique definition names
-	allNames := make(map[string]bool)
-	for name := range baseDefs {
-		allNames[name] = true
-	}
-	for name := range localDefs {
-		allNames[name] = true
-	}
-	for name := range remoteDefs {
-		allNames[name] = true
-	}
-
-	// Analyze each definition
-	for name := range allNames {
+	// Analyze each definition in source order (stable output)
+	for _, name := range sortedDefinitionNames(baseDefs, localDefs, remoteDefs) {
 		baseDef := baseDefs[name]
 		localDef := localDefs[name]
 		remoteDef := remoteDefs[name]
 
 		conflict := analyzeSynthesisConflict(file, name, baseDef, localDef, remoteDef, result.Language)
 		if conflict != nil {
+			if !explain {
+				conflict.Explain = nil
+			}
 			result.Conflicts = append(result.Conflicts, *conflict)
 		}
 	}
 
 	// Detect and consolidate move operations (delete + add of same definition)
-	result.Conflicts = DetectMoves(result.Conflicts)
+	result.Conflicts = DetectMovesWithConfig(result.Conflicts, MoveConfigFor(file))
 
 	return result
 }
@@ -211,19 +341,19 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 	// Normalize bodies for semantic comparison (language-aware: strips comments, normalizes whitespace)
 	var baseNorm, localNorm, remoteNorm string
 	if base != nil {
-		baseNorm = normalizeForLanguage(base.Body, lang)
+		baseNorm = normalizeDefinition(base, lang)
 	}
 	if local != nil {
-		localNorm = normalizeForLanguage(local.Body, lang)
+		localNorm = normalizeDefinition(local, lang)
 	}
 	if remote != nil {
-		remoteNorm = normalizeForLanguage(remote.Body, lang)
+		remoteNorm = normalizeDefinition(remote, lang)
 	}
 
 	// Case 1: Added in both branches (didn't exist in base)
 	if base == nil && local != nil && remote != nil {
 		if localNorm == remoteNorm {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s Added (identical)", kindStr),
@@ -232,9 +362,9 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  local,
 				Remote: remote,
 				Base:   nil,
-			}
+			}).explain("added on both sides; normalized bodies are identical", baseNorm, localNorm, remoteNorm)
 		}
-		return &SynthesisConflict{
+		return (&SynthesisConflict{
 			UIConflict: ui.Conflict{
 				File:         file,
 				ConflictType: fmt.Sprintf("%s '%s' Added (differs)", kindStr, name),
@@ -243,12 +373,12 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 			Local:  local,
 			Remote: remote,
 			Base:   nil,
-		}
+		}).explain("added on both sides; normalized bodies differ", baseNorm, localNorm, remoteNorm)
 	}
 
 	// Case 1b: Added only in local (orphan add - for move detection)
 	if base == nil && local != nil && remote == nil {
-		return &SynthesisConflict{
+		return (&SynthesisConflict{
 			UIConflict: ui.Conflict{
 				File:         file,
 				ConflictType: fmt.Sprintf("%s '%s' Added (local)", kindStr, name),
@@ -257,7 +387,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 			Local:  local,
 			Remote: nil,
 			Base:   nil,
-		}
+		}).explain("added locally only", baseNorm, localNorm, remoteNorm)
 	}
 
 	// Case 1c: Added only in remote
@@ -266,11 +396,13 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 	// they must be inserted inside their parent structure
 	if base == nil && local == nil && remote != nil {
 		status := "Can Auto-merge"
+		rule := "added remotely only; top-level definitions are appended"
 		if strings.Contains(name, ".") {
 			// This is a method or nested definition - can't auto-append
 			status = "Needs Resolution"
+			rule = "added remotely only; nested definitions cannot be appended"
 		}
-		return &SynthesisConflict{
+		return (&SynthesisConflict{
 			UIConflict: ui.Conflict{
 				File:         file,
 				ConflictType: fmt.Sprintf("%s '%s' Added (remote)", kindStr, name),
@@ -279,7 +411,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 			Local:  nil,
 			Remote: remote,
 			Base:   nil,
-		}
+		}).explain(rule, baseNorm, localNorm, remoteNorm)
 	}
 
 	// Case 2: Removed in one branch, modified in other
@@ -287,7 +419,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 		// Deleted on both branches - this is actually agreement, auto-mergeable
 		// (unless move detection later matches it with an add)
 		if local == nil && remote == nil {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Deleted (both)", kindStr, name),
@@ -296,11 +428,11 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  nil,
 				Remote: nil,
 				Base:   base,
-			}
+			}).explain("deleted on both sides", baseNorm, localNorm, remoteNorm)
 		}
 		// Deleted locally, modified remotely - conflict
 		if local == nil && remote != nil && remoteNorm != baseNorm {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Delete/Modify", kindStr, name),
@@ -309,11 +441,11 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  nil,
 				Remote: remote,
 				Base:   base,
-			}
+			}).explain("deleted locally; normalized remote body differs from base", baseNorm, localNorm, remoteNorm)
 		}
 		// Deleted locally, unchanged remotely - auto-merge (accept deletion)
 		if local == nil && remote != nil && remoteNorm == baseNorm {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Deleted (local)", kindStr, name),
@@ -322,11 +454,11 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  nil,
 				Remote: remote,
 				Base:   base,
-			}
+			}).explain("deleted locally; normalized remote body equals base", baseNorm, localNorm, remoteNorm)
 		}
 		// Deleted remotely, modified locally - conflict
 		if remote == nil && local != nil && localNorm != baseNorm {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Modify/Delete", kindStr, name),
@@ -335,11 +467,11 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  local,
 				Remote: nil,
 				Base:   base,
-			}
+			}).explain("deleted remotely; normalized local body differs from base", baseNorm, localNorm, remoteNorm)
 		}
 		// Deleted remotely, unchanged locally - auto-merge (accept deletion)
 		if remote == nil && local != nil && localNorm == baseNorm {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Deleted (remote)", kindStr, name),
@@ -348,7 +480,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  local,
 				Remote: nil,
 				Base:   base,
-			}
+			}).explain("deleted remotely; normalized local body equals base", baseNorm, localNorm, remoteNorm)
 		}
 	}
 
@@ -364,7 +496,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 		if localTextChanged && remoteTextChanged {
 			// Check if bodies are exactly identical
 			if local.Body == remote.Body {
-				return &SynthesisConflict{
+				return (&SynthesisConflict{
 					UIConflict: ui.Conflict{
 						File:         file,
 						ConflictType: fmt.Sprintf("%s '%s' Modified (same)", kindStr, name),
@@ -373,7 +505,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 					Local:  local,
 					Remote: remote,
 					Base:   base,
-				}
+				}).explain("changed on both sides; bodies are textually identical", baseNorm, localNorm, remoteNorm)
 			}
 			// Check if semantically identical but different formatting/comments
 			if localNorm == remoteNorm {
@@ -381,11 +513,13 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				localBasic := normalize(local.Body)
 				remoteBasic := normalize(remote.Body)
 				conflictType := fmt.Sprintf("%s '%s' Formatted Change", kindStr, name)
+				rule := "changed on both sides; normalized bodies are identical, whitespace differs"
 				if localBasic != remoteBasic {
 					// Basic normalization differs but language-aware is same = comment-only change
 					conflictType = fmt.Sprintf("%s '%s' Comment Change", kindStr, name)
+					rule = "changed on both sides; normalized bodies are identical, comments differ"
 				}
-				return &SynthesisConflict{
+				return (&SynthesisConflict{
 					UIConflict: ui.Conflict{
 						File:         file,
 						ConflictType: conflictType,
@@ -394,9 +528,9 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 					Local:  local,
 					Remote: remote,
 					Base:   base,
-				}
+				}).explain(rule, baseNorm, localNorm, remoteNorm)
 			}
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Modified", kindStr, name),
@@ -405,12 +539,12 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  local,
 				Remote: remote,
 				Base:   base,
-			}
+			}).explain("changed on both sides; normalized bodies differ", baseNorm, localNorm, remoteNorm)
 		}
 
 		// Only remote changed semantically - auto-mergeable
 		if remoteSemanticChanged && !localSemanticChanged {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Updated (remote)", kindStr, name),
@@ -419,12 +553,12 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  local,
 				Remote: remote,
 				Base:   base,
-			}
+			}).explain("only the remote normalized body differs from base", baseNorm, localNorm, remoteNorm)
 		}
 
 		// Only local changed semantically - auto-mergeable (keep local as-is)
 		if localSemanticChanged && !remoteSemanticChanged {
-			return &SynthesisConflict{
+			return (&SynthesisConflict{
 				UIConflict: ui.Conflict{
 					File:         file,
 					ConflictType: fmt.Sprintf("%s '%s' Updated (local)", kindStr, name),
@@ -433,7 +567,7 @@ func analyzeSynthesisConflict(file, name string, base, local, remote *Definition
 				Local:  local,
 				Remote: remote,
 				Base:   base,
-			}
+			}).explain("only the local normalized body differs from base", baseNorm, localNorm, remoteNorm)
 		}
 	}
 
@@ -450,31 +584,53 @@ func capitalizeFirst(s string) string {
 
 // SynthesizeFile applies synthesis to rewrite the file on disk
 func SynthesizeFile(analysis *SynthesisAnalysis, config MergeConfig) *SynthesisResult {
+	return finishSynthesis(analysis, config, prepareSynthesis(analysis, config))
+}
+
+// pendingSynthesis is a file merged in memory but not yet written
+type pendingSynthesis struct {
+	result *SynthesisResult
+	canvas []byte
+	done   bool // result is final; nothing to write or run
+}
+
+// prepareSynthesis merges a file's conflicts in memory. It runs no external
+// commands and touches neither the working tree nor the index, so files can
+// be prepared in parallel.
+func prepareSynthesis(analysis *SynthesisAnalysis, config MergeConfig) *pendingSynthesis {
 	result := &SynthesisResult{
 		File:    analysis.File,
 		Success: true,
+		Rules:   policyMatches(analysis),
 	}
 
 	if len(analysis.Conflicts) == 0 {
 		result.AllAutoMerged = true
-		return result
+		return &pendingSynthesis{result: result, done: true}
+	}
+
+	// A regenerate rule rebuilds the file instead, when it is finished
+	if analysis.Regenerate != nil {
+		return &pendingSynthesis{result: result}
 	}
 
 	if len(analysis.LocalContent) == 0 {
 		result.Success = false
 		result.Error = fmt.Errorf("no local content available for synthesis")
-		return result
+		return &pendingSynthesis{result: result, done: true}
 	}
 
+	// Classes moved as a unit are applied member by member
+	workingConflicts := expandContainerMoves(analysis.Conflicts)
+
 	// Check for range collisions before processing
-	collisions := detectCollisions(analysis.Conflicts)
-	workingConflicts := analysis.Conflicts
+	collisions := detectCollisions(workingConflicts)
 	if len(collisions) > 0 {
 		if config.Verbose {
 			ui.Warning(fmt.Sprintf("Detected %d range collision(s) in %s", len(collisions), analysis.File))
 		}
 		// Handle collisions by wrapping outer ranges and skipping inner conflicts
-		workingConflicts = handleCollisions(analysis.Conflicts, collisions)
+		workingConflicts = handleCollisions(workingConflicts, collisions)
 	}
 
 	// Sort conflicts by start byte descending (process from end to beginning)
@@ -510,35 +666,66 @@ func SynthesizeFile(analysis *SynthesisAnalysis, config MergeConfig) *SynthesisR
 	}
 
 	result.AllAutoMerged = allAutoMerged
+	result.Conflicts = workingConflicts
+	return &pendingSynthesis{result: result, canvas: canvas}
+}
+
+// finishSynthesis formats, writes and stages a prepared file, or runs its
+// regenerate rule. It runs external commands and git, so files are finished
+// one at a time.
+func finishSynthesis(analysis *SynthesisAnalysis, config MergeConfig, pending *pendingSynthesis) *SynthesisResult {
+	result := pending.result
+	if pending.done {
+		return result
+	}
+	if analysis.Regenerate != nil {
+		return regenerateFile(analysis, config, result)
+	}
+	canvas := pending.canvas
+
+	// Run the configured formatter once no conflict markers remain
+	if result.AllAutoMerged {
+		canvas = formatMerged(analysis.File, canvas)
+	}
 
 	// Dry-run mode: print diff but don't write
 	if config.DryRun {
-		printDryRunDiff(analysis.File, analysis.LocalContent, canvas)
+		if !config.JSONOutput {
+			printDryRunDiff(analysis.File, analysis.LocalContent, canvas)
+		}
 		// In dry-run mode, don't mark as auto-merged since we didn't write anything
 		result.AllAutoMerged = false
 		return result
 	}
 
 	// Write result to file using atomic write with backup
-	if err := atomicWriteWithBackup(analysis.File, canvas, config.CreateBackup); err != nil {
+	createBackup := config.CreateBackup && settingsFor(analysis.File).backupEnabled()
+	if err := atomicWriteWithBackup(analysis.File, canvas, createBackup); err != nil {
 		result.Success = false
 		result.Error = err
 		return result
 	}
+	result.Written = true
 
 	// If all conflicts were auto-merged, stage the file
-	if allAutoMerged {
-		if err := gitExec.Run(context.Background(), "add", analysis.File); err != nil {
+	if result.AllAutoMerged {
+		if err := stageFile(context.Background(), analysis.File); err != nil {
 			logging.Error("failed to stage file", "file", analysis.File, "error", err)
 			result.Success = false
 			result.Error = fmt.Errorf("failed to stage file: %w", err)
 			return result
 		}
+		result.Staged = true
 	}
 
 	return result
 }
 
+// stageFile stages a file with `git add`
+func stageFile(ctx context.Context, file string) error {
+	return gitExec.Run(ctx, "add", file)
+}
+
 // getConflictStartByte returns the start byte position for a conflict
 func getConflictStartByte(conflict *SynthesisConflict) uint32 {
 	// Use local definition position if available (since we're editing local content)
@@ -677,6 +864,8 @@ func applyUserResolution(canvas []byte, conflict *SynthesisConflict) []byte {
 		if conflict.Base != nil {
 			replacement = conflict.Base.Body
 		}
+	case UserResolutionCustom:
+		replacement = strings.TrimRight(conflict.CustomBody, "\n")
 	default:
 		return canvas
 	}
@@ -801,10 +990,11 @@ func detectCollisions(conflicts []SynthesisConflict) []RangeCollision {
 func handleCollisions(conflicts []SynthesisConflict, collisions []RangeCollision) []SynthesisConflict {
 	// Build sets for inner (to skip) and outer (to mark) conflict positions
 	skipSet := make(map[uint32]bool)
-	markSet := make(map[uint32]bool)
+	markSet := make(map[uint32][]string) // Outer start -> conflict types it subsumes
 	for _, collision := range collisions {
 		skipSet[getConflictStartByte(collision.Inner)] = true
-		markSet[getConflictStartByte(collision.Outer)] = true
+		outer := getConflictStartByte(collision.Outer)
+		markSet[outer] = append(markSet[outer], collision.Inner.UIConflict.ConflictType)
 	}
 
 	// Filter out inner conflicts and mark outer conflicts
@@ -817,32 +1007,45 @@ func handleCollisions(conflicts []SynthesisConflict, collisions []RangeCollision
 		}
 		// Make a copy to avoid mutating the original
 		resultConflict := conflict
-		if markSet[startByte] {
+		if inner, ok := markSet[startByte]; ok {
 			// Mark outer conflict as collision
 			resultConflict.UIConflict.Status = "Needs Resolution"
 			resultConflict.UIConflict.ConflictType += " (Collision Detected)"
+			for _, conflictType := range inner {
+				resultConflict.note("collision: overlaps %q, which is folded into this conflict; needs manual resolution", conflictType)
+			}
 		}
 		result = append(result, resultConflict)
 	}
 	return result
 }
 
+// backupFile copies filename to filename.orig. Only creates a backup if the
+// original exists and the backup doesn't.
+func backupFile(filename string) error {
+	backupPath := filename + ".orig"
+	if _, err := os.Stat(filename); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(backupPath); !os.IsNotExist(err) {
+		return nil
+	}
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		return formatWriteError(filename, err, "read original for backup")
+	}
+	if err := os.WriteFile(backupPath, original, 0644); err != nil {
+		return fmt.Errorf("cannot create backup %s.orig: %w", filename, err)
+	}
+	return nil
+}
+
 // atomicWriteWithBackup safely writes content with backup
 func atomicWriteWithBackup(filename string, content []byte, createBackup bool) error {
 	// Step A: Create backup if requested
 	if createBackup {
-		backupPath := filename + ".orig"
-		// Only create backup if original exists and backup doesn't
-		if _, err := os.Stat(filename); err == nil {
-			if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-				original, err := os.ReadFile(filename)
-				if err != nil {
-					return formatWriteError(filename, err, "read original for backup")
-				}
-				if err := os.WriteFile(backupPath, original, 0644); err != nil {
-					return fmt.Errorf("cannot create backup %s.orig: %w", filename, err)
-				}
-			}
+		if err := backupFile(filename); err != nil {
+			return err
 		}
 	}
 
@@ -861,6 +1064,14 @@ func atomicWriteWithBackup(filename string, content []byte, createBackup bool) e
 		}
 	}()
 
+	// Keep the permissions of the file being replaced
+	if info, err := os.Stat(filename); err == nil {
+		if err := tempFile.Chmod(info.Mode().Perm()); err != nil {
+			tempFile.Close()
+			return formatWriteError(filename, err, "set temp file permissions")
+		}
+	}
+
 	if _, err := tempFile.Write(content); err != nil {
 		tempFile.Close()
 		return formatWriteError(filename, err, "write temp file")
@@ -950,6 +1161,13 @@ func printDryRunDiff(filename string, original, proposed []byte) {
 // AnalyzeConflictFromContents analyzes a file conflict from raw byte contents
 // This is used by the merge driver which receives file contents directly from Git
 func AnalyzeConflictFromContents(filePath string, baseContent, localContent, remoteContent []byte) *SynthesisAnalysis {
+	result := analyzeContents(filePath, baseContent, localContent, remoteContent, false)
+	ApplyPolicy(result)
+	return result
+}
+
+// analyzeContents analyzes the three versions of a conflicting file
+func analyzeContents(filePath string, baseContent, localContent, remoteContent []byte, explain bool) *SynthesisAnalysis {
 	result := &SynthesisAnalysis{
 		File:         filePath,
 		Language:     DetectLanguage(filePath),
@@ -997,32 +1215,23 @@ func AnalyzeConflictFromContents(filePath string, baseContent, localContent, rem
 	localDefs := mapDefinitions(localAnalysis.Definitions)
 	remoteDefs := mapDefinitions(remoteAnalysis.Definitions)
 
-	// Find all unique definition names
-	allNames := make(map[string]bool)
-	for name := range baseDefs {
-		allNames[name] = true
-	}
-	for name := range localDefs {
-		allNames[name] = true
-	}
-	for name := range remoteDefs {
-		allNames[name] = true
-	}
-
-	// Analyze each definition
-	for name := range allNames {
+	// Analyze each definition in source order (stable output)
+	for _, name := range sortedDefinitionNames(baseDefs, localDefs, remoteDefs) {
 		baseDef := baseDefs[name]
 		localDef := localDefs[name]
 		remoteDef := remoteDefs[name]
 
 		conflict := analyzeSynthesisConflict(filePath, name, baseDef, localDef, remoteDef, result.Language)
 		if conflict != nil {
+			if !explain {
+				conflict.Explain = nil
+			}
 			result.Conflicts = append(result.Conflicts, *conflict)
 		}
 	}
 
 	// Detect and consolidate move operations
-	result.Conflicts = DetectMoves(result.Conflicts)
+	result.Conflicts = DetectMovesWithConfig(result.Conflicts, MoveConfigFor(filePath))
 
 	return result
 }
@@ -1038,11 +1247,18 @@ func SynthesizeToBytes(analysis *SynthesisAnalysis) ([]byte, bool, error) {
 		return nil, false, fmt.Errorf("no local content available for synthesis")
 	}
 
+	// Regenerate commands run on the working tree, after the merge
+	if analysis.Regenerate != nil {
+		logging.Warn("leaving conflict markers for regenerate rule", "file", analysis.File, "rule", analysis.Regenerate.Name)
+	}
+
+	// Classes moved as a unit are applied member by member
+	workingConflicts := expandContainerMoves(analysis.Conflicts)
+
 	// Check for range collisions before processing
-	collisions := detectCollisions(analysis.Conflicts)
-	workingConflicts := analysis.Conflicts
+	collisions := detectCollisions(workingConflicts)
 	if len(collisions) > 0 {
-		workingConflicts = handleCollisions(analysis.Conflicts, collisions)
+		workingConflicts = handleCollisions(workingConflicts, collisions)
 	}
 
 	// Sort conflicts by start byte descending (process from end to beginning)
@@ -1071,5 +1287,20 @@ func SynthesizeToBytes(analysis *SynthesisAnalysis) ([]byte, bool, error) {
 		}
 	}
 
+	if allAutoMerged {
+		canvas = formatMerged(analysis.File, canvas)
+	}
+
 	return canvas, allAutoMerged, nil
 }
+
+// formatMerged runs the configured formatter over merged content. If the
+// formatter fails, the unformatted content is kept.
+func formatMerged(file string, content []byte) []byte {
+	formatted, err := runFormatter(file, content)
+	if err != nil {
+		logging.Warn("formatter failed, keeping unformatted result", "file", file, "error", err)
+		return content
+	}
+	return formatted
+}
@@ -1755,3 +1755,37 @@ server:
 		t.Error("expected result to contain logging level")
 	}
 }
+
+// TestMergedBody tests the per-definition result used by merge reports
+func TestMergedBody(t *testing.T) {
+	def := func(body string, start uint32) *Definition {
+		return &Definition{Name: "f", Kind: "function", Body: body, StartByte: start, EndByte: start + uint32(len(body))}
+	}
+	conflict := func(status string, base, local, remote *Definition, resolution UserResolution) *SynthesisConflict {
+		c := &SynthesisConflict{Base: base, Local: local, Remote: remote, UserResolution: resolution}
+		c.UIConflict.Status = status
+		return c
+	}
+	base, local, remote := def("def f(): return 1", 40), def("def f(): return 2", 60), def("def f(): return 3", 10)
+
+	tests := []struct {
+		name   string
+		c      *SynthesisConflict
+		want   string
+		merged bool
+	}{
+		{"remote update", conflict("Can Auto-merge", base, def("def f(): return 1", 60), remote, UserResolutionNone), "def f(): return 3", true},
+		{"local update", conflict("Can Auto-merge", base, local, def("def f(): return 1", 5), UserResolutionNone), "def f(): return 2", true},
+		{"remote deletion", conflict("Can Auto-merge", base, def("def f(): return 1", 60), nil, UserResolutionNone), "", true},
+		{"both", conflict("Needs Resolution", base, local, remote, UserResolutionBoth), "def f(): return 2\n\ndef f(): return 3", true},
+		{"base", conflict("Needs Resolution", base, nil, remote, UserResolutionBase), "def f(): return 1", true},
+		{"unresolved", conflict("Needs Resolution", base, local, remote, UserResolutionNone), "", false},
+		{"skipped", conflict("Needs Resolution", base, local, remote, UserResolutionSkip), "", false},
+	}
+	for _, tt := range tests {
+		got, merged := tt.c.MergedBody()
+		if got != tt.want || merged != tt.merged {
+			t.Errorf("%s: expected %q (%v), got %q (%v)", tt.name, tt.want, tt.merged, got, merged)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package semantic
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/logging"
+)
+
+// tsPathAliases holds the module resolution settings of a tsconfig.json
+// (or jsconfig.json)
+type tsPathAliases struct {
+	BaseURL string        // Directory non-relative imports resolve against, relative to the repository root ("" if unset)
+	Paths   []tsPathAlias // Longest prefix first
+}
+
+// tsPathAlias is one entry of compilerOptions.paths, e.g. "@/*": ["src/*"]
+type tsPathAlias struct {
+	Pattern string
+	Targets []string // Relative to the repository root
+}
+
+// tsconfigFile is the subset of tsconfig.json used for import resolution
+type tsconfigFile struct {
+	Extends         json.RawMessage `json:"extends"` // A string, or a list since TypeScript 5.0
+	CompilerOptions struct {
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+// maxTSConfigDepth bounds extends chains, which may also be cyclic
+const maxTSConfigDepth = 8
+
+// tsAliasesFor returns the path aliases of the nearest tsconfig.json or
+// jsconfig.json at or above dir (relative to the repository root)
+func (s *importScope) tsAliasesFor(dir string) *tsPathAliases {
+	start := dir
+	var aliases *tsPathAliases
+	for {
+		if cached, ok := s.tsAliases[dir]; ok {
+			aliases = cached
+			break
+		}
+		var found bool
+		if aliases, found = loadTSPathAliases(s.root, dir); found {
+			s.tsAliases[dir] = aliases
+			break
+		}
+		if dir == "." || dir == "" {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	s.tsAliases[start] = aliases
+	return aliases
+}
+
+// loadTSPathAliases reads path aliases from tsconfig.json or jsconfig.json in
+// dir (relative to root), following extends. found reports whether either
+// file exists; aliases is nil if the config defines none.
+func loadTSPathAliases(root, dir string) (aliases *tsPathAliases, found bool) {
+	for _, name := range []string{"tsconfig.json", "jsconfig.json"} {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(filepath.Join(root, file)); err != nil {
+			continue
+		}
+
+		baseURL, paths := readTSConfig(root, file, 0)
+		if baseURL == "" && len(paths) == 0 {
+			return nil, true
+		}
+		aliases := &tsPathAliases{BaseURL: baseURL}
+		for pattern, targets := range paths {
+			aliases.Paths = append(aliases.Paths, tsPathAlias{Pattern: pattern, Targets: targets})
+		}
+		sort.Slice(aliases.Paths, func(i, j int) bool {
+			pi, pj := aliases.Paths[i].Pattern, aliases.Paths[j].Pattern
+			if len(pi) != len(pj) {
+				return len(pi) > len(pj)
+			}
+			return pi < pj
+		})
+		return aliases, true
+	}
+	return nil, false
+}
+
+// readTSConfig returns the baseUrl and paths of a config file after applying
+// the configs it extends, all relative to the repository root. Settings of
+// the file itself override inherited ones.
+func readTSConfig(root, file string, depth int) (baseURL string, paths map[string][]string) {
+	content, err := os.ReadFile(filepath.Join(root, file))
+	if err != nil {
+		logging.Debug("failed to read "+file, "error", err)
+		return "", nil
+	}
+	var config tsconfigFile
+	if err := json.Unmarshal(stripJSONComments(content), &config); err != nil {
+		logging.Debug("failed to parse "+file, "error", err)
+		return "", nil
+	}
+	dir := filepath.Dir(file)
+
+	var extends []string
+	if len(config.Extends) > 0 {
+		var single string
+		if err := json.Unmarshal(config.Extends, &single); err == nil {
+			extends = []string{single}
+		} else if err := json.Unmarshal(config.Extends, &extends); err != nil {
+			logging.Debug("ignoring invalid extends in "+file, "error", err)
+		}
+	}
+	for _, ext := range extends {
+		if depth >= maxTSConfigDepth {
+			logging.Warn("tsconfig extends chain too deep, ignoring the rest", "file", file)
+			break
+		}
+		parent := resolveTSExtends(root, dir, ext)
+		if parent == "" {
+			logging.Debug("skipping unresolved tsconfig extends", "file", file, "extends", ext)
+			continue
+		}
+		parentBase, parentPaths := readTSConfig(root, parent, depth+1)
+		if parentBase != "" {
+			baseURL = parentBase
+		}
+		if parentPaths != nil {
+			paths = parentPaths
+		}
+	}
+
+	options := config.CompilerOptions
+	if options.BaseURL != nil {
+		baseURL = filepath.Join(dir, *options.BaseURL)
+	}
+	if options.Paths != nil {
+		// Without baseUrl, paths resolve against the directory of the config
+		// that defines them
+		pathsBase := baseURL
+		if pathsBase == "" {
+			pathsBase = dir
+		}
+		paths = make(map[string][]string, len(options.Paths))
+		for pattern, targets := range options.Paths {
+			for _, target := range targets {
+				paths[pattern] = append(paths[pattern], filepath.ToSlash(filepath.Join(pathsBase, target)))
+			}
+		}
+	}
+	return baseURL, paths
+}
+
+// resolveTSExtends returns the config file (relative to the repository root)
+// an extends value in dir refers to: a relative path, or a package under
+// node_modules. It returns "" if the file is missing or outside the
+// repository.
+func resolveTSExtends(root, dir, ext string) string {
+	var candidates []string
+	if strings.HasPrefix(ext, ".") {
+		candidates = append(candidates, filepath.Join(dir, ext))
+	} else {
+		for d := dir; ; d = filepath.Dir(d) {
+			candidates = append(candidates, filepath.Join(d, "node_modules", ext))
+			if d == "." || d == "" {
+				break
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if candidate == ".." || strings.HasPrefix(candidate, ".."+string(filepath.Separator)) {
+			continue
+		}
+		for _, file := range []string{candidate, candidate + ".json", filepath.Join(candidate, "tsconfig.json")} {
+			if info, err := os.Stat(filepath.Join(root, file)); err == nil && !info.IsDir() {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+// matchWildcard matches value against a pattern with at most one "*" and
+// returns the text the wildcard stands for
+func matchWildcard(pattern, value string) (string, bool) {
+	star := strings.Index(pattern, "*")
+	if star < 0 {
+		return "", pattern == value
+	}
+	prefix, suffix := pattern[:star], pattern[star+1:]
+	if len(value) < len(prefix)+len(suffix) || !strings.HasPrefix(value, prefix) || !strings.HasSuffix(value, suffix) {
+		return "", false
+	}
+	return value[len(prefix) : len(value)-len(suffix)], true
+}
+
+// resolve returns the files (relative to the repository root, without
+// extension) a non-relative import may refer to
+func (a *tsPathAliases) resolve(importPath string) []string {
+	if a == nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, alias := range a.Paths {
+		wildcard, ok := matchWildcard(alias.Pattern, importPath)
+		if !ok {
+			continue
+		}
+		for _, target := range alias.Targets {
+			candidates = append(candidates, filepath.Clean(strings.Replace(target, "*", wildcard, 1)))
+		}
+	}
+	if a.BaseURL != "" {
+		candidates = append(candidates, filepath.Join(a.BaseURL, importPath))
+	}
+	return candidates
+}
+
+// aliasFor returns the aliased import for a file (relative to the
+// repository root, without extension), if an alias covers it
+func (a *tsPathAliases) aliasFor(file string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	file = filepath.ToSlash(file)
+	for _, alias := range a.Paths {
+		for _, target := range alias.Targets {
+			if wildcard, ok := matchWildcard(target, file); ok {
+				return strings.Replace(alias.Pattern, "*", wildcard, 1), true
+			}
+		}
+	}
+	return "", false
+}
+
+// stripJSONComments removes // and /* */ comments and trailing commas, which
+// tsconfig.json allows but encoding/json does not
+func stripJSONComments(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+	inString := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(content) {
+				i++
+				out = append(out, content[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			if i < len(content) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			i += 2
+			for i+1 < len(content) && !(content[i] == '*' && content[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// Drop a trailing comma before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
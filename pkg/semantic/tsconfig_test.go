@@ -0,0 +1,119 @@
+package semantic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadTSPathAliases(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"tsconfig.json": `{
+  // Comments and trailing commas are allowed
+  "compilerOptions": {
+    "baseUrl": ".",
+    "paths": {
+      "@/*": ["src/*"], /* app code */
+      "@lib/*": ["packages/lib/src/*",],
+    },
+  },
+}`,
+	})
+
+	aliases, found := loadTSPathAliases(root, ".")
+	if !found || aliases == nil {
+		t.Fatal("expected aliases to be loaded")
+	}
+
+	if got := aliases.resolve("@/utils/format"); len(got) == 0 || got[0] != "src/utils/format" {
+		t.Errorf("resolve(@/utils/format) = %v", got)
+	}
+	if got := aliases.resolve("@lib/strings"); len(got) == 0 || got[0] != "packages/lib/src/strings" {
+		t.Errorf("resolve(@lib/strings) = %v", got)
+	}
+	if alias, ok := aliases.aliasFor("src/lib/money"); !ok || alias != "@/lib/money" {
+		t.Errorf("aliasFor(src/lib/money) = %q, %v", alias, ok)
+	}
+
+	if aliases, found := loadTSPathAliases(t.TempDir(), "."); found || aliases != nil {
+		t.Error("expected nil without tsconfig.json")
+	}
+}
+
+func TestLoadTSPathAliases_Extends(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"tsconfig.base.json":                       `{"compilerOptions": {"baseUrl": ".", "paths": {"@shared/*": ["shared/*"]}}}`,
+		"node_modules/@company/tsconfig/base.json": `{"compilerOptions": {"paths": {"@ui/*": ["ui/*"]}}}`,
+		"apps/web/tsconfig.json":                   `{"extends": "../../tsconfig.base"}`,
+		"apps/admin/tsconfig.json":                 `{"extends": ["../../tsconfig.base.json"], "compilerOptions": {"paths": {"@/*": ["./src/*"]}}}`,
+		"apps/docs/tsconfig.json":                  `{"extends": "@company/tsconfig/base.json"}`,
+		"apps/loop/tsconfig.json":                  `{"extends": "./tsconfig.json"}`,
+	})
+
+	// Inherited paths keep resolving against the base config's baseUrl
+	web, _ := loadTSPathAliases(root, "apps/web")
+	if got := web.resolve("@shared/date"); len(got) == 0 || got[0] != "shared/date" {
+		t.Errorf("resolve(@shared/date) = %v", got)
+	}
+
+	// Own paths replace inherited ones and resolve against the inherited baseUrl
+	admin, _ := loadTSPathAliases(root, "apps/admin")
+	if got := admin.resolve("@/main"); len(got) == 0 || got[0] != "src/main" {
+		t.Errorf("resolve(@/main) = %v", got)
+	}
+	if _, ok := admin.aliasFor("shared/date"); ok {
+		t.Error("expected own paths to replace inherited ones")
+	}
+
+	// Packages resolve through node_modules; paths without baseUrl resolve
+	// against the package's directory
+	docs, _ := loadTSPathAliases(root, "apps/docs")
+	if got := docs.resolve("@ui/button"); len(got) == 0 || got[0] != "node_modules/@company/tsconfig/ui/button" {
+		t.Errorf("resolve(@ui/button) = %v", got)
+	}
+
+	if loop, found := loadTSPathAliases(root, "apps/loop"); !found || loop != nil {
+		t.Errorf("expected a cyclic extends to end without aliases, got %+v", loop)
+	}
+}
+
+func TestImportScope_TSAliasesFor(t *testing.T) {
+	root := t.TempDir()
+	writeRepoFiles(t, root, map[string]string{
+		"tsconfig.json":              `{"compilerOptions": {"baseUrl": ".", "paths": {"@/*": ["src/*"]}}}`,
+		"packages/ui/tsconfig.json":  `{"compilerOptions": {"paths": {"~/*": ["./lib/*"]}}}`,
+		"packages/cli/jsconfig.json": `{}`,
+	})
+	scope := newImportScope(root)
+
+	if alias, ok := scope.tsAliasesFor("src/pages").aliasFor("src/lib/money"); !ok || alias != "@/lib/money" {
+		t.Errorf("aliasFor(src/lib/money) = %q, %v", alias, ok)
+	}
+	if alias, ok := scope.tsAliasesFor("packages/ui/lib/forms").aliasFor("packages/ui/lib/button"); !ok || alias != "~/button" {
+		t.Errorf("aliasFor(packages/ui/lib/button) = %q, %v", alias, ok)
+	}
+	if aliases := scope.tsAliasesFor("packages/cli/bin"); aliases != nil {
+		t.Errorf("expected the nearest config to win, got %+v", aliases)
+	}
+}
+
+func TestFindJSImportUpdates_PathAlias(t *testing.T) {
+	aliases := &tsPathAliases{Paths: []tsPathAlias{{Pattern: "@/*", Targets: []string{"src/*"}}}}
+	moveMap := map[struct{ sourceModule, defName string }]string{
+		{"src.utils.index", "formatPrice"}: "src.lib.money",
+	}
+
+	content := []byte("import { formatPrice, slugify } from '@/utils';\n")
+	updates := findJSImportUpdates("src/pages/cart.tsx", content, moveMap, aliases)
+
+	if len(updates) != 1 {
+		t.Fatalf("expected 1 update, got %d", len(updates))
+	}
+	if !strings.Contains(updates[0].NewImport, "import { slugify } from '@/utils';") {
+		t.Errorf("expected remaining names to keep the alias, got %q", updates[0].NewImport)
+	}
+	if !strings.Contains(updates[0].NewImport, "import { formatPrice } from '@/lib/money'") {
+		t.Errorf("expected moved name to use the alias, got %q", updates[0].NewImport)
+	}
+}
@@ -0,0 +1,181 @@
+package semantic
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlValue is a decoded YAML node: a scalar, a mapping or a sequence.
+// Mappings keep their keys in document order.
+type yamlValue struct {
+	Kind   yamlKind
+	Scalar string
+	Pairs  []yamlPair
+	Items  []*yamlValue
+	Line   int // 1-based line of the node
+}
+
+// yamlPair is one key of a mapping
+type yamlPair struct {
+	Key   string
+	Value *yamlValue
+}
+
+// yamlKind is the kind of a yamlValue
+type yamlKind int
+
+const (
+	yamlNull yamlKind = iota
+	yamlScalar
+	yamlMapping
+	yamlSequence
+)
+
+// decodeYAML parses the first document of content. Aliases are resolved and
+// merge keys (<<) are expanded; tags other than the standard ones are
+// ignored.
+func decodeYAML(content []byte) (*yamlValue, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return &yamlValue{Kind: yamlNull}, nil
+	}
+	return (&yamlDecoder{}).decode(doc.Content[0], 0)
+}
+
+// Limits on alias expansion. Each alias is expanded in place, so a few
+// nested anchors could otherwise expand exponentially ("billion laughs").
+const (
+	maxYAMLAliasDepth = 32
+	maxYAMLNodes      = 100000 // Nodes in the expanded document
+)
+
+// yamlDecoder converts yaml.v3 nodes into yamlValues, counting the nodes
+// produced against maxYAMLNodes
+type yamlDecoder struct {
+	nodes int
+}
+
+// decode converts a yaml.v3 node into a yamlValue. aliases is the number of
+// aliases followed to reach node.
+func (d *yamlDecoder) decode(node *yaml.Node, aliases int) (*yamlValue, error) {
+	if node.Kind != yaml.AliasNode {
+		d.nodes++
+		if d.nodes > maxYAMLNodes {
+			return nil, fmt.Errorf("line %d: document expands to more than %d nodes", node.Line, maxYAMLNodes)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		if aliases >= maxYAMLAliasDepth {
+			return nil, fmt.Errorf("line %d: aliases nested too deeply", node.Line)
+		}
+		return d.decode(node.Alias, aliases+1)
+
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!null" {
+			return &yamlValue{Kind: yamlNull, Line: node.Line}, nil
+		}
+		return &yamlValue{Kind: yamlScalar, Scalar: node.Value, Line: node.Line}, nil
+
+	case yaml.SequenceNode:
+		value := &yamlValue{Kind: yamlSequence, Line: node.Line}
+		for _, child := range node.Content {
+			item, err := d.decode(child, aliases)
+			if err != nil {
+				return nil, err
+			}
+			value.Items = append(value.Items, item)
+		}
+		return value, nil
+
+	case yaml.MappingNode:
+		value := &yamlValue{Kind: yamlMapping, Line: node.Line}
+		var merges []*yamlValue
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			item, err := d.decode(valueNode, aliases)
+			if err != nil {
+				return nil, err
+			}
+			if keyNode.ShortTag() == "!!merge" {
+				item.Line = keyNode.Line
+				merges = append(merges, item)
+				continue
+			}
+			key, err := d.decode(keyNode, aliases)
+			if err != nil {
+				return nil, err
+			}
+			if key.Kind != yamlScalar {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", keyNode.Line)
+			}
+			if value.has(key.Scalar) {
+				return nil, fmt.Errorf("line %d: %s is set twice", keyNode.Line, key.Scalar)
+			}
+			value.Pairs = append(value.Pairs, yamlPair{Key: key.Scalar, Value: item})
+		}
+		// Keys set in the mapping itself win over merged ones
+		for _, from := range merges {
+			if err := value.merge(from); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
+
+// merge adds the keys of a merge key's mapping (or list of mappings) that
+// the mapping does not set itself
+func (v *yamlValue) merge(from *yamlValue) error {
+	sources := []*yamlValue{from}
+	if from.Kind == yamlSequence {
+		sources = from.Items
+	}
+	for _, source := range sources {
+		if source.Kind != yamlMapping {
+			return fmt.Errorf("line %d: << must merge a mapping", from.Line)
+		}
+		for _, pair := range source.Pairs {
+			if !v.has(pair.Key) {
+				v.Pairs = append(v.Pairs, pair)
+			}
+		}
+	}
+	return nil
+}
+
+// has reports whether a mapping has a key
+func (v *yamlValue) has(key string) bool {
+	for _, pair := range v.Pairs {
+		if pair.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// scalars returns the scalar values of a scalar or a sequence of scalars
+func (v *yamlValue) scalars() ([]string, error) {
+	switch v.Kind {
+	case yamlNull:
+		return []string{}, nil
+	case yamlScalar:
+		return []string{v.Scalar}, nil
+	case yamlSequence:
+		values := make([]string, 0, len(v.Items))
+		for _, item := range v.Items {
+			if item.Kind != yamlScalar {
+				return nil, fmt.Errorf("line %d: expected a list of values", item.Line)
+			}
+			values = append(values, item.Scalar)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("line %d: expected a value or a list of values", v.Line)
+}
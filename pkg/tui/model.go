@@ -18,7 +18,8 @@ const (
 	ResolutionRemote
 	ResolutionBoth
 	ResolutionBase
-	ResolutionSkip // Leave for manual editing
+	ResolutionSkip   // Leave for manual editing
+	ResolutionCustom // Replace with CustomContent, edited in $EDITOR
 )
 
 func (r Resolution) String() string {
@@ -33,6 +34,8 @@ func (r Resolution) String() string {
 		return "Keep Base"
 	case ResolutionSkip:
 		return "Edit Manually"
+	case ResolutionCustom:
+		return "Custom Edit"
 	default:
 		return "Unresolved"
 	}
@@ -48,6 +51,7 @@ type ConflictItem struct {
 	LocalContent string
 	RemoteContent string
 	Resolution   Resolution
+	CustomContent string // Replacement of ResolutionCustom
 	StartByte    uint32
 	EndByte      uint32
 }
@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/output"
+)
+
+// Interactive resolvers (--interactive)
+const (
+	ModeTUI    = "tui"    // Full-screen TUI
+	ModePrompt = "prompt" // Line-based prompt
+)
+
+// EditFunc returns the replacement for a conflict edited by the user
+type EditFunc func(c ConflictItem) (string, error)
+
+// RunPrompt resolves conflicts with a line-based prompt instead of the
+// full-screen TUI, for terminals without an alternate screen. Each conflict
+// is printed with its three versions and a diff, then one letter is read:
+// l (local), r (remote), b (both), B (base), s (skip), e (edit) or q (quit).
+// Closing the input aborts like quitting.
+func RunPrompt(conflicts []ConflictItem, in io.Reader, out io.Writer, edit EditFunc) (*ResolverResult, error) {
+	reader := bufio.NewReader(in)
+	for i := range conflicts {
+		c := &conflicts[i]
+		printPromptConflict(out, *c, i+1, len(conflicts))
+		for c.Resolution == ResolutionNone {
+			fmt.Fprint(out, promptChoices(*c))
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				if err == io.EOF {
+					fmt.Fprintln(out)
+					return &ResolverResult{Conflicts: conflicts, Aborted: true}, nil
+				}
+				return nil, err
+			}
+
+			switch choice := strings.TrimSpace(line); choice {
+			case "l":
+				c.Resolution = ResolutionLocal
+			case "r":
+				c.Resolution = ResolutionRemote
+			case "b":
+				c.Resolution = ResolutionBoth
+			case "B":
+				if c.BaseContent == "" {
+					fmt.Fprintln(out, "No base version to keep")
+					continue
+				}
+				c.Resolution = ResolutionBase
+			case "s":
+				c.Resolution = ResolutionSkip
+			case "e":
+				content, err := edit(*c)
+				if err != nil {
+					fmt.Fprintf(out, "Edit failed: %v\n", err)
+					continue
+				}
+				c.CustomContent = content
+				c.Resolution = ResolutionCustom
+			case "q":
+				return &ResolverResult{Conflicts: conflicts, Aborted: true}, nil
+			default:
+				fmt.Fprintf(out, "Unknown choice %q\n", choice)
+			}
+		}
+		fmt.Fprintf(out, "%s: %s\n\n", c.Name, c.Resolution)
+	}
+	return &ResolverResult{Conflicts: conflicts}, nil
+}
+
+// printPromptConflict prints a conflict's versions and the diff from local
+// to remote
+func printPromptConflict(out io.Writer, c ConflictItem, n, total int) {
+	fmt.Fprintf(out, "[%d/%d] %s: %s", n, total, c.File, c.Name)
+	if c.Kind != "" {
+		fmt.Fprintf(out, " (%s)", c.Kind)
+	}
+	fmt.Fprintln(out)
+	if c.ConflictType != "" {
+		fmt.Fprintln(out, c.ConflictType)
+	}
+
+	for _, version := range []struct{ label, content string }{
+		{"base", c.BaseContent},
+		{"local", c.LocalContent},
+		{"remote", c.RemoteContent},
+	} {
+		fmt.Fprintf(out, "\n=== %s ===\n", version.label)
+		if version.content == "" {
+			fmt.Fprintln(out, "(none)")
+			continue
+		}
+		fmt.Fprintln(out, strings.TrimRight(version.content, "\n"))
+	}
+
+	fmt.Fprint(out, "\n--- local\n+++ remote\n")
+	fmt.Fprint(out, output.FormatDiff(output.Diff(c.LocalContent, c.RemoteContent)))
+	fmt.Fprintln(out)
+}
+
+// promptChoices lists the choices available for a conflict
+func promptChoices(c ConflictItem) string {
+	if c.BaseContent == "" {
+		return "[l]ocal [r]emote [b]oth [s]kip [e]dit [q]uit? "
+	}
+	return "[l]ocal [r]emote [b]oth [B]ase [s]kip [e]dit [q]uit? "
+}
+
+// EditInEditor opens the conflict in $EDITOR (vi if unset), starting from
+// the local and remote versions between conflict markers, and returns the
+// saved text. Markers left in the text are an error.
+func EditInEditor(c ConflictItem) (string, error) {
+	f, err := os.CreateTemp("", "g2-*"+filepath.Ext(c.File))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(editTemplate(c))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// Run through the shell so EDITOR may carry arguments, e.g. "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", editor, err)
+	}
+
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	if hasConflictMarkers(string(content)) {
+		return "", errors.New("conflict markers remain")
+	}
+	return string(content), nil
+}
+
+// editTemplate lays out a conflict for editing like a diff3 conflict
+func editTemplate(c ConflictItem) string {
+	var b strings.Builder
+	b.WriteString("<<<<<<< local\n")
+	writeVersion(&b, c.LocalContent)
+	if c.BaseContent != "" {
+		b.WriteString("||||||| base\n")
+		writeVersion(&b, c.BaseContent)
+	}
+	b.WriteString("=======\n")
+	writeVersion(&b, c.RemoteContent)
+	b.WriteString(">>>>>>> remote\n")
+	return b.String()
+}
+
+// writeVersion writes a version's lines, ending with a newline
+func writeVersion(b *strings.Builder, content string) {
+	if content == "" {
+		return
+	}
+	b.WriteString(strings.TrimRight(content, "\n"))
+	b.WriteByte('\n')
+}
+
+// hasConflictMarkers reports whether any line starts a conflict marker
+func hasConflictMarkers(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if line == "=======" {
+			return true
+		}
+		for _, marker := range []string{"<<<<<<< ", "||||||| ", ">>>>>>> "} {
+			if strings.HasPrefix(line, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
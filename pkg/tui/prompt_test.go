@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// promptConflicts returns a conflict with a base version and one without
+func promptConflicts() []ConflictItem {
+	return []ConflictItem{
+		{
+			File:          "calc.py",
+			Name:          "calc",
+			Kind:          "function",
+			ConflictType:  "Function 'calc' Modified",
+			BaseContent:   "def calc(x):\n    return x\n",
+			LocalContent:  "def calc(x):\n    return x + 1\n",
+			RemoteContent: "def calc(x):\n    return x + 2\n",
+		},
+		{
+			File:          "calc.py",
+			Name:          "fmt",
+			Kind:          "function",
+			LocalContent:  "def fmt(x):\n    return repr(x)\n",
+			RemoteContent: "def fmt(x):\n    return str(x)\n",
+		},
+	}
+}
+
+// TestRunPrompt tests resolving conflicts one letter at a time
+func TestRunPrompt(t *testing.T) {
+	edits := 0
+	edit := func(c ConflictItem) (string, error) {
+		if edits++; edits == 1 {
+			return "", errors.New("conflict markers remain")
+		}
+		return "def fmt(x):\n    return f\"{x}\"\n", nil
+	}
+
+	var out bytes.Buffer
+	result, err := RunPrompt(promptConflicts(), strings.NewReader("x\nB\nB\ne\ne\n"), &out, edit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Aborted {
+		t.Fatal("expected the prompt to finish")
+	}
+	if c := result.Conflicts[0]; c.Resolution != ResolutionBase {
+		t.Errorf("calc: expected the base version, got %s", c.Resolution)
+	}
+	if c := result.Conflicts[1]; c.Resolution != ResolutionCustom || c.CustomContent != "def fmt(x):\n    return f\"{x}\"\n" {
+		t.Errorf("fmt: expected the edited version, got %s %q", c.Resolution, c.CustomContent)
+	}
+	for _, s := range []string{
+		"[1/2] calc.py: calc (function)\nFunction 'calc' Modified\n",
+		"=== base ===\n(none)",
+		"-    return repr(x)\n+    return str(x)\n",
+		`Unknown choice "x"`,
+		"No base version to keep",
+		"Edit failed: conflict markers remain",
+		"[l]ocal [r]emote [b]oth [B]ase [s]kip [e]dit [q]uit? ",
+		"[l]ocal [r]emote [b]oth [s]kip [e]dit [q]uit? ",
+	} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected %q in the prompt output:\n%s", s, out.String())
+		}
+	}
+
+	for _, input := range []string{"l\nq\n", "l\n", ""} {
+		result, err := RunPrompt(promptConflicts(), strings.NewReader(input), &bytes.Buffer{}, edit)
+		if err != nil || !result.Aborted {
+			t.Errorf("%q: expected an abort, got %+v, %v", input, result, err)
+		}
+	}
+}
+
+// TestEditInEditor tests editing a conflict between conflict markers
+func TestEditInEditor(t *testing.T) {
+	c := promptConflicts()[0]
+	want := "<<<<<<< local\ndef calc(x):\n    return x + 1\n||||||| base\ndef calc(x):\n    return x\n=======\ndef calc(x):\n    return x + 2\n>>>>>>> remote\n"
+	if got := editTemplate(c); got != want {
+		t.Errorf("template = %q, want %q", got, want)
+	}
+
+	// An editor that saves the text unchanged leaves the markers
+	t.Setenv("EDITOR", "true")
+	if _, err := EditInEditor(c); err == nil || !strings.Contains(err.Error(), "conflict markers remain") {
+		t.Errorf("expected an error for remaining markers, got %v", err)
+	}
+
+	replacement := filepath.Join(t.TempDir(), "calc.py")
+	if err := os.WriteFile(replacement, []byte("def calc(x):\n    return x + 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", "cp "+replacement)
+	if got, err := EditInEditor(c); err != nil || got != "def calc(x):\n    return x + 3\n" {
+		t.Errorf("unexpected edit: %q, %v", got, err)
+	}
+
+	t.Setenv("EDITOR", "false")
+	if _, err := EditInEditor(c); err == nil {
+		t.Error("expected an error when the editor fails")
+	}
+}
@@ -131,6 +131,8 @@ func ApplyResolutions(conflicts []ConflictItem, fileContent []byte) ([]byte, err
 			}
 		case ResolutionBase:
 			replacement = c.BaseContent
+		case ResolutionCustom:
+			replacement = c.CustomContent
 		}
 
 		// Replace the byte range
@@ -37,6 +37,7 @@ type Conflict struct {
 	File         string
 	ConflictType string
 	Status       string
+	Rule         string // Policy rule that decided the conflict, if any
 }
 
 // Styles
@@ -134,8 +135,8 @@ func ConflictTable(conflicts []Conflict) {
 		if len(c.ConflictType) > typeWidth {
 			typeWidth = len(c.ConflictType)
 		}
-		if len(c.Status) > statusWidth {
-			statusWidth = len(c.Status)
+		if len(statusText(c)) > statusWidth {
+			statusWidth = len(statusText(c))
 		}
 	}
 
@@ -199,7 +200,7 @@ func ConflictTable(conflicts []Conflict) {
 		statusStyle := tableCellStyle
 		if c.Status == "Needs Resolution" {
 			statusStyle = conflictCellStyle
-		} else if c.Status == "Can Auto-merge" {
+		} else if c.Status == "Can Auto-merge" || c.Status == "Resolved by Policy" {
 			statusStyle = autoMergeCellStyle
 		}
 
@@ -209,7 +210,7 @@ func ConflictTable(conflicts []Conflict) {
 			borderStyle.Render(vertical),
 			tableCellStyle.Render(pad(c.ConflictType, typeWidth)),
 			borderStyle.Render(vertical),
-			statusStyle.Render(pad(c.Status, statusWidth)),
+			statusStyle.Render(pad(statusText(c), statusWidth)),
 			borderStyle.Render(vertical),
 		)
 	}
@@ -218,6 +219,15 @@ func ConflictTable(conflicts []Conflict) {
 	fmt.Println(hLine(bottomLeft, bottomT, bottomRight))
 }
 
+// statusText returns the status column of a conflict, with the policy rule
+// that decided it
+func statusText(c Conflict) string {
+	if c.Rule != "" {
+		return fmt.Sprintf("%s (%s)", c.Status, c.Rule)
+	}
+	return c.Status
+}
+
 // Summary prints a conflict summary
 func Summary(needsResolution, total int) {
 	fmt.Println()
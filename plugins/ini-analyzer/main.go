@@ -0,0 +1,81 @@
+// Command ini-analyzer is an example external analyzer for G2. It reports
+// each section of an INI file as a definition, so G2 can merge changes to
+// different sections and ignore comment-only edits.
+//
+// Enable it for *.ini files in .g2.yaml:
+//
+//	paths:
+//	  - match: "*.ini"
+//	    analyzer: ini-analyzer
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/simonkoeck/g2/pkg/analyzer"
+)
+
+func main() {
+	analyzer.Run(analyze)
+}
+
+// analyze returns one "section" definition per [section], from its header to
+// the next header. Keys before the first header form the "(global)" section.
+func analyze(content []byte) ([]analyzer.Definition, error) {
+	var defs []analyzer.Definition
+	seen := make(map[string]bool)
+
+	start, name, signature := 0, "(global)", ""
+	flush := func(end int) {
+		body := content[start:end]
+		if len(bytes.TrimSpace(body)) == 0 && name == "(global)" {
+			return
+		}
+		defs = append(defs, analyzer.Definition{
+			Name:       name,
+			Kind:       "section",
+			Signature:  signature,
+			StartByte:  start,
+			EndByte:    start + len(bytes.TrimRight(body, "\n")),
+			Normalized: normalize(body),
+		})
+	}
+
+	offset := 0
+	for lineNo, line := range strings.SplitAfter(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNo+1)
+			}
+			flush(offset)
+			start, name, signature = offset, strings.TrimSpace(trimmed[1:len(trimmed)-1]), trimmed
+			if seen[name] {
+				return nil, fmt.Errorf("line %d: duplicate section %q", lineNo+1, name)
+			}
+			seen[name] = true
+		}
+		offset += len(line)
+	}
+	flush(len(content))
+	return defs, nil
+}
+
+// normalize drops comments and blank lines and trims whitespace around keys
+// and values, so formatting-only changes compare equal
+func normalize(body []byte) string {
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			line = strings.TrimSpace(key) + "=" + strings.TrimSpace(value)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}